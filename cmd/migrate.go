@@ -0,0 +1,17 @@
+package cmd
+
+import (
+	"github.com/resonatecoop/id/oauth"
+)
+
+// Migrate creates the tables owned by this server
+func Migrate(configBackend string) error {
+	_, db, err := initConfigDB(true, false, configBackend)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	// Shared tables are migrated by user-api
+	return oauth.MigrateAll(db)
+}
@@ -0,0 +1,24 @@
+package cmd
+
+import (
+	"github.com/resonatecoop/id/log"
+	"github.com/resonatecoop/id/oauth"
+)
+
+// RotateKeys replaces the active JWT signing key
+func RotateKeys(configBackend string) error {
+	cnf, db, err := initConfigDB(true, false, configBackend)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	signingKey, err := oauth.NewService(cnf, db).RotateSigningKey()
+	if err != nil {
+		return err
+	}
+
+	log.INFO.Printf("Signing with new key %s", signingKey.ID)
+
+	return nil
+}
@@ -48,7 +48,9 @@ func RunServer(configBackend string) error {
 	// Add routes
 	services.HealthService.RegisterRoutes(router, "/v1")
 	services.OauthService.RegisterRoutes(router, "/v1/oauth")
+	services.OauthService.RegisterAdminRoutes(router, "/v1/admin")
 	services.WebHookService.RegisterRoutes(router, "/webhook")
+	services.OauthService.RegisterWellKnownRoutes(router, "/.well-known")
 
 	webRoutes := mux.NewRouter()
 	services.WebService.RegisterRoutes(webRoutes, "/web")
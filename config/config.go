@@ -29,6 +29,73 @@ type OauthConfig struct {
 	AccessTokenLifetime  int
 	RefreshTokenLifetime int
 	AuthCodeLifetime     int
+	// MaxAccessTokenLifetime, MaxRefreshTokenLifetime and MaxAuthCodeLifetime
+	// are the longest lifetimes (in seconds) a client may register, the
+	// lifetimes above are the maximums when they are not set
+	MaxAccessTokenLifetime  int
+	MaxRefreshTokenLifetime int
+	MaxAuthCodeLifetime     int
+	// RefreshTokenRotation issues a new refresh token on every refresh and
+	// revokes the token family when a rotated token is reused
+	RefreshTokenRotation bool
+	// AccessTokenFormat is opaque (default) or jwt
+	AccessTokenFormat string
+	// AccessTokenAudience is the aud claim of JWT access tokens, defaults to the issuer
+	AccessTokenAudience string
+	// DeviceCodeLifetime is how long (in seconds) the user has to approve a device
+	DeviceCodeLifetime int
+	// DeviceCodeInterval is how often (in seconds) devices may poll for tokens
+	DeviceCodeInterval int
+	// ClientSecretGracePeriod is how long (in seconds) the previous client
+	// secret stays valid after a rotation
+	ClientSecretGracePeriod int
+	// RequestURILifetime is how long (in seconds) the request_uri of a
+	// pushed authorization request can be used
+	RequestURILifetime int
+	// ErrorURI is a page documenting the OAuth error codes, error
+	// responses link to it as error_uri
+	ErrorURI string
+}
+
+// OIDCConfig stores OpenID Connect configuration options
+type OIDCConfig struct {
+	IDTokenLifetime int
+}
+
+// SigningKeysConfig stores options for the keys used to sign JWTs
+type SigningKeysConfig struct {
+	// RS256 or ES256
+	Algorithm string
+	// RotationPeriod is how long (in seconds) a key signs new tokens
+	RotationPeriod int
+	// OverlapPeriod is how long (in seconds) a rotated key is still published
+	// for verification, it must be longer than the lifetime of any signed token
+	OverlapPeriod int
+}
+
+// TOTPConfig stores two-factor authentication options
+type TOTPConfig struct {
+	// Issuer is shown in authenticator apps, defaults to the hostname
+	Issuer string
+	// RequiredRoles lists the role IDs which must use two-factor authentication
+	RequiredRoles []int32
+}
+
+// WebAuthnConfig stores passkey options
+type WebAuthnConfig struct {
+	// RPID is the domain passkeys are bound to, defaults to the hostname
+	RPID string
+	// RPName is shown by authenticators, defaults to the hostname
+	RPName string
+	// Origins lists the origins passkeys can be used on, defaults to https://<RPID>
+	Origins []string
+}
+
+// ClientRegistrationConfig stores dynamic client registration options
+type ClientRegistrationConfig struct {
+	// InitialAccessTokens let partner developers register clients, admins
+	// can always register clients with their access token
+	InitialAccessTokens []string
 }
 
 // SessionConfig stores session configuration for the web app
@@ -55,6 +122,11 @@ type Config struct {
 	Mailgun             MailgunConfig
 	Database            DatabaseConfig
 	Oauth               OauthConfig
+	OIDC                OIDCConfig
+	SigningKeys         SigningKeysConfig
+	TOTP                TOTPConfig
+	WebAuthn            WebAuthnConfig
+	ClientRegistration  ClientRegistrationConfig
 	Session             SessionConfig
 	IsDevelopment       bool
 	Clients             []ClientConfig
@@ -66,4 +138,7 @@ type Config struct {
 	UserAPIPort         string
 	StaticURL           string
 	AppURL              string
+	// TrustedProxies lists the IP addresses or CIDR ranges of the proxies
+	// whose X-Forwarded-For header is trusted
+	TrustedProxies []string
 }
@@ -33,9 +33,37 @@ var Cnf = &Config{
 		MaxOpenConns: 5,
 	},
 	Oauth: OauthConfig{
-		AccessTokenLifetime:  3600,    // 1 hour
-		RefreshTokenLifetime: 1209600, // 14 days
-		AuthCodeLifetime:     3600,    // 1 hour
+		AccessTokenLifetime:     3600,    // 1 hour
+		RefreshTokenLifetime:    1209600, // 14 days
+		AuthCodeLifetime:        3600,    // 1 hour
+		MaxAccessTokenLifetime:  86400,   // 1 day
+		MaxRefreshTokenLifetime: 7776000, // 90 days
+		MaxAuthCodeLifetime:     3600,    // 1 hour
+		AccessTokenFormat:       "opaque",
+		DeviceCodeLifetime:      600, // 10 minutes
+		DeviceCodeInterval:      5,
+		ClientSecretGracePeriod: 86400, // 1 day
+		RequestURILifetime:      60,    // 1 minute
+	},
+	OIDC: OIDCConfig{
+		IDTokenLifetime: 3600, // 1 hour
+	},
+	SigningKeys: SigningKeysConfig{
+		Algorithm:      "RS256",
+		RotationPeriod: 7776000, // 90 days
+		OverlapPeriod:  1209600, // 14 days
+	},
+	TOTP: TOTPConfig{
+		Issuer:        "Resonate",
+		RequiredRoles: []int32{}, // e.g. 1 superadmin, 2 admin, 3 tenantadmin, 4 label
+	},
+	WebAuthn: WebAuthnConfig{
+		RPID:    "id.resonate.coop",
+		RPName:  "Resonate",
+		Origins: []string{"https://id.resonate.coop"},
+	},
+	ClientRegistration: ClientRegistrationConfig{
+		InitialAccessTokens: []string{},
 	},
 	Session: SessionConfig{
 		Secret:   "test_secret",
@@ -59,6 +87,7 @@ var Cnf = &Config{
 	UserAPIPort:         ":11000",
 	StaticURL:           "https://dash.resonate.coop",
 	AppURL:              "https://stream.resonate.coop",
+	TrustedProxies:      []string{},
 }
 
 // NewConfig loads configuration from etcd and returns *Config struct
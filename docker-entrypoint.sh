@@ -2,4 +2,5 @@
 
 set -e
 
+go run go-oauth2-server.go migrate
 go run go-oauth2-server.go runserver
@@ -33,6 +33,20 @@ func init() {
 func main() {
 	// Set the CLI app commands
 	cliApp.Commands = []cli.Command{
+		{
+			Name:  "migrate",
+			Usage: "run migrations",
+			Action: func(c *cli.Context) error {
+				return cmd.Migrate(configBackend)
+			},
+		},
+		{
+			Name:  "rotatekeys",
+			Usage: "rotate the JWT signing key",
+			Action: func(c *cli.Context) error {
+				return cmd.RotateKeys(configBackend)
+			},
+		},
 		{
 			Name:  "runserver",
 			Usage: "run web server",
@@ -9,6 +9,7 @@ require (
 	github.com/didip/tollbooth_negroni v0.0.0-20170928042109-a4e3efc33255
 	github.com/dlclark/regexp2 v1.4.0 // indirect
 	github.com/form3tech-oss/jwt-go v3.2.3+incompatible
+	github.com/fxamacker/cbor/v2 v2.4.0
 	github.com/go-openapi/runtime v0.19.29
 	github.com/go-openapi/strfmt v0.20.1
 	github.com/google/uuid v1.3.0
@@ -26,6 +27,7 @@ require (
 	github.com/pariz/gountries v0.0.0-20200430155801-1c6a393df9c7
 	github.com/patrickmn/go-cache v2.1.0+incompatible // indirect
 	github.com/phyber/negroni-gzip v1.0.0
+	github.com/pquerna/otp v1.4.0
 	github.com/resonatecoop/user-api v1.0.0-10
 	github.com/resonatecoop/user-api-client v0.0.0-20220414135746-4287bec2bff3
 	github.com/russross/blackfriday/v2 v2.1.0 // indirect
@@ -8,8 +8,21 @@ import (
 	"github.com/resonatecoop/user-api/model"
 )
 
-// GrantAccessToken deletes old tokens and grants a new access token
-func (s *Service) GrantAccessToken(client *model.Client, user *model.User, expiresIn int, scope string) (*model.AccessToken, error) {
+// GrantAccessToken deletes old tokens and grants a new access token, linked
+// to the device session unless it is nil. The token does not outlive the
+// client's access token lifetime.
+func (s *Service) GrantAccessToken(client *model.Client, user *model.User, expiresIn int, scope string, deviceSession *DeviceSession) (*model.AccessToken, error) {
+	return s.grantAccessToken(client, user, expiresIn, scope, deviceSession, nil)
+}
+
+// grantAccessToken grants a new access token, the token exchange is saved
+// with it unless it is nil
+func (s *Service) grantAccessToken(client *model.Client, user *model.User, expiresIn int, scope string, deviceSession *DeviceSession, tokenExchange *TokenExchange) (*model.AccessToken, error) {
+	lifetime, err := s.AccessTokenLifetime(client)
+	if err != nil {
+		return nil, err
+	}
+
 	// Begin a transaction
 	tx, err := s.db.Begin()
 	ctx := context.Background()
@@ -45,6 +58,7 @@ func (s *Service) GrantAccessToken(client *model.Client, user *model.User, expir
 	}
 
 	// Create a new access token
+	expiresIn = limitLifetime(expiresIn, lifetime)
 	accessToken = model.NewOauthAccessToken(client, user, expiresIn, scope)
 
 	_, err = tx.NewInsert().
@@ -55,6 +69,23 @@ func (s *Service) GrantAccessToken(client *model.Client, user *model.User, expir
 		tx.Rollback() // rollback the transaction
 		return nil, err
 	}
+
+	if deviceSession != nil {
+		if err := addDeviceSessionToken(tx, deviceSession, accessToken.Token); err != nil {
+			tx.Rollback() // rollback the transaction
+			return nil, err
+		}
+	}
+
+	if tokenExchange != nil {
+		tokenExchange.Token = accessToken.Token
+		tokenExchange.CreatedAt = time.Now().UTC()
+		if _, err := tx.NewInsert().Model(tokenExchange).Exec(ctx); err != nil {
+			tx.Rollback() // rollback the transaction
+			return nil, err
+		}
+	}
+
 	accessToken.ClientID = client.ID
 
 	if user == nil {
@@ -63,6 +94,15 @@ func (s *Service) GrantAccessToken(client *model.Client, user *model.User, expir
 		accessToken.UserID = user.ID
 	}
 
+	// Hand out a signed JWT, the stored token becomes its jti
+	if s.isJWTAccessTokenFormat() {
+		accessToken.Token, err = s.newJWTAccessToken(client, user, accessToken, tokenExchange)
+		if err != nil {
+			tx.Rollback() // rollback the transaction
+			return nil, err
+		}
+	}
+
 	// Commit the transaction
 	err = tx.Commit()
 	if err != nil {
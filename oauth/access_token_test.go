@@ -27,6 +27,7 @@ func (suite *OauthTestSuite) TestGrantAccessToken() {
 		nil,                    // user
 		3600,                   // expires in
 		"scope doesn't matter", // scope
+		nil,                    // device session
 	)
 
 	// Error should be Nil
@@ -66,6 +67,7 @@ func (suite *OauthTestSuite) TestGrantAccessToken() {
 		suite.users[0],         // user
 		3600,                   // expires in
 		"scope doesn't matter", // scope
+		nil,                    // device session
 	)
 
 	// Error should be Nil
@@ -150,6 +152,7 @@ func (suite *OauthTestSuite) TestGrantAccessTokenDeletesExpiredTokens() {
 		suite.users[0],         // user
 		3600,                   // expires in
 		"scope doesn't matter", // scope
+		nil,                    // device session
 	)
 	assert.NoError(suite.T(), err)
 
@@ -187,6 +190,7 @@ func (suite *OauthTestSuite) TestGrantAccessTokenDeletesExpiredTokens() {
 		nil,                    // user
 		3600,                   // expires in
 		"scope doesn't matter", // scope
+		nil,                    // device session
 	)
 	assert.NoError(suite.T(), err)
 
@@ -0,0 +1,278 @@
+package oauth
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"github.com/resonatecoop/id/util"
+	"github.com/resonatecoop/id/util/response"
+	"github.com/resonatecoop/user-api/model"
+)
+
+const (
+	defaultClientsPageSize = 25
+	maxClientsPageSize     = 100
+)
+
+var (
+	// ErrAdminRequired ...
+	ErrAdminRequired = errors.New("Admin access required")
+)
+
+// authAdmin authenticates the access token of an admin or superadmin with
+// write access the request is made with and returns the admin
+func (s *Service) authAdmin(r *http.Request) (*model.User, error) {
+	accessToken, err := s.AuthenticateRequest(r)
+	if err != nil {
+		return nil, err
+	}
+
+	if !util.StringInSlice("read_write", strings.Split(accessToken.Scope, " ")) {
+		return nil, ErrAdminRequired
+	}
+
+	user, err := s.FindUserByID(accessToken.UserID.String())
+	if err != nil {
+		return nil, ErrAdminRequired
+	}
+
+	if user.RoleID != int32(model.SuperAdminRole) && user.RoleID != int32(model.AdminRole) {
+		return nil, ErrAdminRequired
+	}
+
+	return user, nil
+}
+
+// ClientRequest is the body of the admin client create and update requests
+type ClientRequest struct {
+	ClientID            string `json:"client_id"`
+	RedirectURI         string `json:"redirect_uri"`
+	ApplicationName     string `json:"application_name"`
+	ApplicationHostname string `json:"application_hostname"`
+	ApplicationURL      string `json:"application_url"`
+	Disabled            *bool  `json:"disabled"`
+}
+
+// adminClientsHandler lists (GET) or creates (POST) clients
+// (GET|POST /v1/admin/clients)
+func (s *Service) adminClientsHandler(w http.ResponseWriter, r *http.Request) {
+	if !s.adminAuth(w, r) {
+		return
+	}
+
+	if r.Method != http.MethodPost {
+		s.listClients(w, r)
+		return
+	}
+
+	req := new(ClientRequest)
+	if err := json.NewDecoder(r.Body).Decode(req); err != nil || req.ClientID == "" {
+		response.Error(w, ErrInvalidClientMetadata.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if req.RedirectURI != "" {
+		if err := ValidateRedirectURI(req.RedirectURI); err != nil {
+			response.Error(w, err.Error(), getErrStatusCode(err))
+			return
+		}
+	}
+
+	secret, err := newClientSecret()
+	if err != nil {
+		response.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	client, err := s.CreateClient(req.ClientID, secret, req.RedirectURI, req.ApplicationName, req.ApplicationHostname, req.ApplicationURL)
+	if err != nil {
+		response.Error(w, err.Error(), getErrStatusCode(err))
+		return
+	}
+
+	if req.Disabled != nil && *req.Disabled {
+		if err := s.SetClientDisabled(client, true); err != nil {
+			response.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+
+	resp, err := s.NewClientResponse(client)
+	if err != nil {
+		response.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	// The secret is only shown once
+	resp.ClientSecret = secret
+
+	response.WriteJSON(w, resp, http.StatusCreated)
+}
+
+// adminClientHandler reads (GET), updates (PUT) or deletes (DELETE) a client
+// (GET|PUT|DELETE /v1/admin/clients/{client_id})
+func (s *Service) adminClientHandler(w http.ResponseWriter, r *http.Request) {
+	if !s.adminAuth(w, r) {
+		return
+	}
+
+	client, err := s.FindClientByClientID(mux.Vars(r)["client_id"])
+	if err != nil {
+		response.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodPut:
+		req := new(ClientRequest)
+		if err := json.NewDecoder(r.Body).Decode(req); err != nil {
+			response.Error(w, ErrInvalidClientMetadata.Error(), http.StatusBadRequest)
+			return
+		}
+
+		// The client ID cannot be changed
+		if req.ClientID != "" && !strings.EqualFold(req.ClientID, client.Key) {
+			response.Error(w, ErrInvalidClientMetadata.Error(), http.StatusBadRequest)
+			return
+		}
+
+		if req.RedirectURI != "" {
+			if err := ValidateRedirectURI(req.RedirectURI); err != nil {
+				response.Error(w, err.Error(), getErrStatusCode(err))
+				return
+			}
+		}
+
+		err = s.UpdateClient(client, req.RedirectURI, req.ApplicationName, req.ApplicationHostname, req.ApplicationURL)
+		if err == nil && req.Disabled != nil {
+			err = s.SetClientDisabled(client, *req.Disabled)
+		}
+	case http.MethodDelete:
+		if err := s.DeleteClient(client); err != nil {
+			response.Error(w, err.Error(), getErrStatusCode(err))
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	if err != nil {
+		response.Error(w, err.Error(), getErrStatusCode(err))
+		return
+	}
+
+	resp, err := s.NewClientResponse(client)
+	if err != nil {
+		response.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	response.WriteJSON(w, resp, http.StatusOK)
+}
+
+// adminClientSecretHandler rotates the secret of a client
+// (POST /v1/admin/clients/{client_id}/secret)
+func (s *Service) adminClientSecretHandler(w http.ResponseWriter, r *http.Request) {
+	if !s.adminAuth(w, r) {
+		return
+	}
+
+	client, err := s.FindClientByClientID(mux.Vars(r)["client_id"])
+	if err != nil {
+		response.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	secret, err := s.RotateClientSecret(client)
+	if err != nil {
+		response.Error(w, err.Error(), getErrStatusCode(err))
+		return
+	}
+
+	resp, err := s.NewClientResponse(client)
+	if err != nil {
+		response.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	// The new secret is only shown once
+	resp.ClientSecret = secret
+
+	response.WriteJSON(w, resp, http.StatusOK)
+}
+
+// listClients writes a page of clients as a HAL list
+func (s *Service) listClients(w http.ResponseWriter, r *http.Request) {
+	page, limit := 1, defaultClientsPageSize
+	if p, err := strconv.Atoi(r.URL.Query().Get("page")); err == nil && p > 0 {
+		page = p
+	}
+	if l, err := strconv.Atoi(r.URL.Query().Get("limit")); err == nil && l > 0 && l <= maxClientsPageSize {
+		limit = l
+	}
+
+	clients, count, err := s.ListClients(page, limit)
+	if err != nil {
+		response.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	items := make([]*ClientResponse, len(clients))
+	for i, client := range clients {
+		items[i], err = s.NewClientResponse(client)
+		if err != nil {
+			response.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+
+	lastPage := (count + limit - 1) / limit
+	if lastPage < 1 {
+		lastPage = 1
+	}
+
+	pageURI := func(p int) string {
+		u := *r.URL
+		q := u.Query()
+		q.Set("page", strconv.Itoa(p))
+		u.RawQuery = q.Encode()
+		return u.RequestURI()
+	}
+
+	var prev, next string
+	if page > 1 {
+		prev = pageURI(page - 1)
+	}
+	if page < lastPage {
+		next = pageURI(page + 1)
+	}
+
+	response.WriteJSON(w, response.NewListResponse(
+		count,
+		page,
+		r.URL.RequestURI(),
+		pageURI(1),
+		pageURI(lastPage),
+		prev,
+		next,
+		"clients",
+		items,
+	), http.StatusOK)
+}
+
+// adminAuth authenticates the admin's access token, writing an error
+// response if it fails
+func (s *Service) adminAuth(w http.ResponseWriter, r *http.Request) bool {
+	if _, err := s.authAdmin(r); err != nil {
+		if err == ErrAdminRequired {
+			response.Error(w, err.Error(), http.StatusForbidden)
+			return false
+		}
+		response.UnauthorizedError(w, err.Error())
+		return false
+	}
+
+	return true
+}
@@ -0,0 +1,154 @@
+package oauth_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/google/uuid"
+	"github.com/resonatecoop/id/oauth"
+	"github.com/resonatecoop/id/util"
+	"github.com/resonatecoop/user-api/model"
+	"github.com/stretchr/testify/assert"
+)
+
+// adminAccessToken inserts an admin and returns an access token granted to them
+func (suite *OauthTestSuite) adminAccessToken() string {
+	return suite.roleAccessToken("test@admin.com", int32(model.AdminRole))
+}
+
+// roleAccessToken inserts a user with the role and returns an access token
+// granted to them
+func (suite *OauthTestSuite) roleAccessToken(username string, roleID int32) string {
+	user := &model.User{
+		IDRecord: model.IDRecord{ID: uuid.New()},
+		RoleID:   roleID,
+		Username: username,
+		Password: util.StringOrNull(""),
+	}
+
+	_, err := suite.db.NewInsert().
+		Model(user).
+		Exec(context.Background())
+	assert.NoError(suite.T(), err)
+
+	accessToken, err := suite.service.GrantAccessToken(suite.clients[0], user, 3600, "read_write", nil)
+	assert.NoError(suite.T(), err)
+
+	return accessToken.Token
+}
+
+func (suite *OauthTestSuite) adminRequest(method, path, token string, body interface{}) *httptest.ResponseRecorder {
+	var b []byte
+	if body != nil {
+		var err error
+		b, err = json.Marshal(body)
+		assert.NoError(suite.T(), err)
+	}
+
+	r, err := http.NewRequest(method, "http://1.2.3.4/v1/admin"+path, bytes.NewReader(b))
+	assert.NoError(suite.T(), err, "Request setup should not get an error")
+	r.Header.Set("Authorization", "Bearer "+token)
+
+	w := httptest.NewRecorder()
+	suite.router.ServeHTTP(w, r)
+	return w
+}
+
+func (suite *OauthTestSuite) TestAdminClients() {
+	// Members cannot manage clients
+	w := suite.adminRequest("GET", "/clients", suite.roleAccessToken("test@member.com", int32(model.UserRole)), nil)
+	assert.Equal(suite.T(), http.StatusForbidden, w.Code)
+
+	token := suite.adminAccessToken()
+
+	// Create
+	w = suite.adminRequest("POST", "/clients", token, &oauth.ClientRequest{
+		ClientID:        "partner_client",
+		RedirectURI:     "https://partner.example.com/callback",
+		ApplicationName: "Partner",
+	})
+	assert.Equal(suite.T(), http.StatusCreated, w.Code)
+
+	created := new(oauth.ClientResponse)
+	assert.NoError(suite.T(), json.Unmarshal(w.Body.Bytes(), created))
+	assert.Equal(suite.T(), "partner_client", created.ClientID)
+	assert.NotEmpty(suite.T(), created.ClientSecret)
+
+	_, err := suite.service.AuthClient("partner_client", created.ClientSecret)
+	assert.NoError(suite.T(), err)
+
+	// List
+	w = suite.adminRequest("GET", "/clients?limit=100", token, nil)
+	assert.Equal(suite.T(), http.StatusOK, w.Code)
+
+	list := new(struct {
+		Count    uint `json:"count"`
+		Page     uint `json:"page"`
+		Embedded struct {
+			Clients []*oauth.ClientResponse `json:"clients"`
+		} `json:"_embedded"`
+	})
+	assert.NoError(suite.T(), json.Unmarshal(w.Body.Bytes(), list))
+	assert.Equal(suite.T(), uint(len(suite.clients)+1), list.Count)
+	assert.Equal(suite.T(), uint(1), list.Page)
+	assert.Len(suite.T(), list.Embedded.Clients, len(suite.clients)+1)
+
+	// Update and disable
+	disabled := true
+	w = suite.adminRequest("PUT", "/clients/partner_client", token, &oauth.ClientRequest{
+		RedirectURI:     "https://partner.example.com/callback",
+		ApplicationName: "Partner 2",
+		Disabled:        &disabled,
+	})
+	assert.Equal(suite.T(), http.StatusOK, w.Code)
+
+	updated := new(oauth.ClientResponse)
+	assert.NoError(suite.T(), json.Unmarshal(w.Body.Bytes(), updated))
+	assert.Equal(suite.T(), "Partner 2", updated.ApplicationName)
+	assert.True(suite.T(), updated.Disabled)
+
+	_, err = suite.service.AuthClient("partner_client", created.ClientSecret)
+	assert.Equal(suite.T(), oauth.ErrClientDisabled, err)
+
+	// Delete
+	w = suite.adminRequest("DELETE", "/clients/partner_client", token, nil)
+	assert.Equal(suite.T(), http.StatusNoContent, w.Code)
+
+	w = suite.adminRequest("GET", "/clients/partner_client", token, nil)
+	assert.Equal(suite.T(), http.StatusNotFound, w.Code)
+}
+
+func (suite *OauthTestSuite) TestRotateClientSecret() {
+	client, err := suite.service.CreateClient("rotating_client", "old_secret", "https://www.example.com", "", "", "")
+	assert.NoError(suite.T(), err)
+
+	w := suite.adminRequest("POST", "/clients/rotating_client/secret", suite.adminAccessToken(), nil)
+	assert.Equal(suite.T(), http.StatusOK, w.Code)
+
+	resp := new(oauth.ClientResponse)
+	assert.NoError(suite.T(), json.Unmarshal(w.Body.Bytes(), resp))
+	assert.NotEmpty(suite.T(), resp.ClientSecret)
+	assert.NotEmpty(suite.T(), resp.PreviousSecretExpiresAt)
+
+	// Both secrets are valid during the grace period
+	_, err = suite.service.AuthClient("rotating_client", resp.ClientSecret)
+	assert.NoError(suite.T(), err)
+	_, err = suite.service.AuthClient("rotating_client", "old_secret")
+	assert.NoError(suite.T(), err)
+
+	// Once the grace period is over only the new secret is valid
+	_, err = suite.db.NewUpdate().
+		Model((*oauth.ClientState)(nil)).
+		Set("previous_secret_expires_at = NOW() - INTERVAL '1 second'").
+		Where("client_id = ?", client.ID).
+		Exec(context.Background())
+	assert.NoError(suite.T(), err)
+
+	_, err = suite.service.AuthClient("rotating_client", "old_secret")
+	assert.Equal(suite.T(), oauth.ErrInvalidClientSecret, err)
+	_, err = suite.service.AuthClient("rotating_client", resp.ClientSecret)
+	assert.NoError(suite.T(), err)
+}
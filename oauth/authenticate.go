@@ -2,6 +2,7 @@ package oauth
 
 import (
 	"context"
+	"database/sql"
 	"errors"
 	"time"
 
@@ -20,13 +21,19 @@ var (
 
 // Authenticate checks the access token is valid
 func (s *Service) Authenticate(token string) (*model.AccessToken, error) {
+	// JWT access tokens are stored under their jti
+	lookupKey, err := s.getAccessTokenLookupKey(token)
+	if err != nil {
+		return nil, err
+	}
+
 	// Fetch the access token from the database
 	ctx := context.Background()
 	accessToken := new(model.AccessToken)
 
-	err := s.db.NewSelect().
+	err = s.db.NewSelect().
 		Model(accessToken).
-		Where("token = ?", token).
+		Where("token = ?", lookupKey).
 		Limit(1).
 		Scan(ctx)
 
@@ -40,48 +47,91 @@ func (s *Service) Authenticate(token string) (*model.AccessToken, error) {
 		return nil, ErrAccessTokenExpired
 	}
 
-	// Extend refresh token expiration database
+	// Tokens of disabled clients are no longer valid
+	client := &model.Client{IDRecord: model.IDRecord{ID: accessToken.ClientID}}
+	if err := s.CheckClientEnabled(client); err != nil {
+		return nil, err
+	}
+
+	// Rotated refresh tokens have a fixed lifetime, every rotation issues
+	// a new token instead
+	if s.cnf.Oauth.RefreshTokenRotation {
+		return accessToken, nil
+	}
 
+	// Extend refresh token expiration database, by the refresh token
+	// lifetime of the client the token was issued to
+	lifetime, err := s.RefreshTokenLifetime(client)
+	if err != nil {
+		return nil, err
+	}
 	increasedExpiresAt := time.Now().Add(
-		time.Duration(s.cnf.Oauth.RefreshTokenLifetime) * time.Second,
+		time.Duration(lifetime) * time.Second,
 	)
 
-	//var res sql.Result
-
-	//	err = GetOrCreateRefreshToken
+	query := s.db.NewUpdate().
+		Model(new(model.RefreshToken)).
+		Set("expires_at = ?", increasedExpiresAt).
+		Set("updated_at = ?", time.Now().UTC()).
+		Where("client_id = ?", accessToken.ClientID.String())
 
-	if util.IsValidUUID(accessToken.UserID.String()) && accessToken.UserID != uuid.Nil {
-		_, err = s.db.NewUpdate().
-			Model(new(model.RefreshToken)).
-			Set("expires_at = ?", increasedExpiresAt).
-			Set("updated_at = ?", time.Now().UTC()).
-			Where("client_id = ?", accessToken.ClientID.String()).
-			Where("user_id = ?", accessToken.UserID.String()).
-			Exec(ctx)
-	} else {
-		_, err = s.db.NewUpdate().
-			Model(new(model.RefreshToken)).
-			Set("expires_at = ?", increasedExpiresAt).
-			Set("updated_at = ?", time.Now().UTC()).
-			Where("client_id = ?", accessToken.ClientID.String()).
-			Where("user_id = uuid_nil()").
-			Exec(ctx)
+	// Only the refresh token of the access token's device session is
+	// extended, the user's other devices keep their own expiry
+	var deviceSessionID uuid.UUID
+	err = s.db.NewSelect().
+		Model((*DeviceSessionToken)(nil)).
+		Column("device_session_id").
+		Where("token = ?", lookupKey).
+		Limit(1).
+		Scan(ctx, &deviceSessionID)
+
+	switch err {
+	case nil:
+		query = query.Where("token IN (?)", s.db.NewSelect().
+			Model((*DeviceSessionToken)(nil)).
+			Column("token").
+			Where("device_session_id = ?", deviceSessionID))
+	case sql.ErrNoRows:
+		// Access tokens granted before device sessions existed extend the
+		// refresh tokens of the user and client which are not linked either
+		if util.IsValidUUID(accessToken.UserID.String()) && accessToken.UserID != uuid.Nil {
+			query = query.Where("user_id = ?", accessToken.UserID.String())
+		} else {
+			query = query.Where("user_id = uuid_nil()")
+		}
+		query = query.Where("token NOT IN (?)", s.db.NewSelect().
+			Model((*DeviceSessionToken)(nil)).
+			Column("token"))
+	default:
+		return nil, err
 	}
 
-	if err != nil {
+	if _, err := query.Exec(ctx); err != nil {
 		return nil, err
 	}
 
 	return accessToken, nil
 }
 
-// ClearUserTokens deletes the user's access and refresh tokens associated with this client id
+// ClearUserTokens signs out the device session of the user session, tokens
+// granted before device sessions existed are cleared for the user and client
 func (s *Service) ClearUserTokens(userSession *session.UserSession) {
-	// Clear all refresh tokens with user_id and client_id
+	// Only sign out this device, the user stays logged in on other devices
+	deviceSession, err := s.FindDeviceSessionByToken(userSession.RefreshToken)
+	if err == nil {
+		s.RevokeDeviceSession(deviceSession)
+		return
+	}
+
 	ctx := context.Background()
+	linkedTokens := s.db.NewSelect().
+		Model((*DeviceSessionToken)(nil)).
+		Column("token")
+
+	// Clear all refresh tokens with user_id and client_id
 	refreshToken := new(model.RefreshToken)
 
-	err := s.db.NewSelect().
+	err = s.db.NewSelect().
 		Model(refreshToken).
 		Where("token = ?", userSession.RefreshToken).
 		Limit(1).
@@ -92,15 +142,18 @@ func (s *Service) ClearUserTokens(userSession *session.UserSession) {
 		_, err = s.db.NewDelete().
 			Model(refreshToken).
 			Where("client_id = ? AND user_id = ?", refreshToken.ClientID, refreshToken.UserID).
+			Where("token NOT IN (?)", linkedTokens).
 			Exec(ctx)
 	}
 
 	// Clear all access tokens with user_id and client_id
 	accessToken := new(model.AccessToken)
 
+	lookupKey, _ := s.getAccessTokenLookupKey(userSession.AccessToken)
+
 	err = s.db.NewSelect().
 		Model(accessToken).
-		Where("token = ?", userSession.AccessToken).
+		Where("token = ?", lookupKey).
 		Limit(1).
 		Scan(ctx)
 
@@ -109,6 +162,7 @@ func (s *Service) ClearUserTokens(userSession *session.UserSession) {
 		_, err = s.db.NewDelete().
 			Model(accessToken).
 			Where("client_id = ? AND user_id = ?", accessToken.ClientID, accessToken.UserID).
+			Where("token NOT IN (?)", linkedTokens).
 			Exec(ctx)
 	}
 }
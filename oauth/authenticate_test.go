@@ -9,6 +9,7 @@ import (
 	"github.com/resonatecoop/user-api/model"
 
 	"github.com/google/uuid"
+	"github.com/uptrace/bun"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -117,6 +118,54 @@ func (suite *OauthTestSuite) TestAuthenticate() {
 	assert.Nil(suite.T(), err)
 }
 
+func (suite *OauthTestSuite) TestAuthenticateExtendsDeviceSessionRefreshToken() {
+	ctx := context.Background()
+
+	// The user is logged in on two devices
+	accessToken, refreshToken, err := suite.service.Login(suite.clients[0], suite.users[0], "read", suite.service.NewDeviceSession(suite.clients[0], suite.users[0], nil))
+	assert.NoError(suite.T(), err)
+	_, otherRefreshToken, err := suite.service.Login(suite.clients[0], suite.users[0], "read", suite.service.NewDeviceSession(suite.clients[0], suite.users[0], nil))
+	assert.NoError(suite.T(), err)
+	assert.NotEqual(suite.T(), refreshToken.Token, otherRefreshToken.Token)
+
+	expiresAt := time.Now().UTC().Add(10 * time.Second)
+	_, err = suite.db.NewUpdate().
+		Model((*model.RefreshToken)(nil)).
+		Set("expires_at = ?", expiresAt).
+		Where("token IN (?)", bun.In([]string{refreshToken.Token, otherRefreshToken.Token})).
+		Exec(ctx)
+	assert.NoError(suite.T(), err)
+
+	_, err = suite.service.Authenticate(accessToken.Token)
+	assert.NoError(suite.T(), err)
+
+	// Only the refresh token of the same device is extended
+	extended := new(model.RefreshToken)
+	err = suite.db.NewSelect().Model(extended).Where("token = ?", refreshToken.Token).Scan(ctx)
+	assert.NoError(suite.T(), err)
+	assert.True(suite.T(), extended.ExpiresAt.After(expiresAt.Add(time.Minute)))
+
+	other := new(model.RefreshToken)
+	err = suite.db.NewSelect().Model(other).Where("token = ?", otherRefreshToken.Token).Scan(ctx)
+	assert.NoError(suite.T(), err)
+	assert.WithinDuration(suite.T(), expiresAt, other.ExpiresAt, time.Second)
+}
+
+func (suite *OauthTestSuite) TestAuthenticateDisabledClient() {
+	accessToken, _, err := suite.service.Login(suite.clients[0], suite.users[0], "read", nil)
+	assert.NoError(suite.T(), err)
+
+	// The tokens of a disabled client are no longer valid
+	assert.NoError(suite.T(), suite.service.SetClientDisabled(suite.clients[0], true))
+	_, err = suite.service.Authenticate(accessToken.Token)
+	assert.Equal(suite.T(), oauth.ErrClientDisabled, err)
+
+	// Until it is enabled again
+	assert.NoError(suite.T(), suite.service.SetClientDisabled(suite.clients[0], false))
+	_, err = suite.service.Authenticate(accessToken.Token)
+	assert.NoError(suite.T(), err)
+}
+
 func (suite *OauthTestSuite) TestAuthenticateRollingRefreshToken() {
 	var (
 		ctx               context.Context
@@ -15,17 +15,70 @@ var (
 	ErrAuthorizationCodeExpired = errors.New("Authorization code expired")
 )
 
-// GrantAuthorizationCode grants a new authorization code
-func (s *Service) GrantAuthorizationCode(client *model.Client, user *model.User, expiresIn int, redirectURI, scope string) (*model.AuthorizationCode, error) {
-	// Create a new authorization code
-	authorizationCode := model.NewOauthAuthorizationCode(client, user, expiresIn, redirectURI, scope)
+// GrantAuthorizationCode grants a new authorization code, an optional
+// PKCE code challenge and OpenID Connect nonce are stored alongside the code.
+// The code does not outlive the client's authorization code lifetime.
+func (s *Service) GrantAuthorizationCode(client *model.Client, user *model.User, expiresIn int, redirectURI, scope, codeChallenge, codeChallengeMethod, nonce string) (*model.AuthorizationCode, error) {
+	// Validate the code challenge
+	if codeChallenge != "" {
+		var err error
+		codeChallengeMethod, err = ValidateCodeChallenge(codeChallenge, codeChallengeMethod)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	lifetime, err := s.AuthCodeLifetime(client)
+	if err != nil {
+		return nil, err
+	}
+
+	// Begin a transaction
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, err
+	}
 
 	ctx := context.Background()
 
-	_, err := s.db.NewInsert().Model(authorizationCode).Exec(ctx)
+	// Create a new authorization code
+	expiresIn = limitLifetime(expiresIn, lifetime)
+	authorizationCode := model.NewOauthAuthorizationCode(client, user, expiresIn, redirectURI, scope)
+
+	_, err = tx.NewInsert().Model(authorizationCode).Exec(ctx)
 	if err != nil {
+		tx.Rollback() // rollback the transaction
+		return nil, err
+	}
+
+	if codeChallenge != "" {
+		_, err = tx.NewInsert().
+			Model(&AuthorizationCodeChallenge{
+				Code:                authorizationCode.Code,
+				CodeChallenge:       codeChallenge,
+				CodeChallengeMethod: codeChallengeMethod,
+				CreatedAt:           time.Now().UTC(),
+			}).
+			Exec(ctx)
+		if err != nil {
+			tx.Rollback() // rollback the transaction
+			return nil, err
+		}
+	}
+
+	if nonce != "" {
+		if err = s.saveAuthorizationCodeNonce(tx, authorizationCode.Code, nonce); err != nil {
+			tx.Rollback() // rollback the transaction
+			return nil, err
+		}
+	}
+
+	// Commit the transaction
+	if err = tx.Commit(); err != nil {
+		tx.Rollback() // rollback the transaction
 		return nil, err
 	}
+
 	authorizationCode.Client = client
 	authorizationCode.User = user
 
@@ -33,7 +86,7 @@ func (s *Service) GrantAuthorizationCode(client *model.Client, user *model.User,
 }
 
 // getValidAuthorizationCode returns a valid non expired authorization code
-func (s *Service) getValidAuthorizationCode(code, redirectURI string, client *model.Client) (*model.AuthorizationCode, error) {
+func (s *Service) getValidAuthorizationCode(code, redirectURI, codeVerifier string, client *model.Client) (*model.AuthorizationCode, error) {
 	// Fetch the auth code from the database
 	ctx := context.Background()
 	authorizationCode := new(model.AuthorizationCode)
@@ -77,5 +130,10 @@ func (s *Service) getValidAuthorizationCode(code, redirectURI string, client *mo
 		return nil, ErrAuthorizationCodeExpired
 	}
 
+	// Code verifier must match the code challenge if one was used to obtain the authorization code
+	if err := s.verifyAuthorizationCodeChallenge(authorizationCode.Code, codeVerifier); err != nil {
+		return nil, err
+	}
+
 	return authorizationCode, nil
 }
@@ -23,6 +23,9 @@ func (suite *OauthTestSuite) TestGrantAuthorizationCode() {
 		3600,                          // expires in
 		"redirect URI doesn't matter", // redirect URI
 		"scope doesn't matter",        // scope
+		"",                            // code challenge
+		"",                            // code challenge method
+		"",                            // nonce
 	)
 
 	ctx = context.Background()
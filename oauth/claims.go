@@ -0,0 +1,82 @@
+package oauth
+
+import (
+	"github.com/resonatecoop/id/util"
+	"github.com/resonatecoop/user-api/model"
+)
+
+const (
+	// ClaimRole is the name of the user's role, e.g. user, artist or admin
+	ClaimRole = "role"
+	// ClaimEmailConfirmed is true once the user confirmed their email
+	ClaimEmailConfirmed = "email_confirmed"
+	// ClaimCountry is the user's ISO 3166-1 alpha-2 country code
+	ClaimCountry = "country"
+	// ClaimLegacyID is the user's ID on the previous platform
+	ClaimLegacyID = "legacy_id"
+	// ClaimMember is true for members of the cooperative
+	ClaimMember = "member"
+)
+
+var (
+	// UserClaimNames are the user claims a client can register, the role is
+	// always included whether registered or not
+	UserClaimNames = []string{ClaimRole, ClaimEmailConfirmed, ClaimCountry, ClaimLegacyID, ClaimMember}
+)
+
+// UserClaims are the user's role and the other attributes of a user a client
+// chose to receive in introspection responses, ID tokens and JWT access tokens
+type UserClaims struct {
+	Role           string `json:"role,omitempty"`
+	EmailConfirmed *bool  `json:"email_confirmed,omitempty"`
+	Country        string `json:"country,omitempty"`
+	LegacyID       int32  `json:"legacy_id,omitempty"`
+	Member         *bool  `json:"member,omitempty"`
+}
+
+// newUserClaims returns the user's role along with the other user claims the
+// client registered, if any
+func (s *Service) newUserClaims(client *model.Client, user *model.User) (UserClaims, error) {
+	var claims UserClaims
+
+	role, err := s.GetRoleName(user.RoleID)
+	if err != nil {
+		return claims, err
+	}
+	claims.Role = role
+
+	policy, err := s.clientPolicy(client)
+	if err != nil {
+		return claims, err
+	}
+	if policy == nil {
+		return claims, nil
+	}
+
+	for _, name := range policy.Claims {
+		switch name {
+		case ClaimEmailConfirmed:
+			emailConfirmed := user.EmailConfirmed
+			claims.EmailConfirmed = &emailConfirmed
+		case ClaimCountry:
+			claims.Country = user.Country
+		case ClaimLegacyID:
+			claims.LegacyID = user.LegacyID
+		case ClaimMember:
+			member := user.Member
+			claims.Member = &member
+		}
+	}
+
+	return claims, nil
+}
+
+// validateClaimNames checks a client only registers known user claims
+func validateClaimNames(names []string) error {
+	for _, name := range names {
+		if !util.StringInSlice(name, UserClaimNames) {
+			return ErrInvalidClientMetadata
+		}
+	}
+	return nil
+}
@@ -0,0 +1,114 @@
+package oauth_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+
+	jwt "github.com/form3tech-oss/jwt-go"
+	"github.com/resonatecoop/id/oauth"
+	"github.com/stretchr/testify/assert"
+)
+
+func (suite *OauthTestSuite) TestUserClaims() {
+	// Only known user claims can be registered
+	_, err := suite.service.RegisterClient(&oauth.ClientRegistrationRequest{
+		ClientName: "Partner Service",
+		GrantTypes: []string{"client_credentials"},
+		Claims:     []string{"password"},
+	}, true)
+	assert.Equal(suite.T(), oauth.ErrInvalidClientMetadata, err)
+
+	resp, err := suite.service.RegisterClient(&oauth.ClientRegistrationRequest{
+		ClientName:   "Partner App",
+		RedirectURIs: []string{"https://partner.example.com/callback"},
+		GrantTypes:   []string{"authorization_code"},
+		Claims:       []string{oauth.ClaimEmailConfirmed, oauth.ClaimCountry, oauth.ClaimLegacyID, oauth.ClaimMember},
+	}, false)
+	assert.NoError(suite.T(), err)
+
+	client, err := suite.service.FindClientByClientID(resp.ClientID)
+	assert.NoError(suite.T(), err)
+
+	user := suite.users[0]
+
+	role, err := suite.service.GetRoleName(user.RoleID)
+	assert.NoError(suite.T(), err)
+
+	// The claims are those of the client introspecting the token, not of
+	// the client the token was issued to
+	accessToken, _, err := suite.service.Login(suite.clients[0], user, "read", nil)
+	assert.NoError(suite.T(), err)
+
+	r, err := http.NewRequest("POST", "http://1.2.3.4/v1/oauth/introspect", nil)
+	assert.NoError(suite.T(), err, "Request setup should not get an error")
+	r.SetBasicAuth(resp.ClientID, resp.ClientSecret)
+	r.PostForm = url.Values{"token": {accessToken.Token}}
+
+	w := httptest.NewRecorder()
+	suite.router.ServeHTTP(w, r)
+	assert.Equal(suite.T(), http.StatusOK, w.Code)
+
+	introspectResponse := new(oauth.IntrospectResponse)
+	err = json.Unmarshal(w.Body.Bytes(), introspectResponse)
+	assert.NoError(suite.T(), err)
+	assert.True(suite.T(), introspectResponse.Active)
+	assert.Equal(suite.T(), user.ID.String(), introspectResponse.Subject)
+	assert.Equal(suite.T(), suite.clients[0].Key, introspectResponse.ClientID)
+	assert.Equal(suite.T(), role, introspectResponse.Role)
+	if assert.NotNil(suite.T(), introspectResponse.EmailConfirmed) {
+		assert.Equal(suite.T(), user.EmailConfirmed, *introspectResponse.EmailConfirmed)
+	}
+	if assert.NotNil(suite.T(), introspectResponse.Member) {
+		assert.Equal(suite.T(), user.Member, *introspectResponse.Member)
+	}
+	assert.Equal(suite.T(), user.Country, introspectResponse.Country)
+	assert.Equal(suite.T(), user.LegacyID, introspectResponse.LegacyID)
+
+	// Clients which registered no claims get the role only
+	r, err = http.NewRequest("POST", "http://1.2.3.4/v1/oauth/introspect", nil)
+	assert.NoError(suite.T(), err, "Request setup should not get an error")
+	r.SetBasicAuth("test_client_1", "test_secret")
+	r.PostForm = url.Values{"token": {accessToken.Token}}
+
+	w = httptest.NewRecorder()
+	suite.router.ServeHTTP(w, r)
+	assert.Equal(suite.T(), http.StatusOK, w.Code)
+
+	introspectResponse = new(oauth.IntrospectResponse)
+	err = json.Unmarshal(w.Body.Bytes(), introspectResponse)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), oauth.UserClaims{Role: role}, introspectResponse.UserClaims)
+
+	// ID tokens carry the claims of the client they are issued to
+	idToken, err := suite.service.GrantIDToken(client, user, "")
+	assert.NoError(suite.T(), err)
+
+	set, err := suite.service.GetJWKS()
+	assert.NoError(suite.T(), err)
+
+	claims := new(oauth.IDTokenClaims)
+	_, err = jwt.ParseWithClaims(idToken, claims, set.Keyfunc)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), role, claims.Role)
+	assert.Equal(suite.T(), user.LegacyID, claims.LegacyID)
+	if assert.NotNil(suite.T(), claims.EmailConfirmed) {
+		assert.Equal(suite.T(), user.EmailConfirmed, *claims.EmailConfirmed)
+	}
+
+	// And JWT access tokens
+	suite.cnf.Oauth.AccessTokenFormat = oauth.AccessTokenFormatJWT
+	defer func() { suite.cnf.Oauth.AccessTokenFormat = oauth.AccessTokenFormatOpaque }()
+
+	jwtAccessToken, err := suite.service.GrantAccessToken(client, user, 0, "read", nil)
+	assert.NoError(suite.T(), err)
+
+	accessTokenClaims, err := suite.service.ParseJWTAccessToken(jwtAccessToken.Token)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), role, accessTokenClaims.Role)
+	assert.Equal(suite.T(), user.Country, accessTokenClaims.Country)
+	if assert.NotNil(suite.T(), accessTokenClaims.Member) {
+		assert.Equal(suite.T(), user.Member, *accessTokenClaims.Member)
+	}
+}
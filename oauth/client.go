@@ -4,7 +4,9 @@ import (
 	"context"
 	"errors"
 	"strings"
+	"time"
 
+	"github.com/google/uuid"
 	"github.com/resonatecoop/id/util"
 	"github.com/resonatecoop/id/util/password"
 	"github.com/resonatecoop/user-api/model"
@@ -83,14 +85,60 @@ func (s *Service) AuthClient(clientID, secret string) (*model.Client, error) {
 		return nil, ErrClientNotFound
 	}
 
-	// Verify the secret
-	if password.VerifyPassword(client.Secret, secret) != nil {
+	state, err := s.FindClientState(client)
+	if err != nil {
+		return nil, err
+	}
+
+	// Verify the secret, the previous secret is accepted during the grace
+	// period after a rotation
+	if password.VerifyPassword(client.Secret, secret) != nil && !state.verifyPreviousSecret(secret) {
 		return nil, ErrInvalidClientSecret
 	}
 
+	// Disabled clients cannot authenticate
+	if state.Disabled {
+		return nil, ErrClientDisabled
+	}
+
 	return client, nil
 }
 
+// ListClients returns a page of clients ordered by creation, along with the
+// total number of clients
+func (s *Service) ListClients(page, limit int) ([]*model.Client, int, error) {
+	var clients []*model.Client
+
+	count, err := s.db.NewSelect().
+		Model(&clients).
+		Order("created_at ASC").
+		Limit(limit).
+		Offset((page - 1) * limit).
+		ScanAndCount(context.Background())
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return clients, count, nil
+}
+
+// UpdateClient updates the redirect URI and application metadata of a client
+func (s *Service) UpdateClient(client *model.Client, redirectURI, applicationName, applicationHostname, applicationURL string) error {
+	client.RedirectURI = util.StringOrNull(redirectURI)
+	client.ApplicationName = util.StringOrNull(applicationName)
+	client.ApplicationHostname = util.StringOrNull(strings.ToLower(applicationHostname))
+	client.ApplicationURL = util.StringOrNull(strings.ToLower(applicationURL))
+	client.UpdatedAt = time.Now().UTC()
+
+	_, err := s.db.NewUpdate().
+		Model(client).
+		Column("redirect_uri", "application_name", "application_hostname", "application_url", "updated_at").
+		WherePK().
+		Exec(context.Background())
+
+	return err
+}
+
 func (s *Service) createClientCommon(db *bun.DB, clientID, secret, redirectURI, applicationName, applicationHostname, applicationURL string) (*model.Client, error) {
 	ctx := context.Background()
 	// Check client ID
@@ -105,6 +153,7 @@ func (s *Service) createClientCommon(db *bun.DB, clientID, secret, redirectURI,
 	}
 
 	client := &model.Client{
+		IDRecord:            model.IDRecord{ID: uuid.New(), CreatedAt: time.Now().UTC()},
 		Key:                 strings.ToLower(clientID),
 		Secret:              string(secretHash),
 		RedirectURI:         util.StringOrNull(redirectURI),
@@ -0,0 +1,259 @@
+package oauth
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	jwt "github.com/form3tech-oss/jwt-go"
+	"github.com/google/uuid"
+	"github.com/resonatecoop/id/oauth/jwk"
+	"github.com/resonatecoop/user-api/model"
+	"github.com/uptrace/bun"
+)
+
+const (
+	// ClientAssertionTypeJWTBearer is the client assertion type of RFC 7523
+	ClientAssertionTypeJWTBearer = "urn:ietf:params:oauth:client-assertion-type:jwt-bearer"
+)
+
+var (
+	// ErrInvalidClientAssertion ...
+	ErrInvalidClientAssertion = errors.New("Invalid client assertion")
+	// ErrClientAssertionReused ...
+	ErrClientAssertionReused = errors.New("Client assertion reused")
+)
+
+var (
+	// ClientAssertionSigningAlgs are the algorithms clients may sign
+	// assertions with, shared secrets are not accepted
+	ClientAssertionSigningAlgs = []string{"RS256", "ES256"}
+)
+
+// ClientAssertion records the jti of a client assertion, each assertion can
+// only be used once before it expires
+type ClientAssertion struct {
+	bun.BaseModel `bun:"table:client_assertions"`
+
+	ClientID  uuid.UUID `bun:"type:uuid,pk"`
+	JTI       string    `bun:"type:varchar(254),pk"`
+	ExpiresAt time.Time `bun:",notnull"`
+}
+
+// authClient authenticates the client of a token, introspection or
+// revocation request with the method it was registered with. Clients
+// registered before dynamic registration may send their secret either way.
+// Clients using none are only identified by their client ID, callers decide
+// whether that is enough.
+func (s *Service) authClient(r *http.Request) (*model.Client, string, error) {
+	var (
+		client *model.Client
+		err    error
+	)
+
+	method := clientAuthMethod(r)
+
+	switch method {
+	case TokenEndpointAuthMethodBasic:
+		clientID, secret, _ := r.BasicAuth()
+		client, err = s.AuthClient(clientID, secret)
+	case TokenEndpointAuthMethodPost:
+		client, err = s.AuthClient(r.Form.Get("client_id"), r.Form.Get("client_secret"))
+	case TokenEndpointAuthMethodPrivateKeyJWT:
+		client, err = s.authClientAssertion(r)
+	default:
+		client, err = s.identifyClient(r.Form.Get("client_id"))
+	}
+	if err != nil {
+		// For security reasons, return a general error message
+		return nil, "", ErrInvalidClientIDOrSecret
+	}
+
+	// Registered clients must use their registered method
+	policy, err := s.clientPolicy(client)
+	if err != nil {
+		return nil, "", err
+	}
+	if policy != nil && policy.TokenEndpointAuthMethod != method {
+		return nil, "", ErrInvalidClientIDOrSecret
+	}
+
+	// Older clients have no keys to sign assertions with
+	if policy == nil && method == TokenEndpointAuthMethodPrivateKeyJWT {
+		return nil, "", ErrInvalidClientIDOrSecret
+	}
+
+	return client, method, nil
+}
+
+// checkPublicGrant checks a client identified by its client ID only may use
+// the grant type of the request. Devices poll for their device code, other
+// grants need a client registered as public.
+func (s *Service) checkPublicGrant(r *http.Request, client *model.Client) error {
+	if r.Form.Get("grant_type") == DeviceCodeGrantType {
+		return nil
+	}
+	return s.checkPublicClient(client)
+}
+
+// checkPublicClient checks a client identified by its client ID only is
+// registered as public
+func (s *Service) checkPublicClient(client *model.Client) error {
+	public, err := s.IsPublicClient(client)
+	if err != nil {
+		return err
+	}
+	if !public {
+		return ErrInvalidClientIDOrSecret
+	}
+	return nil
+}
+
+// clientAuthMethod returns the client authentication method a request uses
+func clientAuthMethod(r *http.Request) string {
+	if _, _, ok := r.BasicAuth(); ok {
+		return TokenEndpointAuthMethodBasic
+	}
+
+	if r.Form.Get("client_assertion") != "" || r.Form.Get("client_assertion_type") != "" {
+		return TokenEndpointAuthMethodPrivateKeyJWT
+	}
+
+	if r.Form.Get("client_secret") != "" {
+		return TokenEndpointAuthMethodPost
+	}
+
+	return TokenEndpointAuthMethodNone
+}
+
+// identifyClient finds a client by its client ID without authenticating it
+func (s *Service) identifyClient(clientID string) (*model.Client, error) {
+	if clientID == "" {
+		return nil, ErrClientNotFound
+	}
+
+	client, err := s.FindClientByClientID(clientID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Disabled clients cannot authenticate
+	if err := s.CheckClientEnabled(client); err != nil {
+		return nil, err
+	}
+
+	return client, nil
+}
+
+// authClientAssertion authenticates a client with a JWT signed by one of
+// its registered keys (RFC 7523)
+func (s *Service) authClientAssertion(r *http.Request) (*model.Client, error) {
+	if r.Form.Get("client_assertion_type") != ClientAssertionTypeJWTBearer {
+		return nil, ErrInvalidClientAssertion
+	}
+
+	var client *model.Client
+
+	claims := jwt.MapClaims{}
+	parser := &jwt.Parser{ValidMethods: ClientAssertionSigningAlgs}
+	_, err := parser.ParseWithClaims(r.Form.Get("client_assertion"), claims, func(token *jwt.Token) (interface{}, error) {
+		// The client signs the assertion on its own behalf
+		iss, _ := claims["iss"].(string)
+		if sub, _ := claims["sub"].(string); iss != sub {
+			return nil, ErrInvalidClientAssertion
+		}
+
+		var err error
+		client, err = s.identifyClient(iss)
+		if err != nil {
+			return nil, err
+		}
+
+		// The client_id parameter is optional but must match if sent
+		if clientID := r.Form.Get("client_id"); clientID != "" && clientID != client.Key {
+			return nil, ErrInvalidClientAssertion
+		}
+
+		set, err := s.clientJWKS(client)
+		if err != nil {
+			return nil, err
+		}
+
+		return clientKeyfunc(set, token)
+	})
+	if err != nil {
+		return nil, ErrInvalidClientAssertion
+	}
+
+	// The assertion is meant for this server, either the issuer or the
+	// endpoint it is sent to
+	issuer := s.GetIssuer()
+	if !claims.VerifyAudience(issuer, true) && !claims.VerifyAudience(issuer+r.URL.Path, true) {
+		return nil, ErrInvalidClientAssertion
+	}
+
+	exp, ok := claims["exp"].(float64)
+	if !ok {
+		return nil, ErrInvalidClientAssertion
+	}
+
+	jti, _ := claims["jti"].(string)
+	if jti == "" {
+		return nil, ErrInvalidClientAssertion
+	}
+
+	if err := s.useClientAssertion(client, jti, time.Unix(int64(exp), 0)); err != nil {
+		return nil, err
+	}
+
+	return client, nil
+}
+
+// useClientAssertion records the jti of an assertion and fails if it was
+// used before
+func (s *Service) useClientAssertion(client *model.Client, jti string, expiresAt time.Time) error {
+	ctx := context.Background()
+
+	// Expired assertions are rejected anyway, no need to remember them
+	_, err := s.db.NewDelete().
+		Model((*ClientAssertion)(nil)).
+		Where("client_id = ?", client.ID).
+		Where("expires_at < ?", time.Now().UTC()).
+		Exec(ctx)
+	if err != nil {
+		return err
+	}
+
+	res, err := s.db.NewInsert().
+		Model(&ClientAssertion{
+			ClientID:  client.ID,
+			JTI:       jti,
+			ExpiresAt: expiresAt.UTC(),
+		}).
+		On("CONFLICT (client_id, jti) DO NOTHING").
+		Exec(ctx)
+	if err != nil {
+		return err
+	}
+
+	if rows, err := res.RowsAffected(); err != nil || rows == 0 {
+		return ErrClientAssertionReused
+	}
+
+	return nil
+}
+
+// clientKeyfunc looks up the key an assertion was signed with, clients with
+// a single key may leave out the kid header
+func clientKeyfunc(set *jwk.Set, token *jwt.Token) (interface{}, error) {
+	if _, ok := token.Header["kid"]; !ok && len(set.Keys) == 1 {
+		key := set.Keys[0]
+		if key.Algorithm != "" && key.Algorithm != token.Method.Alg() {
+			return nil, jwk.ErrInvalidKey
+		}
+		return key.PublicKey()
+	}
+
+	return set.Keyfunc(token)
+}
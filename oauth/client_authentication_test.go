@@ -0,0 +1,219 @@
+package oauth_test
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"time"
+
+	jwt "github.com/form3tech-oss/jwt-go"
+	"github.com/google/uuid"
+	"github.com/resonatecoop/id/oauth"
+	"github.com/resonatecoop/id/oauth/jwk"
+	testutil "github.com/resonatecoop/id/test-util"
+	"github.com/stretchr/testify/assert"
+)
+
+func (suite *OauthTestSuite) TestClientSecretPost() {
+	resp, err := suite.service.RegisterClient(&oauth.ClientRegistrationRequest{
+		ClientName:              "Partner Service",
+		GrantTypes:              []string{"client_credentials"},
+		TokenEndpointAuthMethod: oauth.TokenEndpointAuthMethodPost,
+	}, true)
+	assert.NoError(suite.T(), err)
+	assert.NotEmpty(suite.T(), resp.ClientSecret)
+
+	token := func(form url.Values, basicAuth bool) *httptest.ResponseRecorder {
+		r, err := http.NewRequest("POST", "http://1.2.3.4/v1/oauth/tokens", nil)
+		assert.NoError(suite.T(), err, "Request setup should not get an error")
+		if basicAuth {
+			r.SetBasicAuth(resp.ClientID, resp.ClientSecret)
+		}
+		r.PostForm = form
+		w := httptest.NewRecorder()
+		suite.router.ServeHTTP(w, r)
+		return w
+	}
+
+	// The secret is sent in the form body
+	w := token(url.Values{
+		"grant_type":    {"client_credentials"},
+		"client_id":     {resp.ClientID},
+		"client_secret": {resp.ClientSecret},
+	}, false)
+	assert.Equal(suite.T(), http.StatusOK, w.Code)
+
+	// Other methods than the registered one are rejected
+	w = token(url.Values{"grant_type": {"client_credentials"}}, true)
+	testutil.TestResponseForOauthError(suite.T(), w, oauth.ErrorCodeInvalidClient, oauth.ErrInvalidClientIDOrSecret.Error(), 401)
+
+	// A wrong secret is rejected
+	w = token(url.Values{
+		"grant_type":    {"client_credentials"},
+		"client_id":     {resp.ClientID},
+		"client_secret": {"bogus"},
+	}, false)
+	testutil.TestResponseForOauthError(suite.T(), w, oauth.ErrorCodeInvalidClient, oauth.ErrInvalidClientIDOrSecret.Error(), 401)
+
+	// Clients without a policy may use either method
+	w = httptest.NewRecorder()
+	r, err := http.NewRequest("POST", "http://1.2.3.4/v1/oauth/tokens", nil)
+	assert.NoError(suite.T(), err, "Request setup should not get an error")
+	r.PostForm = url.Values{
+		"grant_type":    {"client_credentials"},
+		"client_id":     {"test_client_1"},
+		"client_secret": {"test_secret"},
+	}
+	suite.router.ServeHTTP(w, r)
+	assert.Equal(suite.T(), http.StatusOK, w.Code)
+}
+
+func (suite *OauthTestSuite) TestPrivateKeyJWT() {
+	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.NoError(suite.T(), err)
+
+	key, err := jwk.NewKey("partner-1", "RS256", privateKey.Public())
+	assert.NoError(suite.T(), err)
+
+	// Clients using private_key_jwt register their keys
+	_, err = suite.service.RegisterClient(&oauth.ClientRegistrationRequest{
+		ClientName:              "Partner Service",
+		GrantTypes:              []string{"client_credentials"},
+		TokenEndpointAuthMethod: oauth.TokenEndpointAuthMethodPrivateKeyJWT,
+	}, true)
+	assert.Equal(suite.T(), oauth.ErrInvalidClientMetadata, err)
+
+	// Published keys cannot be on internal addresses
+	for _, jwksURI := range []string{
+		"https://127.0.0.1/jwks.json",
+		"https://10.0.0.1/jwks.json",
+		"https://169.254.169.254/jwks.json",
+		"https://localhost/jwks.json",
+	} {
+		_, err = suite.service.RegisterClient(&oauth.ClientRegistrationRequest{
+			ClientName:              "Partner Service",
+			GrantTypes:              []string{"client_credentials"},
+			TokenEndpointAuthMethod: oauth.TokenEndpointAuthMethodPrivateKeyJWT,
+			JWKSURI:                 jwksURI,
+		}, true)
+		assert.Equal(suite.T(), oauth.ErrInvalidClientMetadata, err, jwksURI)
+	}
+
+	resp, err := suite.service.RegisterClient(&oauth.ClientRegistrationRequest{
+		ClientName:              "Partner Service",
+		GrantTypes:              []string{"client_credentials"},
+		TokenEndpointAuthMethod: oauth.TokenEndpointAuthMethodPrivateKeyJWT,
+		JWKS:                    &jwk.Set{Keys: []*jwk.Key{key}},
+	}, true)
+	assert.NoError(suite.T(), err)
+	assert.Empty(suite.T(), resp.ClientSecret)
+
+	assertion := func(aud, jti string) string {
+		token := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{
+			"iss": resp.ClientID,
+			"sub": resp.ClientID,
+			"aud": aud,
+			"jti": jti,
+			"exp": time.Now().Add(time.Minute).Unix(),
+		})
+		token.Header["kid"] = "partner-1"
+		signed, err := token.SignedString(privateKey)
+		assert.NoError(suite.T(), err)
+		return signed
+	}
+
+	token := func(clientAssertion string) *httptest.ResponseRecorder {
+		r, err := http.NewRequest("POST", "http://1.2.3.4/v1/oauth/tokens", nil)
+		assert.NoError(suite.T(), err, "Request setup should not get an error")
+		r.PostForm = url.Values{
+			"grant_type":            {"client_credentials"},
+			"client_assertion_type": {oauth.ClientAssertionTypeJWTBearer},
+			"client_assertion":      {clientAssertion},
+		}
+		w := httptest.NewRecorder()
+		suite.router.ServeHTTP(w, r)
+		return w
+	}
+
+	issuer := suite.service.GetIssuer()
+	jti := uuid.New().String()
+
+	w := token(assertion(issuer+"/v1/oauth/tokens", jti))
+	assert.Equal(suite.T(), http.StatusOK, w.Code)
+
+	// Assertions can only be used once
+	w = token(assertion(issuer+"/v1/oauth/tokens", jti))
+	testutil.TestResponseForOauthError(suite.T(), w, oauth.ErrorCodeInvalidClient, oauth.ErrInvalidClientIDOrSecret.Error(), 401)
+
+	// The assertion must be meant for this server
+	w = token(assertion("https://example.com", uuid.New().String()))
+	testutil.TestResponseForOauthError(suite.T(), w, oauth.ErrorCodeInvalidClient, oauth.ErrInvalidClientIDOrSecret.Error(), 401)
+
+	// The issuer identifier is accepted as audience too
+	w = token(assertion(issuer, uuid.New().String()))
+	assert.Equal(suite.T(), http.StatusOK, w.Code)
+
+	// Assertions signed by another key are rejected
+	otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.NoError(suite.T(), err)
+	forged := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{
+		"iss": resp.ClientID,
+		"sub": resp.ClientID,
+		"aud": issuer,
+		"jti": uuid.New().String(),
+		"exp": time.Now().Add(time.Minute).Unix(),
+	})
+	forged.Header["kid"] = "partner-1"
+	signed, err := forged.SignedString(otherKey)
+	assert.NoError(suite.T(), err)
+	w = token(signed)
+	testutil.TestResponseForOauthError(suite.T(), w, oauth.ErrorCodeInvalidClient, oauth.ErrInvalidClientIDOrSecret.Error(), 401)
+}
+
+func (suite *OauthTestSuite) TestPublicClientAuthentication() {
+	resp, err := suite.service.RegisterClient(&oauth.ClientRegistrationRequest{
+		ClientName:              "Player",
+		RedirectURIs:            []string{"coop.resonate.player:/callback"},
+		GrantTypes:              []string{"authorization_code", "refresh_token"},
+		TokenEndpointAuthMethod: oauth.TokenEndpointAuthMethodNone,
+	}, false)
+	assert.NoError(suite.T(), err)
+
+	// Public clients cannot introspect tokens
+	r, err := http.NewRequest("POST", "http://1.2.3.4/v1/oauth/introspect", nil)
+	assert.NoError(suite.T(), err, "Request setup should not get an error")
+	r.PostForm = url.Values{
+		"client_id": {resp.ClientID},
+		"token":     {"token"},
+	}
+	w := httptest.NewRecorder()
+	suite.router.ServeHTTP(w, r)
+	testutil.TestResponseForOauthError(suite.T(), w, oauth.ErrorCodeInvalidClient, oauth.ErrInvalidClientIDOrSecret.Error(), 401)
+
+	// Authorization codes are only redeemed with a code verifier
+	r, err = http.NewRequest("POST", "http://1.2.3.4/v1/oauth/tokens", nil)
+	assert.NoError(suite.T(), err, "Request setup should not get an error")
+	r.PostForm = url.Values{
+		"grant_type":   {"authorization_code"},
+		"client_id":    {resp.ClientID},
+		"code":         {"code"},
+		"redirect_uri": {"coop.resonate.player:/callback"},
+	}
+	w = httptest.NewRecorder()
+	suite.router.ServeHTTP(w, r)
+	testutil.TestResponseForOauthError(suite.T(), w, oauth.ErrorCodeInvalidClient, oauth.ErrInvalidClientIDOrSecret.Error(), 401)
+
+	// Clients without a policy cannot use refresh tokens without their secret
+	r, err = http.NewRequest("POST", "http://1.2.3.4/v1/oauth/tokens", nil)
+	assert.NoError(suite.T(), err, "Request setup should not get an error")
+	r.PostForm = url.Values{
+		"grant_type":    {"refresh_token"},
+		"client_id":     {"test_client_1"},
+		"refresh_token": {"test_token"},
+	}
+	w = httptest.NewRecorder()
+	suite.router.ServeHTTP(w, r)
+	testutil.TestResponseForOauthError(suite.T(), w, oauth.ErrorCodeInvalidClient, oauth.ErrInvalidClientIDOrSecret.Error(), 401)
+}
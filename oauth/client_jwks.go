@@ -0,0 +1,196 @@
+package oauth
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/resonatecoop/id/oauth/jwk"
+	"github.com/resonatecoop/user-api/model"
+)
+
+const (
+	// clientJWKSLifetime is how long key sets fetched from a jwks_uri are cached
+	clientJWKSLifetime = 5 * time.Minute
+	// clientJWKSErrorLifetime is how long a failed fetch is remembered, so
+	// assertions cannot make this server hammer the jwks_uri
+	clientJWKSErrorLifetime = 30 * time.Second
+	// clientJWKSTimeout is how long fetching a key set may take
+	clientJWKSTimeout = 5 * time.Second
+)
+
+var (
+	// ErrNonPublicAddress ...
+	ErrNonPublicAddress = errors.New("Address is not public")
+)
+
+var (
+	// nonPublicNetworks are the loopback, private, link-local and otherwise
+	// reserved networks client key sets cannot be fetched from
+	nonPublicNetworks = parseCIDRs(
+		"0.0.0.0/8",
+		"10.0.0.0/8",
+		"100.64.0.0/10",
+		"127.0.0.0/8",
+		"169.254.0.0/16",
+		"172.16.0.0/12",
+		"192.0.0.0/24",
+		"192.168.0.0/16",
+		"198.18.0.0/15",
+		"224.0.0.0/4",
+		"240.0.0.0/4",
+		"::/128",
+		"::1/128",
+		"fc00::/7",
+		"fe80::/10",
+		"ff00::/8",
+	)
+
+	// clientJWKSHTTPClient fetches client key sets from public addresses
+	// only, the address is checked when dialing so a hostname cannot be
+	// pointed at an internal address after registration
+	clientJWKSHTTPClient = &http.Client{
+		Timeout: clientJWKSTimeout,
+		Transport: &http.Transport{
+			DialContext: (&net.Dialer{
+				Timeout: clientJWKSTimeout,
+				Control: func(network, address string, c syscall.RawConn) error {
+					host, _, err := net.SplitHostPort(address)
+					if err != nil {
+						return err
+					}
+					if !isPublicIP(net.ParseIP(host)) {
+						return ErrNonPublicAddress
+					}
+					return nil
+				},
+			}).DialContext,
+			TLSHandshakeTimeout: clientJWKSTimeout,
+		},
+		// Redirects could lead anywhere, the jwks_uri must serve the keys
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+)
+
+// clientJWKSCache keeps the key sets fetched from the jwks_uri of clients
+type clientJWKSCache struct {
+	mu      sync.Mutex
+	entries map[uuid.UUID]*clientJWKSEntry
+}
+
+type clientJWKSEntry struct {
+	uri       string
+	set       *jwk.Set
+	err       error
+	expiresAt time.Time
+}
+
+// clientJWKS returns the registered keys of a client, fetching them from its
+// jwks_uri if they are not stored with the client metadata
+func (s *Service) clientJWKS(client *model.Client) (*jwk.Set, error) {
+	policy, err := s.clientPolicy(client)
+	if err != nil {
+		return nil, err
+	}
+	if policy == nil {
+		return nil, ErrInvalidClientAssertion
+	}
+
+	if policy.JWKS != nil {
+		return policy.JWKS, nil
+	}
+
+	if policy.JWKSURI != "" {
+		return s.fetchClientJWKS(client, policy.JWKSURI)
+	}
+
+	return nil, ErrInvalidClientAssertion
+}
+
+// fetchClientJWKS returns the key set published at the jwks_uri of a client,
+// from the cache if it was fetched recently
+func (s *Service) fetchClientJWKS(client *model.Client, uri string) (*jwk.Set, error) {
+	s.jwksCache.mu.Lock()
+	entry, ok := s.jwksCache.entries[client.ID]
+	s.jwksCache.mu.Unlock()
+
+	if ok && entry.uri == uri && time.Now().Before(entry.expiresAt) {
+		return entry.set, entry.err
+	}
+
+	entry = &clientJWKSEntry{uri: uri, expiresAt: time.Now().Add(clientJWKSLifetime)}
+	entry.set, entry.err = jwk.FetchWithClient(clientJWKSHTTPClient, uri)
+	if entry.err != nil {
+		entry.expiresAt = time.Now().Add(clientJWKSErrorLifetime)
+	}
+
+	s.jwksCache.mu.Lock()
+	s.jwksCache.entries[client.ID] = entry
+	s.jwksCache.mu.Unlock()
+
+	return entry.set, entry.err
+}
+
+// isPublicHost returns true if the hostname only resolves to public
+// addresses
+func isPublicHost(hostname string) bool {
+	hostname = strings.ToLower(strings.TrimSuffix(hostname, "."))
+	if hostname == "localhost" || strings.HasSuffix(hostname, ".localhost") {
+		return false
+	}
+
+	if ip := net.ParseIP(hostname); ip != nil {
+		return isPublicIP(ip)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), clientJWKSTimeout)
+	defer cancel()
+
+	addrs, err := net.DefaultResolver.LookupIPAddr(ctx, hostname)
+	if err != nil || len(addrs) == 0 {
+		return false
+	}
+
+	for _, addr := range addrs {
+		if !isPublicIP(addr.IP) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// isPublicIP returns true if the address is not in a non-public network
+func isPublicIP(ip net.IP) bool {
+	if ip == nil {
+		return false
+	}
+
+	for _, network := range nonPublicNetworks {
+		if network.Contains(ip) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func parseCIDRs(cidrs ...string) []*net.IPNet {
+	networks := make([]*net.IPNet, 0, len(cidrs))
+	for _, cidr := range cidrs {
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			panic(err)
+		}
+		networks = append(networks, network)
+	}
+	return networks
+}
@@ -0,0 +1,135 @@
+package oauth
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/resonatecoop/id/util"
+	"github.com/resonatecoop/user-api/model"
+)
+
+var (
+	// ErrUnauthorizedClient uses the error code of RFC 6749 as clients
+	// check for it
+	ErrUnauthorizedClient = errors.New("unauthorized_client")
+)
+
+// clientPolicy returns the registration metadata restricting what a client
+// may do, clients without metadata predate per-client policy and are not
+// restricted. Any other error must fail the request, the client's
+// restrictions are unknown.
+func (s *Service) clientPolicy(client *model.Client) (*ClientMetadata, error) {
+	if client == nil {
+		return nil, nil
+	}
+	metadata, err := s.FindClientMetadata(client)
+	if err == ErrClientNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return metadata, nil
+}
+
+// IsPublicClient returns true for clients registered without a secret
+func (s *Service) IsPublicClient(client *model.Client) (bool, error) {
+	policy, err := s.clientPolicy(client)
+	if err != nil {
+		return false, err
+	}
+	return policy != nil && policy.TokenEndpointAuthMethod == TokenEndpointAuthMethodNone, nil
+}
+
+// AuthorizeGrantType checks the client is allowed to use the grant type,
+// token exchange must be registered even by clients without a policy
+func (s *Service) AuthorizeGrantType(client *model.Client, grantType string) error {
+	policy, err := s.clientPolicy(client)
+	if err != nil {
+		return err
+	}
+	if policy == nil {
+		if grantType == TokenExchangeGrantType {
+			return ErrUnauthorizedClient
+		}
+		return nil
+	}
+
+	if !util.StringInSlice(grantType, policy.GrantTypes) {
+		return ErrUnauthorizedClient
+	}
+
+	// Public clients cannot authenticate, so they cannot act on their own
+	// behalf or with the user's password
+	if policy.TokenEndpointAuthMethod == TokenEndpointAuthMethodNone &&
+		(grantType == "client_credentials" || grantType == "password") {
+		return ErrUnauthorizedClient
+	}
+
+	return nil
+}
+
+// AuthorizeResponseType checks the client is allowed to use the response type
+func (s *Service) AuthorizeResponseType(client *model.Client, responseType string) error {
+	policy, err := s.clientPolicy(client)
+	if err != nil {
+		return err
+	}
+	if policy == nil {
+		return nil
+	}
+
+	if !util.StringInSlice(responseType, policy.ResponseTypes) {
+		return ErrUnauthorizedClient
+	}
+
+	return nil
+}
+
+// authorizeScope checks the scope is within the scope the client may request,
+// clients without a policy are not restricted
+func (s *Service) authorizeScope(client *model.Client, scope string) error {
+	policy, err := s.clientPolicy(client)
+	if err != nil {
+		return err
+	}
+	if policy == nil {
+		return nil
+	}
+
+	// Clients registered without a scope are limited to the default scopes
+	allowed := policy.Scope
+	if allowed == "" {
+		allowed = s.GetDefaultScope()
+	}
+
+	if !util.SpaceDelimitedStringNotGreater(scope, allowed) {
+		return ErrUnauthorizedClient
+	}
+
+	return nil
+}
+
+// allowedDefaultScope returns the default scopes the client may request
+func (s *Service) allowedDefaultScope(client *model.Client) (string, error) {
+	defaultScope := s.GetDefaultScope()
+
+	policy, err := s.clientPolicy(client)
+	if err != nil {
+		return "", err
+	}
+	if policy == nil || policy.Scope == "" {
+		return defaultScope, nil
+	}
+
+	allowed := strings.Split(policy.Scope, " ")
+
+	var scopes []string
+	for _, scope := range strings.Split(defaultScope, " ") {
+		if util.StringInSlice(scope, allowed) {
+			scopes = append(scopes, scope)
+		}
+	}
+
+	return strings.Join(scopes, " "), nil
+}
@@ -0,0 +1,119 @@
+package oauth_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+
+	"github.com/resonatecoop/id/oauth"
+	testutil "github.com/resonatecoop/id/test-util"
+	"github.com/stretchr/testify/assert"
+)
+
+func (suite *OauthTestSuite) TestClientPolicy() {
+	resp, err := suite.service.RegisterClient(&oauth.ClientRegistrationRequest{
+		ClientName:   "Partner App",
+		RedirectURIs: []string{"https://partner.example.com/callback"},
+		GrantTypes:   []string{"authorization_code", "client_credentials"},
+		Scope:        "read",
+	}, true)
+	assert.NoError(suite.T(), err)
+
+	client, err := suite.service.FindClientByClientID(resp.ClientID)
+	assert.NoError(suite.T(), err)
+
+	token := func(form url.Values) *httptest.ResponseRecorder {
+		r, err := http.NewRequest("POST", "http://1.2.3.4/v1/oauth/tokens", nil)
+		assert.NoError(suite.T(), err, "Request setup should not get an error")
+		r.SetBasicAuth(resp.ClientID, resp.ClientSecret)
+		r.PostForm = form
+		w := httptest.NewRecorder()
+		suite.router.ServeHTTP(w, r)
+		return w
+	}
+
+	// The password grant was not registered
+	w := token(url.Values{
+		"grant_type": {"password"},
+		"username":   {"test@user.com"},
+		"password":   {"test_password"},
+		"scope":      {"read"},
+	})
+	testutil.TestResponseForOauthError(suite.T(), w, oauth.ErrorCodeUnauthorizedClient, "", 400)
+
+	// The scope is outside the allowed scope
+	w = token(url.Values{
+		"grant_type": {"client_credentials"},
+		"scope":      {"read_write"},
+	})
+	testutil.TestResponseForOauthError(suite.T(), w, oauth.ErrorCodeUnauthorizedClient, "", 400)
+
+	w = token(url.Values{
+		"grant_type": {"client_credentials"},
+		"scope":      {"read"},
+	})
+	assert.Equal(suite.T(), http.StatusOK, w.Code)
+
+	// The default scope is limited to the allowed scope
+	scope, err := suite.service.GetScope(client, "")
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), "read", scope)
+
+	assert.NoError(suite.T(), suite.service.AuthorizeResponseType(client, "code"))
+	assert.Equal(suite.T(), oauth.ErrUnauthorizedClient, suite.service.AuthorizeResponseType(client, "token"))
+
+	// Confidential clients cannot fall back to the client ID only
+	public, err := suite.service.IsPublicClient(client)
+	assert.NoError(suite.T(), err)
+	assert.False(suite.T(), public)
+	r, err := http.NewRequest("POST", "http://1.2.3.4/v1/oauth/tokens", nil)
+	assert.NoError(suite.T(), err, "Request setup should not get an error")
+	r.PostForm = url.Values{
+		"grant_type":    {"authorization_code"},
+		"client_id":     {resp.ClientID},
+		"code":          {"code"},
+		"code_verifier": {"verifier"},
+	}
+	w = httptest.NewRecorder()
+	suite.router.ServeHTTP(w, r)
+	assert.Equal(suite.T(), http.StatusUnauthorized, w.Code)
+
+	// Clients registered without a scope get the default scopes
+	resp, err = suite.service.RegisterClient(&oauth.ClientRegistrationRequest{
+		ClientName: "Partner Service",
+		GrantTypes: []string{"client_credentials"},
+	}, true)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), suite.service.GetDefaultScope(), resp.Scope)
+
+	w = token(url.Values{
+		"grant_type": {"client_credentials"},
+		"scope":      {"read_write"},
+	})
+	testutil.TestResponseForOauthError(suite.T(), w, oauth.ErrorCodeUnauthorizedClient, "", 400)
+
+	// Clients without a policy are not restricted
+	assert.NoError(suite.T(), suite.service.AuthorizeGrantType(suite.clients[0], "password"))
+	public, err = suite.service.IsPublicClient(suite.clients[0])
+	assert.NoError(suite.T(), err)
+	assert.False(suite.T(), public)
+}
+
+func (suite *OauthTestSuite) TestPublicClientPolicy() {
+	resp, err := suite.service.RegisterClient(&oauth.ClientRegistrationRequest{
+		RedirectURIs:            []string{"coop.resonate.player:/callback"},
+		GrantTypes:              []string{"authorization_code", "client_credentials"},
+		TokenEndpointAuthMethod: oauth.TokenEndpointAuthMethodNone,
+	}, true)
+	assert.NoError(suite.T(), err)
+
+	client, err := suite.service.FindClientByClientID(resp.ClientID)
+	assert.NoError(suite.T(), err)
+
+	public, err := suite.service.IsPublicClient(client)
+	assert.NoError(suite.T(), err)
+	assert.True(suite.T(), public)
+	assert.NoError(suite.T(), suite.service.AuthorizeGrantType(client, "authorization_code"))
+	// Public clients cannot act on their own behalf
+	assert.Equal(suite.T(), oauth.ErrUnauthorizedClient, suite.service.AuthorizeGrantType(client, "client_credentials"))
+}
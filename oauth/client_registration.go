@@ -0,0 +1,690 @@
+package oauth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/resonatecoop/id/oauth/jwk"
+	"github.com/resonatecoop/id/util"
+	"github.com/resonatecoop/id/util/password"
+	"github.com/resonatecoop/user-api/model"
+	"github.com/uptrace/bun"
+)
+
+const (
+	// TokenEndpointAuthMethodBasic authenticates with the client secret using basic auth
+	TokenEndpointAuthMethodBasic = "client_secret_basic"
+	// TokenEndpointAuthMethodPost authenticates with the client secret in the form body
+	TokenEndpointAuthMethodPost = "client_secret_post"
+	// TokenEndpointAuthMethodPrivateKeyJWT authenticates with a JWT signed by a registered key
+	TokenEndpointAuthMethodPrivateKeyJWT = "private_key_jwt"
+	// TokenEndpointAuthMethodNone is used by public clients which cannot keep a secret
+	TokenEndpointAuthMethodNone = "none"
+)
+
+var (
+	// ErrInvalidClientMetadata ...
+	ErrInvalidClientMetadata = errors.New("Invalid client metadata")
+	// ErrInvalidClientURI ...
+	ErrInvalidClientURI = errors.New("Invalid client URI")
+	// ErrInvalidResponseType ...
+	ErrInvalidResponseType = errors.New("Invalid response type")
+	// ErrInvalidTokenEndpointAuthMethod ...
+	ErrInvalidTokenEndpointAuthMethod = errors.New("Invalid token endpoint auth method")
+	// ErrInvalidRegistrationToken ...
+	ErrInvalidRegistrationToken = errors.New("Invalid registration access token")
+)
+
+var (
+	// registrableGrantTypes are the grant types partner clients can register
+	// for
+	registrableGrantTypes = []string{
+		"authorization_code",
+		"implicit",
+		"refresh_token",
+		DeviceCodeGrantType,
+	}
+	// adminGrantTypes are the grant types only admins can register, they
+	// let a client act without a user's consent
+	adminGrantTypes = []string{
+		"password",
+		"client_credentials",
+		TokenExchangeGrantType,
+	}
+	// responseTypeGrantTypes maps response types to the grant type they need
+	responseTypeGrantTypes = map[string]string{
+		"code":  "authorization_code",
+		"token": "implicit",
+	}
+)
+
+// ClientMetadata stores the registration metadata of a client (RFC 7591),
+// clients created before dynamic registration have none
+type ClientMetadata struct {
+	bun.BaseModel `bun:"table:client_metadata"`
+
+	ClientID                uuid.UUID `bun:"type:uuid,pk"`
+	RedirectURIs            []string  `bun:",array"`
+	GrantTypes              []string  `bun:",array"`
+	ResponseTypes           []string  `bun:",array"`
+	TokenEndpointAuthMethod string    `bun:"type:varchar(40),notnull"`
+	Scope                   string    `bun:"type:varchar(200)"`
+	Contacts                []string  `bun:",array"`
+	// JWKS or JWKSURI hold the keys of private_key_jwt clients
+	JWKS    *jwk.Set `bun:"type:jsonb"`
+	JWKSURI string   `bun:"type:varchar(254)"`
+	// FirstParty clients are run by Resonate and skip the consent screen
+	FirstParty bool `bun:",notnull,default:false"`
+	// RequirePushedAuthorizationRequests clients send their authorization
+	// requests to the par endpoint first
+	RequirePushedAuthorizationRequests bool `bun:",notnull,default:false"`
+	// AccessTokenLifetime, RefreshTokenLifetime and AuthCodeLifetime are in
+	// seconds, zero is the server default
+	AccessTokenLifetime  int `bun:",notnull,default:0"`
+	RefreshTokenLifetime int `bun:",notnull,default:0"`
+	AuthCodeLifetime     int `bun:",notnull,default:0"`
+	// Claims are the user claims included in introspection responses, ID
+	// tokens and JWT access tokens
+	Claims []string `bun:",array"`
+	// RegistrationAccessToken is a SHA-256 hash of the token
+	RegistrationAccessToken string    `bun:"type:varchar(64),nullzero"`
+	CreatedAt               time.Time `bun:",nullzero,notnull,default:current_timestamp"`
+	UpdatedAt               time.Time `bun:",nullzero,notnull,default:current_timestamp"`
+}
+
+// ClientRegistrationRequest is the client metadata sent to the registration
+// endpoint
+type ClientRegistrationRequest struct {
+	ClientID                string   `json:"client_id,omitempty"`
+	ClientName              string   `json:"client_name"`
+	ClientURI               string   `json:"client_uri"`
+	RedirectURIs            []string `json:"redirect_uris"`
+	GrantTypes              []string `json:"grant_types"`
+	ResponseTypes           []string `json:"response_types"`
+	TokenEndpointAuthMethod string   `json:"token_endpoint_auth_method"`
+	Scope                   string   `json:"scope"`
+	Contacts                []string `json:"contacts"`
+	JWKS                    *jwk.Set `json:"jwks,omitempty"`
+	JWKSURI                 string   `json:"jwks_uri,omitempty"`
+	// FirstParty can only be set by admins
+	FirstParty bool `json:"first_party,omitempty"`
+	// RequirePushedAuthorizationRequests rejects authorization requests
+	// not pushed to the par endpoint first
+	RequirePushedAuthorizationRequests bool `json:"require_pushed_authorization_requests,omitempty"`
+	// AccessTokenLifetime, RefreshTokenLifetime and AuthCodeLifetime are in
+	// seconds, up to the server maximums
+	AccessTokenLifetime  int `json:"access_token_lifetime,omitempty"`
+	RefreshTokenLifetime int `json:"refresh_token_lifetime,omitempty"`
+	AuthCodeLifetime     int `json:"authorization_code_lifetime,omitempty"`
+	// Claims selects the user claims of introspection responses and tokens
+	Claims []string `json:"claims,omitempty"`
+}
+
+// RegisterClient creates a new client from the metadata and returns its
+// credentials, which are only shown once. Admins may register any grant type.
+func (s *Service) RegisterClient(req *ClientRegistrationRequest, admin bool) (*ClientRegistrationResponse, error) {
+	if err := s.validateClientRegistration(req, admin); err != nil {
+		return nil, err
+	}
+
+	secret, err := newClientSecret()
+	if err != nil {
+		return nil, err
+	}
+
+	registrationAccessToken, err := newClientSecret()
+	if err != nil {
+		return nil, err
+	}
+
+	secretHash, err := password.HashPassword(secret)
+	if err != nil {
+		return nil, err
+	}
+
+	client := &model.Client{
+		IDRecord: model.IDRecord{ID: uuid.New(), CreatedAt: time.Now().UTC()},
+		Key:      uuid.New().String(),
+		Secret:   string(secretHash),
+	}
+	setClientRegistration(client, req)
+
+	metadata := &ClientMetadata{
+		RegistrationAccessToken: hashRegistrationAccessToken(registrationAccessToken),
+		CreatedAt:               time.Now().UTC(),
+	}
+	setClientMetadata(metadata, req)
+
+	// Begin a transaction
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+
+	ctx := context.Background()
+
+	if _, err := tx.NewInsert().Model(client).Exec(ctx); err != nil {
+		tx.Rollback() // rollback the transaction
+		return nil, err
+	}
+
+	metadata.ClientID = client.ID
+
+	if _, err := tx.NewInsert().Model(metadata).Exec(ctx); err != nil {
+		tx.Rollback() // rollback the transaction
+		return nil, err
+	}
+
+	// Commit the transaction
+	if err = tx.Commit(); err != nil {
+		tx.Rollback() // rollback the transaction
+		return nil, err
+	}
+
+	resp := s.NewClientRegistrationResponse(client, metadata)
+	resp.RegistrationAccessToken = registrationAccessToken
+	// Only clients authenticating with their secret need to know it
+	switch metadata.TokenEndpointAuthMethod {
+	case TokenEndpointAuthMethodBasic, TokenEndpointAuthMethodPost:
+		resp.ClientSecret = secret
+	}
+
+	return resp, nil
+}
+
+// GetClientRegistration returns the registered metadata of a client
+func (s *Service) GetClientRegistration(client *model.Client) (*ClientRegistrationResponse, error) {
+	metadata, err := s.FindClientMetadata(client)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.NewClientRegistrationResponse(client, metadata), nil
+}
+
+// UpdateClientRegistration replaces the metadata of a client, the client ID
+// and credentials do not change. The metadata is also the client's policy of
+// allowed grant types, response types and scope.
+func (s *Service) UpdateClientRegistration(client *model.Client, req *ClientRegistrationRequest, admin bool) (*ClientRegistrationResponse, error) {
+	if req.ClientID != "" && !strings.EqualFold(req.ClientID, client.Key) {
+		return nil, ErrInvalidClientMetadata
+	}
+
+	if err := s.validateClientRegistration(req, admin); err != nil {
+		return nil, err
+	}
+
+	// Clients created before dynamic registration get their policy set by
+	// an admin
+	metadata, err := s.FindClientMetadata(client)
+	if err != nil && (err != ErrClientNotFound || !admin) {
+		return nil, err
+	}
+	isNew := err != nil
+	if isNew {
+		metadata = &ClientMetadata{ClientID: client.ID, CreatedAt: time.Now().UTC()}
+	}
+
+	setClientRegistration(client, req)
+	setClientMetadata(metadata, req)
+	metadata.UpdatedAt = time.Now().UTC()
+
+	// Begin a transaction
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+
+	ctx := context.Background()
+
+	_, err = tx.NewUpdate().
+		Model(client).
+		Column("redirect_uri", "application_name", "application_hostname", "application_url").
+		WherePK().
+		Exec(ctx)
+	if err != nil {
+		tx.Rollback() // rollback the transaction
+		return nil, err
+	}
+
+	if isNew {
+		_, err = tx.NewInsert().Model(metadata).Exec(ctx)
+	} else {
+		_, err = tx.NewUpdate().
+			Model(metadata).
+			ExcludeColumn("registration_access_token", "created_at").
+			WherePK().
+			Exec(ctx)
+	}
+	if err != nil {
+		tx.Rollback() // rollback the transaction
+		return nil, err
+	}
+
+	// Commit the transaction
+	if err = tx.Commit(); err != nil {
+		tx.Rollback() // rollback the transaction
+		return nil, err
+	}
+
+	return s.NewClientRegistrationResponse(client, metadata), nil
+}
+
+// DeleteClient deletes a client along with its tokens, codes, device
+// sessions and metadata
+func (s *Service) DeleteClient(client *model.Client) error {
+	// Begin a transaction
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+
+	// Rows keyed by the client's tokens, codes and device sessions are
+	// deleted before them
+	accessTokens := tx.NewSelect().
+		Model((*model.AccessToken)(nil)).
+		Column("token").
+		Where("client_id = ?", client.ID).
+		WhereAllWithDeleted()
+	refreshTokens := tx.NewSelect().
+		Model((*model.RefreshToken)(nil)).
+		Column("token").
+		Where("client_id = ?", client.ID).
+		WhereAllWithDeleted()
+	codes := tx.NewSelect().
+		Model((*model.AuthorizationCode)(nil)).
+		Column("code").
+		Where("client_id = ?", client.ID).
+		WhereAllWithDeleted()
+	deviceSessions := tx.NewSelect().
+		Model((*DeviceSession)(nil)).
+		Column("id").
+		Where("client_id = ?", client.ID)
+
+	for _, query := range []*bun.DeleteQuery{
+		tx.NewDelete().
+			Model((*TokenBinding)(nil)).
+			Where("token IN (?) OR token IN (?)", accessTokens, refreshTokens),
+		tx.NewDelete().
+			Model((*TokenExchange)(nil)).
+			Where("token IN (?)", accessTokens),
+		tx.NewDelete().
+			Model((*DeviceSessionToken)(nil)).
+			Where("device_session_id IN (?)", deviceSessions),
+		tx.NewDelete().
+			Model((*AuthorizationCodeChallenge)(nil)).
+			Where("code IN (?)", codes),
+		tx.NewDelete().
+			Model((*AuthorizationCodeNonce)(nil)).
+			Where("code IN (?)", codes),
+	} {
+		if _, err = query.Exec(ctx); err != nil {
+			tx.Rollback() // rollback the transaction
+			return err
+		}
+	}
+
+	for _, m := range []interface{}{
+		(*model.AccessToken)(nil),
+		(*model.RefreshToken)(nil),
+		(*model.AuthorizationCode)(nil),
+		(*DeviceSession)(nil),
+		(*DeviceCode)(nil),
+	} {
+		_, err = tx.NewDelete().
+			Model(m).
+			Where("client_id = ?", client.ID).
+			ForceDelete().
+			Exec(ctx)
+		if err != nil {
+			tx.Rollback() // rollback the transaction
+			return err
+		}
+	}
+
+	for _, m := range []interface{}{
+		(*RefreshTokenRotation)(nil),
+		(*PushedAuthorizationRequest)(nil),
+		(*ClientAssertion)(nil),
+		(*ClientMetadata)(nil),
+		(*ClientState)(nil),
+		(*Consent)(nil),
+	} {
+		_, err = tx.NewDelete().
+			Model(m).
+			Where("client_id = ?", client.ID).
+			Exec(ctx)
+		if err != nil {
+			tx.Rollback() // rollback the transaction
+			return err
+		}
+	}
+
+	_, err = tx.NewDelete().
+		Model(client).
+		WherePK().
+		ForceDelete().
+		Exec(ctx)
+	if err != nil {
+		tx.Rollback() // rollback the transaction
+		return err
+	}
+
+	// Commit the transaction
+	if err = tx.Commit(); err != nil {
+		tx.Rollback() // rollback the transaction
+		return err
+	}
+
+	return nil
+}
+
+// FindClientMetadata returns the registration metadata of a client,
+// ErrClientNotFound if it has none
+func (s *Service) FindClientMetadata(client *model.Client) (*ClientMetadata, error) {
+	metadata := new(ClientMetadata)
+	err := s.db.NewSelect().
+		Model(metadata).
+		Where("client_id = ?", client.ID).
+		Limit(1).
+		Scan(context.Background())
+	if err == sql.ErrNoRows {
+		return nil, ErrClientNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return metadata, nil
+}
+
+// AuthRegistrationAccessToken returns the client the registration access
+// token was issued for
+func (s *Service) AuthRegistrationAccessToken(clientID, token string) (*model.Client, error) {
+	client, err := s.FindClientByClientID(clientID)
+	if err != nil {
+		return nil, ErrInvalidRegistrationToken
+	}
+
+	metadata, err := s.FindClientMetadata(client)
+	if err == ErrClientNotFound {
+		return nil, ErrInvalidRegistrationToken
+	}
+	if err != nil {
+		return nil, err
+	}
+	if metadata.RegistrationAccessToken == "" {
+		return nil, ErrInvalidRegistrationToken
+	}
+
+	if subtle.ConstantTimeCompare([]byte(metadata.RegistrationAccessToken), []byte(hashRegistrationAccessToken(token))) != 1 {
+		return nil, ErrInvalidRegistrationToken
+	}
+
+	return client, nil
+}
+
+// isInitialAccessToken returns true if the token is one of the configured
+// initial access tokens allowed to register clients
+func (s *Service) isInitialAccessToken(token string) bool {
+	for _, initialAccessToken := range s.cnf.ClientRegistration.InitialAccessTokens {
+		if initialAccessToken != "" && subtle.ConstantTimeCompare([]byte(initialAccessToken), []byte(token)) == 1 {
+			return true
+		}
+	}
+	return false
+}
+
+// authRegistration authenticates a registration request with an initial
+// access token or the access token of an admin, it returns true for admins
+func (s *Service) authRegistration(r *http.Request) (bool, error) {
+	token, err := util.ParseBearerToken(r)
+	if err == nil && s.isInitialAccessToken(string(token)) {
+		return false, nil
+	}
+
+	if _, err := s.authAdmin(r); err != nil {
+		return false, ErrInvalidRegistrationToken
+	}
+
+	return true, nil
+}
+
+// validateClientRegistration checks the metadata and fills in defaults
+func (s *Service) validateClientRegistration(req *ClientRegistrationRequest, admin bool) error {
+	if len(req.GrantTypes) == 0 {
+		req.GrantTypes = []string{"authorization_code"}
+	}
+
+	if len(req.ResponseTypes) == 0 && util.StringInSlice("authorization_code", req.GrantTypes) {
+		req.ResponseTypes = []string{"code"}
+	}
+
+	if req.TokenEndpointAuthMethod == "" {
+		req.TokenEndpointAuthMethod = TokenEndpointAuthMethodBasic
+	}
+
+	for _, grantType := range req.GrantTypes {
+		if !util.StringInSlice(grantType, registrableGrantTypes) && !(admin && util.StringInSlice(grantType, adminGrantTypes)) {
+			return ErrInvalidGrantType
+		}
+	}
+
+	// Response types need their grant type
+	for _, responseType := range req.ResponseTypes {
+		grantType, ok := responseTypeGrantTypes[responseType]
+		if !ok || !util.StringInSlice(grantType, req.GrantTypes) {
+			return ErrInvalidResponseType
+		}
+	}
+
+	switch req.TokenEndpointAuthMethod {
+	case TokenEndpointAuthMethodBasic, TokenEndpointAuthMethodPost, TokenEndpointAuthMethodNone:
+	case TokenEndpointAuthMethodPrivateKeyJWT:
+		// The keys are either registered or published by the client
+		if (req.JWKS == nil) == (req.JWKSURI == "") {
+			return ErrInvalidClientMetadata
+		}
+	default:
+		return ErrInvalidTokenEndpointAuthMethod
+	}
+
+	if req.JWKS != nil {
+		if len(req.JWKS.Keys) == 0 {
+			return ErrInvalidClientMetadata
+		}
+		for _, key := range req.JWKS.Keys {
+			if _, err := key.PublicKey(); err != nil {
+				return ErrInvalidClientMetadata
+			}
+		}
+	}
+
+	if req.JWKSURI != "" {
+		u, err := url.Parse(req.JWKSURI)
+		if err != nil || u.Scheme != "https" || !isValidHostname(u.Hostname()) || len(req.JWKSURI) > 254 {
+			return ErrInvalidClientMetadata
+		}
+		// The keys are fetched by this server, so they cannot be internal
+		if !isPublicHost(u.Hostname()) {
+			return ErrInvalidClientMetadata
+		}
+	}
+
+	// Redirection based grants need somewhere to redirect to
+	redirects := util.StringInSlice("authorization_code", req.GrantTypes) || util.StringInSlice("implicit", req.GrantTypes)
+	if redirects && len(req.RedirectURIs) == 0 {
+		return ErrInvalidRedirectURI
+	}
+
+	for _, redirectURI := range req.RedirectURIs {
+		if err := ValidateRedirectURI(redirectURI); err != nil {
+			return err
+		}
+	}
+
+	if req.ClientURI != "" {
+		if _, err := clientURIHostname(req.ClientURI); err != nil {
+			return err
+		}
+	}
+
+	// Clients are limited to the default scopes unless they register more
+	if req.Scope == "" {
+		req.Scope = s.GetDefaultScope()
+	}
+
+	if req.Scope != "" && !s.ScopeExists(req.Scope) {
+		return ErrInvalidScope
+	}
+
+	if req.FirstParty && !admin {
+		return ErrInvalidClientMetadata
+	}
+
+	if err := s.validateTokenLifetimes(req); err != nil {
+		return err
+	}
+
+	if err := validateClaimNames(req.Claims); err != nil {
+		return err
+	}
+
+	if len(req.ClientName) > 200 || len(req.ClientURI) > 200 {
+		return ErrInvalidClientMetadata
+	}
+
+	return nil
+}
+
+// ValidateRedirectURI checks a redirect URI is absolute, has no fragment and
+// uses https. Plain http is only allowed on loopback addresses, and native
+// apps may use a private-use scheme such as coop.resonate.player:/callback.
+func ValidateRedirectURI(redirectURI string) error {
+	if len(redirectURI) > 200 {
+		return ErrInvalidRedirectURI
+	}
+
+	u, err := url.Parse(redirectURI)
+	if err != nil || !u.IsAbs() || u.Fragment != "" {
+		return ErrInvalidRedirectURI
+	}
+
+	switch u.Scheme {
+	case "https":
+		if !isValidHostname(u.Hostname()) {
+			return ErrInvalidRedirectURI
+		}
+	case "http":
+		if !isLoopback(u.Hostname()) {
+			return ErrInvalidRedirectURI
+		}
+	default:
+		// Private-use schemes are reverse domain names
+		if !strings.Contains(u.Scheme, ".") {
+			return ErrInvalidRedirectURI
+		}
+	}
+
+	return nil
+}
+
+// clientURIHostname returns the hostname of the client's home page, which
+// must be an https URL
+func clientURIHostname(clientURI string) (string, error) {
+	u, err := url.Parse(clientURI)
+	if err != nil || u.Scheme != "https" || !isValidHostname(u.Hostname()) {
+		return "", ErrInvalidClientURI
+	}
+	return strings.ToLower(u.Hostname()), nil
+}
+
+// isValidHostname returns true for DNS names made of letters, digits and hyphens
+func isValidHostname(hostname string) bool {
+	if hostname == "" || len(hostname) > 253 {
+		return false
+	}
+
+	for _, label := range strings.Split(hostname, ".") {
+		if label == "" || len(label) > 63 || strings.HasPrefix(label, "-") || strings.HasSuffix(label, "-") {
+			return false
+		}
+		for _, c := range label {
+			if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '-') {
+				return false
+			}
+		}
+	}
+
+	return true
+}
+
+// isLoopback returns true for localhost and loopback IP addresses
+func isLoopback(hostname string) bool {
+	if strings.EqualFold(hostname, "localhost") {
+		return true
+	}
+	ip := net.ParseIP(hostname)
+	return ip != nil && ip.IsLoopback()
+}
+
+// setClientRegistration copies the metadata stored on the client itself
+func setClientRegistration(client *model.Client, req *ClientRegistrationRequest) {
+	redirectURI := ""
+	if len(req.RedirectURIs) > 0 {
+		redirectURI = req.RedirectURIs[0]
+	}
+
+	hostname, _ := clientURIHostname(req.ClientURI)
+
+	client.RedirectURI = util.StringOrNull(redirectURI)
+	client.ApplicationName = util.StringOrNull(req.ClientName)
+	client.ApplicationHostname = util.StringOrNull(hostname)
+	client.ApplicationURL = util.StringOrNull(strings.ToLower(req.ClientURI))
+}
+
+// setClientMetadata copies the metadata stored alongside the client
+func setClientMetadata(metadata *ClientMetadata, req *ClientRegistrationRequest) {
+	metadata.RedirectURIs = req.RedirectURIs
+	metadata.GrantTypes = req.GrantTypes
+	metadata.ResponseTypes = req.ResponseTypes
+	metadata.TokenEndpointAuthMethod = req.TokenEndpointAuthMethod
+	metadata.Scope = req.Scope
+	metadata.Contacts = req.Contacts
+	metadata.JWKS = req.JWKS
+	metadata.JWKSURI = req.JWKSURI
+	metadata.FirstParty = req.FirstParty
+	metadata.RequirePushedAuthorizationRequests = req.RequirePushedAuthorizationRequests
+	metadata.AccessTokenLifetime = req.AccessTokenLifetime
+	metadata.RefreshTokenLifetime = req.RefreshTokenLifetime
+	metadata.AuthCodeLifetime = req.AuthCodeLifetime
+	metadata.Claims = req.Claims
+}
+
+// newClientSecret returns a random client secret or registration access token
+func newClientSecret() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// hashRegistrationAccessToken hashes a registration access token for storage
+func hashRegistrationAccessToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,206 @@
+package oauth_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/resonatecoop/id/oauth"
+	testutil "github.com/resonatecoop/id/test-util"
+	"github.com/stretchr/testify/assert"
+	"github.com/uptrace/bun"
+)
+
+func TestValidateRedirectURI(t *testing.T) {
+	for _, redirectURI := range []string{
+		"https://www.example.com/callback",
+		"http://127.0.0.1:8080/callback",
+		"http://[::1]/callback",
+		"http://localhost:3000/callback",
+		"coop.resonate.player:/callback",
+	} {
+		assert.NoError(t, oauth.ValidateRedirectURI(redirectURI), redirectURI)
+	}
+
+	for _, redirectURI := range []string{
+		"",
+		"/callback",
+		"http://www.example.com/callback",
+		"https://www.example.com/callback#fragment",
+		"https://exa_mple.com/callback",
+		"player:/callback",
+		"javascript:alert(1)",
+	} {
+		assert.Equal(t, oauth.ErrInvalidRedirectURI, oauth.ValidateRedirectURI(redirectURI), redirectURI)
+	}
+}
+
+func (suite *OauthTestSuite) TestRegisterClient() {
+	suite.cnf.ClientRegistration.InitialAccessTokens = []string{"initial_access_token"}
+	defer func() {
+		suite.cnf.ClientRegistration.InitialAccessTokens = []string{}
+	}()
+
+	register := func(token string, req *oauth.ClientRegistrationRequest) *httptest.ResponseRecorder {
+		body, err := json.Marshal(req)
+		assert.NoError(suite.T(), err)
+		r, err := http.NewRequest("POST", "http://1.2.3.4/v1/oauth/register", bytes.NewReader(body))
+		assert.NoError(suite.T(), err, "Request setup should not get an error")
+		r.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		suite.router.ServeHTTP(w, r)
+		return w
+	}
+
+	req := &oauth.ClientRegistrationRequest{
+		ClientName:   "Partner App",
+		ClientURI:    "https://partner.example.com",
+		RedirectURIs: []string{"https://partner.example.com/callback"},
+	}
+
+	// An initial access token is required
+	w := register("bogus", req)
+	assert.Equal(suite.T(), http.StatusUnauthorized, w.Code)
+
+	// Grants acting without the user's consent are for admins only
+	for _, grantType := range []string{"password", "client_credentials", oauth.TokenExchangeGrantType} {
+		w = register("initial_access_token", &oauth.ClientRegistrationRequest{
+			RedirectURIs: []string{"https://partner.example.com/callback"},
+			GrantTypes:   []string{grantType},
+		})
+		testutil.TestResponseForOauthError(suite.T(), w, oauth.ErrorCodeInvalidClientMetadata, oauth.ErrInvalidGrantType.Error(), 400)
+	}
+
+	// Plain http redirects are only allowed on loopback addresses
+	w = register("initial_access_token", &oauth.ClientRegistrationRequest{
+		RedirectURIs: []string{"http://partner.example.com/callback"},
+	})
+	testutil.TestResponseForOauthError(suite.T(), w, oauth.ErrorCodeInvalidRedirectURI, oauth.ErrInvalidRedirectURI.Error(), 400)
+
+	w = register("initial_access_token", req)
+	assert.Equal(suite.T(), http.StatusCreated, w.Code)
+
+	resp := new(oauth.ClientRegistrationResponse)
+	assert.NoError(suite.T(), json.Unmarshal(w.Body.Bytes(), resp))
+	assert.NotEmpty(suite.T(), resp.ClientID)
+	assert.NotEmpty(suite.T(), resp.ClientSecret)
+	assert.NotEmpty(suite.T(), resp.RegistrationAccessToken)
+	assert.Equal(suite.T(), []string{"authorization_code"}, resp.GrantTypes)
+	assert.Equal(suite.T(), []string{"code"}, resp.ResponseTypes)
+	assert.Equal(suite.T(), oauth.TokenEndpointAuthMethodBasic, resp.TokenEndpointAuthMethod)
+	assert.Equal(suite.T(), "https://"+suite.cnf.Hostname+"/v1/oauth/register/"+resp.ClientID, resp.RegistrationClientURI)
+
+	// The new client can authenticate with its secret
+	client, err := suite.service.AuthClient(resp.ClientID, resp.ClientSecret)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), "partner.example.com", client.ApplicationHostname.String)
+}
+
+func (suite *OauthTestSuite) TestClientConfiguration() {
+	resp, err := suite.service.RegisterClient(&oauth.ClientRegistrationRequest{
+		ClientName:              "Partner Player",
+		RedirectURIs:            []string{"coop.resonate.player:/callback"},
+		TokenEndpointAuthMethod: oauth.TokenEndpointAuthMethodNone,
+	}, false)
+	assert.NoError(suite.T(), err)
+	// Public clients get no secret
+	assert.Empty(suite.T(), resp.ClientSecret)
+
+	configure := func(method, token string, req *oauth.ClientRegistrationRequest) *httptest.ResponseRecorder {
+		body, err := json.Marshal(req)
+		assert.NoError(suite.T(), err)
+		r, err := http.NewRequest(method, resp.RegistrationClientURI, bytes.NewReader(body))
+		assert.NoError(suite.T(), err, "Request setup should not get an error")
+		r.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		suite.router.ServeHTTP(w, r)
+		return w
+	}
+
+	// Read
+	w := configure("GET", "bogus", nil)
+	assert.Equal(suite.T(), http.StatusUnauthorized, w.Code)
+
+	w = configure("GET", resp.RegistrationAccessToken, nil)
+	assert.Equal(suite.T(), http.StatusOK, w.Code)
+
+	read := new(oauth.ClientRegistrationResponse)
+	assert.NoError(suite.T(), json.Unmarshal(w.Body.Bytes(), read))
+	assert.Equal(suite.T(), resp.ClientID, read.ClientID)
+	assert.Empty(suite.T(), read.RegistrationAccessToken)
+	assert.Equal(suite.T(), []string{"coop.resonate.player:/callback"}, read.RedirectURIs)
+
+	// Update
+	w = configure("PUT", resp.RegistrationAccessToken, &oauth.ClientRegistrationRequest{
+		ClientID:                resp.ClientID,
+		ClientName:              "Partner Player 2",
+		RedirectURIs:            []string{"coop.resonate.player:/callback", "http://127.0.0.1/callback"},
+		TokenEndpointAuthMethod: oauth.TokenEndpointAuthMethodNone,
+	})
+	assert.Equal(suite.T(), http.StatusOK, w.Code)
+
+	updated := new(oauth.ClientRegistrationResponse)
+	assert.NoError(suite.T(), json.Unmarshal(w.Body.Bytes(), updated))
+	assert.Equal(suite.T(), "Partner Player 2", updated.ClientName)
+	assert.Len(suite.T(), updated.RedirectURIs, 2)
+
+	// Delete
+	w = configure("DELETE", resp.RegistrationAccessToken, nil)
+	assert.Equal(suite.T(), http.StatusNoContent, w.Code)
+
+	_, err = suite.service.FindClientByClientID(resp.ClientID)
+	assert.Equal(suite.T(), oauth.ErrClientNotFound, err)
+}
+
+func (suite *OauthTestSuite) TestDeleteClient() {
+	resp, err := suite.service.RegisterClient(&oauth.ClientRegistrationRequest{
+		ClientName:   "Partner Player",
+		RedirectURIs: []string{"https://partner.example.com/callback"},
+		Scope:        "read",
+	}, false)
+	assert.NoError(suite.T(), err)
+
+	client, err := suite.service.FindClientByClientID(resp.ClientID)
+	assert.NoError(suite.T(), err)
+
+	accessToken, refreshToken, err := suite.service.Login(client, suite.users[0], "read", suite.service.NewDeviceSession(client, suite.users[0], nil))
+	assert.NoError(suite.T(), err)
+
+	authorizationCode, err := suite.service.GrantAuthorizationCode(
+		client,                                 // client
+		suite.users[0],                         // user
+		3600,                                   // expires in
+		"https://partner.example.com/callback", // redirect URI
+		"read",                                 // scope
+		testCodeChallenge,                      // code challenge
+		"S256",                                 // code challenge method
+		"nonce",                                // nonce
+	)
+	assert.NoError(suite.T(), err)
+
+	assert.NoError(suite.T(), suite.service.DeleteClient(client))
+
+	// Nothing keyed by the client's tokens and codes is left behind
+	ctx := context.Background()
+	for _, query := range []*bun.SelectQuery{
+		suite.db.NewSelect().
+			Model((*oauth.DeviceSessionToken)(nil)).
+			Where("token IN (?)", bun.In([]string{accessToken.Token, refreshToken.Token})),
+		suite.db.NewSelect().
+			Model((*oauth.AuthorizationCodeChallenge)(nil)).
+			Where("code = ?", authorizationCode.Code),
+		suite.db.NewSelect().
+			Model((*oauth.AuthorizationCodeNonce)(nil)).
+			Where("code = ?", authorizationCode.Code),
+		suite.db.NewSelect().
+			Model((*oauth.ClientMetadata)(nil)).
+			Where("client_id = ?", client.ID),
+	} {
+		count, err := query.Count(ctx)
+		assert.NoError(suite.T(), err)
+		assert.Equal(suite.T(), 0, count)
+	}
+}
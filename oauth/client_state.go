@@ -0,0 +1,159 @@
+package oauth
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/resonatecoop/id/util/password"
+	"github.com/resonatecoop/user-api/model"
+	"github.com/uptrace/bun"
+)
+
+var (
+	// ErrClientDisabled ...
+	ErrClientDisabled = errors.New("Client disabled")
+)
+
+// ClientState stores the lifecycle of a client managed by admins, clients
+// without a state are enabled and have a single secret
+type ClientState struct {
+	bun.BaseModel `bun:"table:client_states"`
+
+	ClientID uuid.UUID `bun:"type:uuid,pk"`
+	Disabled bool      `bun:",notnull,default:false"`
+	// PreviousSecret is the hash of the secret replaced by the last rotation,
+	// it stays valid until PreviousSecretExpiresAt
+	PreviousSecret          string    `bun:"type:varchar(60),nullzero"`
+	PreviousSecretExpiresAt time.Time `bun:",nullzero"`
+	UpdatedAt               time.Time `bun:",nullzero,notnull,default:current_timestamp"`
+}
+
+// FindClientState returns the lifecycle state of a client, clients without
+// a stored state get the default state
+func (s *Service) FindClientState(client *model.Client) (*ClientState, error) {
+	state := new(ClientState)
+	err := s.db.NewSelect().
+		Model(state).
+		Where("client_id = ?", client.ID).
+		Limit(1).
+		Scan(context.Background())
+	if err == sql.ErrNoRows {
+		return &ClientState{ClientID: client.ID}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return state, nil
+}
+
+// CheckClientEnabled returns ErrClientDisabled if an admin disabled the
+// client, the state must be found for the client to be enabled
+func (s *Service) CheckClientEnabled(client *model.Client) error {
+	state, err := s.FindClientState(client)
+	if err != nil {
+		return err
+	}
+
+	if state.Disabled {
+		return ErrClientDisabled
+	}
+
+	return nil
+}
+
+// SetClientDisabled disables or enables a client, disabled clients cannot
+// authenticate and their tokens are no longer valid
+func (s *Service) SetClientDisabled(client *model.Client, disabled bool) error {
+	state, err := s.FindClientState(client)
+	if err != nil {
+		return err
+	}
+	state.Disabled = disabled
+	state.UpdatedAt = time.Now().UTC()
+
+	_, err = s.db.NewInsert().
+		Model(state).
+		On("CONFLICT (client_id) DO UPDATE").
+		Set("disabled = EXCLUDED.disabled").
+		Set("updated_at = EXCLUDED.updated_at").
+		Exec(context.Background())
+
+	return err
+}
+
+// RotateClientSecret replaces the client secret and returns the new one,
+// which is only shown once. The previous secret stays valid for the
+// configured grace period so the client can be redeployed.
+func (s *Service) RotateClientSecret(client *model.Client) (string, error) {
+	secret, err := newClientSecret()
+	if err != nil {
+		return "", err
+	}
+
+	secretHash, err := password.HashPassword(secret)
+	if err != nil {
+		return "", err
+	}
+
+	state, err := s.FindClientState(client)
+	if err != nil {
+		return "", err
+	}
+
+	now := time.Now().UTC()
+	state.PreviousSecret = client.Secret
+	state.PreviousSecretExpiresAt = now.Add(time.Duration(s.cnf.Oauth.ClientSecretGracePeriod) * time.Second)
+	state.UpdatedAt = now
+
+	// Begin a transaction
+	tx, err := s.db.Begin()
+	if err != nil {
+		return "", err
+	}
+
+	ctx := context.Background()
+
+	_, err = tx.NewUpdate().
+		Model((*model.Client)(nil)).
+		Set("secret = ?", string(secretHash)).
+		Where("id = ?", client.ID).
+		Exec(ctx)
+	if err != nil {
+		tx.Rollback() // rollback the transaction
+		return "", err
+	}
+
+	_, err = tx.NewInsert().
+		Model(state).
+		On("CONFLICT (client_id) DO UPDATE").
+		Set("previous_secret = EXCLUDED.previous_secret").
+		Set("previous_secret_expires_at = EXCLUDED.previous_secret_expires_at").
+		Set("updated_at = EXCLUDED.updated_at").
+		Exec(ctx)
+	if err != nil {
+		tx.Rollback() // rollback the transaction
+		return "", err
+	}
+
+	// Commit the transaction
+	if err = tx.Commit(); err != nil {
+		tx.Rollback() // rollback the transaction
+		return "", err
+	}
+
+	client.Secret = string(secretHash)
+
+	return secret, nil
+}
+
+// verifyPreviousSecret returns true if the secret is the previous secret of
+// the client and its grace period has not ended yet
+func (state *ClientState) verifyPreviousSecret(secret string) bool {
+	if state.PreviousSecret == "" || time.Now().After(state.PreviousSecretExpiresAt) {
+		return false
+	}
+	return password.VerifyPassword(state.PreviousSecret, secret) == nil
+}
@@ -0,0 +1,224 @@
+package oauth
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/resonatecoop/id/util"
+	"github.com/resonatecoop/user-api/model"
+	"github.com/uptrace/bun"
+)
+
+// ConnectedApp is a client holding tokens or consent for a user
+type ConnectedApp struct {
+	Client            *model.Client
+	Scope             string
+	FirstAuthorizedAt time.Time
+	LastAuthorizedAt  time.Time
+}
+
+// FindConnectedApps returns the clients holding valid tokens or consent for
+// the user, most recently authorized first
+func (s *Service) FindConnectedApps(user *model.User) ([]*ConnectedApp, error) {
+	ctx := context.Background()
+	now := time.Now().UTC()
+
+	var accessTokens []*model.AccessToken
+	err := s.db.NewSelect().
+		Model(&accessTokens).
+		Where("user_id = ?", user.ID).
+		Where("expires_at > ?", now).
+		Scan(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var refreshTokens []*model.RefreshToken
+	err = s.db.NewSelect().
+		Model(&refreshTokens).
+		Where("user_id = ?", user.ID).
+		Where("expires_at > ?", now).
+		Scan(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var consents []*Consent
+	err = s.db.NewSelect().
+		Model(&consents).
+		Where("user_id = ?", user.ID).
+		Scan(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	apps := make(map[uuid.UUID]*ConnectedApp)
+
+	add := func(clientID uuid.UUID, scope string, createdAt, updatedAt time.Time) {
+		app, ok := apps[clientID]
+		if !ok {
+			app = &ConnectedApp{FirstAuthorizedAt: createdAt, LastAuthorizedAt: updatedAt}
+			apps[clientID] = app
+		}
+		if createdAt.Before(app.FirstAuthorizedAt) {
+			app.FirstAuthorizedAt = createdAt
+		}
+		if updatedAt.After(app.LastAuthorizedAt) {
+			app.LastAuthorizedAt = updatedAt
+		}
+		for _, scope := range strings.Fields(scope) {
+			if !util.StringInSlice(scope, strings.Fields(app.Scope)) {
+				app.Scope = strings.TrimSpace(app.Scope + " " + scope)
+			}
+		}
+	}
+
+	for _, accessToken := range accessTokens {
+		add(accessToken.ClientID, accessToken.Scope, accessToken.CreatedAt, accessToken.CreatedAt)
+	}
+	for _, refreshToken := range refreshTokens {
+		add(refreshToken.ClientID, refreshToken.Scope, refreshToken.CreatedAt, refreshToken.CreatedAt)
+	}
+	for _, consent := range consents {
+		add(consent.ClientID, consent.Scope, consent.CreatedAt, consent.UpdatedAt)
+	}
+
+	if len(apps) == 0 {
+		return []*ConnectedApp{}, nil
+	}
+
+	clientIDs := make([]uuid.UUID, 0, len(apps))
+	for clientID := range apps {
+		clientIDs = append(clientIDs, clientID)
+	}
+
+	var clients []*model.Client
+	err = s.db.NewSelect().
+		Model(&clients).
+		Where("id IN (?)", bun.In(clientIDs)).
+		Scan(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	connectedApps := make([]*ConnectedApp, 0, len(clients))
+	for _, client := range clients {
+		app := apps[client.ID]
+		app.Client = client
+		connectedApps = append(connectedApps, app)
+	}
+
+	sort.Slice(connectedApps, func(i, j int) bool {
+		return connectedApps[i].LastAuthorizedAt.After(connectedApps[j].LastAuthorizedAt)
+	})
+
+	return connectedApps, nil
+}
+
+// RevokeConnectedApp deletes the access and refresh tokens the client holds
+// for the user, along with the authorization and device codes not redeemed
+// yet, and forgets the user's consent, so the client has to ask again
+func (s *Service) RevokeConnectedApp(user *model.User, client *model.Client) error {
+	ctx := context.Background()
+
+	var deviceSessionIDs []uuid.UUID
+	err := s.db.NewSelect().
+		Model((*DeviceSession)(nil)).
+		Column("id").
+		Where("user_id = ?", user.ID).
+		Where("client_id = ?", client.ID).
+		Scan(ctx, &deviceSessionIDs)
+	if err != nil {
+		return err
+	}
+
+	// Begin a transaction
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+
+	for _, deviceSessionID := range deviceSessionIDs {
+		if err := revokeDeviceSession(tx, deviceSessionID); err != nil {
+			tx.Rollback() // rollback the transaction
+			return err
+		}
+	}
+
+	// Tokens granted before device sessions existed, or without one
+	for _, m := range []interface{}{
+		(*model.RefreshToken)(nil),
+		(*model.AccessToken)(nil),
+	} {
+		_, err = tx.NewDelete().
+			Model(m).
+			Where("user_id = ?", user.ID).
+			Where("client_id = ?", client.ID).
+			ForceDelete().
+			Exec(ctx)
+		if err != nil {
+			tx.Rollback() // rollback the transaction
+			return err
+		}
+	}
+
+	// Authorization codes not redeemed yet, with their code challenges and
+	// nonces
+	codes := tx.NewSelect().
+		Model((*model.AuthorizationCode)(nil)).
+		Column("code").
+		Where("user_id = ?", user.ID).
+		Where("client_id = ?", client.ID)
+
+	for _, m := range []interface{}{
+		(*AuthorizationCodeChallenge)(nil),
+		(*AuthorizationCodeNonce)(nil),
+	} {
+		_, err = tx.NewDelete().
+			Model(m).
+			Where("code IN (?)", codes).
+			Exec(ctx)
+		if err != nil {
+			tx.Rollback() // rollback the transaction
+			return err
+		}
+	}
+
+	_, err = tx.NewDelete().
+		Model((*model.AuthorizationCode)(nil)).
+		Where("user_id = ?", user.ID).
+		Where("client_id = ?", client.ID).
+		ForceDelete().
+		Exec(ctx)
+	if err != nil {
+		tx.Rollback() // rollback the transaction
+		return err
+	}
+
+	// Device codes the user approved which were not redeemed yet
+	_, err = tx.NewDelete().
+		Model((*DeviceCode)(nil)).
+		Where("user_id = ?", user.ID).
+		Where("client_id = ?", client.ID).
+		Exec(ctx)
+	if err != nil {
+		tx.Rollback() // rollback the transaction
+		return err
+	}
+
+	_, err = tx.NewDelete().
+		Model((*Consent)(nil)).
+		Where("user_id = ?", user.ID).
+		Where("client_id = ?", client.ID).
+		Exec(ctx)
+	if err != nil {
+		tx.Rollback() // rollback the transaction
+		return err
+	}
+
+	// Commit the transaction
+	return tx.Commit()
+}
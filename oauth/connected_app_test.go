@@ -0,0 +1,103 @@
+package oauth_test
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/resonatecoop/id/oauth"
+	"github.com/resonatecoop/user-api/model"
+	"github.com/stretchr/testify/assert"
+)
+
+func (suite *OauthTestSuite) TestConnectedApps() {
+	user := suite.users[0]
+
+	apps, err := suite.service.FindConnectedApps(user)
+	assert.NoError(suite.T(), err)
+	assert.Empty(suite.T(), apps)
+
+	r, err := http.NewRequest("POST", "http://1.2.3.4/v1/oauth/tokens", nil)
+	assert.NoError(suite.T(), err, "Request setup should not get an error")
+	deviceSession := suite.service.NewDeviceSession(suite.clients[0], user, r)
+
+	_, refreshToken, err := suite.service.Login(suite.clients[0], user, "read_write", deviceSession)
+	assert.NoError(suite.T(), err)
+	assert.NoError(suite.T(), suite.service.GrantConsent(user, suite.clients[1], "read"))
+
+	// Codes issued to the client but not redeemed yet
+	authorizationCode, err := suite.service.GrantAuthorizationCode(
+		suite.clients[0],
+		user,
+		3600,
+		"https://www.example.com",
+		"read_write",
+		"E9Melhoa2OwvFrEMTJguCHaoeK1t8URWbuGJSstw-cM",
+		oauth.CodeChallengeMethodS256,
+		"nonce",
+	)
+	assert.NoError(suite.T(), err)
+
+	deviceCode, err := suite.service.GrantDeviceCode(suite.clients[0], "read_write")
+	assert.NoError(suite.T(), err)
+	assert.NoError(suite.T(), suite.service.ApproveDeviceCode(deviceCode, user))
+
+	apps, err = suite.service.FindConnectedApps(user)
+	assert.NoError(suite.T(), err)
+	if assert.Len(suite.T(), apps, 2) {
+		// Most recently authorized first
+		assert.Equal(suite.T(), suite.clients[1].ID, apps[0].Client.ID)
+		assert.Equal(suite.T(), "read", apps[0].Scope)
+		assert.Equal(suite.T(), suite.clients[0].ID, apps[1].Client.ID)
+		assert.Equal(suite.T(), "read_write", apps[1].Scope)
+	}
+
+	assert.NoError(suite.T(), suite.service.RevokeConnectedApp(user, suite.clients[0]))
+
+	// The tokens and device session are gone
+	_, err = suite.service.FindUserDeviceSession(user, deviceSession.ID.String())
+	assert.Error(suite.T(), err)
+	_, err = suite.service.GetValidRefreshToken(refreshToken.Token, suite.clients[0])
+	assert.Error(suite.T(), err)
+
+	count, err := suite.db.NewSelect().
+		Model((*model.AccessToken)(nil)).
+		Where("user_id = ?", user.ID).
+		Where("client_id = ?", suite.clients[0].ID).
+		Count(context.Background())
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), 0, count)
+
+	// So are the codes not redeemed yet
+	for _, m := range []interface{}{
+		(*oauth.AuthorizationCodeChallenge)(nil),
+		(*oauth.AuthorizationCodeNonce)(nil),
+	} {
+		count, err = suite.db.NewSelect().
+			Model(m).
+			Where("code = ?", authorizationCode.Code).
+			Count(context.Background())
+		assert.NoError(suite.T(), err)
+		assert.Equal(suite.T(), 0, count)
+	}
+
+	count, err = suite.db.NewSelect().
+		Model((*model.AuthorizationCode)(nil)).
+		WhereAllWithDeleted().
+		Where("code = ?", authorizationCode.Code).
+		Count(context.Background())
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), 0, count)
+
+	count, err = suite.db.NewSelect().
+		Model((*oauth.DeviceCode)(nil)).
+		Where("code = ?", deviceCode.Code).
+		Count(context.Background())
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), 0, count)
+
+	apps, err = suite.service.FindConnectedApps(user)
+	assert.NoError(suite.T(), err)
+	if assert.Len(suite.T(), apps, 1) {
+		assert.Equal(suite.T(), suite.clients[1].ID, apps[0].Client.ID)
+	}
+}
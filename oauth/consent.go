@@ -0,0 +1,113 @@
+package oauth
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/resonatecoop/id/util"
+	"github.com/resonatecoop/user-api/model"
+	"github.com/uptrace/bun"
+)
+
+var (
+	// ErrConsentNotFound ...
+	ErrConsentNotFound = errors.New("Consent not found")
+)
+
+// Consent stores the scopes a user allowed a client, so the consent screen
+// is only shown again for newly requested scopes
+type Consent struct {
+	bun.BaseModel `bun:"table:consents"`
+
+	UserID    uuid.UUID `bun:"type:uuid,pk"`
+	ClientID  uuid.UUID `bun:"type:uuid,pk"`
+	Scope     string    `bun:"type:varchar(200),notnull"`
+	CreatedAt time.Time `bun:",nullzero,notnull,default:current_timestamp"`
+	UpdatedAt time.Time `bun:",nullzero,notnull,default:current_timestamp"`
+}
+
+// FindConsent returns the scopes the user allowed the client
+func (s *Service) FindConsent(user *model.User, client *model.Client) (*Consent, error) {
+	consent := new(Consent)
+	err := s.db.NewSelect().
+		Model(consent).
+		Where("user_id = ?", user.ID).
+		Where("client_id = ?", client.ID).
+		Limit(1).
+		Scan(context.Background())
+	if err != nil {
+		return nil, ErrConsentNotFound
+	}
+	return consent, nil
+}
+
+// GrantConsent remembers the user allowed the client the scope, on top of
+// any scope allowed before
+func (s *Service) GrantConsent(user *model.User, client *model.Client, scope string) error {
+	scopes := strings.Fields(scope)
+	if consent, err := s.FindConsent(user, client); err == nil {
+		for _, granted := range strings.Fields(consent.Scope) {
+			if !util.StringInSlice(granted, scopes) {
+				scopes = append(scopes, granted)
+			}
+		}
+	}
+	sort.Strings(scopes)
+
+	now := time.Now().UTC()
+	consent := &Consent{
+		UserID:    user.ID,
+		ClientID:  client.ID,
+		Scope:     strings.Join(scopes, " "),
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+
+	_, err := s.db.NewInsert().
+		Model(consent).
+		On("CONFLICT (user_id, client_id) DO UPDATE").
+		Set("scope = EXCLUDED.scope").
+		Set("updated_at = EXCLUDED.updated_at").
+		Exec(context.Background())
+
+	return err
+}
+
+// ScopesNeedingConsent returns the requested scopes the user has not allowed
+// the client yet, first-party clients never need consent
+func (s *Service) ScopesNeedingConsent(user *model.User, client *model.Client, scope string) ([]string, error) {
+	firstParty, err := s.IsFirstPartyClient(client)
+	if err != nil {
+		return nil, err
+	}
+	if firstParty {
+		return nil, nil
+	}
+
+	var granted []string
+	if consent, err := s.FindConsent(user, client); err == nil {
+		granted = strings.Fields(consent.Scope)
+	}
+
+	var scopes []string
+	for _, requested := range strings.Fields(scope) {
+		if !util.StringInSlice(requested, granted) {
+			scopes = append(scopes, requested)
+		}
+	}
+
+	return scopes, nil
+}
+
+// IsFirstPartyClient returns true for clients flagged as run by Resonate
+func (s *Service) IsFirstPartyClient(client *model.Client) (bool, error) {
+	policy, err := s.clientPolicy(client)
+	if err != nil {
+		return false, err
+	}
+	return policy != nil && policy.FirstParty, nil
+}
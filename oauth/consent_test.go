@@ -0,0 +1,71 @@
+package oauth_test
+
+import (
+	"github.com/resonatecoop/id/oauth"
+	"github.com/stretchr/testify/assert"
+)
+
+func (suite *OauthTestSuite) TestGrantConsent() {
+	user, client := suite.users[0], suite.clients[0]
+
+	_, err := suite.service.FindConsent(user, client)
+	assert.Equal(suite.T(), oauth.ErrConsentNotFound, err)
+
+	scopes, err := suite.service.ScopesNeedingConsent(user, client, "read read_write")
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), []string{"read", "read_write"}, scopes)
+
+	assert.NoError(suite.T(), suite.service.GrantConsent(user, client, "read"))
+
+	// Only newly requested scopes need consent
+	scopes, err = suite.service.ScopesNeedingConsent(user, client, "read")
+	assert.NoError(suite.T(), err)
+	assert.Empty(suite.T(), scopes)
+	scopes, err = suite.service.ScopesNeedingConsent(user, client, "read read_write")
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), []string{"read_write"}, scopes)
+
+	// Granted scopes add up
+	assert.NoError(suite.T(), suite.service.GrantConsent(user, client, "read_write"))
+
+	consent, err := suite.service.FindConsent(user, client)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), "read read_write", consent.Scope)
+	scopes, err = suite.service.ScopesNeedingConsent(user, client, "read read_write")
+	assert.NoError(suite.T(), err)
+	assert.Empty(suite.T(), scopes)
+}
+
+func (suite *OauthTestSuite) TestFirstPartyClientSkipsConsent() {
+	// Only admins can flag first-party clients
+	_, err := suite.service.RegisterClient(&oauth.ClientRegistrationRequest{
+		RedirectURIs: []string{"https://www.resonate.coop/callback"},
+		FirstParty:   true,
+	}, false)
+	assert.Equal(suite.T(), oauth.ErrInvalidClientMetadata, err)
+
+	resp, err := suite.service.RegisterClient(&oauth.ClientRegistrationRequest{
+		RedirectURIs: []string{"https://www.resonate.coop/callback"},
+		FirstParty:   true,
+	}, true)
+	assert.NoError(suite.T(), err)
+
+	client, err := suite.service.FindClientByClientID(resp.ClientID)
+	assert.NoError(suite.T(), err)
+
+	firstParty, err := suite.service.IsFirstPartyClient(client)
+	assert.NoError(suite.T(), err)
+	assert.True(suite.T(), firstParty)
+	scopes, err := suite.service.ScopesNeedingConsent(suite.users[0], client, "read read_write")
+	assert.NoError(suite.T(), err)
+	assert.Empty(suite.T(), scopes)
+}
+
+func (suite *OauthTestSuite) TestFindScopes() {
+	scopes, err := suite.service.FindScopes("openid read")
+	assert.NoError(suite.T(), err)
+	if assert.Len(suite.T(), scopes, 2) {
+		assert.Equal(suite.T(), "openid", scopes[0].Name)
+		assert.Equal(suite.T(), "read", scopes[1].Name)
+	}
+}
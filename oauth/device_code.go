@@ -0,0 +1,292 @@
+package oauth
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/resonatecoop/user-api/model"
+	"github.com/uptrace/bun"
+)
+
+const (
+	// DeviceCodeGrantType is the grant type of RFC 8628
+	DeviceCodeGrantType = "urn:ietf:params:oauth:grant-type:device_code"
+
+	// DeviceCodePending ...
+	DeviceCodePending = "pending"
+	// DeviceCodeApproved ...
+	DeviceCodeApproved = "approved"
+	// DeviceCodeDenied ...
+	DeviceCodeDenied = "denied"
+
+	// userCodeCharset leaves out vowels and characters easily confused
+	userCodeCharset = "BCDFGHJKLMNPQRSTVWXZ"
+	userCodeLength  = 8
+	// slowDownInterval is added to the polling interval of a device polling too fast
+	slowDownInterval = 5
+)
+
+var (
+	// ErrDeviceCodeNotFound ...
+	ErrDeviceCodeNotFound = errors.New("Device code not found")
+	// ErrUserCodeNotFound ...
+	ErrUserCodeNotFound = errors.New("Invalid or expired code")
+	// Errors returned while polling use the error codes of RFC 8628 as devices
+	// check for them
+
+	// ErrAuthorizationPending ...
+	ErrAuthorizationPending = errors.New("authorization_pending")
+	// ErrSlowDown ...
+	ErrSlowDown = errors.New("slow_down")
+	// ErrAccessDenied ...
+	ErrAccessDenied = errors.New("access_denied")
+	// ErrDeviceCodeExpired ...
+	ErrDeviceCodeExpired = errors.New("expired_token")
+)
+
+// DeviceCode is a pending device authorization request, the device polls the
+// tokens endpoint with the device code while the user approves the request
+// by entering the user code at /web/device
+type DeviceCode struct {
+	bun.BaseModel `bun:"table:device_codes"`
+
+	Code         string    `bun:"type:varchar(64),pk"`
+	UserCode     string    `bun:"type:varchar(8),notnull,unique"`
+	ClientID     uuid.UUID `bun:"type:uuid,notnull"`
+	UserID       uuid.UUID `bun:"type:uuid,nullzero"`
+	Scope        string    `bun:"type:varchar(200),notnull"`
+	Status       string    `bun:"type:varchar(20),notnull"`
+	PollInterval int       `bun:",notnull"`
+	ExpiresAt    time.Time `bun:",notnull"`
+	LastPolledAt time.Time `bun:",nullzero"`
+	CreatedAt    time.Time `bun:",nullzero,notnull,default:current_timestamp"`
+
+	Client *model.Client `bun:"rel:belongs-to,join:client_id=id"`
+}
+
+// GrantDeviceCode starts a device authorization request for the client
+func (s *Service) GrantDeviceCode(client *model.Client, scope string) (*DeviceCode, error) {
+	code, err := newDeviceCode()
+	if err != nil {
+		return nil, err
+	}
+
+	userCode, err := newUserCode()
+	if err != nil {
+		return nil, err
+	}
+
+	interval := s.cnf.Oauth.DeviceCodeInterval
+	if interval <= 0 {
+		interval = slowDownInterval
+	}
+
+	deviceCode := &DeviceCode{
+		Code:         code,
+		UserCode:     userCode,
+		ClientID:     client.ID,
+		Scope:        scope,
+		Status:       DeviceCodePending,
+		PollInterval: interval,
+		ExpiresAt:    time.Now().UTC().Add(time.Duration(s.cnf.Oauth.DeviceCodeLifetime) * time.Second),
+		CreatedAt:    time.Now().UTC(),
+	}
+
+	_, err = s.db.NewInsert().
+		Model(deviceCode).
+		Exec(context.Background())
+	if err != nil {
+		return nil, err
+	}
+
+	deviceCode.Client = client
+
+	return deviceCode, nil
+}
+
+// FindDeviceCodeByUserCode returns the pending device authorization request
+// of a user code, dashes and case are ignored
+func (s *Service) FindDeviceCodeByUserCode(userCode string) (*DeviceCode, error) {
+	userCode = NormalizeUserCode(userCode)
+	if len(userCode) != userCodeLength {
+		return nil, ErrUserCodeNotFound
+	}
+
+	deviceCode := new(DeviceCode)
+	err := s.db.NewSelect().
+		Model(deviceCode).
+		Relation("Client").
+		Where("user_code = ?", userCode).
+		Where("status = ?", DeviceCodePending).
+		Where("expires_at > ?", time.Now().UTC()).
+		Limit(1).
+		Scan(context.Background())
+	if err != nil {
+		return nil, ErrUserCodeNotFound
+	}
+
+	return deviceCode, nil
+}
+
+// ApproveDeviceCode lets the device poll for tokens granted to the user
+func (s *Service) ApproveDeviceCode(deviceCode *DeviceCode, user *model.User) error {
+	return s.updateDeviceCodeStatus(deviceCode, user.ID, DeviceCodeApproved)
+}
+
+// DenyDeviceCode makes the device stop polling
+func (s *Service) DenyDeviceCode(deviceCode *DeviceCode) error {
+	return s.updateDeviceCodeStatus(deviceCode, uuid.Nil, DeviceCodeDenied)
+}
+
+// updateDeviceCodeStatus approves or denies a pending device code once
+func (s *Service) updateDeviceCodeStatus(deviceCode *DeviceCode, userID uuid.UUID, status string) error {
+	query := s.db.NewUpdate().
+		Model((*DeviceCode)(nil)).
+		Set("status = ?", status).
+		Where("code = ?", deviceCode.Code).
+		Where("status = ?", DeviceCodePending).
+		Where("expires_at > ?", time.Now().UTC())
+
+	if userID != uuid.Nil {
+		query = query.Set("user_id = ?", userID)
+	}
+
+	res, err := query.Exec(context.Background())
+	if err != nil {
+		return err
+	}
+
+	if n, _ := res.RowsAffected(); n != 1 {
+		return ErrUserCodeNotFound
+	}
+
+	deviceCode.Status = status
+	deviceCode.UserID = userID
+
+	return nil
+}
+
+// getApprovedDeviceCode returns the device code once the user approved it,
+// polling faster than the interval slows the device down
+func (s *Service) getApprovedDeviceCode(code string, client *model.Client) (*DeviceCode, error) {
+	ctx := context.Background()
+
+	deviceCode := new(DeviceCode)
+	err := s.db.NewSelect().
+		Model(deviceCode).
+		Where("code = ?", code).
+		Where("client_id = ?", client.ID).
+		Limit(1).
+		Scan(ctx)
+
+	// Not Found!
+	if err != nil {
+		return nil, ErrDeviceCodeNotFound
+	}
+
+	now := time.Now().UTC()
+
+	if now.After(deviceCode.ExpiresAt) {
+		return nil, ErrDeviceCodeExpired
+	}
+
+	query := s.db.NewUpdate().
+		Model(deviceCode).
+		Set("last_polled_at = ?", now).
+		WherePK()
+
+	slowDown := !deviceCode.LastPolledAt.IsZero() &&
+		now.Before(deviceCode.LastPolledAt.Add(time.Duration(deviceCode.PollInterval)*time.Second))
+	if slowDown {
+		query = query.Set("poll_interval = ?", deviceCode.PollInterval+slowDownInterval)
+	}
+
+	if _, err := query.Exec(ctx); err != nil {
+		return nil, err
+	}
+
+	if slowDown {
+		return nil, ErrSlowDown
+	}
+
+	switch deviceCode.Status {
+	case DeviceCodeApproved:
+		deviceCode.Client = client
+		return deviceCode, nil
+	case DeviceCodeDenied:
+		return nil, ErrAccessDenied
+	default:
+		return nil, ErrAuthorizationPending
+	}
+}
+
+// claimDeviceCode deletes an approved device code, only one of concurrent
+// requests polling with the same code claims it
+func (s *Service) claimDeviceCode(deviceCode *DeviceCode) error {
+	res, err := s.db.NewDelete().
+		Model((*DeviceCode)(nil)).
+		Where("code = ?", deviceCode.Code).
+		Where("status = ?", DeviceCodeApproved).
+		Exec(context.Background())
+	if err != nil {
+		return err
+	}
+
+	if n, _ := res.RowsAffected(); n != 1 {
+		return ErrDeviceCodeNotFound
+	}
+
+	return nil
+}
+
+// NormalizeUserCode uppercases a user code and drops dashes and spaces
+func NormalizeUserCode(userCode string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '-' || r == ' ' {
+			return -1
+		}
+		return r
+	}, strings.ToUpper(userCode))
+}
+
+// FormatUserCode formats a user code as XXXX-XXXX to be read out more easily
+func FormatUserCode(userCode string) string {
+	if len(userCode) != userCodeLength {
+		return userCode
+	}
+	return userCode[:4] + "-" + userCode[4:]
+}
+
+// newDeviceCode returns a random device code
+func newDeviceCode() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// newUserCode returns a random user code of userCodeLength characters from
+// userCodeCharset
+func newUserCode() (string, error) {
+	code := make([]byte, 0, userCodeLength)
+	b := make([]byte, 1)
+
+	for len(code) < userCodeLength {
+		if _, err := rand.Read(b); err != nil {
+			return "", err
+		}
+		// Skip bytes that would make some characters more likely
+		if int(b[0]) >= 256-256%len(userCodeCharset) {
+			continue
+		}
+		code = append(code, userCodeCharset[int(b[0])%len(userCodeCharset)])
+	}
+
+	return string(code), nil
+}
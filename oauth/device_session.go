@@ -0,0 +1,349 @@
+package oauth
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+	"github.com/resonatecoop/id/util"
+	"github.com/resonatecoop/user-api/model"
+	"github.com/uptrace/bun"
+)
+
+var (
+	// ErrDeviceSessionNotFound ...
+	ErrDeviceSessionNotFound = errors.New("Session not found")
+)
+
+// DeviceSession groups the tokens granted to a user on one device, so that
+// logging out or revoking a token does not affect the user's other devices
+type DeviceSession struct {
+	bun.BaseModel `bun:"table:device_sessions"`
+
+	ID         uuid.UUID `bun:"type:uuid,pk"`
+	ClientID   uuid.UUID `bun:"type:uuid,notnull"`
+	UserID     uuid.UUID `bun:"type:uuid,notnull"`
+	UserAgent  string    `bun:"type:varchar(255)"`
+	IPAddress  string    `bun:"type:varchar(45)"`
+	CreatedAt  time.Time `bun:",nullzero,notnull,default:current_timestamp"`
+	LastUsedAt time.Time `bun:",nullzero,notnull,default:current_timestamp"`
+
+	Client *model.Client `bun:"rel:belongs-to,join:client_id=id"`
+}
+
+// DeviceSessionToken links an access or refresh token to its device session
+type DeviceSessionToken struct {
+	bun.BaseModel `bun:"table:device_session_tokens"`
+
+	Token           string    `bun:"type:varchar(40),pk"`
+	DeviceSessionID uuid.UUID `bun:"type:uuid,notnull"`
+	CreatedAt       time.Time `bun:",nullzero,notnull,default:current_timestamp"`
+}
+
+// NewDeviceSession returns a new device session for the device the request
+// came from, it is saved when tokens are first granted for it
+func (s *Service) NewDeviceSession(client *model.Client, user *model.User, r *http.Request) *DeviceSession {
+	deviceSession := &DeviceSession{
+		ClientID: client.ID,
+		UserID:   user.ID,
+	}
+
+	if r != nil {
+		deviceSession.UserAgent = truncate(r.UserAgent(), 255)
+		deviceSession.IPAddress = truncate(util.GetClientIP(r, s.cnf.TrustedProxies), 45)
+	}
+
+	return deviceSession
+}
+
+// FindDeviceSessionByToken returns the device session an access or refresh token belongs to
+func (s *Service) FindDeviceSessionByToken(token string) (*DeviceSession, error) {
+	// JWT access tokens are linked under their jti
+	lookupKey, err := s.getAccessTokenLookupKey(token)
+	if err != nil {
+		return nil, ErrDeviceSessionNotFound
+	}
+
+	deviceSession := new(DeviceSession)
+
+	err = s.db.NewSelect().
+		Model(deviceSession).
+		Where("id = (?)", s.db.NewSelect().
+			Model((*DeviceSessionToken)(nil)).
+			Column("device_session_id").
+			Where("token = ?", lookupKey)).
+		Limit(1).
+		Scan(context.Background())
+
+	// Not found
+	if err != nil {
+		return nil, ErrDeviceSessionNotFound
+	}
+
+	return deviceSession, nil
+}
+
+// GetRefreshTokenDeviceSession returns the device session of a refresh token,
+// tokens granted before device sessions existed get a new session
+func (s *Service) GetRefreshTokenDeviceSession(refreshToken *model.RefreshToken, r *http.Request) *DeviceSession {
+	deviceSession, err := s.FindDeviceSessionByToken(refreshToken.Token)
+	if err != nil {
+		return s.NewDeviceSession(refreshToken.Client, refreshToken.User, r)
+	}
+
+	return deviceSession
+}
+
+// FindActiveDeviceSessions returns the user's device sessions which still have
+// a valid refresh token, most recently used first
+func (s *Service) FindActiveDeviceSessions(user *model.User) ([]*DeviceSession, error) {
+	var deviceSessions []*DeviceSession
+
+	err := s.db.NewSelect().
+		Model(&deviceSessions).
+		Relation("Client").
+		Where("device_session.user_id = ?", user.ID).
+		Where("device_session.id IN (?)", s.activeDeviceSessionIDs()).
+		Order("device_session.last_used_at DESC").
+		Scan(context.Background())
+
+	if err != nil {
+		return nil, err
+	}
+
+	return deviceSessions, nil
+}
+
+// FindUserDeviceSession returns a device session of the user by its ID
+func (s *Service) FindUserDeviceSession(user *model.User, id string) (*DeviceSession, error) {
+	deviceSessionID, err := uuid.Parse(id)
+	if err != nil {
+		return nil, ErrDeviceSessionNotFound
+	}
+
+	deviceSession := new(DeviceSession)
+
+	err = s.db.NewSelect().
+		Model(deviceSession).
+		Where("id = ?", deviceSessionID).
+		Where("user_id = ?", user.ID).
+		Limit(1).
+		Scan(context.Background())
+
+	// Not found
+	if err != nil {
+		return nil, ErrDeviceSessionNotFound
+	}
+
+	return deviceSession, nil
+}
+
+// RevokeOtherDeviceSessions signs the user out everywhere except on the
+// current device session, which may be nil to sign out everywhere. Tokens
+// granted before device sessions existed are deleted too.
+func (s *Service) RevokeOtherDeviceSessions(user *model.User, current *DeviceSession) error {
+	ctx := context.Background()
+
+	query := s.db.NewSelect().
+		Model((*DeviceSession)(nil)).
+		Column("id").
+		Where("user_id = ?", user.ID)
+	if current != nil {
+		query = query.Where("id != ?", current.ID)
+	}
+
+	var deviceSessionIDs []uuid.UUID
+	if err := query.Scan(ctx, &deviceSessionIDs); err != nil {
+		return err
+	}
+
+	// Begin a transaction
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+
+	for _, deviceSessionID := range deviceSessionIDs {
+		if err := revokeDeviceSession(tx, deviceSessionID); err != nil {
+			tx.Rollback() // rollback the transaction
+			return err
+		}
+	}
+
+	_, err = tx.NewDelete().
+		Model((*model.RefreshToken)(nil)).
+		Where("user_id = ?", user.ID).
+		Where("token NOT IN (?)", tx.NewSelect().Model((*DeviceSessionToken)(nil)).Column("token")).
+		ForceDelete().
+		Exec(ctx)
+	if err != nil {
+		tx.Rollback() // rollback the transaction
+		return err
+	}
+
+	_, err = tx.NewDelete().
+		Model((*model.AccessToken)(nil)).
+		Where("user_id = ?", user.ID).
+		Where("token NOT IN (?)", tx.NewSelect().Model((*DeviceSessionToken)(nil)).Column("token")).
+		ForceDelete().
+		Exec(ctx)
+	if err != nil {
+		tx.Rollback() // rollback the transaction
+		return err
+	}
+
+	// Commit the transaction
+	return tx.Commit()
+}
+
+// RevokeDeviceSession deletes the device session along with its tokens
+func (s *Service) RevokeDeviceSession(deviceSession *DeviceSession) error {
+	// Begin a transaction
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+
+	if err := revokeDeviceSession(tx, deviceSession.ID); err != nil {
+		tx.Rollback() // rollback the transaction
+		return err
+	}
+
+	// Commit the transaction
+	return tx.Commit()
+}
+
+// activeDeviceSessionIDs selects the IDs of device sessions with a valid refresh token
+func (s *Service) activeDeviceSessionIDs() *bun.SelectQuery {
+	return s.db.NewSelect().
+		Model((*DeviceSessionToken)(nil)).
+		Column("device_session_id").
+		Where("token IN (?)", s.db.NewSelect().
+			Model((*model.RefreshToken)(nil)).
+			Column("token").
+			Where("expires_at > ?", time.Now().UTC()))
+}
+
+// saveDeviceSession inserts a new device session or marks an existing one as used
+func saveDeviceSession(db bun.IDB, deviceSession *DeviceSession) error {
+	ctx := context.Background()
+	now := time.Now().UTC()
+
+	if deviceSession.ID != uuid.Nil {
+		deviceSession.LastUsedAt = now
+
+		_, err := db.NewUpdate().
+			Model(deviceSession).
+			Column("last_used_at").
+			WherePK().
+			Exec(ctx)
+
+		return err
+	}
+
+	deviceSession.ID = uuid.New()
+	deviceSession.CreatedAt = now
+	deviceSession.LastUsedAt = now
+
+	_, err := db.NewInsert().
+		Model(deviceSession).
+		Exec(ctx)
+
+	return err
+}
+
+// addDeviceSessionToken links a stored token to the device session
+func addDeviceSessionToken(db bun.IDB, deviceSession *DeviceSession, token string) error {
+	ctx := context.Background()
+
+	_, err := db.NewInsert().
+		Model(&DeviceSessionToken{
+			Token:           token,
+			DeviceSessionID: deviceSession.ID,
+			CreatedAt:       time.Now().UTC(),
+		}).
+		Exec(ctx)
+	if err != nil {
+		return err
+	}
+
+	// Forget tokens which have been deleted since, e.g. expired access tokens
+	_, err = db.NewDelete().
+		Model((*DeviceSessionToken)(nil)).
+		Where("device_session_id = ?", deviceSession.ID).
+		Where("token NOT IN (?)", db.NewSelect().Model((*model.AccessToken)(nil)).Column("token")).
+		Where("token NOT IN (?)", db.NewSelect().Model((*model.RefreshToken)(nil)).Column("token")).
+		Exec(ctx)
+
+	return err
+}
+
+// revokeDeviceSession deletes the tokens of a device session and the session itself
+func revokeDeviceSession(db bun.IDB, deviceSessionID uuid.UUID) error {
+	ctx := context.Background()
+
+	tokens := db.NewSelect().
+		Model((*DeviceSessionToken)(nil)).
+		Column("token").
+		Where("device_session_id = ?", deviceSessionID)
+
+	_, err := db.NewDelete().
+		Model((*model.RefreshToken)(nil)).
+		Where("token IN (?)", tokens).
+		ForceDelete().
+		Exec(ctx)
+	if err != nil {
+		return err
+	}
+
+	_, err = db.NewDelete().
+		Model((*model.AccessToken)(nil)).
+		Where("token IN (?)", tokens).
+		ForceDelete().
+		Exec(ctx)
+	if err != nil {
+		return err
+	}
+
+	_, err = db.NewDelete().
+		Model((*DeviceSessionToken)(nil)).
+		Where("device_session_id = ?", deviceSessionID).
+		Exec(ctx)
+	if err != nil {
+		return err
+	}
+
+	_, err = db.NewDelete().
+		Model((*DeviceSession)(nil)).
+		Where("id = ?", deviceSessionID).
+		Exec(ctx)
+
+	return err
+}
+
+// deleteUnlinkedAccessTokens deletes the access tokens granted to the user and
+// client which do not belong to any device session, i.e. tokens granted
+// before device sessions were introduced
+func deleteUnlinkedAccessTokens(db bun.IDB, clientID, userID uuid.UUID) error {
+	_, err := db.NewDelete().
+		Model((*model.AccessToken)(nil)).
+		Where("client_id = ?", clientID).
+		Where("user_id = ?", userID).
+		Where("token NOT IN (?)", db.NewSelect().Model((*DeviceSessionToken)(nil)).Column("token")).
+		ForceDelete().
+		Exec(context.Background())
+
+	return err
+}
+
+// truncate shortens s to at most n characters, as counted by varchar
+// columns, without splitting a multi-byte character
+func truncate(s string, n int) string {
+	if utf8.RuneCountInString(s) > n {
+		return string([]rune(s)[:n])
+	}
+	return s
+}
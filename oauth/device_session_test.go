@@ -0,0 +1,213 @@
+package oauth_test
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/resonatecoop/id/oauth"
+	"github.com/resonatecoop/id/session"
+	"github.com/stretchr/testify/assert"
+)
+
+func (suite *OauthTestSuite) TestNewDeviceSessionTruncatesUserAgent() {
+	r, err := http.NewRequest("POST", "http://1.2.3.4/web/login", nil)
+	assert.NoError(suite.T(), err, "Request setup should not get an error")
+	r.Header.Set("User-Agent", strings.Repeat("ü", 300))
+
+	// Long user agents are cut to the column length without splitting a
+	// character
+	deviceSession := suite.service.NewDeviceSession(suite.clients[0], suite.users[0], r)
+	assert.True(suite.T(), utf8.ValidString(deviceSession.UserAgent))
+	assert.Equal(suite.T(), 255, utf8.RuneCountInString(deviceSession.UserAgent))
+
+	_, _, err = suite.service.Login(suite.clients[0], suite.users[0], "read", deviceSession)
+	assert.NoError(suite.T(), err)
+}
+
+func (suite *OauthTestSuite) TestLoginCreatesDeviceSessions() {
+	r, err := http.NewRequest("POST", "http://1.2.3.4/web/login", nil)
+	assert.NoError(suite.T(), err, "Request setup should not get an error")
+	r.Header.Set("User-Agent", "test-agent")
+	r.RemoteAddr = "10.0.0.1:51234"
+
+	deviceSession := suite.service.NewDeviceSession(suite.clients[0], suite.users[0], r)
+
+	accessToken, refreshToken, err := suite.service.Login(
+		suite.clients[0],
+		suite.users[0],
+		"read_write",
+		deviceSession,
+	)
+	assert.NoError(suite.T(), err)
+
+	// Both tokens belong to the new device session
+	found, err := suite.service.FindDeviceSessionByToken(accessToken.Token)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), deviceSession.ID, found.ID)
+	assert.Equal(suite.T(), "test-agent", found.UserAgent)
+	assert.Equal(suite.T(), "10.0.0.1", found.IPAddress)
+
+	found, err = suite.service.FindDeviceSessionByToken(refreshToken.Token)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), deviceSession.ID, found.ID)
+
+	// Logging in again on the same device keeps the refresh token
+	_, sameRefreshToken, err := suite.service.Login(
+		suite.clients[0],
+		suite.users[0],
+		"read_write",
+		deviceSession,
+	)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), refreshToken.Token, sameRefreshToken.Token)
+
+	// Logging in on another device gets a new refresh token
+	_, otherRefreshToken, err := suite.service.Login(
+		suite.clients[0],
+		suite.users[0],
+		"read_write",
+		suite.service.NewDeviceSession(suite.clients[0], suite.users[0], nil),
+	)
+	assert.NoError(suite.T(), err)
+	assert.NotEqual(suite.T(), refreshToken.Token, otherRefreshToken.Token)
+}
+
+func (suite *OauthTestSuite) TestClearUserTokensOnlyClearsDeviceSession() {
+	accessToken, refreshToken, err := suite.service.Login(
+		suite.clients[0],
+		suite.users[0],
+		"read_write",
+		suite.service.NewDeviceSession(suite.clients[0], suite.users[0], nil),
+	)
+	assert.NoError(suite.T(), err)
+
+	otherAccessToken, otherRefreshToken, err := suite.service.Login(
+		suite.clients[0],
+		suite.users[0],
+		"read_write",
+		suite.service.NewDeviceSession(suite.clients[0], suite.users[0], nil),
+	)
+	assert.NoError(suite.T(), err)
+
+	suite.service.ClearUserTokens(&session.UserSession{
+		ClientID:     suite.clients[0].Key,
+		Username:     suite.users[0].Username,
+		AccessToken:  accessToken.Token,
+		RefreshToken: refreshToken.Token,
+	})
+
+	// The device session is signed out
+	_, err = suite.service.Authenticate(accessToken.Token)
+	assert.Equal(suite.T(), oauth.ErrAccessTokenNotFound, err)
+	_, err = suite.service.FindDeviceSessionByToken(refreshToken.Token)
+	assert.Equal(suite.T(), oauth.ErrDeviceSessionNotFound, err)
+
+	// The other device stays logged in
+	_, err = suite.service.Authenticate(otherAccessToken.Token)
+	assert.NoError(suite.T(), err)
+	_, err = suite.service.GetValidRefreshToken(otherRefreshToken.Token, suite.clients[0])
+	assert.NoError(suite.T(), err)
+}
+
+func (suite *OauthTestSuite) TestRevokeRefreshTokenOnlyRevokesDeviceSession() {
+	accessToken, refreshToken, err := suite.service.Login(
+		suite.clients[0],
+		suite.users[0],
+		"read_write",
+		suite.service.NewDeviceSession(suite.clients[0], suite.users[0], nil),
+	)
+	assert.NoError(suite.T(), err)
+
+	otherAccessToken, _, err := suite.service.Login(
+		suite.clients[0],
+		suite.users[0],
+		"read_write",
+		suite.service.NewDeviceSession(suite.clients[0], suite.users[0], nil),
+	)
+	assert.NoError(suite.T(), err)
+
+	w := suite.serveRevokeRequest(url.Values{
+		"token":           {refreshToken.Token},
+		"token_type_hint": {"refresh_token"},
+	})
+	assert.Equal(suite.T(), 200, w.Code)
+
+	_, err = suite.service.Authenticate(accessToken.Token)
+	assert.Equal(suite.T(), oauth.ErrAccessTokenNotFound, err)
+
+	_, err = suite.service.Authenticate(otherAccessToken.Token)
+	assert.NoError(suite.T(), err)
+}
+
+func (suite *OauthTestSuite) TestFindActiveDeviceSessions() {
+	deviceSession := suite.service.NewDeviceSession(suite.clients[0], suite.users[0], nil)
+	_, _, err := suite.service.Login(
+		suite.clients[0],
+		suite.users[0],
+		"read_write",
+		deviceSession,
+	)
+	assert.NoError(suite.T(), err)
+
+	otherDeviceSession := suite.service.NewDeviceSession(suite.clients[0], suite.users[0], nil)
+	_, _, err = suite.service.Login(
+		suite.clients[0],
+		suite.users[0],
+		"read_write",
+		otherDeviceSession,
+	)
+	assert.NoError(suite.T(), err)
+
+	deviceSessions, err := suite.service.FindActiveDeviceSessions(suite.users[0])
+	assert.NoError(suite.T(), err)
+	assert.Len(suite.T(), deviceSessions, 2)
+
+	// Most recently used first, with the client loaded
+	assert.Equal(suite.T(), otherDeviceSession.ID, deviceSessions[0].ID)
+	assert.Equal(suite.T(), suite.clients[0].Key, deviceSessions[0].Client.Key)
+
+	// Sessions of other users are not found
+	_, err = suite.service.FindUserDeviceSession(suite.users[1], deviceSession.ID.String())
+	assert.Equal(suite.T(), oauth.ErrDeviceSessionNotFound, err)
+
+	found, err := suite.service.FindUserDeviceSession(suite.users[0], deviceSession.ID.String())
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), deviceSession.ID, found.ID)
+}
+
+func (suite *OauthTestSuite) TestRevokeOtherDeviceSessions() {
+	currentDeviceSession := suite.service.NewDeviceSession(suite.clients[0], suite.users[0], nil)
+	accessToken, _, err := suite.service.Login(
+		suite.clients[0],
+		suite.users[0],
+		"read_write",
+		currentDeviceSession,
+	)
+	assert.NoError(suite.T(), err)
+
+	otherAccessToken, _, err := suite.service.Login(
+		suite.clients[1],
+		suite.users[0],
+		"read_write",
+		suite.service.NewDeviceSession(suite.clients[1], suite.users[0], nil),
+	)
+	assert.NoError(suite.T(), err)
+
+	err = suite.service.RevokeOtherDeviceSessions(suite.users[0], currentDeviceSession)
+	assert.NoError(suite.T(), err)
+
+	// The current device stays logged in
+	_, err = suite.service.Authenticate(accessToken.Token)
+	assert.NoError(suite.T(), err)
+
+	_, err = suite.service.Authenticate(otherAccessToken.Token)
+	assert.Equal(suite.T(), oauth.ErrAccessTokenNotFound, err)
+
+	deviceSessions, err := suite.service.FindActiveDeviceSessions(suite.users[0])
+	assert.NoError(suite.T(), err)
+	if assert.Len(suite.T(), deviceSessions, 1) {
+		assert.Equal(suite.T(), currentDeviceSession.ID, deviceSessions[0].ID)
+	}
+}
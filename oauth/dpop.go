@@ -0,0 +1,328 @@
+package oauth
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	jwt "github.com/form3tech-oss/jwt-go"
+	"github.com/resonatecoop/id/oauth/jwk"
+	"github.com/resonatecoop/id/oauth/tokentypes"
+	"github.com/resonatecoop/id/util"
+	"github.com/resonatecoop/user-api/model"
+	"github.com/uptrace/bun"
+)
+
+const (
+	// DPoPHeader carries the DPoP proof of a request (RFC 9449)
+	DPoPHeader = "DPoP"
+
+	// dpopProofType is the typ header of DPoP proofs
+	dpopProofType = "dpop+jwt"
+	// dpopProofLifetime is how far the iat of a proof may be from now
+	dpopProofLifetime = 5 * time.Minute
+)
+
+var (
+	// ErrInvalidDPoPProof ...
+	ErrInvalidDPoPProof = errors.New("Invalid DPoP proof")
+	// ErrDPoPProofReused ...
+	ErrDPoPProofReused = errors.New("DPoP proof reused")
+	// ErrDPoPKeyMismatch ...
+	ErrDPoPKeyMismatch = errors.New("DPoP proof does not match the token binding")
+)
+
+var (
+	// DPoPSigningAlgs are the algorithms clients may sign DPoP proofs with
+	DPoPSigningAlgs = []string{"RS256", "ES256"}
+)
+
+// Confirmation is the cnf claim binding a token to a key, jkt is the JWK
+// thumbprint of the DPoP key (RFC 9449 section 6)
+type Confirmation struct {
+	JKT string `json:"jkt"`
+}
+
+// TokenBinding binds an access or refresh token to the thumbprint of the
+// DPoP key of the client it was issued to
+type TokenBinding struct {
+	bun.BaseModel `bun:"table:token_bindings"`
+
+	Token     string    `bun:"type:varchar(40),pk"`
+	JKT       string    `bun:"type:varchar(43),notnull"`
+	CreatedAt time.Time `bun:",nullzero,notnull,default:current_timestamp"`
+}
+
+// DPoPProof records the jti of a DPoP proof, each proof can only be used once
+type DPoPProof struct {
+	bun.BaseModel `bun:"table:dpop_proofs"`
+
+	JKT       string    `bun:"type:varchar(43),pk"`
+	JTI       string    `bun:"type:varchar(254),pk"`
+	ExpiresAt time.Time `bun:",notnull"`
+}
+
+// dpopProofClaims are the claims of a DPoP proof, they are checked by
+// verifyDPoPProof rather than the JWT parser
+type dpopProofClaims struct {
+	JTI string `json:"jti"`
+	HTM string `json:"htm"`
+	HTU string `json:"htu"`
+	IAT int64  `json:"iat"`
+	ATH string `json:"ath"`
+}
+
+// Valid leaves the checks to verifyDPoPProof
+func (c *dpopProofClaims) Valid() error {
+	return nil
+}
+
+// verifyDPoPProof checks the DPoP proof of a request, if any, and returns the
+// thumbprint of the key it was signed with. Requests without a proof return
+// an empty thumbprint. Proofs sent along with an access token must carry its
+// hash.
+func (s *Service) verifyDPoPProof(r *http.Request, accessToken string) (string, error) {
+	proofs := r.Header.Values(DPoPHeader)
+	if len(proofs) == 0 {
+		return "", nil
+	}
+	if len(proofs) > 1 {
+		return "", ErrInvalidDPoPProof
+	}
+
+	var key *jwk.Key
+
+	claims := new(dpopProofClaims)
+	parser := &jwt.Parser{ValidMethods: DPoPSigningAlgs}
+	_, err := parser.ParseWithClaims(proofs[0], claims, func(token *jwt.Token) (interface{}, error) {
+		if typ, _ := token.Header["typ"].(string); typ != dpopProofType {
+			return nil, ErrInvalidDPoPProof
+		}
+
+		// The public key travels in the jwk header, private keys must not
+		b, err := json.Marshal(token.Header["jwk"])
+		if err != nil {
+			return nil, ErrInvalidDPoPProof
+		}
+		var members map[string]interface{}
+		if err := json.Unmarshal(b, &members); err != nil || members["d"] != nil {
+			return nil, ErrInvalidDPoPProof
+		}
+		key = new(jwk.Key)
+		if err := json.Unmarshal(b, key); err != nil {
+			return nil, ErrInvalidDPoPProof
+		}
+
+		if key.Algorithm != "" && key.Algorithm != token.Method.Alg() {
+			return nil, ErrInvalidDPoPProof
+		}
+
+		return key.PublicKey()
+	})
+	if err != nil {
+		return "", ErrInvalidDPoPProof
+	}
+
+	// The proof is for this request and fresh
+	if claims.HTM != r.Method || claims.HTU != s.GetIssuer()+r.URL.Path || claims.JTI == "" {
+		return "", ErrInvalidDPoPProof
+	}
+	iat := time.Unix(claims.IAT, 0)
+	if time.Since(iat) > dpopProofLifetime || time.Until(iat) > dpopProofLifetime {
+		return "", ErrInvalidDPoPProof
+	}
+	if accessToken != "" {
+		ath := sha256.Sum256([]byte(accessToken))
+		if claims.ATH != base64.RawURLEncoding.EncodeToString(ath[:]) {
+			return "", ErrInvalidDPoPProof
+		}
+	}
+
+	jkt, err := key.Thumbprint()
+	if err != nil {
+		return "", ErrInvalidDPoPProof
+	}
+
+	if err := s.useDPoPProof(jkt, claims.JTI, iat.Add(dpopProofLifetime)); err != nil {
+		return "", err
+	}
+
+	return jkt, nil
+}
+
+// useDPoPProof records the jti of a proof and fails if it was used before
+func (s *Service) useDPoPProof(jkt, jti string, expiresAt time.Time) error {
+	ctx := context.Background()
+
+	// Expired proofs are rejected anyway, no need to remember them
+	_, err := s.db.NewDelete().
+		Model((*DPoPProof)(nil)).
+		Where("expires_at < ?", time.Now().UTC()).
+		Exec(ctx)
+	if err != nil {
+		return err
+	}
+
+	res, err := s.db.NewInsert().
+		Model(&DPoPProof{
+			JKT:       jkt,
+			JTI:       jti,
+			ExpiresAt: expiresAt.UTC(),
+		}).
+		On("CONFLICT (jkt, jti) DO NOTHING").
+		Exec(ctx)
+	if err != nil {
+		return err
+	}
+
+	if rows, err := res.RowsAffected(); err != nil || rows == 0 {
+		return ErrDPoPProofReused
+	}
+
+	return nil
+}
+
+// findTokenBinding returns the DPoP key thumbprint a stored access or
+// refresh token is bound to, nil if the token is not bound
+func (s *Service) findTokenBinding(token string) (*TokenBinding, error) {
+	tokenBinding := new(TokenBinding)
+	err := s.db.NewSelect().
+		Model(tokenBinding).
+		Where("token = ?", token).
+		Limit(1).
+		Scan(context.Background())
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return tokenBinding, nil
+}
+
+// AuthenticateRequest authenticates the access token a request to this
+// server is made with. Unbound tokens are sent with the Bearer scheme, tokens
+// bound to a DPoP key with the DPoP scheme and a proof of the request signed
+// by that key.
+func (s *Service) AuthenticateRequest(r *http.Request) (*model.AccessToken, error) {
+	dpop := false
+	token, err := util.ParseBearerToken(r)
+	if err != nil {
+		if token, err = util.ParseDPoPToken(r); err != nil {
+			return nil, ErrTokenMissing
+		}
+		dpop = true
+	}
+
+	// JWT access tokens are bound under their jti
+	lookupKey, err := s.getAccessTokenLookupKey(string(token))
+	if err != nil {
+		return nil, err
+	}
+
+	tokenBinding, err := s.findTokenBinding(lookupKey)
+	if err != nil {
+		return nil, err
+	}
+	jkt := ""
+	if tokenBinding != nil {
+		jkt = tokenBinding.JKT
+	}
+
+	// Bound tokens cannot be used as bearer tokens and the other way round
+	if dpop != (jkt != "") {
+		return nil, ErrDPoPKeyMismatch
+	}
+
+	if dpop {
+		proofJKT, err := s.verifyDPoPProof(r, string(token))
+		if err != nil {
+			return nil, err
+		}
+		if proofJKT != jkt {
+			return nil, ErrDPoPKeyMismatch
+		}
+	}
+
+	return s.Authenticate(string(token))
+}
+
+// checkTokenBinding rejects using a bound refresh or access token at the
+// token endpoint without a proof signed by the key it is bound to
+func (s *Service) checkTokenBinding(token, jkt string) error {
+	tokenBinding, err := s.findTokenBinding(token)
+	if err != nil {
+		return err
+	}
+
+	// Unbound tokens can be used with or without a proof
+	if tokenBinding == nil {
+		return nil
+	}
+
+	if tokenBinding.JKT != jkt {
+		return ErrDPoPKeyMismatch
+	}
+
+	return nil
+}
+
+// checkSubjectTokenBinding rejects exchanging a bound access token without a
+// proof signed by the key it is bound to
+func (s *Service) checkSubjectTokenBinding(token, jkt string) error {
+	// JWT access tokens are bound under their jti
+	lookupKey, err := s.getAccessTokenLookupKey(token)
+	if err != nil {
+		return err
+	}
+	return s.checkTokenBinding(lookupKey, jkt)
+}
+
+// bindTokenResponse binds the tokens of a token response to the DPoP key,
+// JWT access tokens are signed again to carry the cnf claim
+func (s *Service) bindTokenResponse(resp *AccessTokenResponse, jkt string) error {
+	accessToken := resp.AccessToken
+
+	if IsJWT(resp.AccessToken) {
+		claims, err := s.ParseJWTAccessToken(resp.AccessToken)
+		if err != nil {
+			return err
+		}
+		claims.Cnf = &Confirmation{JKT: jkt}
+
+		resp.AccessToken, err = s.signJWT(claims, jwtAccessTokenType)
+		if err != nil {
+			return err
+		}
+		accessToken = claims.Id
+	}
+
+	tokens := []string{accessToken}
+	if resp.RefreshToken != "" {
+		tokens = append(tokens, resp.RefreshToken)
+	}
+
+	for _, token := range tokens {
+		_, err := s.db.NewInsert().
+			Model(&TokenBinding{
+				Token:     token,
+				JKT:       jkt,
+				CreatedAt: time.Now().UTC(),
+			}).
+			On("CONFLICT (token) DO UPDATE").
+			Set("jkt = EXCLUDED.jkt").
+			Exec(context.Background())
+		if err != nil {
+			return err
+		}
+	}
+
+	resp.TokenType = tokentypes.DPoP
+
+	return nil
+}
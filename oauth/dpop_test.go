@@ -0,0 +1,227 @@
+package oauth_test
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"time"
+
+	jwt "github.com/form3tech-oss/jwt-go"
+	"github.com/google/uuid"
+	"github.com/resonatecoop/id/oauth"
+	"github.com/resonatecoop/id/oauth/jwk"
+	"github.com/resonatecoop/id/oauth/tokentypes"
+	testutil "github.com/resonatecoop/id/test-util"
+	"github.com/resonatecoop/user-api/model"
+	"github.com/stretchr/testify/assert"
+)
+
+// dpopProof signs a DPoP proof for a POST request to the path
+func (suite *OauthTestSuite) dpopProof(privateKey *ecdsa.PrivateKey, path string) string {
+	return suite.dpopAccessTokenProof(privateKey, "POST", path, "")
+}
+
+// dpopAccessTokenProof signs a DPoP proof for a request to the path made with
+// the access token, if any
+func (suite *OauthTestSuite) dpopAccessTokenProof(privateKey *ecdsa.PrivateKey, method, path, accessToken string) string {
+	key, err := jwk.NewKey("", "ES256", &privateKey.PublicKey)
+	assert.NoError(suite.T(), err)
+
+	claims := jwt.MapClaims{
+		"jti": uuid.New().String(),
+		"htm": method,
+		"htu": suite.service.GetIssuer() + path,
+		"iat": time.Now().Unix(),
+	}
+	if accessToken != "" {
+		ath := sha256.Sum256([]byte(accessToken))
+		claims["ath"] = base64.RawURLEncoding.EncodeToString(ath[:])
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodES256, claims)
+	token.Header["typ"] = "dpop+jwt"
+	token.Header["jwk"] = key
+
+	signed, err := token.SignedString(privateKey)
+	assert.NoError(suite.T(), err)
+	return signed
+}
+
+func (suite *OauthTestSuite) TestDPoP() {
+	suite.allowTokenExchange(suite.clients[0])
+
+	privateKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(suite.T(), err)
+
+	key, err := jwk.NewKey("", "ES256", &privateKey.PublicKey)
+	assert.NoError(suite.T(), err)
+	jkt, err := key.Thumbprint()
+	assert.NoError(suite.T(), err)
+
+	token := func(form url.Values, proof string) *httptest.ResponseRecorder {
+		r, err := http.NewRequest("POST", "http://1.2.3.4/v1/oauth/tokens", nil)
+		assert.NoError(suite.T(), err, "Request setup should not get an error")
+		r.SetBasicAuth("test_client_1", "test_secret")
+		if proof != "" {
+			r.Header.Set("DPoP", proof)
+		}
+		r.PostForm = form
+		w := httptest.NewRecorder()
+		suite.router.ServeHTTP(w, r)
+		return w
+	}
+
+	proof := suite.dpopProof(privateKey, "/v1/oauth/tokens")
+	w := token(url.Values{
+		"grant_type": {"password"},
+		"username":   {"test@user.com"},
+		"password":   {"test_password"},
+		"scope":      {"read_write"},
+	}, proof)
+	assert.Equal(suite.T(), http.StatusOK, w.Code)
+
+	resp := new(oauth.AccessTokenResponse)
+	assert.NoError(suite.T(), json.Unmarshal(w.Body.Bytes(), resp))
+	assert.Equal(suite.T(), tokentypes.DPoP, resp.TokenType)
+
+	// Proofs can only be used once
+	w = token(url.Values{
+		"grant_type": {"client_credentials"},
+	}, proof)
+	testutil.TestResponseForOauthError(suite.T(), w, oauth.ErrorCodeInvalidDPoPProof, oauth.ErrDPoPProofReused.Error(), 400)
+
+	// Proofs must be for the endpoint they are sent to
+	w = token(url.Values{
+		"grant_type": {"client_credentials"},
+	}, suite.dpopProof(privateKey, "/v1/oauth/introspect"))
+	testutil.TestResponseForOauthError(suite.T(), w, oauth.ErrorCodeInvalidDPoPProof, oauth.ErrInvalidDPoPProof.Error(), 400)
+
+	// Introspection exposes the binding
+	r, err := http.NewRequest("POST", "http://1.2.3.4/v1/oauth/introspect", nil)
+	assert.NoError(suite.T(), err, "Request setup should not get an error")
+	r.SetBasicAuth("test_client_1", "test_secret")
+	r.PostForm = url.Values{"token": {resp.AccessToken}}
+	w = httptest.NewRecorder()
+	suite.router.ServeHTTP(w, r)
+	assert.Equal(suite.T(), http.StatusOK, w.Code)
+
+	introspectResponse := new(oauth.IntrospectResponse)
+	assert.NoError(suite.T(), json.Unmarshal(w.Body.Bytes(), introspectResponse))
+	assert.Equal(suite.T(), tokentypes.DPoP, introspectResponse.TokenType)
+	if assert.NotNil(suite.T(), introspectResponse.Cnf) {
+		assert.Equal(suite.T(), jkt, introspectResponse.Cnf.JKT)
+	}
+
+	// Refreshing needs a proof signed by the same key
+	w = token(url.Values{
+		"grant_type":    {"refresh_token"},
+		"refresh_token": {resp.RefreshToken},
+	}, "")
+	testutil.TestResponseForOauthError(suite.T(), w, oauth.ErrorCodeInvalidGrant, oauth.ErrDPoPKeyMismatch.Error(), 400)
+
+	otherKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(suite.T(), err)
+	w = token(url.Values{
+		"grant_type":    {"refresh_token"},
+		"refresh_token": {resp.RefreshToken},
+	}, suite.dpopProof(otherKey, "/v1/oauth/tokens"))
+	testutil.TestResponseForOauthError(suite.T(), w, oauth.ErrorCodeInvalidGrant, oauth.ErrDPoPKeyMismatch.Error(), 400)
+
+	w = token(url.Values{
+		"grant_type":    {"refresh_token"},
+		"refresh_token": {resp.RefreshToken},
+	}, suite.dpopProof(privateKey, "/v1/oauth/tokens"))
+	assert.Equal(suite.T(), http.StatusOK, w.Code)
+
+	resp = new(oauth.AccessTokenResponse)
+	assert.NoError(suite.T(), json.Unmarshal(w.Body.Bytes(), resp))
+
+	// Bound access tokens cannot be exchanged for unbound ones
+	exchange := url.Values{
+		"grant_type":         {oauth.TokenExchangeGrantType},
+		"subject_token":      {resp.AccessToken},
+		"subject_token_type": {oauth.AccessTokenType},
+		"audience":           {"test_client_1"},
+	}
+	w = token(exchange, "")
+	testutil.TestResponseForOauthError(suite.T(), w, oauth.ErrorCodeInvalidGrant, oauth.ErrDPoPKeyMismatch.Error(), 400)
+
+	w = token(exchange, suite.dpopProof(otherKey, "/v1/oauth/tokens"))
+	testutil.TestResponseForOauthError(suite.T(), w, oauth.ErrorCodeInvalidGrant, oauth.ErrDPoPKeyMismatch.Error(), 400)
+
+	w = token(exchange, suite.dpopProof(privateKey, "/v1/oauth/tokens"))
+	assert.Equal(suite.T(), http.StatusOK, w.Code)
+
+	exchanged := new(oauth.AccessTokenResponse)
+	assert.NoError(suite.T(), json.Unmarshal(w.Body.Bytes(), exchanged))
+	assert.Equal(suite.T(), tokentypes.DPoP, exchanged.TokenType)
+}
+
+func (suite *OauthTestSuite) TestDPoPBoundAccessToken() {
+	privateKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(suite.T(), err)
+
+	key, err := jwk.NewKey("", "ES256", &privateKey.PublicKey)
+	assert.NoError(suite.T(), err)
+	jkt, err := key.Thumbprint()
+	assert.NoError(suite.T(), err)
+
+	accessToken := &model.AccessToken{
+		IDRecord:  model.IDRecord{CreatedAt: time.Now().UTC()},
+		Token:     "test_dpop_token",
+		ExpiresAt: time.Now().UTC().Add(+10 * time.Second),
+		ClientID:  suite.clients[0].ID,
+		UserID:    suite.users[0].ID,
+		Scope:     "read_write openid",
+	}
+	_, err = suite.db.NewInsert().Model(accessToken).Exec(context.Background())
+	assert.NoError(suite.T(), err, "Inserting test data failed")
+	_, err = suite.db.NewInsert().Model(&oauth.TokenBinding{
+		Token:     accessToken.Token,
+		JKT:       jkt,
+		CreatedAt: time.Now().UTC(),
+	}).Exec(context.Background())
+	assert.NoError(suite.T(), err, "Inserting test data failed")
+
+	userInfo := func(authorization, proof string) *httptest.ResponseRecorder {
+		r, err := http.NewRequest("GET", "http://1.2.3.4/v1/oauth/userinfo", nil)
+		assert.NoError(suite.T(), err, "Request setup should not get an error")
+		r.Header.Set("Authorization", authorization)
+		if proof != "" {
+			r.Header.Set("DPoP", proof)
+		}
+		w := httptest.NewRecorder()
+		suite.router.ServeHTTP(w, r)
+		return w
+	}
+
+	// Bound tokens are not bearer tokens
+	w := userInfo("Bearer test_dpop_token", "")
+	assert.Equal(suite.T(), http.StatusUnauthorized, w.Code)
+	assert.Contains(suite.T(), w.Header().Get("WWW-Authenticate"), `error="invalid_token"`)
+
+	w = userInfo("DPoP test_dpop_token", "")
+	assert.Equal(suite.T(), http.StatusUnauthorized, w.Code)
+
+	// The proof must be for this request and token, signed by the bound key
+	w = userInfo("DPoP test_dpop_token", suite.dpopAccessTokenProof(privateKey, "GET", "/v1/oauth/userinfo", ""))
+	assert.Equal(suite.T(), http.StatusUnauthorized, w.Code)
+
+	w = userInfo("DPoP test_dpop_token", suite.dpopAccessTokenProof(privateKey, "POST", "/v1/oauth/userinfo", "test_dpop_token"))
+	assert.Equal(suite.T(), http.StatusUnauthorized, w.Code)
+
+	otherKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(suite.T(), err)
+	w = userInfo("DPoP test_dpop_token", suite.dpopAccessTokenProof(otherKey, "GET", "/v1/oauth/userinfo", "test_dpop_token"))
+	assert.Equal(suite.T(), http.StatusUnauthorized, w.Code)
+
+	w = userInfo("DPoP test_dpop_token", suite.dpopAccessTokenProof(privateKey, "GET", "/v1/oauth/userinfo", "test_dpop_token"))
+	testutil.TestResponseObject(suite.T(), w, oauth.NewUserInfoResponse(suite.users[0]), 200)
+}
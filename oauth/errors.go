@@ -2,24 +2,61 @@ package oauth
 
 import (
 	"net/http"
+
+	"github.com/resonatecoop/id/util/response"
 )
 
 var (
 	errStatusCodeMap = map[error]int{
-		ErrAuthorizationCodeNotFound:     http.StatusNotFound,
-		ErrAuthorizationCodeExpired:      http.StatusBadRequest,
-		ErrInvalidRedirectURI:            http.StatusBadRequest,
-		ErrInvalidScope:                  http.StatusBadRequest,
-		ErrInvalidUsernameOrPassword:     http.StatusBadRequest,
-		ErrRefreshTokenNotFound:          http.StatusNotFound,
-		ErrRefreshTokenExpired:           http.StatusBadRequest,
-		ErrRequestedScopeCannotBeGreater: http.StatusBadRequest,
-		ErrTokenHintInvalid:              http.StatusBadRequest,
-		ErrAccessTokenNotFound:           http.StatusNotFound,
-		ErrRefreshTokenNotFound:          http.StatusNotFound,
-		ErrTokenMissing:                  http.StatusBadRequest,
-		ErrTokenHintInvalid:              http.StatusBadRequest,
-		ErrInvalidUsernameOrPassword:     http.StatusUnauthorized,
+		ErrAuthorizationCodeNotFound:      http.StatusNotFound,
+		ErrAuthorizationCodeExpired:       http.StatusBadRequest,
+		ErrInvalidRedirectURI:             http.StatusBadRequest,
+		ErrInvalidScope:                   http.StatusBadRequest,
+		ErrInvalidUsernameOrPassword:      http.StatusBadRequest,
+		ErrRefreshTokenNotFound:           http.StatusNotFound,
+		ErrRefreshTokenExpired:            http.StatusBadRequest,
+		ErrRequestedScopeCannotBeGreater:  http.StatusBadRequest,
+		ErrTokenHintInvalid:               http.StatusBadRequest,
+		ErrAccessTokenNotFound:            http.StatusNotFound,
+		ErrRefreshTokenNotFound:           http.StatusNotFound,
+		ErrTokenMissing:                   http.StatusBadRequest,
+		ErrTokenHintInvalid:               http.StatusBadRequest,
+		ErrInvalidUsernameOrPassword:      http.StatusUnauthorized,
+		ErrSecondFactorRequired:           http.StatusBadRequest,
+		ErrInvalidCodeChallenge:           http.StatusBadRequest,
+		ErrInvalidCodeChallengeMethod:     http.StatusBadRequest,
+		ErrCodeVerifierMissing:            http.StatusBadRequest,
+		ErrInvalidCodeVerifier:            http.StatusBadRequest,
+		ErrTokenNotIssuedToClient:         http.StatusBadRequest,
+		ErrRefreshTokenReused:             http.StatusBadRequest,
+		ErrDeviceCodeNotFound:             http.StatusNotFound,
+		ErrAuthorizationPending:           http.StatusBadRequest,
+		ErrSlowDown:                       http.StatusBadRequest,
+		ErrAccessDenied:                   http.StatusBadRequest,
+		ErrDeviceCodeExpired:              http.StatusBadRequest,
+		ErrSubjectTokenMissing:            http.StatusBadRequest,
+		ErrInvalidSubjectTokenType:        http.StatusBadRequest,
+		ErrInvalidRequestedTokenType:      http.StatusBadRequest,
+		ErrAudienceMissing:                http.StatusBadRequest,
+		ErrInvalidAudience:                http.StatusBadRequest,
+		ErrInvalidClientMetadata:          http.StatusBadRequest,
+		ErrInvalidClientURI:               http.StatusBadRequest,
+		ErrInvalidResponseType:            http.StatusBadRequest,
+		ErrInvalidTokenEndpointAuthMethod: http.StatusBadRequest,
+		ErrInvalidGrantType:               http.StatusBadRequest,
+		ErrClientNotFound:                 http.StatusNotFound,
+		ErrUnauthorizedClient:             http.StatusBadRequest,
+		ErrRedirectURIMismatch:            http.StatusBadRequest,
+		ErrRedirectURIRequired:            http.StatusBadRequest,
+		ErrClientIDTaken:                  http.StatusBadRequest,
+		ErrClientDisabled:                 http.StatusUnauthorized,
+		ErrInvalidDPoPProof:               http.StatusBadRequest,
+		ErrDPoPProofReused:                http.StatusBadRequest,
+		ErrDPoPKeyMismatch:                http.StatusBadRequest,
+		ErrInvalidRequestURI:              http.StatusBadRequest,
+		ErrPushedAuthorizationRequired:    http.StatusBadRequest,
+		ErrInvalidRequest:                 http.StatusBadRequest,
+		ErrRefreshTokenUserNotFound:       http.StatusBadRequest,
 	}
 )
 
@@ -31,3 +68,144 @@ func getErrStatusCode(err error) int {
 
 	return http.StatusInternalServerError
 }
+
+// Error codes of RFC 6749 section 5.2 and the extensions implemented here
+const (
+	ErrorCodeInvalidRequest          = "invalid_request"
+	ErrorCodeInvalidClient           = "invalid_client"
+	ErrorCodeInvalidGrant            = "invalid_grant"
+	ErrorCodeUnauthorizedClient      = "unauthorized_client"
+	ErrorCodeUnsupportedGrantType    = "unsupported_grant_type"
+	ErrorCodeUnsupportedResponseType = "unsupported_response_type"
+	ErrorCodeUnsupportedTokenType    = "unsupported_token_type"
+	ErrorCodeInvalidScope            = "invalid_scope"
+	ErrorCodeInvalidTarget           = "invalid_target"
+	ErrorCodeInvalidDPoPProof        = "invalid_dpop_proof"
+	ErrorCodeAuthorizationPending    = "authorization_pending"
+	ErrorCodeSlowDown                = "slow_down"
+	ErrorCodeAccessDenied            = "access_denied"
+	ErrorCodeExpiredToken            = "expired_token"
+	ErrorCodeServerError             = "server_error"
+)
+
+// Error codes of RFC 7591 section 3.2.2 returned by client registration
+const (
+	ErrorCodeInvalidRedirectURI    = "invalid_redirect_uri"
+	ErrorCodeInvalidClientMetadata = "invalid_client_metadata"
+)
+
+var (
+	// errCodeMap maps errors to the error codes returned by the OAuth
+	// endpoints, any other error is a server error
+	errCodeMap = map[error]string{
+		ErrInvalidGrantType:              ErrorCodeUnsupportedGrantType,
+		ErrInvalidClientIDOrSecret:       ErrorCodeInvalidClient,
+		ErrInvalidClientSecret:           ErrorCodeInvalidClient,
+		ErrClientNotFound:                ErrorCodeInvalidClient,
+		ErrClientDisabled:                ErrorCodeInvalidClient,
+		ErrInvalidClientAssertion:        ErrorCodeInvalidClient,
+		ErrClientAssertionReused:         ErrorCodeInvalidClient,
+		ErrUnauthorizedClient:            ErrorCodeUnauthorizedClient,
+		ErrAuthorizationCodeNotFound:     ErrorCodeInvalidGrant,
+		ErrAuthorizationCodeExpired:      ErrorCodeInvalidGrant,
+		ErrInvalidRedirectURI:            ErrorCodeInvalidGrant,
+		ErrInvalidUsernameOrPassword:     ErrorCodeInvalidGrant,
+		ErrSecondFactorRequired:          ErrorCodeInvalidGrant,
+		ErrUserNotFound:                  ErrorCodeInvalidGrant,
+		ErrRefreshTokenNotFound:          ErrorCodeInvalidGrant,
+		ErrRefreshTokenExpired:           ErrorCodeInvalidGrant,
+		ErrRefreshTokenReused:            ErrorCodeInvalidGrant,
+		ErrRefreshTokenUserNotFound:      ErrorCodeInvalidGrant,
+		ErrTokenNotIssuedToClient:        ErrorCodeInvalidGrant,
+		ErrAccessTokenNotFound:           ErrorCodeInvalidGrant,
+		ErrAccessTokenExpired:            ErrorCodeInvalidGrant,
+		ErrInvalidCodeVerifier:           ErrorCodeInvalidGrant,
+		ErrDeviceCodeNotFound:            ErrorCodeInvalidGrant,
+		ErrDPoPKeyMismatch:               ErrorCodeInvalidGrant,
+		ErrInvalidScope:                  ErrorCodeInvalidScope,
+		ErrRequestedScopeCannotBeGreater: ErrorCodeInvalidScope,
+		ErrOpenIDScopeRequired:           ErrorCodeInvalidScope,
+		ErrCodeVerifierMissing:           ErrorCodeInvalidRequest,
+		ErrInvalidCodeChallenge:          ErrorCodeInvalidRequest,
+		ErrInvalidCodeChallengeMethod:    ErrorCodeInvalidRequest,
+		ErrSubjectTokenMissing:           ErrorCodeInvalidRequest,
+		ErrInvalidSubjectTokenType:       ErrorCodeInvalidRequest,
+		ErrInvalidRequestedTokenType:     ErrorCodeInvalidRequest,
+		ErrAudienceMissing:               ErrorCodeInvalidRequest,
+		ErrTokenMissing:                  ErrorCodeInvalidRequest,
+		ErrRedirectURIMismatch:           ErrorCodeInvalidRequest,
+		ErrRedirectURIRequired:           ErrorCodeInvalidRequest,
+		ErrInvalidRequestURI:             ErrorCodeInvalidRequest,
+		ErrPushedAuthorizationRequired:   ErrorCodeInvalidRequest,
+		ErrInvalidRequest:                ErrorCodeInvalidRequest,
+		ErrInvalidResponseType:           ErrorCodeUnsupportedResponseType,
+		ErrTokenHintInvalid:              ErrorCodeUnsupportedTokenType,
+		ErrInvalidAudience:               ErrorCodeInvalidTarget,
+		ErrInvalidDPoPProof:              ErrorCodeInvalidDPoPProof,
+		ErrDPoPProofReused:               ErrorCodeInvalidDPoPProof,
+		ErrAuthorizationPending:          ErrorCodeAuthorizationPending,
+		ErrSlowDown:                      ErrorCodeSlowDown,
+		ErrAccessDenied:                  ErrorCodeAccessDenied,
+		ErrDeviceCodeExpired:             ErrorCodeExpiredToken,
+	}
+)
+
+var (
+	// registrationErrCodeMap maps the errors of invalid client metadata to
+	// the error codes returned by client registration
+	registrationErrCodeMap = map[error]string{
+		ErrInvalidRedirectURI:             ErrorCodeInvalidRedirectURI,
+		ErrInvalidClientMetadata:          ErrorCodeInvalidClientMetadata,
+		ErrInvalidClientURI:               ErrorCodeInvalidClientMetadata,
+		ErrInvalidGrantType:               ErrorCodeInvalidClientMetadata,
+		ErrInvalidResponseType:            ErrorCodeInvalidClientMetadata,
+		ErrInvalidTokenEndpointAuthMethod: ErrorCodeInvalidClientMetadata,
+		ErrInvalidScope:                   ErrorCodeInvalidClientMetadata,
+	}
+)
+
+// writeRegistrationError writes an error response of RFC 7591 section 3.2.2
+// for invalid client metadata, other errors such as unknown clients are
+// plain errors
+func (s *Service) writeRegistrationError(w http.ResponseWriter, err error) {
+	code, ok := registrationErrCodeMap[err]
+	if !ok {
+		response.Error(w, err.Error(), getErrStatusCode(err))
+		return
+	}
+
+	response.OauthError(w, code, err.Error(), "", http.StatusBadRequest)
+}
+
+// writeOauthError writes an error response of RFC 6749 section 5.2. Client
+// authentication failures are 401, server errors are 500 and do not give
+// their message away, anything else is 400.
+func (s *Service) writeOauthError(w http.ResponseWriter, err error) {
+	code, ok := errCodeMap[err]
+	if !ok {
+		code = ErrorCodeServerError
+	}
+
+	status := http.StatusBadRequest
+	description := err.Error()
+
+	switch code {
+	case ErrorCodeInvalidClient:
+		status = http.StatusUnauthorized
+	case ErrorCodeServerError:
+		status = http.StatusInternalServerError
+		description = ""
+	}
+
+	// Errors named after their code need no description
+	if description == code {
+		description = ""
+	}
+
+	uri := ""
+	if s.cnf.Oauth.ErrorURI != "" {
+		uri = s.cnf.Oauth.ErrorURI + "#" + code
+	}
+
+	response.OauthError(w, code, description, uri, status)
+}
@@ -20,6 +20,7 @@ func (s *Service) authorizationCodeGrant(r *http.Request, client *model.Client)
 	authorizationCode, err := s.getValidAuthorizationCode(
 		r.Form.Get("code"),
 		r.Form.Get("redirect_uri"),
+		r.Form.Get("code_verifier"),
 		client,
 	)
 	if err != nil {
@@ -31,19 +32,50 @@ func (s *Service) authorizationCodeGrant(r *http.Request, client *model.Client)
 		authorizationCode.Client,
 		authorizationCode.User,
 		authorizationCode.Scope,
+		s.NewDeviceSession(authorizationCode.Client, authorizationCode.User, r),
 	)
 	if err != nil {
 		return nil, err
 	}
 
-	// Delete the authorization code
+	// Fetch the nonce before the authorization code is gone
+	nonce, err := s.popAuthorizationCodeNonce(authorizationCode.Code)
+	if err != nil {
+		return nil, err
+	}
+
+	// Delete the authorization code along with its code challenge, if any
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, err
+	}
 
-	_, err = s.db.NewDelete().
+	_, err = tx.NewDelete().
 		Model(authorizationCode).
 		WherePK().
 		ForceDelete().
 		Exec(ctx)
+	if err != nil {
+		tx.Rollback() // rollback the transaction
+		return nil, err
+	}
+
+	_, err = tx.NewDelete().
+		Model((*AuthorizationCodeChallenge)(nil)).
+		Where("code = ?", authorizationCode.Code).
+		Exec(ctx)
+	if err != nil {
+		tx.Rollback() // rollback the transaction
+		return nil, err
+	}
 
+	// Commit the transaction
+	if err = tx.Commit(); err != nil {
+		tx.Rollback() // rollback the transaction
+		return nil, err
+	}
+
+	expiresIn, err := s.AccessTokenLifetime(client)
 	if err != nil {
 		return nil, err
 	}
@@ -52,12 +84,24 @@ func (s *Service) authorizationCodeGrant(r *http.Request, client *model.Client)
 	accessTokenResponse, err := NewAccessTokenResponse(
 		accessToken,
 		refreshToken,
-		s.cnf.Oauth.AccessTokenLifetime,
+		expiresIn,
 		tokentypes.Bearer,
 	)
 	if err != nil {
 		return nil, err
 	}
 
+	// Issue an ID token to OpenID Connect clients
+	if HasOpenIDScope(accessToken.Scope) {
+		accessTokenResponse.IDToken, err = s.GrantIDToken(
+			authorizationCode.Client,
+			authorizationCode.User,
+			nonce,
+		)
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	return accessTokenResponse, nil
 }
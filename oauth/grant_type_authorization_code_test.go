@@ -30,11 +30,12 @@ func (suite *OauthTestSuite) TestAuthorizationCodeGrantEmptyNotFound() {
 	suite.router.ServeHTTP(w, r)
 
 	// Check the response
-	testutil.TestResponseForError(
+	testutil.TestResponseForOauthError(
 		suite.T(),
 		w,
+		oauth.ErrorCodeInvalidGrant,
 		oauth.ErrAuthorizationCodeNotFound.Error(),
-		404,
+		400,
 	)
 }
 
@@ -53,11 +54,12 @@ func (suite *OauthTestSuite) TestAuthorizationCodeGrantBogusNotFound() {
 	suite.router.ServeHTTP(w, r)
 
 	// Check the response
-	testutil.TestResponseForError(
+	testutil.TestResponseForOauthError(
 		suite.T(),
 		w,
+		oauth.ErrorCodeInvalidGrant,
 		oauth.ErrAuthorizationCodeNotFound.Error(),
-		404,
+		400,
 	)
 }
 
@@ -97,9 +99,10 @@ func (suite *OauthTestSuite) TestAuthorizationCodeGrantExpired() {
 	suite.router.ServeHTTP(w, r)
 
 	// Check the response
-	testutil.TestResponseForError(
+	testutil.TestResponseForOauthError(
 		suite.T(),
 		w,
+		oauth.ErrorCodeInvalidGrant,
 		oauth.ErrAuthorizationCodeExpired.Error(),
 		400,
 	)
@@ -141,9 +144,10 @@ func (suite *OauthTestSuite) TestAuthorizationCodeGrantInvalidRedirectURI() {
 	suite.router.ServeHTTP(w, r)
 
 	// Check the response
-	testutil.TestResponseForError(
+	testutil.TestResponseForOauthError(
 		suite.T(),
 		w,
+		oauth.ErrorCodeInvalidGrant,
 		oauth.ErrInvalidRedirectURI.Error(),
 		400,
 	)
@@ -208,6 +212,7 @@ func (suite *OauthTestSuite) TestAuthorizationCodeGrant() {
 		ExpiresIn:    3600,
 		TokenType:    tokentypes.Bearer,
 		Scope:        "read_write tenantadmin",
+		Role:         "tenantadmin",
 		RefreshToken: refreshToken.Token,
 	}
 	testutil.TestResponseObject(suite.T(), w, expected, 200)
@@ -9,7 +9,12 @@ import (
 
 func (s *Service) clientCredentialsGrant(r *http.Request, client *model.Client) (*AccessTokenResponse, error) {
 	// Get the scope string
-	scope, err := s.GetScope(r.Form.Get("scope"))
+	scope, err := s.GetScope(client, r.Form.Get("scope"))
+	if err != nil {
+		return nil, err
+	}
+
+	expiresIn, err := s.AccessTokenLifetime(client)
 	if err != nil {
 		return nil, err
 	}
@@ -17,9 +22,10 @@ func (s *Service) clientCredentialsGrant(r *http.Request, client *model.Client)
 	// Create a new access token
 	accessToken, err := s.GrantAccessToken(
 		client,
-		nil,                             // empty user
-		s.cnf.Oauth.AccessTokenLifetime, // expires in
+		nil,       // empty user
+		expiresIn, // expires in
 		scope,
+		nil, // no device session
 	)
 	if err != nil {
 		return nil, err
@@ -29,7 +35,7 @@ func (s *Service) clientCredentialsGrant(r *http.Request, client *model.Client)
 	accessTokenResponse, err := NewAccessTokenResponse(
 		accessToken,
 		nil, // refresh token
-		s.cnf.Oauth.AccessTokenLifetime,
+		expiresIn,
 		tokentypes.Bearer,
 	)
 	if err != nil {
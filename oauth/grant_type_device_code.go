@@ -0,0 +1,50 @@
+package oauth
+
+import (
+	"net/http"
+
+	"github.com/resonatecoop/id/oauth/tokentypes"
+	"github.com/resonatecoop/user-api/model"
+)
+
+func (s *Service) deviceCodeGrant(r *http.Request, client *model.Client) (*AccessTokenResponse, error) {
+	// Fetch the device code, once approved by the user
+	deviceCode, err := s.getApprovedDeviceCode(r.Form.Get("device_code"), client)
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := s.FindUserByID(deviceCode.UserID.String())
+	if err != nil {
+		return nil, err
+	}
+
+	// Delete the device code before logging in, it can only be used once
+	if err := s.claimDeviceCode(deviceCode); err != nil {
+		return nil, err
+	}
+
+	// Log in the user
+	accessToken, refreshToken, err := s.Login(client, user, deviceCode.Scope, s.NewDeviceSession(client, user, r))
+	if err != nil {
+		return nil, err
+	}
+
+	expiresIn, err := s.AccessTokenLifetime(client)
+	if err != nil {
+		return nil, err
+	}
+
+	// Create response
+	accessTokenResponse, err := NewAccessTokenResponse(
+		accessToken,
+		refreshToken,
+		expiresIn,
+		tokentypes.Bearer,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return accessTokenResponse, nil
+}
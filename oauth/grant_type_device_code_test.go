@@ -0,0 +1,146 @@
+package oauth_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"time"
+
+	"github.com/resonatecoop/id/oauth"
+	testutil "github.com/resonatecoop/id/test-util"
+	"github.com/stretchr/testify/assert"
+)
+
+func (suite *OauthTestSuite) TestDeviceAuthorization() {
+	// Prepare a request
+	r, err := http.NewRequest("POST", "http://1.2.3.4/v1/oauth/device_authorization", nil)
+	assert.NoError(suite.T(), err, "Request setup should not get an error")
+	r.SetBasicAuth("test_client_1", "test_secret")
+	r.PostForm = url.Values{
+		"client_id": {"test_client_1"},
+		"scope":     {"read_write"},
+	}
+
+	// Serve the request
+	w := httptest.NewRecorder()
+	suite.router.ServeHTTP(w, r)
+
+	assert.Equal(suite.T(), http.StatusOK, w.Code)
+
+	resp := new(oauth.DeviceAuthorizationResponse)
+	assert.NoError(suite.T(), json.Unmarshal(w.Body.Bytes(), resp))
+	assert.NotEmpty(suite.T(), resp.DeviceCode)
+	assert.Regexp(suite.T(), "^[BCDFGHJKLMNPQRSTVWXZ]{4}-[BCDFGHJKLMNPQRSTVWXZ]{4}$", resp.UserCode)
+	assert.Equal(suite.T(), "https://"+suite.cnf.Hostname+"/web/device", resp.VerificationURI)
+	assert.Equal(suite.T(), 5, resp.Interval)
+
+	// The user code is found ignoring case and dashes
+	deviceCode, err := suite.service.FindDeviceCodeByUserCode(oauth.NormalizeUserCode(resp.UserCode))
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), "read_write", deviceCode.Scope)
+	assert.Equal(suite.T(), suite.clients[0].ID, deviceCode.Client.ID)
+}
+
+func (suite *OauthTestSuite) TestDeviceAuthorizationClientAuth() {
+	resp, err := suite.service.RegisterClient(&oauth.ClientRegistrationRequest{
+		ClientName:              "Stream Player",
+		GrantTypes:              []string{oauth.DeviceCodeGrantType},
+		TokenEndpointAuthMethod: oauth.TokenEndpointAuthMethodNone,
+	}, false)
+	assert.NoError(suite.T(), err)
+
+	deviceAuthorization := func(clientID string) *httptest.ResponseRecorder {
+		r, err := http.NewRequest("POST", "http://1.2.3.4/v1/oauth/device_authorization", nil)
+		assert.NoError(suite.T(), err, "Request setup should not get an error")
+		r.PostForm = url.Values{"client_id": {clientID}}
+		w := httptest.NewRecorder()
+		suite.router.ServeHTTP(w, r)
+		return w
+	}
+
+	// Clients registered as public start the flow with the client ID only
+	w := deviceAuthorization(resp.ClientID)
+	assert.Equal(suite.T(), http.StatusOK, w.Code)
+
+	// Other clients must authenticate
+	w = deviceAuthorization("test_client_1")
+	testutil.TestResponseForOauthError(suite.T(), w, oauth.ErrorCodeInvalidClient, oauth.ErrInvalidClientIDOrSecret.Error(), 401)
+
+	confidential, err := suite.service.RegisterClient(&oauth.ClientRegistrationRequest{
+		ClientName: "Set-top Box",
+		GrantTypes: []string{oauth.DeviceCodeGrantType},
+	}, false)
+	assert.NoError(suite.T(), err)
+
+	w = deviceAuthorization(confidential.ClientID)
+	testutil.TestResponseForOauthError(suite.T(), w, oauth.ErrorCodeInvalidClient, oauth.ErrInvalidClientIDOrSecret.Error(), 401)
+}
+
+func (suite *OauthTestSuite) TestDeviceCodeGrant() {
+	deviceCode, err := suite.service.GrantDeviceCode(suite.clients[0], "read_write")
+	assert.NoError(suite.T(), err)
+
+	poll := func() *httptest.ResponseRecorder {
+		r, err := http.NewRequest("POST", "http://1.2.3.4/v1/oauth/tokens", nil)
+		assert.NoError(suite.T(), err, "Request setup should not get an error")
+		r.PostForm = url.Values{
+			"grant_type":  {oauth.DeviceCodeGrantType},
+			"device_code": {deviceCode.Code},
+			"client_id":   {"test_client_1"},
+		}
+		w := httptest.NewRecorder()
+		suite.router.ServeHTTP(w, r)
+		return w
+	}
+
+	testutil.TestResponseForOauthError(suite.T(), poll(), oauth.ErrorCodeAuthorizationPending, "", 400)
+
+	// Polling faster than the interval slows the device down
+	testutil.TestResponseForOauthError(suite.T(), poll(), oauth.ErrorCodeSlowDown, "", 400)
+
+	assert.NoError(suite.T(), suite.service.ApproveDeviceCode(deviceCode, suite.users[0]))
+
+	// A user code can only be used once
+	_, err = suite.service.FindDeviceCodeByUserCode(deviceCode.UserCode)
+	assert.Equal(suite.T(), oauth.ErrUserCodeNotFound, err)
+
+	_, err = suite.db.NewUpdate().
+		Model((*oauth.DeviceCode)(nil)).
+		Set("last_polled_at = ?", time.Now().UTC().Add(-time.Minute)).
+		Where("code = ?", deviceCode.Code).
+		Exec(context.Background())
+	assert.NoError(suite.T(), err)
+
+	w := poll()
+	assert.Equal(suite.T(), http.StatusOK, w.Code)
+
+	resp := new(oauth.AccessTokenResponse)
+	assert.NoError(suite.T(), json.Unmarshal(w.Body.Bytes(), resp))
+	assert.Equal(suite.T(), suite.users[0].ID.String(), resp.UserID)
+	assert.Equal(suite.T(), "read_write", resp.Scope)
+	assert.NotEmpty(suite.T(), resp.RefreshToken)
+
+	// The device code is gone once redeemed
+	testutil.TestResponseForOauthError(suite.T(), poll(), oauth.ErrorCodeInvalidGrant, oauth.ErrDeviceCodeNotFound.Error(), 400)
+}
+
+func (suite *OauthTestSuite) TestDeviceCodeGrantDenied() {
+	deviceCode, err := suite.service.GrantDeviceCode(suite.clients[0], "read_write")
+	assert.NoError(suite.T(), err)
+
+	assert.NoError(suite.T(), suite.service.DenyDeviceCode(deviceCode))
+
+	r, err := http.NewRequest("POST", "http://1.2.3.4/v1/oauth/tokens", nil)
+	assert.NoError(suite.T(), err, "Request setup should not get an error")
+	r.PostForm = url.Values{
+		"grant_type":  {oauth.DeviceCodeGrantType},
+		"device_code": {deviceCode.Code},
+		"client_id":   {"test_client_1"},
+	}
+	w := httptest.NewRecorder()
+	suite.router.ServeHTTP(w, r)
+
+	testutil.TestResponseForOauthError(suite.T(), w, oauth.ErrorCodeAccessDenied, "", 400)
+}
@@ -11,11 +11,13 @@ import (
 var (
 	// ErrInvalidUsernameOrPassword ...
 	ErrInvalidUsernameOrPassword = errors.New("Invalid username or password")
+	// ErrSecondFactorRequired ...
+	ErrSecondFactorRequired = errors.New("Two-factor authentication is required, use the authorization code flow")
 )
 
 func (s *Service) passwordGrant(r *http.Request, client *model.Client) (*AccessTokenResponse, error) {
 	// Get the scope string
-	scope, err := s.GetScope(r.Form.Get("scope"))
+	scope, err := s.GetScope(client, r.Form.Get("scope"))
 	if err != nil {
 		return nil, err
 	}
@@ -27,8 +29,19 @@ func (s *Service) passwordGrant(r *http.Request, client *model.Client) (*AccessT
 		return nil, ErrInvalidUsernameOrPassword
 	}
 
+	// The grant has no second step, so users with a second factor log in
+	// through the web login instead
+	if s.IsTOTPRequired(user) || s.IsTOTPEnabled(user) || s.HasWebAuthnCredentials(user) {
+		return nil, ErrSecondFactorRequired
+	}
+
 	// Log in the user
-	accessToken, refreshToken, err := s.Login(client, user, scope)
+	accessToken, refreshToken, err := s.Login(client, user, scope, s.NewDeviceSession(client, user, r))
+	if err != nil {
+		return nil, err
+	}
+
+	expiresIn, err := s.AccessTokenLifetime(client)
 	if err != nil {
 		return nil, err
 	}
@@ -37,7 +50,7 @@ func (s *Service) passwordGrant(r *http.Request, client *model.Client) (*AccessT
 	accessTokenResponse, err := NewAccessTokenResponse(
 		accessToken,
 		refreshToken,
-		s.cnf.Oauth.AccessTokenLifetime,
+		expiresIn,
 		tokentypes.Bearer,
 	)
 	if err != nil {
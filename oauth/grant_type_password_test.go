@@ -5,7 +5,9 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"net/url"
+	"time"
 
+	"github.com/pquerna/otp/totp"
 	"github.com/resonatecoop/id/oauth"
 
 	"github.com/resonatecoop/id/oauth/tokentypes"
@@ -51,6 +53,11 @@ func (suite *OauthTestSuite) TestPasswordGrant() {
 	// a refresh token is founds
 	assert.Nil(suite.T(), err)
 
+	user, err := suite.service.FindUserByUsername("test@user.com")
+	assert.NoError(suite.T(), err)
+	role, err := suite.service.GetRoleName(user.RoleID)
+	assert.NoError(suite.T(), err)
+
 	// Check the response
 	expected := &oauth.AccessTokenResponse{
 		UserID:       accessToken.UserID.String(),
@@ -58,6 +65,7 @@ func (suite *OauthTestSuite) TestPasswordGrant() {
 		ExpiresIn:    3600,
 		TokenType:    tokentypes.Bearer,
 		Scope:        "read_write artist",
+		Role:         role,
 		RefreshToken: refreshToken.Token,
 	}
 	testutil.TestResponseObject(suite.T(), w, expected, 200)
@@ -82,12 +90,62 @@ func (suite *OauthTestSuite) TestPasswordGrantWithRoleRestriction() {
 	suite.router.ServeHTTP(w, r)
 
 	// Check the response
-	testutil.TestResponseForError(
+	testutil.TestResponseForOauthError(
 		suite.T(),
 		w,
+		oauth.ErrorCodeInvalidGrant,
 		oauth.ErrInvalidUsernameOrPassword.Error(),
-		401,
+		400,
 	)
 
 	suite.service.RestrictToRoles(int32(model.SuperAdminRole), int32(model.AdminRole), int32(model.TenantAdminRole), int32(model.LabelRole), int32(model.ArtistRole), int32(model.UserRole))
 }
+
+func (suite *OauthTestSuite) TestPasswordGrantWithSecondFactor() {
+	defer suite.service.RequireTOTPForRoles(suite.cnf.TOTP.RequiredRoles...)
+
+	user, err := suite.service.FindUserByUsername("test@user.com")
+	assert.NoError(suite.T(), err)
+
+	passwordGrant := func() *httptest.ResponseRecorder {
+		r, err := http.NewRequest("POST", "http://1.2.3.4/v1/oauth/tokens", nil)
+		assert.NoError(suite.T(), err, "Request setup should not get an error")
+		r.SetBasicAuth("test_client_1", "test_secret")
+		r.PostForm = url.Values{
+			"grant_type": {"password"},
+			"username":   {"test@user.com"},
+			"password":   {"test_password"},
+			"scope":      {"read_write"},
+		}
+		w := httptest.NewRecorder()
+		suite.router.ServeHTTP(w, r)
+		return w
+	}
+
+	// Roles which require two-factor authentication cannot use the grant
+	suite.service.RequireTOTPForRoles(user.RoleID)
+	testutil.TestResponseForOauthError(
+		suite.T(),
+		passwordGrant(),
+		oauth.ErrorCodeInvalidGrant,
+		oauth.ErrSecondFactorRequired.Error(),
+		400,
+	)
+	suite.service.RequireTOTPForRoles()
+
+	// Nor can users who enabled two-factor authentication
+	key, err := suite.service.EnrollTOTP(user)
+	assert.NoError(suite.T(), err)
+	code, err := totp.GenerateCode(key.Secret(), time.Now())
+	assert.NoError(suite.T(), err)
+	_, err = suite.service.EnableTOTP(user, code)
+	assert.NoError(suite.T(), err)
+
+	testutil.TestResponseForOauthError(
+		suite.T(),
+		passwordGrant(),
+		oauth.ErrorCodeInvalidGrant,
+		oauth.ErrSecondFactorRequired.Error(),
+		400,
+	)
+}
@@ -8,6 +8,13 @@ import (
 )
 
 func (s *Service) refreshTokenGrant(r *http.Request, client *model.Client) (*AccessTokenResponse, error) {
+	// Presenting a rotated refresh token again revokes its whole family
+	if s.cnf.Oauth.RefreshTokenRotation {
+		if err := s.checkRefreshTokenReuse(r.Form.Get("refresh_token"), client); err != nil {
+			return nil, err
+		}
+	}
+
 	// Fetch the refresh token
 	theRefreshToken, err := s.GetValidRefreshToken(r.Form.Get("refresh_token"), client)
 	if err != nil {
@@ -20,26 +27,54 @@ func (s *Service) refreshTokenGrant(r *http.Request, client *model.Client) (*Acc
 		return nil, err
 	}
 
+	// Keep using the device session the refresh token was granted for
+	deviceSession := s.GetRefreshTokenDeviceSession(theRefreshToken, r)
+
+	// With rotation, the refresh token is replaced by the one returned
+	var rotatedRefreshToken *model.RefreshToken
+	if s.cnf.Oauth.RefreshTokenRotation {
+		rotatedRefreshToken = theRefreshToken
+	}
+
 	// Log in the user
-	accessToken, refreshToken, err := s.Login(
+	accessToken, refreshToken, err := s.login(
 		theRefreshToken.Client,
 		theRefreshToken.User,
 		scope,
+		deviceSession,
+		rotatedRefreshToken,
 	)
 	if err != nil {
 		return nil, err
 	}
 
+	expiresIn, err := s.AccessTokenLifetime(client)
+	if err != nil {
+		return nil, err
+	}
+
 	// Create response
 	accessTokenResponse, err := NewAccessTokenResponse(
 		accessToken,
 		refreshToken,
-		s.cnf.Oauth.AccessTokenLifetime,
+		expiresIn,
 		tokentypes.Bearer,
 	)
 	if err != nil {
 		return nil, err
 	}
 
+	// Issue an ID token to OpenID Connect clients
+	if HasOpenIDScope(accessToken.Scope) {
+		accessTokenResponse.IDToken, err = s.GrantIDToken(
+			theRefreshToken.Client,
+			theRefreshToken.User,
+			"", // nonce is only sent with the authorization request
+		)
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	return accessTokenResponse, nil
 }
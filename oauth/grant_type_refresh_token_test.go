@@ -29,11 +29,12 @@ func (suite *OauthTestSuite) TestRefreshTokenGrantEmptyNotFound() {
 	suite.router.ServeHTTP(w, r)
 
 	// Check the response
-	testutil.TestResponseForError(
+	testutil.TestResponseForOauthError(
 		suite.T(),
 		w,
+		oauth.ErrorCodeInvalidGrant,
 		oauth.ErrRefreshTokenNotFound.Error(),
-		404,
+		400,
 	)
 }
 
@@ -52,11 +53,12 @@ func (suite *OauthTestSuite) TestRefreshTokenGrantBogusNotFound() {
 	suite.router.ServeHTTP(w, r)
 
 	// Check the response
-	testutil.TestResponseForError(
+	testutil.TestResponseForOauthError(
 		suite.T(),
 		w,
+		oauth.ErrorCodeInvalidGrant,
 		oauth.ErrRefreshTokenNotFound.Error(),
-		404,
+		400,
 	)
 }
 
@@ -95,9 +97,10 @@ func (suite *OauthTestSuite) TestRefreshTokenGrantExipired() {
 	suite.router.ServeHTTP(w, r)
 
 	// Check the response
-	testutil.TestResponseForError(
+	testutil.TestResponseForOauthError(
 		suite.T(),
 		w,
+		oauth.ErrorCodeInvalidGrant,
 		oauth.ErrRefreshTokenExpired.Error(),
 		400,
 	)
@@ -138,9 +141,10 @@ func (suite *OauthTestSuite) TestRefreshTokenGrantScopeCannotBeGreater() {
 	suite.router.ServeHTTP(w, r)
 
 	// Check the response
-	testutil.TestResponseForError(
+	testutil.TestResponseForOauthError(
 		suite.T(),
 		w,
+		oauth.ErrorCodeInvalidScope,
 		oauth.ErrRequestedScopeCannotBeGreater.Error(),
 		400,
 	)
@@ -209,6 +213,7 @@ func (suite *OauthTestSuite) TestRefreshTokenGrantDefaultsToOriginalScope() {
 		ExpiresIn:    3600,
 		TokenType:    tokentypes.Bearer,
 		Scope:        "read_write tenantadmin",
+		Role:         "tenantadmin",
 		RefreshToken: refreshToken.Token,
 	}
 	testutil.TestResponseObject(suite.T(), w, expected, 200)
@@ -277,6 +282,7 @@ func (suite *OauthTestSuite) TestRefreshTokenGrant() {
 		ExpiresIn:    3600,
 		TokenType:    tokentypes.Bearer,
 		Scope:        "read_write tenantadmin",
+		Role:         "tenantadmin",
 		RefreshToken: refreshToken.Token,
 	}
 	testutil.TestResponseObject(suite.T(), w, expected, 200)
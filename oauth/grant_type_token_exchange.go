@@ -0,0 +1,108 @@
+package oauth
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/resonatecoop/id/oauth/tokentypes"
+	"github.com/resonatecoop/id/util"
+	"github.com/resonatecoop/user-api/model"
+)
+
+func (s *Service) tokenExchangeGrant(r *http.Request, client *model.Client) (*AccessTokenResponse, error) {
+	if r.Form.Get("subject_token") == "" {
+		return nil, ErrSubjectTokenMissing
+	}
+
+	if r.Form.Get("subject_token_type") != AccessTokenType {
+		return nil, ErrInvalidSubjectTokenType
+	}
+
+	// Only access tokens are issued
+	if requestedTokenType := r.Form.Get("requested_token_type"); requestedTokenType != "" && requestedTokenType != AccessTokenType {
+		return nil, ErrInvalidRequestedTokenType
+	}
+
+	// The target service must be a registered client
+	audience := r.Form.Get("audience")
+	if audience == "" {
+		return nil, ErrAudienceMissing
+	}
+	if _, err := s.FindClientByClientID(audience); err != nil {
+		return nil, ErrInvalidAudience
+	}
+
+	// Authenticate the subject token
+	subjectToken, err := s.Authenticate(r.Form.Get("subject_token"))
+	if err != nil {
+		return nil, err
+	}
+
+	// Requested scope CANNOT include any scope not granted to the subject token
+	scope := subjectToken.Scope
+	if r.Form.Get("scope") != "" {
+		scope, err = s.GetScope(client, r.Form.Get("scope"))
+		if err != nil {
+			return nil, err
+		}
+		if !util.SpaceDelimitedStringNotGreater(scope, subjectToken.Scope) {
+			return nil, ErrRequestedScopeCannotBeGreater
+		}
+	}
+
+	// The inherited scope must be within the exchanging client's policy too
+	if err := s.authorizeScope(client, scope); err != nil {
+		return nil, err
+	}
+
+	var user *model.User
+	if subjectToken.UserID != uuid.Nil {
+		user, err = s.FindUserByID(subjectToken.UserID.String())
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// The client becomes the actor, after any previous actor of the subject token
+	act := &Actor{Subject: client.Key}
+	if previous, err := s.findTokenExchange(subjectToken.Token); err == nil {
+		act.Actor = previous.Act
+	}
+
+	// The exchanged token does not outlive the subject token
+	expiresIn, err := s.AccessTokenLifetime(client)
+	if err != nil {
+		return nil, err
+	}
+	remaining := int(time.Until(subjectToken.ExpiresAt).Seconds())
+	if remaining <= 0 {
+		return nil, ErrAccessTokenExpired
+	}
+	if remaining < expiresIn {
+		expiresIn = remaining
+	}
+
+	accessToken, err := s.grantAccessToken(client, user, expiresIn, scope, nil, &TokenExchange{
+		Audience: audience,
+		Act:      act,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Create response, without a refresh token
+	accessTokenResponse, err := NewAccessTokenResponse(
+		accessToken,
+		nil,
+		expiresIn,
+		tokentypes.Bearer,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	accessTokenResponse.IssuedTokenType = AccessTokenType
+
+	return accessTokenResponse, nil
+}
@@ -0,0 +1,166 @@
+package oauth_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+
+	"github.com/resonatecoop/id/oauth"
+	testutil "github.com/resonatecoop/id/test-util"
+	"github.com/resonatecoop/user-api/model"
+	"github.com/stretchr/testify/assert"
+)
+
+// allowTokenExchange sets a policy for a client created before dynamic
+// registration, only clients registered for token exchange may use it
+func (suite *OauthTestSuite) allowTokenExchange(client *model.Client) {
+	_, err := suite.db.NewInsert().
+		Model(&oauth.ClientMetadata{
+			ClientID:                client.ID,
+			GrantTypes:              []string{"password", "refresh_token", "client_credentials", oauth.TokenExchangeGrantType},
+			TokenEndpointAuthMethod: oauth.TokenEndpointAuthMethodBasic,
+			Scope:                   "read read_write",
+		}).
+		Exec(context.Background())
+	assert.NoError(suite.T(), err)
+}
+
+func (suite *OauthTestSuite) exchangeToken(subjectToken, audience, scope string) *httptest.ResponseRecorder {
+	r, err := http.NewRequest("POST", "http://1.2.3.4/v1/oauth/tokens", nil)
+	assert.NoError(suite.T(), err, "Request setup should not get an error")
+	r.SetBasicAuth("test_client_1", "test_secret")
+	r.PostForm = url.Values{
+		"grant_type":         {oauth.TokenExchangeGrantType},
+		"subject_token":      {subjectToken},
+		"subject_token_type": {oauth.AccessTokenType},
+		"audience":           {audience},
+		"scope":              {scope},
+	}
+
+	w := httptest.NewRecorder()
+	suite.router.ServeHTTP(w, r)
+	return w
+}
+
+func (suite *OauthTestSuite) TestTokenExchangeGrant() {
+	suite.allowTokenExchange(suite.clients[0])
+
+	subjectToken, _, err := suite.service.Login(suite.clients[1], suite.users[0], "read_write", nil)
+	assert.NoError(suite.T(), err)
+
+	w := suite.exchangeToken(subjectToken.Token, suite.clients[1].Key, "")
+	assert.Equal(suite.T(), http.StatusOK, w.Code)
+
+	resp := new(oauth.AccessTokenResponse)
+	assert.NoError(suite.T(), json.Unmarshal(w.Body.Bytes(), resp))
+	assert.Equal(suite.T(), oauth.AccessTokenType, resp.IssuedTokenType)
+	assert.Equal(suite.T(), subjectToken.Scope, resp.Scope)
+	assert.Equal(suite.T(), suite.users[0].ID.String(), resp.UserID)
+	assert.Empty(suite.T(), resp.RefreshToken)
+
+	// The acting client and audience are introspected
+	accessToken, err := suite.service.Authenticate(resp.AccessToken)
+	assert.NoError(suite.T(), err)
+	introspectResponse, err := suite.service.NewIntrospectResponseFromAccessToken(accessToken)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), "test_client_1", introspectResponse.ClientID)
+	assert.Equal(suite.T(), suite.clients[1].Key, introspectResponse.Audience)
+	assert.Equal(suite.T(), &oauth.Actor{Subject: "test_client_1"}, introspectResponse.Act)
+
+	// Exchanging again nests the previous actor
+	w = suite.exchangeToken(resp.AccessToken, suite.clients[1].Key, "")
+	assert.Equal(suite.T(), http.StatusOK, w.Code)
+
+	resp = new(oauth.AccessTokenResponse)
+	assert.NoError(suite.T(), json.Unmarshal(w.Body.Bytes(), resp))
+	accessToken, err = suite.service.Authenticate(resp.AccessToken)
+	assert.NoError(suite.T(), err)
+	introspectResponse, err = suite.service.NewIntrospectResponseFromAccessToken(accessToken)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), &oauth.Actor{
+		Subject: "test_client_1",
+		Actor:   &oauth.Actor{Subject: "test_client_1"},
+	}, introspectResponse.Act)
+}
+
+func (suite *OauthTestSuite) TestTokenExchangeGrantErrors() {
+	subjectToken, _, err := suite.service.Login(suite.clients[1], suite.users[0], "read", nil)
+	assert.NoError(suite.T(), err)
+
+	// Clients without a policy cannot exchange tokens
+	testutil.TestResponseForOauthError(
+		suite.T(),
+		suite.exchangeToken(subjectToken.Token, suite.clients[1].Key, ""),
+		oauth.ErrorCodeUnauthorizedClient,
+		"",
+		400,
+	)
+
+	suite.allowTokenExchange(suite.clients[0])
+
+	testutil.TestResponseForOauthError(
+		suite.T(),
+		suite.exchangeToken(subjectToken.Token, suite.clients[1].Key, "read_write"),
+		oauth.ErrorCodeInvalidScope,
+		oauth.ErrRequestedScopeCannotBeGreater.Error(),
+		400,
+	)
+
+	testutil.TestResponseForOauthError(
+		suite.T(),
+		suite.exchangeToken(subjectToken.Token, "bogus", ""),
+		oauth.ErrorCodeInvalidTarget,
+		oauth.ErrInvalidAudience.Error(),
+		400,
+	)
+
+	testutil.TestResponseForOauthError(
+		suite.T(),
+		suite.exchangeToken("bogus", suite.clients[1].Key, ""),
+		oauth.ErrorCodeInvalidGrant,
+		oauth.ErrAccessTokenNotFound.Error(),
+		400,
+	)
+}
+
+func (suite *OauthTestSuite) TestTokenExchangeGrantWithClientPolicy() {
+	resp, err := suite.service.RegisterClient(&oauth.ClientRegistrationRequest{
+		ClientName: "Partner Service",
+		GrantTypes: []string{oauth.TokenExchangeGrantType},
+		Scope:      "read",
+	}, true)
+	assert.NoError(suite.T(), err)
+
+	subjectToken, _, err := suite.service.Login(suite.clients[1], suite.users[0], "read_write", nil)
+	assert.NoError(suite.T(), err)
+
+	exchangeToken := func(scope string) *httptest.ResponseRecorder {
+		r, err := http.NewRequest("POST", "http://1.2.3.4/v1/oauth/tokens", nil)
+		assert.NoError(suite.T(), err, "Request setup should not get an error")
+		r.SetBasicAuth(resp.ClientID, resp.ClientSecret)
+		r.PostForm = url.Values{
+			"grant_type":         {oauth.TokenExchangeGrantType},
+			"subject_token":      {subjectToken.Token},
+			"subject_token_type": {oauth.AccessTokenType},
+			"audience":           {suite.clients[1].Key},
+			"scope":              {scope},
+		}
+		w := httptest.NewRecorder()
+		suite.router.ServeHTTP(w, r)
+		return w
+	}
+
+	// The scope inherited from the subject token is outside the client's policy
+	testutil.TestResponseForOauthError(
+		suite.T(),
+		exchangeToken(""),
+		oauth.ErrorCodeUnauthorizedClient,
+		"",
+		400,
+	)
+
+	w := exchangeToken("read")
+	assert.Equal(suite.T(), http.StatusOK, w.Code)
+}
@@ -1,9 +1,12 @@
 package oauth
 
 import (
+	"encoding/json"
 	"errors"
 	"net/http"
 
+	"github.com/gorilla/mux"
+	"github.com/resonatecoop/id/util"
 	"github.com/resonatecoop/id/util/response"
 	"github.com/resonatecoop/user-api/model"
 )
@@ -13,6 +16,8 @@ var (
 	ErrInvalidGrantType = errors.New("Invalid grant type")
 	// ErrInvalidClientIDOrSecret ...
 	ErrInvalidClientIDOrSecret = errors.New("Invalid client ID or secret")
+	// ErrInvalidRequest ...
+	ErrInvalidRequest = errors.New("Invalid request")
 )
 
 // tokensHandler handles all OAuth 2.0 grant types
@@ -20,57 +25,264 @@ var (
 func (s *Service) tokensHandler(w http.ResponseWriter, r *http.Request) {
 	// Parse the form so r.Form becomes available
 	if err := r.ParseForm(); err != nil {
-		response.Error(w, err.Error(), http.StatusInternalServerError)
+		s.writeOauthError(w, ErrInvalidRequest)
 		return
 	}
 
 	// Map of grant types against handler functions
 	grantTypes := map[string]func(r *http.Request, client *model.Client) (*AccessTokenResponse, error){
-		"authorization_code": s.authorizationCodeGrant,
-		"password":           s.passwordGrant,
-		"client_credentials": s.clientCredentialsGrant,
-		"refresh_token":      s.refreshTokenGrant,
+		"authorization_code":   s.authorizationCodeGrant,
+		"password":             s.passwordGrant,
+		"client_credentials":   s.clientCredentialsGrant,
+		"refresh_token":        s.refreshTokenGrant,
+		DeviceCodeGrantType:    s.deviceCodeGrant,
+		TokenExchangeGrantType: s.tokenExchangeGrant,
 	}
 
 	// Check the grant type
 	grantHandler, ok := grantTypes[r.Form.Get("grant_type")]
 	if !ok {
-		response.Error(w, ErrInvalidGrantType.Error(), http.StatusBadRequest)
+		s.writeOauthError(w, ErrInvalidGrantType)
 		return
 	}
 
 	// Client auth
-	client, err := s.basicAuthClient(r)
+	client, method, err := s.authClient(r)
+	if err == nil && method == TokenEndpointAuthMethodNone {
+		err = s.checkPublicGrant(r, client)
+	}
 	if err != nil {
-		response.UnauthorizedError(w, err.Error())
+		s.writeOauthError(w, err)
+		return
+	}
+
+	// The client must be allowed to use the grant type
+	if err := s.AuthorizeGrantType(client, r.Form.Get("grant_type")); err != nil {
+		s.writeOauthError(w, err)
+		return
+	}
+
+	// Clients holding a DPoP key prove possession of it
+	jkt, err := s.verifyDPoPProof(r, "")
+	if err != nil {
+		s.writeOauthError(w, err)
+		return
+	}
+
+	// Bound refresh and subject tokens need a proof signed by the same key
+	switch r.Form.Get("grant_type") {
+	case "refresh_token":
+		err = s.checkTokenBinding(r.Form.Get("refresh_token"), jkt)
+	case TokenExchangeGrantType:
+		err = s.checkSubjectTokenBinding(r.Form.Get("subject_token"), jkt)
+	}
+	if err != nil {
+		s.writeOauthError(w, err)
 		return
 	}
 
 	// Grant processing
 	resp, err := grantHandler(r, client)
 	if err != nil {
-		response.Error(w, err.Error(), getErrStatusCode(err))
+		s.writeOauthError(w, err)
 		return
 	}
 
+	// Tokens granted to a user carry the user's role
+	if resp.UserID != "" {
+		resp.Role, err = s.getUserRoleName(resp.UserID)
+		if err != nil {
+			s.writeOauthError(w, err)
+			return
+		}
+	}
+
+	// Bind the issued tokens to the DPoP key
+	if jkt != "" {
+		if err := s.bindTokenResponse(resp, jkt); err != nil {
+			s.writeOauthError(w, err)
+			return
+		}
+	}
+
 	// Write response to json
 	response.WriteJSON(w, resp, 200)
 }
 
+// deviceAuthorizationHandler starts a device authorization request
+// (POST /v1/oauth/device_authorization)
+func (s *Service) deviceAuthorizationHandler(w http.ResponseWriter, r *http.Request) {
+	// Parse the form so r.Form becomes available
+	if err := r.ParseForm(); err != nil {
+		s.writeOauthError(w, ErrInvalidRequest)
+		return
+	}
+
+	// Client auth, devices usually run clients registered as public which
+	// start the flow with the client ID only
+	client, method, err := s.authClient(r)
+	if err == nil && method == TokenEndpointAuthMethodNone {
+		err = s.checkPublicClient(client)
+	}
+	if err != nil {
+		s.writeOauthError(w, err)
+		return
+	}
+
+	// The client must be allowed to use the device code grant
+	if err := s.AuthorizeGrantType(client, DeviceCodeGrantType); err != nil {
+		s.writeOauthError(w, err)
+		return
+	}
+
+	// Get the scope string
+	scope, err := s.GetScope(client, r.Form.Get("scope"))
+	if err != nil {
+		s.writeOauthError(w, err)
+		return
+	}
+
+	deviceCode, err := s.GrantDeviceCode(client, scope)
+	if err != nil {
+		s.writeOauthError(w, err)
+		return
+	}
+
+	// Write response to json
+	response.WriteJSON(w, NewDeviceAuthorizationResponse(deviceCode, s.GetIssuer()+"/web/device"), 200)
+}
+
+// parHandler stores a pushed authorization request (RFC 9126)
+// (POST /v1/oauth/par)
+func (s *Service) parHandler(w http.ResponseWriter, r *http.Request) {
+	// Parse the form so r.Form becomes available
+	if err := r.ParseForm(); err != nil {
+		s.writeOauthError(w, ErrInvalidRequest)
+		return
+	}
+
+	// Client auth, clients registered as public push their requests with the
+	// client ID only
+	client, method, err := s.authClient(r)
+	if err == nil && method == TokenEndpointAuthMethodNone {
+		err = s.checkPublicClient(client)
+	}
+	if err != nil {
+		s.writeOauthError(w, err)
+		return
+	}
+
+	pushedAuthorizationRequest, err := s.PushAuthorizationRequest(client, r.PostForm)
+	if err != nil {
+		s.writeOauthError(w, err)
+		return
+	}
+
+	// Write response to json
+	response.WriteJSON(w, NewPushedAuthorizationResponse(pushedAuthorizationRequest), http.StatusCreated)
+}
+
+// registerHandler registers a new client (RFC 7591)
+// (POST /v1/oauth/register)
+func (s *Service) registerHandler(w http.ResponseWriter, r *http.Request) {
+	// Partners need an initial access token, admins their access token
+	admin, err := s.authRegistration(r)
+	if err != nil {
+		response.UnauthorizedError(w, err.Error())
+		return
+	}
+
+	req := new(ClientRegistrationRequest)
+	if err := json.NewDecoder(r.Body).Decode(req); err != nil {
+		s.writeRegistrationError(w, ErrInvalidClientMetadata)
+		return
+	}
+
+	resp, err := s.RegisterClient(req, admin)
+	if err != nil {
+		s.writeRegistrationError(w, err)
+		return
+	}
+
+	// Write response to json
+	response.WriteJSON(w, resp, http.StatusCreated)
+}
+
+// clientConfigurationHandler reads, updates or deletes a registered client
+// (RFC 7592)
+// (GET|PUT|DELETE /v1/oauth/register/{client_id})
+func (s *Service) clientConfigurationHandler(w http.ResponseWriter, r *http.Request) {
+	// The registration access token only manages its own client, admins
+	// manage any client
+	admin := false
+	token, _ := util.ParseBearerToken(r)
+	client, err := s.AuthRegistrationAccessToken(mux.Vars(r)["client_id"], string(token))
+	if err != nil {
+		if _, adminErr := s.authAdmin(r); adminErr != nil {
+			response.UnauthorizedError(w, err.Error())
+			return
+		}
+		admin = true
+		client, err = s.FindClientByClientID(mux.Vars(r)["client_id"])
+		if err != nil {
+			response.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+	}
+
+	var resp *ClientRegistrationResponse
+
+	switch r.Method {
+	case http.MethodPut:
+		req := new(ClientRegistrationRequest)
+		if err := json.NewDecoder(r.Body).Decode(req); err != nil {
+			s.writeRegistrationError(w, ErrInvalidClientMetadata)
+			return
+		}
+		resp, err = s.UpdateClientRegistration(client, req, admin)
+	case http.MethodDelete:
+		if err := s.DeleteClient(client); err != nil {
+			response.Error(w, err.Error(), getErrStatusCode(err))
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+		return
+	default:
+		resp, err = s.GetClientRegistration(client)
+	}
+
+	if err != nil {
+		s.writeRegistrationError(w, err)
+		return
+	}
+
+	// Write response to json
+	response.WriteJSON(w, resp, http.StatusOK)
+}
+
 // introspectHandler handles OAuth 2.0 introspect request
 // (POST /v1/oauth/introspect)
 func (s *Service) introspectHandler(w http.ResponseWriter, r *http.Request) {
-	// Client auth
-	client, err := s.basicAuthClient(r)
+	// Parse the form so r.Form becomes available
+	if err := r.ParseForm(); err != nil {
+		s.writeOauthError(w, ErrInvalidRequest)
+		return
+	}
+
+	// Client auth, public clients cannot introspect tokens
+	client, method, err := s.authClient(r)
+	if err == nil && method == TokenEndpointAuthMethodNone {
+		err = ErrInvalidClientIDOrSecret
+	}
 	if err != nil {
-		response.UnauthorizedError(w, err.Error())
+		s.writeOauthError(w, err)
 		return
 	}
 
 	// Introspect the token
 	resp, err := s.introspectToken(r, client)
 	if err != nil {
-		response.Error(w, err.Error(), getErrStatusCode(err))
+		s.writeOauthError(w, err)
 		return
 	}
 
@@ -78,20 +290,83 @@ func (s *Service) introspectHandler(w http.ResponseWriter, r *http.Request) {
 	response.WriteJSON(w, resp, 200)
 }
 
-// Get client credentials from basic auth and try to authenticate client
-func (s *Service) basicAuthClient(r *http.Request) (*model.Client, error) {
-	// Get client credentials from basic auth
-	clientID, secret, ok := r.BasicAuth()
-	if !ok {
-		return nil, ErrInvalidClientIDOrSecret
+// revokeHandler handles OAuth 2.0 token revocation request
+// (POST /v1/oauth/revoke)
+func (s *Service) revokeHandler(w http.ResponseWriter, r *http.Request) {
+	// Parse the form so r.Form becomes available
+	if err := r.ParseForm(); err != nil {
+		s.writeOauthError(w, ErrInvalidRequest)
+		return
 	}
 
-	// Authenticate the client
-	client, err := s.AuthClient(clientID, secret)
+	// Client auth, clients registered as public revoke their own tokens
+	// with the client ID only
+	client, method, err := s.authClient(r)
+	if err == nil && method == TokenEndpointAuthMethodNone {
+		err = s.checkPublicClient(client)
+	}
 	if err != nil {
-		// For security reasons, return a general error message
-		return nil, ErrInvalidClientIDOrSecret
+		s.writeOauthError(w, err)
+		return
+	}
+
+	// Revoke the token
+	if err := s.revokeToken(r, client); err != nil {
+		s.writeOauthError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// userInfoHandler returns claims about the user the access token was granted for
+// (GET /v1/oauth/userinfo)
+func (s *Service) userInfoHandler(w http.ResponseWriter, r *http.Request) {
+	// Authenticate the access token
+	accessToken, err := s.AuthenticateRequest(r)
+	if err == ErrTokenMissing {
+		response.UnauthorizedError(w, err.Error())
+		return
+	}
+	if err != nil {
+		response.InvalidTokenError(w, err.Error())
+		return
+	}
+
+	// UserInfo is only available to OpenID Connect clients
+	if !HasOpenIDScope(accessToken.Scope) {
+		response.Error(w, ErrOpenIDScopeRequired.Error(), http.StatusForbidden)
+		return
+	}
+
+	// Fetch the user
+	user, err := s.FindUserByID(accessToken.UserID.String())
+	if err != nil {
+		response.InvalidTokenError(w, err.Error())
+		return
+	}
+
+	// Write response to json
+	response.WriteJSON(w, NewUserInfoResponse(user), 200)
+}
+
+// openIDConfigurationHandler returns the OpenID Connect discovery document
+// (GET /.well-known/openid-configuration)
+func (s *Service) openIDConfigurationHandler(w http.ResponseWriter, r *http.Request) {
+	response.WriteJSON(w, s.NewOpenIDConfiguration(), 200)
+}
+
+// jwksHandler returns the public keys tokens are signed with
+// (GET /.well-known/jwks.json)
+func (s *Service) jwksHandler(w http.ResponseWriter, r *http.Request) {
+	set, err := s.GetJWKS()
+	if err != nil {
+		response.Error(w, err.Error(), http.StatusInternalServerError)
+		return
 	}
 
-	return client, nil
+	// Resource servers may cache the keys, a rotated key stays published
+	// for the whole overlap period
+	w.Header().Set("Cache-Control", "public, max-age=3600")
+	response.WriteJSON(w, set, 200)
 }
@@ -4,6 +4,7 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"net/url"
+	"strings"
 
 	"github.com/resonatecoop/id/oauth"
 	testutil "github.com/resonatecoop/id/test-util"
@@ -21,9 +22,10 @@ func (suite *OauthTestSuite) TestTokensHandlerClientAuthenticationRequired() {
 	suite.router.ServeHTTP(w, r)
 
 	// Check the response
-	testutil.TestResponseForError(
+	testutil.TestResponseForOauthError(
 		suite.T(),
 		w,
+		oauth.ErrorCodeInvalidClient,
 		oauth.ErrInvalidClientIDOrSecret.Error(),
 		401,
 	)
@@ -41,14 +43,36 @@ func (suite *OauthTestSuite) TestTokensHandlerInvalidGrantType() {
 	suite.router.ServeHTTP(w, r)
 
 	// Check the response
-	testutil.TestResponseForError(
+	testutil.TestResponseForOauthError(
 		suite.T(),
 		w,
+		oauth.ErrorCodeUnsupportedGrantType,
 		oauth.ErrInvalidGrantType.Error(),
 		400,
 	)
 }
 
+func (suite *OauthTestSuite) TestTokensHandlerMalformedForm() {
+	// Make a request with a body which cannot be parsed
+	r, err := http.NewRequest("POST", "http://1.2.3.4/v1/oauth/tokens", strings.NewReader("grant_type=%zz"))
+	assert.NoError(suite.T(), err, "Request setup should not get an error")
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	r.SetBasicAuth("test_client_1", "test_secret")
+
+	// Serve the request
+	w := httptest.NewRecorder()
+	suite.router.ServeHTTP(w, r)
+
+	// Check the response
+	testutil.TestResponseForOauthError(
+		suite.T(),
+		w,
+		oauth.ErrorCodeInvalidRequest,
+		oauth.ErrInvalidRequest.Error(),
+		400,
+	)
+}
+
 func (suite *OauthTestSuite) TestIntrospectHandlerClientAuthenticationRequired() {
 	// Prepare a request
 	r, err := http.NewRequest("POST", "http://1.2.3.4/v1/oauth/introspect", nil)
@@ -60,9 +84,10 @@ func (suite *OauthTestSuite) TestIntrospectHandlerClientAuthenticationRequired()
 	suite.router.ServeHTTP(w, r)
 
 	// Check the response
-	testutil.TestResponseForError(
+	testutil.TestResponseForOauthError(
 		suite.T(),
 		w,
+		oauth.ErrorCodeInvalidClient,
 		oauth.ErrInvalidClientIDOrSecret.Error(),
 		401,
 	)
@@ -2,8 +2,10 @@ package oauth
 
 import (
 	"context"
+	"database/sql"
 	"errors"
 	"net/http"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/resonatecoop/id/oauth/tokentypes"
@@ -28,7 +30,7 @@ var (
 func (s *Service) introspectToken(r *http.Request, client *model.Client) (*IntrospectResponse, error) {
 	// Parse the form so r.Form becomes available
 	if err := r.ParseForm(); err != nil {
-		return nil, err
+		return nil, ErrInvalidRequest
 	}
 
 	// Get token from the query
@@ -45,62 +47,98 @@ func (s *Service) introspectToken(r *http.Request, client *model.Client) (*Intro
 		tokenTypeHint = AccessTokenHint
 	}
 
+	// The hint only decides which token type is looked up first
+	var tokenTypes []string
 	switch tokenTypeHint {
 	case AccessTokenHint:
-		accessToken, err := s.Authenticate(token)
-		if err != nil {
-			return nil, err
-		}
-		return s.NewIntrospectResponseFromAccessToken(accessToken)
+		tokenTypes = []string{AccessTokenHint, RefreshTokenHint}
 	case RefreshTokenHint:
-		refreshToken, err := s.GetValidRefreshToken(token, client)
-		if err != nil {
-			return nil, err
-		}
-		return s.NewIntrospectResponseFromRefreshToken(refreshToken)
+		tokenTypes = []string{RefreshTokenHint, AccessTokenHint}
 	default:
 		return nil, ErrTokenHintInvalid
 	}
+
+	var (
+		introspectResponse *IntrospectResponse
+		err                error
+	)
+	for _, tokenType := range tokenTypes {
+		switch tokenType {
+		case AccessTokenHint:
+			var accessToken *model.AccessToken
+			accessToken, err = s.Authenticate(token)
+			if err == nil {
+				introspectResponse, err = s.introspectAccessToken(accessToken, client)
+			}
+		case RefreshTokenHint:
+			var refreshToken *model.RefreshToken
+			refreshToken, err = s.GetValidRefreshToken(token, client)
+			if err == nil {
+				introspectResponse, err = s.introspectRefreshToken(refreshToken, client)
+			}
+		}
+		if err == nil {
+			return introspectResponse, nil
+		}
+		if !isInactiveTokenError(err) {
+			return nil, err
+		}
+	}
+
+	// Unknown, expired and revoked tokens are inactive (RFC 7662 section 2.2)
+	return &IntrospectResponse{Active: false}, nil
+}
+
+// isInactiveTokenError returns true for errors of tokens which are no longer
+// valid: unknown or expired tokens, tokens of disabled clients and tokens of
+// deleted users
+func isInactiveTokenError(err error) bool {
+	switch err {
+	case ErrAccessTokenNotFound, ErrAccessTokenExpired,
+		ErrRefreshTokenNotFound, ErrRefreshTokenExpired, ErrRefreshTokenUserNotFound,
+		ErrClientDisabled, ErrUserNotFound:
+		return true
+	}
+	return false
 }
 
 // NewIntrospectResponseFromAccessToken ...
 func (s *Service) NewIntrospectResponseFromAccessToken(accessToken *model.AccessToken) (*IntrospectResponse, error) {
-	ctx := context.Background()
-	var introspectResponse = &IntrospectResponse{
-		Active:    true,
-		Scope:     accessToken.Scope,
-		TokenType: tokentypes.Bearer,
-		ExpiresAt: int(accessToken.ExpiresAt.Unix()),
-	}
+	return s.introspectAccessToken(accessToken, nil)
+}
 
-	if util.IsValidUUID(accessToken.ClientID.String()) && accessToken.ClientID != uuid.Nil {
-		client := new(model.Client)
-		err := s.db.NewSelect().
-			Model(client).
-			Column("key").
-			Where("id = ?", accessToken.ClientID.String()).
-			Limit(1).
-			Scan(ctx)
-		if err != nil {
-			return nil, ErrClientNotFound
-		}
-		introspectResponse.ClientID = client.Key
+// introspectAccessToken returns the introspection response of an access token
+// for the client introspecting it, if any
+func (s *Service) introspectAccessToken(accessToken *model.AccessToken, requester *model.Client) (*IntrospectResponse, error) {
+	introspectResponse, err := s.newIntrospectResponse(
+		accessToken.ClientID,
+		accessToken.UserID,
+		accessToken.Scope,
+		accessToken.CreatedAt,
+		accessToken.ExpiresAt,
+		requester,
+	)
+	if err != nil {
+		return nil, err
 	}
 
-	if util.IsValidUUID(accessToken.UserID.String()) && accessToken.UserID != uuid.Nil {
-		user := new(model.User)
-		err := s.db.NewSelect().
-			Model(user).
-			Column("username").
-			Where("id = ?", accessToken.UserID.String()).
-			Limit(1).
-			Scan(ctx)
-		if err != nil {
-			return nil, ErrUserNotFound
-		}
+	// Access tokens are for the resource servers, like JWT access tokens
+	introspectResponse.Audience = s.getAccessTokenAudience()
 
-		introspectResponse.Username = user.Username
-		introspectResponse.UserID = accessToken.UserID.String()
+	// Exchanged tokens name their audience and the actor
+	if tokenExchange, err := s.findTokenExchange(accessToken.Token); err == nil {
+		introspectResponse.Audience = tokenExchange.Audience
+		introspectResponse.Act = tokenExchange.Act
+	}
+
+	// DPoP bound tokens carry the thumbprint of their key
+	tokenBinding, err := s.findTokenBinding(accessToken.Token)
+	if err != nil {
+		return nil, err
+	}
+	if tokenBinding != nil {
+		introspectResponse.TokenType = tokentypes.DPoP
+		introspectResponse.Cnf = &Confirmation{JKT: tokenBinding.JKT}
 	}
 
 	return introspectResponse, nil
@@ -108,42 +146,99 @@ func (s *Service) NewIntrospectResponseFromAccessToken(accessToken *model.Access
 
 // NewIntrospectResponseFromRefreshToken ...
 func (s *Service) NewIntrospectResponseFromRefreshToken(refreshToken *model.RefreshToken) (*IntrospectResponse, error) {
+	return s.introspectRefreshToken(refreshToken, nil)
+}
+
+// introspectRefreshToken returns the introspection response of a refresh
+// token for the client introspecting it, if any
+func (s *Service) introspectRefreshToken(refreshToken *model.RefreshToken, requester *model.Client) (*IntrospectResponse, error) {
+	introspectResponse, err := s.newIntrospectResponse(
+		refreshToken.ClientID,
+		refreshToken.UserID,
+		refreshToken.Scope,
+		refreshToken.CreatedAt,
+		refreshToken.ExpiresAt,
+		requester,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	// Refresh tokens are only ever sent back to this server
+	introspectResponse.Audience = s.GetIssuer()
+
+	// DPoP bound tokens carry the thumbprint of their key
+	tokenBinding, err := s.findTokenBinding(refreshToken.Token)
+	if err != nil {
+		return nil, err
+	}
+	if tokenBinding != nil {
+		introspectResponse.TokenType = tokentypes.DPoP
+		introspectResponse.Cnf = &Confirmation{JKT: tokenBinding.JKT}
+	}
+
+	return introspectResponse, nil
+}
+
+// newIntrospectResponse returns the meta-information shared by access and
+// refresh tokens. The subject is the user, or the client for tokens granted
+// with client credentials. The user's role is always included, the other
+// user claims are those registered by the requester, the resource server
+// introspecting the token.
+func (s *Service) newIntrospectResponse(clientID, userID uuid.UUID, scope string, createdAt, expiresAt time.Time, requester *model.Client) (*IntrospectResponse, error) {
 	ctx := context.Background()
 	var introspectResponse = &IntrospectResponse{
 		Active:    true,
-		Scope:     refreshToken.Scope,
+		Scope:     scope,
 		TokenType: tokentypes.Bearer,
-		ExpiresAt: int(refreshToken.ExpiresAt.Unix()),
+		ExpiresAt: int(expiresAt.Unix()),
+		Issuer:    s.GetIssuer(),
+	}
+
+	if !createdAt.IsZero() {
+		introspectResponse.IssuedAt = int(createdAt.Unix())
+		introspectResponse.NotBefore = int(createdAt.Unix())
 	}
 
-	if util.IsValidUUID(refreshToken.ClientID.String()) && refreshToken.ClientID != uuid.Nil {
-		client := new(model.Client)
+	var client *model.Client
+	if util.IsValidUUID(clientID.String()) && clientID != uuid.Nil {
+		client = new(model.Client)
 		err := s.db.NewSelect().
 			Model(client).
-			Column("key").
-			Where("id = ?", refreshToken.ClientID.String()).
+			Column("id", "key").
+			Where("id = ?", clientID.String()).
 			Limit(1).
 			Scan(ctx)
 		if err != nil {
 			return nil, ErrClientNotFound
 		}
 		introspectResponse.ClientID = client.Key
+		introspectResponse.Subject = client.Key
 	}
 
-	if util.IsValidUUID(refreshToken.UserID.String()) && refreshToken.UserID != uuid.Nil {
+	if util.IsValidUUID(userID.String()) && userID != uuid.Nil {
 		user := new(model.User)
 		err := s.db.NewSelect().
 			Model(user).
-			Column("username").
-			Where("id = ?", refreshToken.UserID.String()).
+			Where("id = ?", userID.String()).
 			Limit(1).
 			Scan(ctx)
-		if err != nil {
+		if err == sql.ErrNoRows {
 			return nil, ErrUserNotFound
 		}
+		if err != nil {
+			return nil, err
+		}
+
+		userClaims, err := s.newUserClaims(requester, user)
+		if err != nil {
+			return nil, err
+		}
 
 		introspectResponse.Username = user.Username
-		introspectResponse.UserID = refreshToken.UserID.String()
+		introspectResponse.UserID = userID.String()
+		introspectResponse.Subject = userID.String()
+		introspectResponse.UserClaims = userClaims
 	}
 
 	return introspectResponse, nil
@@ -18,6 +18,7 @@ import (
 func (suite *OauthTestSuite) TestNewIntrospectResponseFromAccessToken() {
 
 	accessToken := &model.AccessToken{
+		IDRecord:  model.IDRecord{CreatedAt: time.Now().UTC()},
 		Token:     "test_token_introspect_1",
 		ExpiresAt: time.Now().UTC().Add(+10 * time.Second),
 		ClientID:  suite.clients[0].ID,
@@ -25,13 +26,19 @@ func (suite *OauthTestSuite) TestNewIntrospectResponseFromAccessToken() {
 		Scope:     "read_write",
 	}
 	expected := &oauth.IntrospectResponse{
-		Active:    true,
-		Scope:     accessToken.Scope,
-		TokenType: tokentypes.Bearer,
-		ExpiresAt: int(accessToken.ExpiresAt.Unix()),
-		ClientID:  suite.clients[0].Key,
-		UserID:    accessToken.UserID.String(),
-		Username:  suite.users[0].Username,
+		Active:     true,
+		Scope:      accessToken.Scope,
+		TokenType:  tokentypes.Bearer,
+		ExpiresAt:  int(accessToken.ExpiresAt.Unix()),
+		IssuedAt:   int(accessToken.CreatedAt.Unix()),
+		NotBefore:  int(accessToken.CreatedAt.Unix()),
+		Subject:    accessToken.UserID.String(),
+		Audience:   suite.service.GetIssuer(),
+		Issuer:     suite.service.GetIssuer(),
+		ClientID:   suite.clients[0].Key,
+		UserID:     accessToken.UserID.String(),
+		Username:   suite.users[0].Username,
+		UserClaims: oauth.UserClaims{Role: "tenantadmin"},
 	}
 
 	actual, err := suite.service.NewIntrospectResponseFromAccessToken(accessToken)
@@ -46,7 +53,9 @@ func (suite *OauthTestSuite) TestNewIntrospectResponseFromAccessToken() {
 
 	accessToken.UserID = uuid.Nil
 	expected.Username = ""
+	expected.Role = ""
 	expected.UserID = ""
+	expected.Subject = ""
 	actual, err = suite.service.NewIntrospectResponseFromAccessToken(accessToken)
 	assert.NoError(suite.T(), err)
 	assert.Equal(suite.T(), expected, actual)
@@ -54,6 +63,7 @@ func (suite *OauthTestSuite) TestNewIntrospectResponseFromAccessToken() {
 
 func (suite *OauthTestSuite) TestNewIntrospectResponseFromRefreshToken() {
 	refreshToken := &model.RefreshToken{
+		IDRecord:  model.IDRecord{CreatedAt: time.Now().UTC()},
 		Token:     "test_token_introspect_1",
 		ExpiresAt: time.Now().UTC().Add(+10 * time.Second),
 		ClientID:  suite.clients[0].ID,
@@ -61,13 +71,19 @@ func (suite *OauthTestSuite) TestNewIntrospectResponseFromRefreshToken() {
 		Scope:     "read_write",
 	}
 	expected := &oauth.IntrospectResponse{
-		Active:    true,
-		Scope:     refreshToken.Scope,
-		TokenType: tokentypes.Bearer,
-		ExpiresAt: int(refreshToken.ExpiresAt.Unix()),
-		ClientID:  suite.clients[0].Key,
-		UserID:    refreshToken.UserID.String(),
-		Username:  suite.users[0].Username,
+		Active:     true,
+		Scope:      refreshToken.Scope,
+		TokenType:  tokentypes.Bearer,
+		ExpiresAt:  int(refreshToken.ExpiresAt.Unix()),
+		IssuedAt:   int(refreshToken.CreatedAt.Unix()),
+		NotBefore:  int(refreshToken.CreatedAt.Unix()),
+		Subject:    refreshToken.UserID.String(),
+		Audience:   suite.service.GetIssuer(),
+		Issuer:     suite.service.GetIssuer(),
+		ClientID:   suite.clients[0].Key,
+		UserID:     refreshToken.UserID.String(),
+		Username:   suite.users[0].Username,
+		UserClaims: oauth.UserClaims{Role: "tenantadmin"},
 	}
 
 	actual, err := suite.service.NewIntrospectResponseFromRefreshToken(refreshToken)
@@ -82,7 +98,9 @@ func (suite *OauthTestSuite) TestNewIntrospectResponseFromRefreshToken() {
 
 	refreshToken.UserID = uuid.Nil
 	expected.Username = ""
+	expected.Role = ""
 	expected.UserID = ""
+	expected.Subject = ""
 	actual, err = suite.service.NewIntrospectResponseFromRefreshToken(refreshToken)
 	assert.NoError(suite.T(), err)
 	assert.Equal(suite.T(), expected, actual)
@@ -100,9 +118,10 @@ func (suite *OauthTestSuite) TestHandleIntrospectMissingToken() {
 	suite.router.ServeHTTP(w, r)
 
 	// Check response
-	testutil.TestResponseForError(
+	testutil.TestResponseForOauthError(
 		suite.T(),
 		w,
+		oauth.ErrorCodeInvalidRequest,
 		oauth.ErrTokenMissing.Error(),
 		400,
 	)
@@ -120,9 +139,10 @@ func (suite *OauthTestSuite) TestHandleIntrospectInvailidTokenHint() {
 	suite.router.ServeHTTP(w, r)
 
 	// Check response
-	testutil.TestResponseForError(
+	testutil.TestResponseForOauthError(
 		suite.T(),
 		w,
+		oauth.ErrorCodeUnsupportedTokenType,
 		oauth.ErrTokenHintInvalid.Error(),
 		400,
 	)
@@ -178,13 +198,8 @@ func (suite *OauthTestSuite) TestHandleIntrospectAccessToken() {
 	w = httptest.NewRecorder()
 	suite.router.ServeHTTP(w, r)
 
-	// Check response
-	testutil.TestResponseForError(
-		suite.T(),
-		w,
-		oauth.ErrRefreshTokenNotFound.Error(),
-		404,
-	)
+	// The hint only decides the lookup order
+	testutil.TestResponseObject(suite.T(), w, expected, 200)
 
 	// Without token hint
 	r.PostForm = url.Values{
@@ -251,13 +266,8 @@ func (suite *OauthTestSuite) TestHandleIntrospectRefreshToken() {
 	w = httptest.NewRecorder()
 	suite.router.ServeHTTP(w, r)
 
-	// Check response
-	testutil.TestResponseForError(
-		suite.T(),
-		w,
-		oauth.ErrAccessTokenNotFound.Error(),
-		404,
-	)
+	// The hint only decides the lookup order
+	testutil.TestResponseObject(suite.T(), w, expected, 200)
 
 	// Without token hint
 	r.PostForm = url.Values{
@@ -269,12 +279,7 @@ func (suite *OauthTestSuite) TestHandleIntrospectRefreshToken() {
 	suite.router.ServeHTTP(w, r)
 
 	// Check response
-	testutil.TestResponseForError(
-		suite.T(),
-		w,
-		oauth.ErrAccessTokenNotFound.Error(),
-		404,
-	)
+	testutil.TestResponseObject(suite.T(), w, expected, 200)
 }
 
 func (suite *OauthTestSuite) TestHandleIntrospectInactiveToken() {
@@ -293,13 +298,8 @@ func (suite *OauthTestSuite) TestHandleIntrospectInactiveToken() {
 	w := httptest.NewRecorder()
 	suite.router.ServeHTTP(w, r)
 
-	// Check response
-	testutil.TestResponseForError(
-		suite.T(),
-		w,
-		oauth.ErrAccessTokenNotFound.Error(),
-		404,
-	)
+	// Unknown tokens are inactive
+	testutil.TestResponseObject(suite.T(), w, &oauth.IntrospectResponse{Active: false}, 200)
 
 	// With refresh token hint
 	r.PostForm = url.Values{
@@ -311,13 +311,8 @@ func (suite *OauthTestSuite) TestHandleIntrospectInactiveToken() {
 	w = httptest.NewRecorder()
 	suite.router.ServeHTTP(w, r)
 
-	// Check response
-	testutil.TestResponseForError(
-		suite.T(),
-		w,
-		oauth.ErrRefreshTokenNotFound.Error(),
-		404,
-	)
+	// Unknown tokens are inactive
+	testutil.TestResponseObject(suite.T(), w, &oauth.IntrospectResponse{Active: false}, 200)
 
 	// Without token hint
 	r.PostForm = url.Values{
@@ -328,11 +323,35 @@ func (suite *OauthTestSuite) TestHandleIntrospectInactiveToken() {
 	w = httptest.NewRecorder()
 	suite.router.ServeHTTP(w, r)
 
-	// Check response
-	testutil.TestResponseForError(
-		suite.T(),
-		w,
-		oauth.ErrAccessTokenNotFound.Error(),
-		404,
-	)
+	// Unknown tokens are inactive
+	testutil.TestResponseObject(suite.T(), w, &oauth.IntrospectResponse{Active: false}, 200)
+}
+
+func (suite *OauthTestSuite) TestHandleIntrospectTokenOfDisabledClient() {
+	accessToken, _, err := suite.service.Login(suite.clients[1], suite.users[0], "read", nil)
+	assert.NoError(suite.T(), err)
+	assert.NoError(suite.T(), suite.service.SetClientDisabled(suite.clients[1], true))
+
+	r, err := http.NewRequest("POST", "http://1.2.3.4/v1/oauth/introspect", nil)
+	assert.NoError(suite.T(), err, "Request setup should not get an error")
+	r.SetBasicAuth("test_client_1", "test_secret")
+	r.PostForm = url.Values{
+		"token": {accessToken.Token},
+	}
+
+	w := httptest.NewRecorder()
+	suite.router.ServeHTTP(w, r)
+
+	// Tokens of disabled clients are inactive
+	testutil.TestResponseObject(suite.T(), w, &oauth.IntrospectResponse{Active: false}, 200)
+
+	// As are tokens of deleted users
+	_, err = suite.service.NewIntrospectResponseFromAccessToken(&model.AccessToken{
+		Token:     "test_token_introspect_deleted_user",
+		ExpiresAt: time.Now().UTC().Add(+10 * time.Second),
+		ClientID:  suite.clients[0].ID,
+		UserID:    uuid.New(),
+		Scope:     "read",
+	})
+	assert.Equal(suite.T(), oauth.ErrUserNotFound, err)
 }
@@ -0,0 +1,215 @@
+// Package jwk encodes and decodes JSON Web Keys (RFC 7517) so that tokens
+// signed by this server can be verified offline by resource servers
+package jwk
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"time"
+
+	jwt "github.com/form3tech-oss/jwt-go"
+)
+
+var (
+	// ErrUnsupportedKeyType ...
+	ErrUnsupportedKeyType = errors.New("Unsupported key type")
+	// ErrInvalidKey ...
+	ErrInvalidKey = errors.New("Invalid key")
+	// ErrKeyNotFound ...
+	ErrKeyNotFound = errors.New("Key not found")
+	// ErrSetTooLarge ...
+	ErrSetTooLarge = errors.New("Key set too large")
+)
+
+const (
+	// MaxSetSize is the largest key set (in bytes) Fetch accepts
+	MaxSetSize = 64 * 1024
+)
+
+// Key is a public JSON Web Key
+type Key struct {
+	KeyType   string `json:"kty"`
+	Use       string `json:"use,omitempty"`
+	KeyID     string `json:"kid,omitempty"`
+	Algorithm string `json:"alg,omitempty"`
+	// RSA
+	N string `json:"n,omitempty"`
+	E string `json:"e,omitempty"`
+	// EC
+	Curve string `json:"crv,omitempty"`
+	X     string `json:"x,omitempty"`
+	Y     string `json:"y,omitempty"`
+}
+
+// Set is a JSON Web Key Set
+type Set struct {
+	Keys []*Key `json:"keys"`
+}
+
+// NewKey returns the JSON Web Key of an RSA or ECDSA public key
+func NewKey(kid, alg string, publicKey crypto.PublicKey) (*Key, error) {
+	switch pub := publicKey.(type) {
+	case *rsa.PublicKey:
+		return &Key{
+			KeyType:   "RSA",
+			Use:       "sig",
+			KeyID:     kid,
+			Algorithm: alg,
+			N:         base64.RawURLEncoding.EncodeToString(pub.N.Bytes()),
+			E:         base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes()),
+		}, nil
+	case *ecdsa.PublicKey:
+		size := (pub.Curve.Params().BitSize + 7) / 8
+		return &Key{
+			KeyType:   "EC",
+			Use:       "sig",
+			KeyID:     kid,
+			Algorithm: alg,
+			Curve:     pub.Curve.Params().Name,
+			X:         base64.RawURLEncoding.EncodeToString(pub.X.FillBytes(make([]byte, size))),
+			Y:         base64.RawURLEncoding.EncodeToString(pub.Y.FillBytes(make([]byte, size))),
+		}, nil
+	default:
+		return nil, ErrUnsupportedKeyType
+	}
+}
+
+// PublicKey decodes the JSON Web Key into an *rsa.PublicKey or *ecdsa.PublicKey
+func (k *Key) PublicKey() (crypto.PublicKey, error) {
+	switch k.KeyType {
+	case "RSA":
+		n, err := base64.RawURLEncoding.DecodeString(k.N)
+		if err != nil {
+			return nil, ErrInvalidKey
+		}
+		e, err := base64.RawURLEncoding.DecodeString(k.E)
+		if err != nil {
+			return nil, ErrInvalidKey
+		}
+		return &rsa.PublicKey{
+			N: new(big.Int).SetBytes(n),
+			E: int(new(big.Int).SetBytes(e).Int64()),
+		}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Curve {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, ErrUnsupportedKeyType
+		}
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil {
+			return nil, ErrInvalidKey
+		}
+		y, err := base64.RawURLEncoding.DecodeString(k.Y)
+		if err != nil {
+			return nil, ErrInvalidKey
+		}
+		pub := &ecdsa.PublicKey{
+			Curve: curve,
+			X:     new(big.Int).SetBytes(x),
+			Y:     new(big.Int).SetBytes(y),
+		}
+		if !curve.IsOnCurve(pub.X, pub.Y) {
+			return nil, ErrInvalidKey
+		}
+		return pub, nil
+	default:
+		return nil, ErrUnsupportedKeyType
+	}
+}
+
+// Thumbprint returns the base64url encoded SHA-256 JWK thumbprint of the
+// key (RFC 7638), built from its required members in lexicographic order
+func (k *Key) Thumbprint() (string, error) {
+	var members string
+	switch k.KeyType {
+	case "RSA":
+		members = fmt.Sprintf(`{"e":%q,"kty":"RSA","n":%q}`, k.E, k.N)
+	case "EC":
+		members = fmt.Sprintf(`{"crv":%q,"kty":"EC","x":%q,"y":%q}`, k.Curve, k.X, k.Y)
+	default:
+		return "", ErrUnsupportedKeyType
+	}
+
+	sum := sha256.Sum256([]byte(members))
+	return base64.RawURLEncoding.EncodeToString(sum[:]), nil
+}
+
+// Key returns the key with the given key ID
+func (s *Set) Key(kid string) (*Key, error) {
+	for _, key := range s.Keys {
+		if key.KeyID == kid {
+			return key, nil
+		}
+	}
+	return nil, ErrKeyNotFound
+}
+
+// Keyfunc looks up the verification key of a token by its kid header,
+// it can be passed to jwt.Parse and jwt.ParseWithClaims
+func (s *Set) Keyfunc(token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+
+	key, err := s.Key(kid)
+	if err != nil {
+		return nil, err
+	}
+
+	// The token must be signed with the algorithm the key was published for
+	if key.Algorithm != "" && key.Algorithm != token.Method.Alg() {
+		return nil, ErrInvalidKey
+	}
+
+	return key.PublicKey()
+}
+
+// Fetch downloads a JSON Web Key Set, e.g. from https://id.resonate.coop/.well-known/jwks.json
+func Fetch(url string) (*Set, error) {
+	return FetchWithClient(&http.Client{Timeout: 10 * time.Second}, url)
+}
+
+// FetchWithClient downloads a JSON Web Key Set with the HTTP client, key sets
+// larger than MaxSetSize are rejected
+func FetchWithClient(client *http.Client, url string) (*Set, error) {
+	resp, err := client.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("Fetching %s failed with status %d", url, resp.StatusCode)
+	}
+
+	b, err := ioutil.ReadAll(io.LimitReader(resp.Body, MaxSetSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(b) > MaxSetSize {
+		return nil, ErrSetTooLarge
+	}
+
+	set := new(Set)
+	if err := json.Unmarshal(b, set); err != nil {
+		return nil, err
+	}
+
+	return set, nil
+}
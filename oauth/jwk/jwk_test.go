@@ -0,0 +1,117 @@
+package jwk_test
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	jwt "github.com/form3tech-oss/jwt-go"
+	"github.com/resonatecoop/id/oauth/jwk"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRSAKey(t *testing.T) {
+	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.NoError(t, err)
+
+	key, err := jwk.NewKey("test_kid", "RS256", &privateKey.PublicKey)
+	assert.NoError(t, err)
+	assert.Equal(t, "RSA", key.KeyType)
+	assert.Equal(t, "AQAB", key.E)
+
+	publicKey, err := key.PublicKey()
+	assert.NoError(t, err)
+	assert.Equal(t, &privateKey.PublicKey, publicKey)
+}
+
+func TestECKey(t *testing.T) {
+	privateKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+
+	key, err := jwk.NewKey("test_kid", "ES256", &privateKey.PublicKey)
+	assert.NoError(t, err)
+	assert.Equal(t, "EC", key.KeyType)
+	assert.Equal(t, "P-256", key.Curve)
+
+	publicKey, err := key.PublicKey()
+	assert.NoError(t, err)
+	assert.True(t, privateKey.PublicKey.Equal(publicKey))
+}
+
+func TestSetKeyfunc(t *testing.T) {
+	privateKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+
+	key, err := jwk.NewKey("test_kid", "ES256", &privateKey.PublicKey)
+	assert.NoError(t, err)
+	set := &jwk.Set{Keys: []*jwk.Key{key}}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodES256, jwt.StandardClaims{Subject: "test"})
+	token.Header["kid"] = "test_kid"
+	signed, err := token.SignedString(privateKey)
+	assert.NoError(t, err)
+
+	parsed, err := jwt.Parse(signed, set.Keyfunc)
+	assert.NoError(t, err)
+	assert.True(t, parsed.Valid)
+
+	// Unknown key ID
+	token.Header["kid"] = "bogus"
+	signed, err = token.SignedString(privateKey)
+	assert.NoError(t, err)
+
+	_, err = jwt.Parse(signed, set.Keyfunc)
+	assert.Error(t, err)
+}
+
+func TestThumbprint(t *testing.T) {
+	// Example of RFC 7638 section 3.1
+	key := &jwk.Key{
+		KeyType: "RSA",
+		N:       "0vx7agoebGcQSuuPiLJXZptN9nndrQmbXEps2aiAFbWhM78LhWx4cbbfAAtVT86zwu1RK7aPFFxuhDR1L6tSoc_BJECPebWKRXjBZCiFV4n3oknjhMstn64tZ_2W-5JsGY4Hc5n9yBXArwl93lqt7_RN5w6Cf0h4QyQ5v-65YGjQR0_FDW2QvzqY368QQMicAtaSqzs8KJZgnYb9c7d0zgdAZHzu6qMQvRL5hajrn1n91CbOpbISD08qNLyrdkt-bFTWhAI4vMQFh6WeZu0fM4lFd2NcRwr3XPksINHaQ-G_xBniIqbw0Ls1jF44-csFCur-kEgU8awapJzKnqDKgw",
+		E:       "AQAB",
+		KeyID:   "2011-04-29",
+	}
+
+	thumbprint, err := key.Thumbprint()
+	assert.NoError(t, err)
+	assert.Equal(t, "NzbLsXh8uDCcd-6MNwXF4W_7noWXFZAfHkxZsRGC9Xs", thumbprint)
+
+	// Unsupported key type
+	_, err = (&jwk.Key{KeyType: "oct"}).Thumbprint()
+	assert.Equal(t, jwk.ErrUnsupportedKeyType, err)
+}
+
+func TestFetchWithClient(t *testing.T) {
+	privateKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+	key, err := jwk.NewKey("test", "ES256", &privateKey.PublicKey)
+	assert.NoError(t, err)
+
+	body := ""
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(body))
+	}))
+	defer server.Close()
+
+	b, err := json.Marshal(&jwk.Set{Keys: []*jwk.Key{key}})
+	assert.NoError(t, err)
+	body = string(b)
+
+	set, err := jwk.FetchWithClient(server.Client(), server.URL)
+	assert.NoError(t, err)
+	if assert.Len(t, set.Keys, 1) {
+		assert.Equal(t, "test", set.Keys[0].KeyID)
+	}
+
+	// Oversized key sets are not read
+	body = `{"keys":[` + strings.Repeat(" ", jwk.MaxSetSize) + `]}`
+	_, err = jwk.FetchWithClient(server.Client(), server.URL)
+	assert.Equal(t, jwk.ErrSetTooLarge, err)
+}
@@ -0,0 +1,119 @@
+package oauth
+
+import (
+	"strings"
+
+	jwt "github.com/form3tech-oss/jwt-go"
+	"github.com/resonatecoop/user-api/model"
+)
+
+const (
+	// AccessTokenFormatOpaque issues random access tokens which have to be introspected
+	AccessTokenFormatOpaque = "opaque"
+	// AccessTokenFormatJWT issues signed access tokens which resource servers
+	// can validate locally against the JWKS (RFC 9068)
+	AccessTokenFormatJWT = "jwt"
+
+	// jwtAccessTokenType is the typ header of JWT access tokens
+	jwtAccessTokenType = "at+jwt"
+)
+
+// AccessTokenClaims are the claims of a JWT access token
+// https://datatracker.ietf.org/doc/html/rfc9068#section-2.2
+type AccessTokenClaims struct {
+	jwt.StandardClaims
+	ClientID string `json:"client_id"`
+	Scope    string `json:"scope,omitempty"`
+	Act      *Actor `json:"act,omitempty"`
+	// Cnf binds DPoP access tokens to the client's key
+	Cnf *Confirmation `json:"cnf,omitempty"`
+	UserClaims
+}
+
+// IsJWT returns true if the token looks like a JWS compact serialization
+func IsJWT(token string) bool {
+	return strings.Count(token, ".") == 2
+}
+
+// ParseJWTAccessToken verifies a JWT access token issued by this server
+func (s *Service) ParseJWTAccessToken(token string) (*AccessTokenClaims, error) {
+	claims := new(AccessTokenClaims)
+
+	_, err := s.parseJWT(token, claims, jwtAccessTokenType)
+	if err != nil {
+		if validationErr, ok := err.(*jwt.ValidationError); ok && validationErr.Errors&jwt.ValidationErrorExpired != 0 {
+			return nil, ErrAccessTokenExpired
+		}
+		return nil, ErrAccessTokenNotFound
+	}
+
+	if !claims.VerifyIssuer(s.GetIssuer(), true) {
+		return nil, ErrAccessTokenNotFound
+	}
+
+	return claims, nil
+}
+
+// newJWTAccessToken signs the claims of a stored access token, the stored
+// token is used as the jti so the JWT can still be introspected and revoked.
+// Exchanged tokens are for the audience and actor of the token exchange.
+func (s *Service) newJWTAccessToken(client *model.Client, user *model.User, accessToken *model.AccessToken, tokenExchange *TokenExchange) (string, error) {
+	claims := &AccessTokenClaims{
+		StandardClaims: jwt.StandardClaims{
+			Issuer:    s.GetIssuer(),
+			Subject:   client.Key,
+			Audience:  []string{s.getAccessTokenAudience()},
+			IssuedAt:  accessToken.CreatedAt.Unix(),
+			ExpiresAt: accessToken.ExpiresAt.Unix(),
+			Id:        accessToken.Token,
+		},
+		ClientID: client.Key,
+		Scope:    accessToken.Scope,
+	}
+
+	if tokenExchange != nil {
+		claims.Audience = []string{tokenExchange.Audience}
+		claims.Act = tokenExchange.Act
+	}
+
+	if user != nil {
+		claims.Subject = user.ID.String()
+
+		userClaims, err := s.newUserClaims(client, user)
+		if err != nil {
+			return "", err
+		}
+		claims.UserClaims = userClaims
+	}
+
+	return s.signJWT(claims, jwtAccessTokenType)
+}
+
+// getAccessTokenAudience returns the aud claim of JWT access tokens
+func (s *Service) getAccessTokenAudience() string {
+	if s.cnf.Oauth.AccessTokenAudience != "" {
+		return s.cnf.Oauth.AccessTokenAudience
+	}
+	return s.GetIssuer()
+}
+
+// getAccessTokenLookupKey returns the value an access token is stored
+// under, JWT access tokens are stored under their jti. JWTs are accepted
+// whatever the configured format so switching formats does not log users out
+func (s *Service) getAccessTokenLookupKey(token string) (string, error) {
+	if !IsJWT(token) {
+		return token, nil
+	}
+
+	claims, err := s.ParseJWTAccessToken(token)
+	if err != nil {
+		return "", err
+	}
+
+	return claims.Id, nil
+}
+
+// isJWTAccessTokenFormat returns true if access tokens are issued as JWTs
+func (s *Service) isJWTAccessTokenFormat() bool {
+	return s.cnf.Oauth.AccessTokenFormat == AccessTokenFormatJWT
+}
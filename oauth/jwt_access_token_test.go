@@ -0,0 +1,69 @@
+package oauth_test
+
+import (
+	"testing"
+
+	"github.com/resonatecoop/id/oauth"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIsJWT(t *testing.T) {
+	assert.True(t, oauth.IsJWT("eyJhbGciOiJSUzI1NiJ9.eyJzdWIiOiIxIn0.c2ln"))
+	assert.False(t, oauth.IsJWT("00ccd40e-72ca-4e79-a4b6-67c95e2e3f1c"))
+}
+
+func (suite *OauthTestSuite) TestGrantJWTAccessToken() {
+	suite.cnf.Oauth.AccessTokenFormat = oauth.AccessTokenFormatJWT
+	defer func() { suite.cnf.Oauth.AccessTokenFormat = oauth.AccessTokenFormatOpaque }()
+
+	accessToken, err := suite.service.GrantAccessToken(
+		suite.clients[0], // client
+		suite.users[0],   // user
+		3600,             // expires in
+		"read_write",     // scope
+		nil,              // device session
+	)
+	assert.NoError(suite.T(), err)
+	assert.True(suite.T(), oauth.IsJWT(accessToken.Token))
+
+	claims, err := suite.service.ParseJWTAccessToken(accessToken.Token)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), suite.service.GetIssuer(), claims.Issuer)
+	assert.Equal(suite.T(), suite.users[0].ID.String(), claims.Subject)
+	assert.Equal(suite.T(), suite.clients[0].Key, claims.ClientID)
+	assert.Equal(suite.T(), "read_write", claims.Scope)
+	assert.Equal(suite.T(), "tenantadmin", claims.Role)
+	assert.Equal(suite.T(), accessToken.ExpiresAt.Unix(), claims.ExpiresAt)
+
+	// The JWT can still be authenticated against the stored token
+	authenticated, err := suite.service.Authenticate(accessToken.Token)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), claims.Id, authenticated.Token)
+}
+
+func (suite *OauthTestSuite) TestGrantJWTAccessTokenClientOnly() {
+	suite.cnf.Oauth.AccessTokenFormat = oauth.AccessTokenFormatJWT
+	defer func() { suite.cnf.Oauth.AccessTokenFormat = oauth.AccessTokenFormatOpaque }()
+
+	accessToken, err := suite.service.GrantAccessToken(
+		suite.clients[0], // client
+		nil,              // user
+		3600,             // expires in
+		"read_write",     // scope
+		nil,              // device session
+	)
+	assert.NoError(suite.T(), err)
+
+	claims, err := suite.service.ParseJWTAccessToken(accessToken.Token)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), suite.clients[0].Key, claims.Subject)
+	assert.Empty(suite.T(), claims.Role)
+}
+
+func (suite *OauthTestSuite) TestAuthenticateRejectsIDToken() {
+	idToken, err := suite.service.GrantIDToken(suite.clients[0], suite.users[0], "")
+	assert.NoError(suite.T(), err)
+
+	_, err = suite.service.Authenticate(idToken)
+	assert.Equal(suite.T(), oauth.ErrAccessTokenNotFound, err)
+}
@@ -1,16 +1,20 @@
 package oauth
 
 import (
-	"context"
 	"errors"
-	"strings"
 
 	"github.com/resonatecoop/user-api/model"
 )
 
 // Login creates an access token and refresh token for a user (logs him/her in)
-func (s *Service) Login(client *model.Client, user *model.User, scope string) (*model.AccessToken, *model.RefreshToken, error) {
+// on the device session, a new device session is started if it is nil
+func (s *Service) Login(client *model.Client, user *model.User, scope string, deviceSession *DeviceSession) (*model.AccessToken, *model.RefreshToken, error) {
+	return s.login(client, user, scope, deviceSession, nil)
+}
 
+// login logs in the user, the refresh token to rotate, if any, is replaced by
+// a new one instead of reusing or creating the device session's token
+func (s *Service) login(client *model.Client, user *model.User, scope string, deviceSession *DeviceSession, rotatedRefreshToken *model.RefreshToken) (*model.AccessToken, *model.RefreshToken, error) {
 	if user == nil {
 		return nil, nil, errors.New("valid user must be supplied")
 	}
@@ -21,59 +25,58 @@ func (s *Service) Login(client *model.Client, user *model.User, scope string) (*
 		return nil, nil, ErrInvalidUsernameOrPassword
 	}
 
-	scope, err := s.updateUserScopeWithRole(user, scope)
+	if deviceSession == nil {
+		deviceSession = s.NewDeviceSession(client, user, nil)
+	}
 
+	// The device session is saved along with the new refresh token when rotating
+	var (
+		refreshToken *model.RefreshToken
+		err          error
+	)
+	if rotatedRefreshToken != nil {
+		refreshToken, err = s.rotateRefreshToken(rotatedRefreshToken, deviceSession)
+	} else {
+		err = saveDeviceSession(s.db, deviceSession)
+	}
 	if err != nil {
 		return nil, nil, err
 	}
 
-	// Create a new access token
-	accessToken, err := s.GrantAccessToken(
-		client,
-		user,
-		s.cnf.Oauth.AccessTokenLifetime, // expires in
-		scope,
-	)
+	accessTokenLifetime, err := s.AccessTokenLifetime(client)
 	if err != nil {
 		return nil, nil, err
 	}
 
-	// Create or retrieve a refresh token
-	refreshToken, err := s.GetOrCreateRefreshToken(
+	// Create a new access token
+	accessToken, err := s.GrantAccessToken(
 		client,
 		user,
-		s.cnf.Oauth.RefreshTokenLifetime, // expires in
+		accessTokenLifetime, // expires in
 		scope,
+		deviceSession,
 	)
 	if err != nil {
 		return nil, nil, err
 	}
 
-	return accessToken, refreshToken, nil
-}
-
-func (s *Service) updateUserScopeWithRole(user *model.User, scope string) (string, error) {
-
-	ctx := context.Background()
-
-	scopes := strings.Split(scope, " ")
-
-	if scopes[0] != "read" && scopes[0] != "read_write" {
-		return "", errors.New("invalid scope format")
-	}
-
-	scopeRole := new(model.Role)
-
-	err := s.db.NewSelect().
-		Model(scopeRole).
-		Where("id = ?", user.RoleID).
-		Scan(ctx)
-
-	if err != nil {
-		return "", errors.New("problem determining role from user record")
+	// Create or retrieve a refresh token
+	if refreshToken == nil {
+		refreshTokenLifetime, err := s.RefreshTokenLifetime(client)
+		if err != nil {
+			return nil, nil, err
+		}
+		refreshToken, err = s.GetOrCreateRefreshToken(
+			client,
+			user,
+			refreshTokenLifetime, // expires in
+			scope,
+			deviceSession,
+		)
+		if err != nil {
+			return nil, nil, err
+		}
 	}
 
-	scope = scopes[0] + " " + scopeRole.Name
-
-	return scope, nil
+	return accessToken, refreshToken, nil
 }
@@ -0,0 +1,23 @@
+package oauth_test
+
+import (
+	"github.com/stretchr/testify/assert"
+)
+
+func (suite *OauthTestSuite) TestLoginKeepsScope() {
+	// The granted scope is not rewritten with the user's role
+	accessToken, refreshToken, err := suite.service.Login(
+		suite.clients[0],
+		suite.users[0],
+		"read read_write openid",
+		nil,
+	)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), "read read_write openid", accessToken.Scope)
+	assert.Equal(suite.T(), "read read_write openid", refreshToken.Scope)
+
+	introspectResponse, err := suite.service.NewIntrospectResponseFromAccessToken(accessToken)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), "read read_write openid", introspectResponse.Scope)
+	assert.Equal(suite.T(), "tenantadmin", introspectResponse.Role)
+}
@@ -0,0 +1,176 @@
+package oauth
+
+import (
+	"context"
+	"time"
+
+	"github.com/uptrace/bun"
+)
+
+// SchemaMigration records a migration applied to the database
+type SchemaMigration struct {
+	bun.BaseModel `bun:"table:schema_migrations"`
+
+	Version   int       `bun:",pk"`
+	AppliedAt time.Time `bun:",nullzero,notnull,default:current_timestamp"`
+}
+
+// models lists the tables owned by this server. Shared tables such as
+// clients, scopes, users and tokens are migrated by user-api.
+var models = []interface{}{
+	(*SchemaMigration)(nil),
+	(*AuthorizationCodeChallenge)(nil),
+	(*AuthorizationCodeNonce)(nil),
+	(*SigningKey)(nil),
+	(*RefreshTokenRotation)(nil),
+	(*DeviceSession)(nil),
+	(*DeviceSessionToken)(nil),
+	(*TOTPSecret)(nil),
+	(*RecoveryCode)(nil),
+	(*WebAuthnCredential)(nil),
+	(*DeviceCode)(nil),
+	(*TokenExchange)(nil),
+	(*ClientMetadata)(nil),
+	(*Consent)(nil),
+	(*ClientState)(nil),
+	(*ClientAssertion)(nil),
+	(*TokenBinding)(nil),
+	(*DPoPProof)(nil),
+	(*PushedAuthorizationRequest)(nil),
+}
+
+// migration changes tables created by an earlier version of this server
+type migration struct {
+	Version int
+	Queries []string
+}
+
+// migrations run once each, in order of their version. New tables are
+// created with all their columns, so the queries must leave them unchanged.
+// Never edit a migration once released, add a new one instead.
+var migrations = []migration{
+	{
+		// private_key_jwt client authentication
+		Version: 1,
+		Queries: []string{
+			`ALTER TABLE client_metadata ADD COLUMN IF NOT EXISTS jwks jsonb`,
+			`ALTER TABLE client_metadata ADD COLUMN IF NOT EXISTS jwksuri varchar(254)`,
+		},
+	},
+	{
+		// First-party clients skip the consent screen
+		Version: 2,
+		Queries: []string{
+			`ALTER TABLE client_metadata ADD COLUMN IF NOT EXISTS first_party BOOLEAN NOT NULL DEFAULT false`,
+		},
+	},
+	{
+		// Clients may be required to push their authorization requests
+		Version: 3,
+		Queries: []string{
+			`ALTER TABLE client_metadata ADD COLUMN IF NOT EXISTS require_pushed_authorization_requests BOOLEAN NOT NULL DEFAULT false`,
+		},
+	},
+	{
+		// Per-client token lifetimes
+		Version: 4,
+		Queries: []string{
+			`ALTER TABLE client_metadata ADD COLUMN IF NOT EXISTS access_token_lifetime BIGINT NOT NULL DEFAULT 0`,
+			`ALTER TABLE client_metadata ADD COLUMN IF NOT EXISTS refresh_token_lifetime BIGINT NOT NULL DEFAULT 0`,
+			`ALTER TABLE client_metadata ADD COLUMN IF NOT EXISTS auth_code_lifetime BIGINT NOT NULL DEFAULT 0`,
+		},
+	},
+	{
+		// Registered user claims
+		Version: 5,
+		Queries: []string{
+			`ALTER TABLE client_metadata ADD COLUMN IF NOT EXISTS claims VARCHAR[]`,
+		},
+	},
+	{
+		// Device sessions are looked up by user and their tokens by session,
+		// refresh token families are revoked as a whole
+		Version: 6,
+		Queries: []string{
+			`CREATE INDEX IF NOT EXISTS device_session_tokens_device_session_id_idx ON device_session_tokens (device_session_id)`,
+			`CREATE INDEX IF NOT EXISTS device_sessions_user_id_idx ON device_sessions (user_id)`,
+			`CREATE INDEX IF NOT EXISTS refresh_token_rotations_family_id_idx ON refresh_token_rotations (family_id)`,
+		},
+	},
+}
+
+// MigrateAll creates any missing tables owned by the oauth service, then
+// applies the migrations the database has not seen yet
+func MigrateAll(db *bun.DB) error {
+	ctx := context.Background()
+
+	for _, model := range models {
+		_, err := db.NewCreateTable().
+			Model(model).
+			IfNotExists().
+			Exec(ctx)
+		if err != nil {
+			return err
+		}
+	}
+
+	var versions []int
+	err := db.NewSelect().
+		Model((*SchemaMigration)(nil)).
+		Column("version").
+		Scan(ctx, &versions)
+	if err != nil {
+		return err
+	}
+
+	applied := make(map[int]bool, len(versions))
+	for _, version := range versions {
+		applied[version] = true
+	}
+
+	for _, migration := range migrations {
+		if applied[migration.Version] {
+			continue
+		}
+		if err := applyMigration(db, migration); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// applyMigration runs the queries of a migration and records it, all or
+// nothing
+func applyMigration(db *bun.DB, migration migration) error {
+	ctx := context.Background()
+
+	// Begin a transaction
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+
+	for _, query := range migration.Queries {
+		if _, err := tx.ExecContext(ctx, query); err != nil {
+			tx.Rollback() // rollback the transaction
+			return err
+		}
+	}
+
+	_, err = tx.NewInsert().
+		Model(&SchemaMigration{Version: migration.Version}).
+		Exec(ctx)
+	if err != nil {
+		tx.Rollback() // rollback the transaction
+		return err
+	}
+
+	// Commit the transaction
+	if err = tx.Commit(); err != nil {
+		tx.Rollback() // rollback the transaction
+		return err
+	}
+
+	return nil
+}
@@ -1,6 +1,8 @@
 package mocks
 
 import (
+	"net/http"
+
 	"github.com/resonatecoop/id/config"
 	//"github.com/resonatecoop/id/oauth"
 	"github.com/resonatecoop/id/oauth"
@@ -161,6 +163,18 @@ func (_m *ServiceInterface) AuthClient(clientID uuid.UUID, secret string) (*mode
 
 	return r0, r1
 }
+func (_m *ServiceInterface) CheckClientEnabled(client *model.Client) error {
+	ret := _m.Called(client)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(*model.Client) error); ok {
+		r0 = rf(client)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
 func (_m *ServiceInterface) UserExists(username string) bool {
 	ret := _m.Called(username)
 
@@ -305,19 +319,109 @@ func (_m *ServiceInterface) AuthUser(username string, thePassword string) (*mode
 
 	return r0, r1
 }
-func (_m *ServiceInterface) GetScope(requestedScope string) (string, error) {
-	ret := _m.Called(requestedScope)
+func (_m *ServiceInterface) GetScope(client *model.Client, requestedScope string) (string, error) {
+	ret := _m.Called(client, requestedScope)
 
 	var r0 string
-	if rf, ok := ret.Get(0).(func(string) string); ok {
-		r0 = rf(requestedScope)
+	if rf, ok := ret.Get(0).(func(*model.Client, string) string); ok {
+		r0 = rf(client, requestedScope)
 	} else {
 		r0 = ret.Get(0).(string)
 	}
 
 	var r1 error
-	if rf, ok := ret.Get(1).(func(string) error); ok {
-		r1 = rf(requestedScope)
+	if rf, ok := ret.Get(1).(func(*model.Client, string) error); ok {
+		r1 = rf(client, requestedScope)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+func (_m *ServiceInterface) AccessTokenLifetime(client *model.Client) (int, error) {
+	ret := _m.Called(client)
+
+	var r0 int
+	if rf, ok := ret.Get(0).(func(*model.Client) int); ok {
+		r0 = rf(client)
+	} else {
+		r0 = ret.Get(0).(int)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(*model.Client) error); ok {
+		r1 = rf(client)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+func (_m *ServiceInterface) RefreshTokenLifetime(client *model.Client) (int, error) {
+	ret := _m.Called(client)
+
+	var r0 int
+	if rf, ok := ret.Get(0).(func(*model.Client) int); ok {
+		r0 = rf(client)
+	} else {
+		r0 = ret.Get(0).(int)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(*model.Client) error); ok {
+		r1 = rf(client)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+func (_m *ServiceInterface) AuthCodeLifetime(client *model.Client) (int, error) {
+	ret := _m.Called(client)
+
+	var r0 int
+	if rf, ok := ret.Get(0).(func(*model.Client) int); ok {
+		r0 = rf(client)
+	} else {
+		r0 = ret.Get(0).(int)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(*model.Client) error); ok {
+		r1 = rf(client)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+func (_m *ServiceInterface) NewDeviceSession(client *model.Client, user *model.User, r *http.Request) *oauth.DeviceSession {
+	ret := _m.Called(client, user, r)
+
+	var r0 *oauth.DeviceSession
+	if rf, ok := ret.Get(0).(func(*model.Client, *model.User, *http.Request) *oauth.DeviceSession); ok {
+		r0 = rf(client, user, r)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*oauth.DeviceSession)
+		}
+	}
+
+	return r0
+}
+func (_m *ServiceInterface) GetRoleName(id int32) (string, error) {
+	ret := _m.Called(id)
+
+	var r0 string
+	if rf, ok := ret.Get(0).(func(int32) string); ok {
+		r0 = rf(id)
+	} else {
+		r0 = ret.Get(0).(string)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(int32) error); ok {
+		r1 = rf(id)
 	} else {
 		r1 = ret.Error(1)
 	}
@@ -459,6 +563,27 @@ func (_m *ServiceInterface) Authenticate(token string) (*model.AccessToken, erro
 
 	return r0, r1
 }
+func (_m *ServiceInterface) AuthenticateRequest(r *http.Request) (*model.AccessToken, error) {
+	ret := _m.Called(r)
+
+	var r0 *model.AccessToken
+	if rf, ok := ret.Get(0).(func(*http.Request) *model.AccessToken); ok {
+		r0 = rf(r)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*model.AccessToken)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(*http.Request) error); ok {
+		r1 = rf(r)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
 func (_m *ServiceInterface) NewIntrospectResponseFromAccessToken(accessToken *model.AccessToken) (*oauth.IntrospectResponse, error) {
 	ret := _m.Called(accessToken)
 
@@ -0,0 +1,200 @@
+package oauth
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	jwt "github.com/form3tech-oss/jwt-go"
+	"github.com/resonatecoop/id/util"
+	"github.com/resonatecoop/user-api/model"
+	"github.com/uptrace/bun"
+)
+
+const (
+	// OpenIDScope must be granted for ID tokens to be issued
+	OpenIDScope = "openid"
+)
+
+var (
+	// ErrOpenIDScopeRequired ...
+	ErrOpenIDScopeRequired = errors.New("The openid scope is required")
+)
+
+// AuthorizationCodeNonce stores the OpenID Connect nonce sent along with
+// the authorization request that created an authorization code
+type AuthorizationCodeNonce struct {
+	bun.BaseModel `bun:"table:authorization_code_nonces"`
+
+	Code      string    `bun:"type:varchar(40),pk"`
+	Nonce     string    `bun:"type:varchar(255),notnull"`
+	CreatedAt time.Time `bun:",nullzero,notnull,default:current_timestamp"`
+}
+
+// IDTokenClaims are the claims of an OpenID Connect ID token
+type IDTokenClaims struct {
+	jwt.StandardClaims
+	Nonce         string `json:"nonce,omitempty"`
+	Email         string `json:"email,omitempty"`
+	EmailVerified bool   `json:"email_verified"`
+	Name          string `json:"name,omitempty"`
+	UserClaims
+}
+
+// UserInfoResponse ...
+type UserInfoResponse struct {
+	Subject       string `json:"sub"`
+	Email         string `json:"email,omitempty"`
+	EmailVerified bool   `json:"email_verified"`
+	Name          string `json:"name,omitempty"`
+}
+
+// OpenIDConfiguration is the OpenID Connect discovery document
+type OpenIDConfiguration struct {
+	Issuer                            string   `json:"issuer"`
+	AuthorizationEndpoint             string   `json:"authorization_endpoint"`
+	TokenEndpoint                     string   `json:"token_endpoint"`
+	UserInfoEndpoint                  string   `json:"userinfo_endpoint"`
+	IntrospectionEndpoint             string   `json:"introspection_endpoint"`
+	JWKSURI                           string   `json:"jwks_uri"`
+	DeviceAuthorizationEndpoint       string   `json:"device_authorization_endpoint"`
+	RegistrationEndpoint              string   `json:"registration_endpoint"`
+	PushedAuthorizationEndpoint       string   `json:"pushed_authorization_request_endpoint"`
+	ScopesSupported                   []string `json:"scopes_supported"`
+	ResponseTypesSupported            []string `json:"response_types_supported"`
+	GrantTypesSupported               []string `json:"grant_types_supported"`
+	SubjectTypesSupported             []string `json:"subject_types_supported"`
+	IDTokenSigningAlgValuesSupported  []string `json:"id_token_signing_alg_values_supported"`
+	TokenEndpointAuthMethodsSupported []string `json:"token_endpoint_auth_methods_supported"`
+	TokenEndpointAuthSigningAlgValues []string `json:"token_endpoint_auth_signing_alg_values_supported"`
+	ClaimsSupported                   []string `json:"claims_supported"`
+	CodeChallengeMethodsSupported     []string `json:"code_challenge_methods_supported"`
+	DPoPSigningAlgValuesSupported     []string `json:"dpop_signing_alg_values_supported"`
+}
+
+// HasOpenIDScope returns true if the space delimited scope includes openid
+func HasOpenIDScope(scope string) bool {
+	return util.StringInSlice(OpenIDScope, strings.Split(scope, " "))
+}
+
+// GetIssuer returns the issuer identifier used in ID tokens
+func (s *Service) GetIssuer() string {
+	return fmt.Sprintf("https://%s", s.cnf.Hostname)
+}
+
+// NewOpenIDConfiguration returns the discovery document for this server
+func (s *Service) NewOpenIDConfiguration() *OpenIDConfiguration {
+	issuer := s.GetIssuer()
+
+	return &OpenIDConfiguration{
+		Issuer:                            issuer,
+		AuthorizationEndpoint:             issuer + "/web/authorize",
+		TokenEndpoint:                     issuer + "/v1/oauth/tokens",
+		UserInfoEndpoint:                  issuer + "/v1/oauth/userinfo",
+		IntrospectionEndpoint:             issuer + "/v1/oauth/introspect",
+		JWKSURI:                           issuer + "/.well-known/jwks.json",
+		DeviceAuthorizationEndpoint:       issuer + "/v1/oauth/device_authorization",
+		RegistrationEndpoint:              issuer + "/v1/oauth/register",
+		PushedAuthorizationEndpoint:       issuer + "/v1/oauth/par",
+		ScopesSupported:                   []string{OpenIDScope, "read", "read_write"},
+		ResponseTypesSupported:            []string{"code", "token"},
+		GrantTypesSupported:               []string{"authorization_code", "implicit", "password", "client_credentials", "refresh_token", DeviceCodeGrantType, TokenExchangeGrantType},
+		SubjectTypesSupported:             []string{"public"},
+		IDTokenSigningAlgValuesSupported:  []string{s.cnf.SigningKeys.Algorithm},
+		TokenEndpointAuthMethodsSupported: []string{TokenEndpointAuthMethodBasic, TokenEndpointAuthMethodPost, TokenEndpointAuthMethodPrivateKeyJWT, TokenEndpointAuthMethodNone},
+		TokenEndpointAuthSigningAlgValues: ClientAssertionSigningAlgs,
+		ClaimsSupported:                   []string{"iss", "sub", "aud", "exp", "iat", "nonce", "email", "email_verified", "name", ClaimRole, ClaimEmailConfirmed, ClaimCountry, ClaimLegacyID, ClaimMember},
+		CodeChallengeMethodsSupported:     []string{CodeChallengeMethodPlain, CodeChallengeMethodS256},
+		DPoPSigningAlgValuesSupported:     DPoPSigningAlgs,
+	}
+}
+
+// NewUserInfoResponse returns the standard claims about a user
+func NewUserInfoResponse(user *model.User) *UserInfoResponse {
+	name := user.FullName
+	if name == "" {
+		name = strings.TrimSpace(user.FirstName + " " + user.LastName)
+	}
+
+	return &UserInfoResponse{
+		Subject:       user.ID.String(),
+		Email:         user.Username,
+		EmailVerified: user.EmailConfirmed,
+		Name:          name,
+	}
+}
+
+// GrantIDToken creates a signed ID token for the user authenticated by the client
+func (s *Service) GrantIDToken(client *model.Client, user *model.User, nonce string) (string, error) {
+	userInfo := NewUserInfoResponse(user)
+	now := time.Now().UTC()
+
+	userClaims, err := s.newUserClaims(client, user)
+	if err != nil {
+		return "", err
+	}
+
+	claims := &IDTokenClaims{
+		StandardClaims: jwt.StandardClaims{
+			Issuer:    s.GetIssuer(),
+			Subject:   userInfo.Subject,
+			Audience:  []string{client.Key},
+			IssuedAt:  now.Unix(),
+			ExpiresAt: now.Add(time.Duration(s.cnf.OIDC.IDTokenLifetime) * time.Second).Unix(),
+		},
+		Nonce:         nonce,
+		Email:         userInfo.Email,
+		EmailVerified: userInfo.EmailVerified,
+		Name:          userInfo.Name,
+		UserClaims:    userClaims,
+	}
+
+	return s.signJWT(claims, "")
+}
+
+// saveAuthorizationCodeNonce stores the nonce of an authorization request
+func (s *Service) saveAuthorizationCodeNonce(db bun.IDB, code, nonce string) error {
+	_, err := db.NewInsert().
+		Model(&AuthorizationCodeNonce{
+			Code:      code,
+			Nonce:     nonce,
+			CreatedAt: time.Now().UTC(),
+		}).
+		Exec(context.Background())
+
+	return err
+}
+
+// popAuthorizationCodeNonce returns and deletes the nonce stored for an authorization code
+func (s *Service) popAuthorizationCodeNonce(code string) (string, error) {
+	ctx := context.Background()
+	nonce := new(AuthorizationCodeNonce)
+
+	err := s.db.NewSelect().
+		Model(nonce).
+		Where("code = ?", code).
+		Limit(1).
+		Scan(ctx)
+
+	// Not found, no nonce was sent with the authorization request
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+
+	_, err = s.db.NewDelete().
+		Model(nonce).
+		WherePK().
+		Exec(ctx)
+
+	if err != nil {
+		return "", err
+	}
+
+	return nonce.Nonce, nil
+}
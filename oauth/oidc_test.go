@@ -0,0 +1,125 @@
+package oauth_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	jwt "github.com/form3tech-oss/jwt-go"
+	"github.com/resonatecoop/id/oauth"
+	testutil "github.com/resonatecoop/id/test-util"
+	"github.com/resonatecoop/user-api/model"
+	"github.com/stretchr/testify/assert"
+)
+
+func (suite *OauthTestSuite) TestGrantIDToken() {
+	idToken, err := suite.service.GrantIDToken(suite.clients[0], suite.users[0], "test_nonce")
+	assert.NoError(suite.T(), err)
+
+	set, err := suite.service.GetJWKS()
+	assert.NoError(suite.T(), err)
+
+	claims := new(oauth.IDTokenClaims)
+	token, err := jwt.ParseWithClaims(idToken, claims, set.Keyfunc)
+	assert.NoError(suite.T(), err)
+	assert.True(suite.T(), token.Valid)
+
+	assert.Equal(suite.T(), suite.service.GetIssuer(), claims.Issuer)
+	assert.Equal(suite.T(), suite.users[0].ID.String(), claims.Subject)
+	assert.Equal(suite.T(), []string{suite.clients[0].Key}, claims.Audience)
+	assert.Equal(suite.T(), "test_nonce", claims.Nonce)
+	assert.Equal(suite.T(), suite.users[0].Username, claims.Email)
+	assert.Equal(suite.T(), suite.users[0].EmailConfirmed, claims.EmailVerified)
+}
+
+func (suite *OauthTestSuite) TestUserInfoHandler() {
+	accessToken := &model.AccessToken{
+		IDRecord:  model.IDRecord{CreatedAt: time.Now().UTC()},
+		Token:     "test_userinfo_token",
+		ExpiresAt: time.Now().UTC().Add(+10 * time.Second),
+		ClientID:  suite.clients[0].ID,
+		UserID:    suite.users[0].ID,
+		Scope:     "read_write openid",
+	}
+	_, err := suite.db.NewInsert().Model(accessToken).Exec(context.Background())
+	assert.NoError(suite.T(), err, "Inserting test data failed")
+
+	// Prepare a request
+	r, err := http.NewRequest("GET", "http://1.2.3.4/v1/oauth/userinfo", nil)
+	assert.NoError(suite.T(), err, "Request setup should not get an error")
+	r.Header.Set("Authorization", "Bearer test_userinfo_token")
+
+	// Serve the request
+	w := httptest.NewRecorder()
+	suite.router.ServeHTTP(w, r)
+
+	// Check the response
+	testutil.TestResponseObject(suite.T(), w, oauth.NewUserInfoResponse(suite.users[0]), 200)
+}
+
+func (suite *OauthTestSuite) TestUserInfoHandlerRequiresOpenIDScope() {
+	accessToken := &model.AccessToken{
+		IDRecord:  model.IDRecord{CreatedAt: time.Now().UTC()},
+		Token:     "test_userinfo_token",
+		ExpiresAt: time.Now().UTC().Add(+10 * time.Second),
+		ClientID:  suite.clients[0].ID,
+		UserID:    suite.users[0].ID,
+		Scope:     "read_write",
+	}
+	_, err := suite.db.NewInsert().Model(accessToken).Exec(context.Background())
+	assert.NoError(suite.T(), err, "Inserting test data failed")
+
+	// Prepare a request
+	r, err := http.NewRequest("GET", "http://1.2.3.4/v1/oauth/userinfo", nil)
+	assert.NoError(suite.T(), err, "Request setup should not get an error")
+	r.Header.Set("Authorization", "Bearer test_userinfo_token")
+
+	// Serve the request
+	w := httptest.NewRecorder()
+	suite.router.ServeHTTP(w, r)
+
+	// Check the response
+	testutil.TestResponseForError(
+		suite.T(),
+		w,
+		oauth.ErrOpenIDScopeRequired.Error(),
+		403,
+	)
+}
+
+func (suite *OauthTestSuite) TestUserInfoHandlerInvalidToken() {
+	userInfo := func(authorization string) *httptest.ResponseRecorder {
+		r, err := http.NewRequest("GET", "http://1.2.3.4/v1/oauth/userinfo", nil)
+		assert.NoError(suite.T(), err, "Request setup should not get an error")
+		if authorization != "" {
+			r.Header.Set("Authorization", authorization)
+		}
+		w := httptest.NewRecorder()
+		suite.router.ServeHTTP(w, r)
+		return w
+	}
+
+	// Requests without a token are only asked to authenticate
+	w := userInfo("")
+	testutil.TestResponseForError(suite.T(), w, oauth.ErrTokenMissing.Error(), 401)
+	assert.Equal(suite.T(), "Bearer realm=go_oauth2_server", w.Header().Get("WWW-Authenticate"))
+
+	// Invalid tokens are reported as such
+	w = userInfo("Bearer bogus")
+	testutil.TestResponseForError(suite.T(), w, oauth.ErrAccessTokenNotFound.Error(), 401)
+	assert.Equal(suite.T(), "Bearer realm=go_oauth2_server, error=\"invalid_token\"", w.Header().Get("WWW-Authenticate"))
+}
+
+func (suite *OauthTestSuite) TestOpenIDConfigurationHandler() {
+	// Prepare a request
+	r, err := http.NewRequest("GET", "http://1.2.3.4/.well-known/openid-configuration", nil)
+	assert.NoError(suite.T(), err, "Request setup should not get an error")
+
+	// Serve the request
+	w := httptest.NewRecorder()
+	suite.router.ServeHTTP(w, r)
+
+	// Check the response
+	testutil.TestResponseObject(suite.T(), w, suite.service.NewOpenIDConfiguration(), 200)
+}
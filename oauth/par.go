@@ -0,0 +1,211 @@
+package oauth
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/resonatecoop/user-api/model"
+	"github.com/uptrace/bun"
+)
+
+const (
+	// RequestURIPrefix starts the request_uri of pushed authorization requests
+	RequestURIPrefix = "urn:ietf:params:oauth:request_uri:"
+)
+
+var (
+	// ErrInvalidRequestURI ...
+	ErrInvalidRequestURI = errors.New("Invalid or expired request URI")
+	// ErrPushedAuthorizationRequired ...
+	ErrPushedAuthorizationRequired = errors.New("Pushed authorization request required")
+)
+
+var (
+	// authorizationParams are the parameters of an authorization request,
+	// pushed requests replace them all
+	authorizationParams = []string{
+		"response_type",
+		"redirect_uri",
+		"scope",
+		"state",
+		"code_challenge",
+		"code_challenge_method",
+		"nonce",
+	}
+)
+
+// PushedAuthorizationRequest stores the authorization parameters a client
+// pushed to the par endpoint until the user is sent to /web/authorize with
+// its request_uri (RFC 9126)
+type PushedAuthorizationRequest struct {
+	bun.BaseModel `bun:"table:pushed_authorization_requests"`
+
+	RequestURI string     `bun:"type:varchar(100),pk"`
+	ClientID   uuid.UUID  `bun:"type:uuid,notnull"`
+	Params     url.Values `bun:"type:jsonb,notnull"`
+	ExpiresAt  time.Time  `bun:",notnull"`
+	CreatedAt  time.Time  `bun:",nullzero,notnull,default:current_timestamp"`
+}
+
+// PushAuthorizationRequest validates the authorization parameters of a
+// client and stores them under a new request_uri
+func (s *Service) PushAuthorizationRequest(client *model.Client, form url.Values) (*PushedAuthorizationRequest, error) {
+	// A pushed request cannot refer to another one
+	if form.Get("request_uri") != "" {
+		return nil, ErrInvalidRequestURI
+	}
+
+	params := url.Values{}
+	for _, name := range authorizationParams {
+		if form.Get(name) != "" {
+			params.Set(name, form.Get(name))
+		}
+	}
+
+	if err := s.validateAuthorizationParams(client, params); err != nil {
+		return nil, err
+	}
+
+	reference, err := newClientSecret()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+	pushedAuthorizationRequest := &PushedAuthorizationRequest{
+		RequestURI: RequestURIPrefix + reference,
+		ClientID:   client.ID,
+		Params:     params,
+		ExpiresAt:  now.Add(time.Duration(s.cnf.Oauth.RequestURILifetime) * time.Second),
+		CreatedAt:  now,
+	}
+
+	ctx := context.Background()
+
+	// Expired requests can no longer be used
+	_, err = s.db.NewDelete().
+		Model((*PushedAuthorizationRequest)(nil)).
+		Where("expires_at < ?", now).
+		Exec(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = s.db.NewInsert().
+		Model(pushedAuthorizationRequest).
+		Exec(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return pushedAuthorizationRequest, nil
+}
+
+// ResolveAuthorizationRequest returns the parameters of an authorization
+// request. A request_uri is resolved to the parameters pushed by the client,
+// which replace any sent along with it. Clients requiring pushed requests
+// cannot send their parameters to /web/authorize directly.
+func (s *Service) ResolveAuthorizationRequest(client *model.Client, form url.Values) (url.Values, error) {
+	requestURI := form.Get("request_uri")
+	if requestURI == "" {
+		required, err := s.RequiresPushedAuthorizationRequests(client)
+		if err != nil {
+			return nil, err
+		}
+		if required {
+			return nil, ErrPushedAuthorizationRequired
+		}
+		return form, nil
+	}
+
+	if !strings.HasPrefix(requestURI, RequestURIPrefix) {
+		return nil, ErrInvalidRequestURI
+	}
+
+	pushedAuthorizationRequest := new(PushedAuthorizationRequest)
+	err := s.db.NewSelect().
+		Model(pushedAuthorizationRequest).
+		Where("request_uri = ?", requestURI).
+		Where("client_id = ?", client.ID).
+		Limit(1).
+		Scan(context.Background())
+	if err != nil {
+		return nil, ErrInvalidRequestURI
+	}
+
+	if time.Now().UTC().After(pushedAuthorizationRequest.ExpiresAt) {
+		return nil, ErrInvalidRequestURI
+	}
+
+	resolved := url.Values{}
+	for name, values := range form {
+		resolved[name] = values
+	}
+	for _, name := range authorizationParams {
+		resolved.Del(name)
+		if value := pushedAuthorizationRequest.Params.Get(name); value != "" {
+			resolved.Set(name, value)
+		}
+	}
+
+	return resolved, nil
+}
+
+// DeletePushedAuthorizationRequest makes a request_uri unusable once the
+// user answered the authorization request
+func (s *Service) DeletePushedAuthorizationRequest(requestURI string) error {
+	_, err := s.db.NewDelete().
+		Model((*PushedAuthorizationRequest)(nil)).
+		Where("request_uri = ?", requestURI).
+		Exec(context.Background())
+	return err
+}
+
+// RequiresPushedAuthorizationRequests returns true if the client must push
+// its authorization requests
+func (s *Service) RequiresPushedAuthorizationRequests(client *model.Client) (bool, error) {
+	policy, err := s.clientPolicy(client)
+	if err != nil {
+		return false, err
+	}
+	return policy != nil && policy.RequirePushedAuthorizationRequests, nil
+}
+
+// validateAuthorizationParams checks the parameters of a pushed request the
+// same way /web/authorize does
+func (s *Service) validateAuthorizationParams(client *model.Client, params url.Values) error {
+	responseType := params.Get("response_type")
+	if responseType == "" {
+		responseType = "code"
+	}
+
+	if _, ok := responseTypeGrantTypes[responseType]; !ok {
+		return ErrInvalidResponseType
+	}
+
+	if err := s.AuthorizeResponseType(client, responseType); err != nil {
+		return err
+	}
+
+	if _, err := s.ResolveRedirectURI(client, params.Get("redirect_uri")); err != nil {
+		return err
+	}
+
+	if _, err := s.GetScope(client, params.Get("scope")); err != nil {
+		return err
+	}
+
+	codeChallenge := params.Get("code_challenge")
+	codeChallengeMethod := params.Get("code_challenge_method")
+	if codeChallenge != "" || codeChallengeMethod != "" {
+		if _, err := ValidateCodeChallenge(codeChallenge, codeChallengeMethod); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
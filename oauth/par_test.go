@@ -0,0 +1,130 @@
+package oauth_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+
+	"github.com/resonatecoop/id/oauth"
+	testutil "github.com/resonatecoop/id/test-util"
+	"github.com/stretchr/testify/assert"
+)
+
+func (suite *OauthTestSuite) TestPushedAuthorizationRequest() {
+	resp, err := suite.service.RegisterClient(&oauth.ClientRegistrationRequest{
+		ClientName:                         "Partner App",
+		RedirectURIs:                       []string{"https://partner.example.com/callback"},
+		GrantTypes:                         []string{"authorization_code"},
+		RequirePushedAuthorizationRequests: true,
+	}, false)
+	assert.NoError(suite.T(), err)
+
+	client, err := suite.service.FindClientByClientID(resp.ClientID)
+	assert.NoError(suite.T(), err)
+
+	par := func(form url.Values) *httptest.ResponseRecorder {
+		r, err := http.NewRequest("POST", "http://1.2.3.4/v1/oauth/par", nil)
+		assert.NoError(suite.T(), err, "Request setup should not get an error")
+		r.SetBasicAuth(resp.ClientID, resp.ClientSecret)
+		r.PostForm = form
+		w := httptest.NewRecorder()
+		suite.router.ServeHTTP(w, r)
+		return w
+	}
+
+	// The pushed request is validated
+	w := par(url.Values{
+		"response_type": {"code"},
+		"redirect_uri":  {"https://evil.example.com/callback"},
+	})
+	testutil.TestResponseForOauthError(suite.T(), w, oauth.ErrorCodeInvalidRequest, oauth.ErrRedirectURIMismatch.Error(), 400)
+
+	w = par(url.Values{
+		"response_type": {"code"},
+		"redirect_uri":  {"https://partner.example.com/callback"},
+		"scope":         {"read"},
+		"state":         {"state"},
+	})
+	assert.Equal(suite.T(), http.StatusCreated, w.Code)
+
+	pushed := new(oauth.PushedAuthorizationResponse)
+	assert.NoError(suite.T(), json.Unmarshal(w.Body.Bytes(), pushed))
+	assert.True(suite.T(), strings.HasPrefix(pushed.RequestURI, oauth.RequestURIPrefix))
+	assert.InDelta(suite.T(), suite.cnf.Oauth.RequestURILifetime, pushed.ExpiresIn, 2)
+
+	// The client requires pushed requests
+	_, err = suite.service.ResolveAuthorizationRequest(client, url.Values{
+		"client_id": {resp.ClientID},
+		"scope":     {"read"},
+	})
+	assert.Equal(suite.T(), oauth.ErrPushedAuthorizationRequired, err)
+
+	// The pushed parameters replace the ones of the query string
+	form, err := suite.service.ResolveAuthorizationRequest(client, url.Values{
+		"client_id":   {resp.ClientID},
+		"request_uri": {pushed.RequestURI},
+		"scope":       {"read_write"},
+		"nonce":       {"nonce"},
+	})
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), "read", form.Get("scope"))
+	assert.Equal(suite.T(), "state", form.Get("state"))
+	assert.Equal(suite.T(), "", form.Get("nonce"))
+	assert.Equal(suite.T(), resp.ClientID, form.Get("client_id"))
+
+	// Other clients cannot use the request URI
+	_, err = suite.service.ResolveAuthorizationRequest(suite.clients[0], url.Values{
+		"request_uri": {pushed.RequestURI},
+	})
+	assert.Equal(suite.T(), oauth.ErrInvalidRequestURI, err)
+
+	// Request URIs are used once
+	assert.NoError(suite.T(), suite.service.DeletePushedAuthorizationRequest(pushed.RequestURI))
+	_, err = suite.service.ResolveAuthorizationRequest(client, url.Values{
+		"request_uri": {pushed.RequestURI},
+	})
+	assert.Equal(suite.T(), oauth.ErrInvalidRequestURI, err)
+}
+
+func (suite *OauthTestSuite) TestPushedAuthorizationRequestClientAuth() {
+	resp, err := suite.service.RegisterClient(&oauth.ClientRegistrationRequest{
+		ClientName:   "Partner App",
+		RedirectURIs: []string{"https://partner.example.com/callback"},
+		GrantTypes:   []string{"authorization_code"},
+	}, false)
+	assert.NoError(suite.T(), err)
+
+	par := func(clientID string) *httptest.ResponseRecorder {
+		r, err := http.NewRequest("POST", "http://1.2.3.4/v1/oauth/par", nil)
+		assert.NoError(suite.T(), err, "Request setup should not get an error")
+		r.PostForm = url.Values{
+			"client_id":     {clientID},
+			"response_type": {"code"},
+			"redirect_uri":  {"https://partner.example.com/callback"},
+		}
+		w := httptest.NewRecorder()
+		suite.router.ServeHTTP(w, r)
+		return w
+	}
+
+	// Confidential and older clients cannot push requests without their secret
+	w := par(resp.ClientID)
+	testutil.TestResponseForOauthError(suite.T(), w, oauth.ErrorCodeInvalidClient, oauth.ErrInvalidClientIDOrSecret.Error(), 401)
+
+	w = par("test_client_1")
+	testutil.TestResponseForOauthError(suite.T(), w, oauth.ErrorCodeInvalidClient, oauth.ErrInvalidClientIDOrSecret.Error(), 401)
+
+	// Clients registered as public can
+	public, err := suite.service.RegisterClient(&oauth.ClientRegistrationRequest{
+		ClientName:              "Stream Player",
+		RedirectURIs:            []string{"https://partner.example.com/callback"},
+		GrantTypes:              []string{"authorization_code"},
+		TokenEndpointAuthMethod: oauth.TokenEndpointAuthMethodNone,
+	}, false)
+	assert.NoError(suite.T(), err)
+
+	w = par(public.ClientID)
+	assert.Equal(suite.T(), http.StatusCreated, w.Code)
+}
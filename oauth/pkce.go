@@ -0,0 +1,125 @@
+package oauth
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"regexp"
+	"time"
+
+	"github.com/uptrace/bun"
+)
+
+const (
+	// CodeChallengeMethodPlain uses the code verifier as the code challenge
+	CodeChallengeMethodPlain = "plain"
+	// CodeChallengeMethodS256 uses BASE64URL(SHA256(code_verifier)) as the code challenge
+	CodeChallengeMethodS256 = "S256"
+)
+
+var (
+	// ErrInvalidCodeChallenge ...
+	ErrInvalidCodeChallenge = errors.New("Invalid code challenge")
+	// ErrInvalidCodeChallengeMethod ...
+	ErrInvalidCodeChallengeMethod = errors.New("Invalid code challenge method")
+	// ErrCodeVerifierMissing ...
+	ErrCodeVerifierMissing = errors.New("Code verifier missing")
+	// ErrInvalidCodeVerifier ...
+	ErrInvalidCodeVerifier = errors.New("Invalid code verifier")
+
+	// https://tools.ietf.org/html/rfc7636#section-4.1
+	pkceValueRegex = regexp.MustCompile(`^[A-Za-z0-9\-._~]{43,128}$`)
+)
+
+// AuthorizationCodeChallenge stores the PKCE code challenge sent along
+// with the authorization request that created an authorization code
+type AuthorizationCodeChallenge struct {
+	bun.BaseModel `bun:"table:authorization_code_challenges"`
+
+	Code                string    `bun:"type:varchar(40),pk"`
+	CodeChallenge       string    `bun:"type:varchar(128),notnull"`
+	CodeChallengeMethod string    `bun:"type:varchar(10),notnull"`
+	CreatedAt           time.Time `bun:",nullzero,notnull,default:current_timestamp"`
+}
+
+// ValidateCodeChallenge checks the code challenge and method sent to the
+// authorization endpoint and returns the method to be stored (plain by default)
+func ValidateCodeChallenge(codeChallenge, codeChallengeMethod string) (string, error) {
+	if codeChallengeMethod == "" {
+		codeChallengeMethod = CodeChallengeMethodPlain
+	}
+
+	if codeChallengeMethod != CodeChallengeMethodPlain && codeChallengeMethod != CodeChallengeMethodS256 {
+		return "", ErrInvalidCodeChallengeMethod
+	}
+
+	if !pkceValueRegex.MatchString(codeChallenge) {
+		return "", ErrInvalidCodeChallenge
+	}
+
+	return codeChallengeMethod, nil
+}
+
+// VerifyCodeVerifier returns true if the code verifier matches the code challenge
+func VerifyCodeVerifier(codeVerifier, codeChallenge, codeChallengeMethod string) bool {
+	if !pkceValueRegex.MatchString(codeVerifier) {
+		return false
+	}
+
+	expected := codeVerifier
+
+	if codeChallengeMethod == CodeChallengeMethodS256 {
+		sum := sha256.Sum256([]byte(codeVerifier))
+		expected = base64.RawURLEncoding.EncodeToString(sum[:])
+	}
+
+	return subtle.ConstantTimeCompare([]byte(expected), []byte(codeChallenge)) == 1
+}
+
+// findAuthorizationCodeChallenge returns the code challenge stored for an authorization code, if any
+func (s *Service) findAuthorizationCodeChallenge(code string) (*AuthorizationCodeChallenge, error) {
+	ctx := context.Background()
+	challenge := new(AuthorizationCodeChallenge)
+
+	err := s.db.NewSelect().
+		Model(challenge).
+		Where("code = ?", code).
+		Limit(1).
+		Scan(ctx)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return challenge, nil
+}
+
+// verifyAuthorizationCodeChallenge checks the code verifier against the code
+// challenge stored for the authorization code
+func (s *Service) verifyAuthorizationCodeChallenge(code, codeVerifier string) error {
+	challenge, err := s.findAuthorizationCodeChallenge(code)
+
+	// No challenge was sent with the authorization request
+	if err == sql.ErrNoRows {
+		if codeVerifier != "" {
+			return ErrInvalidCodeVerifier
+		}
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if codeVerifier == "" {
+		return ErrCodeVerifierMissing
+	}
+
+	if !VerifyCodeVerifier(codeVerifier, challenge.CodeChallenge, challenge.CodeChallengeMethod) {
+		return ErrInvalidCodeVerifier
+	}
+
+	return nil
+}
@@ -0,0 +1,229 @@
+package oauth_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/resonatecoop/id/oauth"
+	testutil "github.com/resonatecoop/id/test-util"
+	"github.com/stretchr/testify/assert"
+)
+
+// Example values from https://tools.ietf.org/html/rfc7636#appendix-B
+const (
+	testCodeVerifier  = "dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk"
+	testCodeChallenge = "E9Melhoa2OwvFrEMTJguCHaoeK1t8URWbuGJSstw-cM"
+)
+
+func TestValidateCodeChallenge(t *testing.T) {
+	method, err := oauth.ValidateCodeChallenge(testCodeChallenge, "")
+	assert.NoError(t, err)
+	assert.Equal(t, oauth.CodeChallengeMethodPlain, method)
+
+	method, err = oauth.ValidateCodeChallenge(testCodeChallenge, "S256")
+	assert.NoError(t, err)
+	assert.Equal(t, oauth.CodeChallengeMethodS256, method)
+
+	_, err = oauth.ValidateCodeChallenge(testCodeChallenge, "S512")
+	assert.Equal(t, oauth.ErrInvalidCodeChallengeMethod, err)
+
+	_, err = oauth.ValidateCodeChallenge("too_short", "S256")
+	assert.Equal(t, oauth.ErrInvalidCodeChallenge, err)
+}
+
+func TestVerifyCodeVerifier(t *testing.T) {
+	assert.True(t, oauth.VerifyCodeVerifier(testCodeVerifier, testCodeChallenge, oauth.CodeChallengeMethodS256))
+	assert.True(t, oauth.VerifyCodeVerifier(testCodeVerifier, testCodeVerifier, oauth.CodeChallengeMethodPlain))
+	assert.False(t, oauth.VerifyCodeVerifier(testCodeVerifier, testCodeChallenge, oauth.CodeChallengeMethodPlain))
+	assert.False(t, oauth.VerifyCodeVerifier(testCodeChallenge, testCodeChallenge, oauth.CodeChallengeMethodS256))
+}
+
+func (suite *OauthTestSuite) TestGrantAuthorizationCodeWithCodeChallenge() {
+	authorizationCode, err := suite.service.GrantAuthorizationCode(
+		suite.clients[0],          // client
+		suite.users[0],            // user
+		3600,                      // expires in
+		"https://www.example.com", // redirect URI
+		"read_write",              // scope
+		testCodeChallenge,         // code challenge
+		"S256",                    // code challenge method
+		"",                        // nonce
+	)
+	assert.NoError(suite.T(), err)
+
+	challenge := new(oauth.AuthorizationCodeChallenge)
+	err = suite.db.NewSelect().
+		Model(challenge).
+		Where("code = ?", authorizationCode.Code).
+		Limit(1).
+		Scan(context.Background())
+
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), testCodeChallenge, challenge.CodeChallenge)
+	assert.Equal(suite.T(), oauth.CodeChallengeMethodS256, challenge.CodeChallengeMethod)
+}
+
+func (suite *OauthTestSuite) TestAuthorizationCodeGrantPublicClientWithPKCE() {
+	resp, err := suite.service.RegisterClient(&oauth.ClientRegistrationRequest{
+		RedirectURIs:            []string{"https://www.example.com"},
+		Scope:                   "read_write",
+		TokenEndpointAuthMethod: oauth.TokenEndpointAuthMethodNone,
+	}, false)
+	assert.NoError(suite.T(), err)
+
+	client, err := suite.service.FindClientByClientID(resp.ClientID)
+	assert.NoError(suite.T(), err)
+
+	authorizationCode, err := suite.service.GrantAuthorizationCode(
+		client,                    // client
+		suite.users[0],            // user
+		3600,                      // expires in
+		"https://www.example.com", // redirect URI
+		"read_write",              // scope
+		testCodeChallenge,         // code challenge
+		"S256",                    // code challenge method
+		"",                        // nonce
+	)
+	assert.NoError(suite.T(), err)
+
+	// Prepare a request without basic auth
+	r, err := http.NewRequest("POST", "http://1.2.3.4/v1/oauth/tokens", nil)
+	assert.NoError(suite.T(), err, "Request setup should not get an error")
+	r.PostForm = url.Values{
+		"grant_type":    {"authorization_code"},
+		"client_id":     {resp.ClientID},
+		"code":          {authorizationCode.Code},
+		"redirect_uri":  {"https://www.example.com"},
+		"code_verifier": {testCodeVerifier},
+	}
+
+	// Serve the request
+	w := httptest.NewRecorder()
+	suite.router.ServeHTTP(w, r)
+
+	assert.Equal(suite.T(), 200, w.Code)
+
+	// The code challenge should get deleted with the authorization code
+	count, err := suite.db.NewSelect().
+		Model((*oauth.AuthorizationCodeChallenge)(nil)).
+		Where("code = ?", authorizationCode.Code).
+		Count(context.Background())
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), 0, count)
+}
+
+func (suite *OauthTestSuite) TestAuthorizationCodeGrantConfidentialClientWithPKCE() {
+	authorizationCode, err := suite.service.GrantAuthorizationCode(
+		suite.clients[0],          // client
+		suite.users[0],            // user
+		3600,                      // expires in
+		"https://www.example.com", // redirect URI
+		"read_write",              // scope
+		testCodeChallenge,         // code challenge
+		"S256",                    // code challenge method
+		"",                        // nonce
+	)
+	assert.NoError(suite.T(), err)
+
+	// Prepare a request without basic auth, the code verifier does not
+	// replace the secret of a confidential client
+	r, err := http.NewRequest("POST", "http://1.2.3.4/v1/oauth/tokens", nil)
+	assert.NoError(suite.T(), err, "Request setup should not get an error")
+	r.PostForm = url.Values{
+		"grant_type":    {"authorization_code"},
+		"client_id":     {"test_client_1"},
+		"code":          {authorizationCode.Code},
+		"redirect_uri":  {"https://www.example.com"},
+		"code_verifier": {testCodeVerifier},
+	}
+
+	// Serve the request
+	w := httptest.NewRecorder()
+	suite.router.ServeHTTP(w, r)
+
+	testutil.TestResponseForOauthError(
+		suite.T(),
+		w,
+		oauth.ErrorCodeInvalidClient,
+		oauth.ErrInvalidClientIDOrSecret.Error(),
+		401,
+	)
+}
+
+func (suite *OauthTestSuite) TestAuthorizationCodeGrantInvalidCodeVerifier() {
+	authorizationCode, err := suite.service.GrantAuthorizationCode(
+		suite.clients[0],          // client
+		suite.users[0],            // user
+		3600,                      // expires in
+		"https://www.example.com", // redirect URI
+		"read_write",              // scope
+		testCodeChallenge,         // code challenge
+		"S256",                    // code challenge method
+		"",                        // nonce
+	)
+	assert.NoError(suite.T(), err)
+
+	// Prepare a request
+	r, err := http.NewRequest("POST", "http://1.2.3.4/v1/oauth/tokens", nil)
+	assert.NoError(suite.T(), err, "Request setup should not get an error")
+	r.SetBasicAuth("test_client_1", "test_secret")
+	r.PostForm = url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {authorizationCode.Code},
+		"redirect_uri":  {"https://www.example.com"},
+		"code_verifier": {testCodeChallenge},
+	}
+
+	// Serve the request
+	w := httptest.NewRecorder()
+	suite.router.ServeHTTP(w, r)
+
+	// Check the response
+	testutil.TestResponseForOauthError(
+		suite.T(),
+		w,
+		oauth.ErrorCodeInvalidGrant,
+		oauth.ErrInvalidCodeVerifier.Error(),
+		400,
+	)
+}
+
+func (suite *OauthTestSuite) TestAuthorizationCodeGrantCodeVerifierMissing() {
+	authorizationCode, err := suite.service.GrantAuthorizationCode(
+		suite.clients[0],          // client
+		suite.users[0],            // user
+		3600,                      // expires in
+		"https://www.example.com", // redirect URI
+		"read_write",              // scope
+		testCodeChallenge,         // code challenge
+		"S256",                    // code challenge method
+		"",                        // nonce
+	)
+	assert.NoError(suite.T(), err)
+
+	// Prepare a request
+	r, err := http.NewRequest("POST", "http://1.2.3.4/v1/oauth/tokens", nil)
+	assert.NoError(suite.T(), err, "Request setup should not get an error")
+	r.SetBasicAuth("test_client_1", "test_secret")
+	r.PostForm = url.Values{
+		"grant_type":   {"authorization_code"},
+		"code":         {authorizationCode.Code},
+		"redirect_uri": {"https://www.example.com"},
+	}
+
+	// Serve the request
+	w := httptest.NewRecorder()
+	suite.router.ServeHTTP(w, r)
+
+	// Check the response
+	testutil.TestResponseForOauthError(
+		suite.T(),
+		w,
+		oauth.ErrorCodeInvalidRequest,
+		oauth.ErrCodeVerifierMissing.Error(),
+		400,
+	)
+}
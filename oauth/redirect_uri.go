@@ -0,0 +1,90 @@
+package oauth
+
+import (
+	"errors"
+	"net/url"
+
+	"github.com/resonatecoop/user-api/model"
+)
+
+var (
+	// ErrRedirectURIMismatch ...
+	ErrRedirectURIMismatch = errors.New("Redirect URI does not match any registered redirect URI")
+	// ErrRedirectURIRequired ...
+	ErrRedirectURIRequired = errors.New("Redirect URI required, the client has several registered")
+)
+
+// RegisteredRedirectURIs returns the redirect URIs registered for a client,
+// clients created before dynamic registration have a single one
+func (s *Service) RegisteredRedirectURIs(client *model.Client) ([]string, error) {
+	policy, err := s.clientPolicy(client)
+	if err != nil {
+		return nil, err
+	}
+	if policy != nil && len(policy.RedirectURIs) > 0 {
+		return policy.RedirectURIs, nil
+	}
+
+	if client.RedirectURI.String == "" {
+		return nil, nil
+	}
+
+	return []string{client.RedirectURI.String}, nil
+}
+
+// ResolveRedirectURI returns the redirect URI of an authorization request,
+// the client's only registered redirect URI is used if none was requested.
+// Errors must not be redirected to the requested redirect URI.
+func (s *Service) ResolveRedirectURI(client *model.Client, redirectURI string) (*url.URL, error) {
+	registered, err := s.RegisteredRedirectURIs(client)
+	if err != nil {
+		return nil, err
+	}
+
+	if redirectURI == "" {
+		if len(registered) != 1 {
+			return nil, ErrRedirectURIRequired
+		}
+		redirectURI = registered[0]
+	}
+
+	redirectURI, err = MatchRedirectURI(registered, redirectURI)
+	if err != nil {
+		return nil, err
+	}
+
+	return url.ParseRequestURI(redirectURI)
+}
+
+// MatchRedirectURI returns the redirect URI if it matches one of the
+// registered redirect URIs. Matching is exact, except that loopback redirect
+// URIs of native apps match on any port (RFC 8252 section 7.3).
+func MatchRedirectURI(registered []string, redirectURI string) (string, error) {
+	for _, registeredURI := range registered {
+		if redirectURI == registeredURI {
+			return redirectURI, nil
+		}
+	}
+
+	u, err := url.Parse(redirectURI)
+	if err != nil || u.Scheme != "http" || !isLoopback(u.Hostname()) {
+		return "", ErrRedirectURIMismatch
+	}
+
+	for _, registeredURI := range registered {
+		r, err := url.Parse(registeredURI)
+		if err != nil || r.Scheme != "http" || !isLoopback(r.Hostname()) {
+			continue
+		}
+
+		if r.Hostname() == u.Hostname() &&
+			r.EscapedPath() == u.EscapedPath() &&
+			r.RawQuery == u.RawQuery &&
+			r.User.String() == u.User.String() &&
+			u.Fragment == "" {
+			return redirectURI, nil
+		}
+	}
+
+	return "", ErrRedirectURIMismatch
+}
@@ -0,0 +1,73 @@
+package oauth_test
+
+import (
+	"testing"
+
+	"github.com/resonatecoop/id/oauth"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMatchRedirectURI(t *testing.T) {
+	registered := []string{
+		"https://www.example.com/callback",
+		"https://staging.example.com/callback",
+		"http://127.0.0.1/callback",
+		"http://[::1]:8080/callback",
+	}
+
+	for _, redirectURI := range []string{
+		"https://www.example.com/callback",
+		"https://staging.example.com/callback",
+		// Loopback redirect URIs match on any port
+		"http://127.0.0.1/callback",
+		"http://127.0.0.1:51004/callback",
+		"http://[::1]:60123/callback",
+	} {
+		matched, err := oauth.MatchRedirectURI(registered, redirectURI)
+		assert.NoError(t, err, redirectURI)
+		assert.Equal(t, redirectURI, matched)
+	}
+
+	for _, redirectURI := range []string{
+		"",
+		"https://www.example.com/callback/",
+		"https://www.example.com/callback?foo=bar",
+		"https://www.example.com:8443/callback",
+		"http://www.example.com/callback",
+		"http://127.0.0.1:51004/other",
+		"http://localhost:51004/callback",
+		"https://127.0.0.1:51004/callback",
+	} {
+		_, err := oauth.MatchRedirectURI(registered, redirectURI)
+		assert.Equal(t, oauth.ErrRedirectURIMismatch, err, redirectURI)
+	}
+}
+
+func (suite *OauthTestSuite) TestResolveRedirectURI() {
+	// Clients created before dynamic registration have a single redirect URI
+	redirectURI, err := suite.service.ResolveRedirectURI(suite.clients[0], "")
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), suite.clients[0].RedirectURI.String, redirectURI.String())
+
+	resp, err := suite.service.RegisterClient(&oauth.ClientRegistrationRequest{
+		RedirectURIs: []string{
+			"https://www.example.com/callback",
+			"https://staging.example.com/callback",
+		},
+	}, false)
+	assert.NoError(suite.T(), err)
+
+	client, err := suite.service.FindClientByClientID(resp.ClientID)
+	assert.NoError(suite.T(), err)
+
+	redirectURI, err = suite.service.ResolveRedirectURI(client, "https://staging.example.com/callback")
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), "https://staging.example.com/callback", redirectURI.String())
+
+	// The redirect URI cannot be left out with several registered
+	_, err = suite.service.ResolveRedirectURI(client, "")
+	assert.Equal(suite.T(), oauth.ErrRedirectURIRequired, err)
+
+	_, err = suite.service.ResolveRedirectURI(client, "https://evil.example.com/callback")
+	assert.Equal(suite.T(), oauth.ErrRedirectURIMismatch, err)
+}
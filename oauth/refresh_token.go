@@ -17,11 +17,14 @@ var (
 	ErrRefreshTokenExpired = errors.New("Refresh token expired")
 	// ErrRequestedScopeCannotBeGreater ...
 	ErrRequestedScopeCannotBeGreater = errors.New("Requested scope cannot be greater")
+	// ErrRefreshTokenUserNotFound ...
+	ErrRefreshTokenUserNotFound = errors.New("Refresh token does not have a valid user")
 )
 
-// GetOrCreateRefreshToken retrieves an existing refresh token, if expired,
-// the token gets deleted and new refresh token is created
-func (s *Service) GetOrCreateRefreshToken(client *model.Client, user *model.User, expiresIn int, scope string) (*model.RefreshToken, error) {
+// GetOrCreateRefreshToken retrieves an existing refresh token of the device
+// session, if expired, the token gets deleted and new refresh token is created
+// which does not outlive the client's refresh token lifetime
+func (s *Service) GetOrCreateRefreshToken(client *model.Client, user *model.User, expiresIn int, scope string, deviceSession *DeviceSession) (*model.RefreshToken, error) {
 	ctx := context.Background()
 	// Try to fetch an existing refresh token first
 	refreshToken := new(model.RefreshToken)
@@ -29,7 +32,17 @@ func (s *Service) GetOrCreateRefreshToken(client *model.Client, user *model.User
 
 	var err error
 
-	if user != nil && user.ID != uuid.Nil {
+	if deviceSession != nil {
+		err = s.db.NewSelect().
+			Model(refreshToken).
+			Where("client_id = ?", client.ID).
+			Where("token IN (?)", s.db.NewSelect().
+				Model((*DeviceSessionToken)(nil)).
+				Column("token").
+				Where("device_session_id = ?", deviceSession.ID)).
+			Limit(1).
+			Scan(ctx)
+	} else if user != nil && user.ID != uuid.Nil {
 		err = s.db.NewSelect().
 			Model(refreshToken).
 			Where("client_id = ?", client.ID).
@@ -68,6 +81,11 @@ func (s *Service) GetOrCreateRefreshToken(client *model.Client, user *model.User
 
 	// Create a new refresh token if it expired or was not found
 	if expired || (err != nil) {
+		lifetime, err := s.RefreshTokenLifetime(client)
+		if err != nil {
+			return nil, err
+		}
+		expiresIn = limitLifetime(expiresIn, lifetime)
 		refreshToken = model.NewOauthRefreshToken(client, user, expiresIn, scope)
 
 		_, err = s.db.NewInsert().
@@ -78,6 +96,12 @@ func (s *Service) GetOrCreateRefreshToken(client *model.Client, user *model.User
 			return nil, err
 		}
 
+		if deviceSession != nil {
+			if err := addDeviceSessionToken(s.db, deviceSession, refreshToken.Token); err != nil {
+				return nil, err
+			}
+		}
+
 		refreshToken.Client = client
 		refreshToken.User = user
 	}
@@ -118,7 +142,7 @@ func (s *Service) GetValidRefreshToken(token string, client *model.Client) (*mod
 
 	// Not found
 	if err != nil {
-		return nil, errors.New("refresh token does not have valid user")
+		return nil, ErrRefreshTokenUserNotFound
 	}
 
 	refreshToken.Client = client
@@ -136,7 +160,7 @@ func (s *Service) getRefreshTokenScope(refreshToken *model.RefreshToken, request
 
 	// If the scope is specified in the request, get the scope string
 	if requestedScope != "" {
-		scope, err = s.GetScope(requestedScope)
+		scope, err = s.GetScope(refreshToken.Client, requestedScope)
 		if err != nil {
 			return "", err
 		}
@@ -0,0 +1,279 @@
+package oauth
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/resonatecoop/user-api/model"
+	"github.com/uptrace/bun"
+)
+
+var (
+	// ErrRefreshTokenReused ...
+	ErrRefreshTokenReused = errors.New("Refresh token has already been used")
+)
+
+// RefreshTokenRotation links the refresh tokens issued by rotation into a
+// family, a token which has been rotated must never be presented again
+type RefreshTokenRotation struct {
+	bun.BaseModel `bun:"table:refresh_token_rotations"`
+
+	Token     string    `bun:"type:varchar(40),pk"`
+	FamilyID  uuid.UUID `bun:"type:uuid,notnull"`
+	ClientID  uuid.UUID `bun:"type:uuid,notnull"`
+	UserID    uuid.UUID `bun:"type:uuid"`
+	CreatedAt time.Time `bun:",nullzero,notnull,default:current_timestamp"`
+	RotatedAt time.Time `bun:",nullzero"`
+}
+
+// checkRefreshTokenReuse revokes the whole family when a refresh token
+// which has already been rotated is presented again
+func (s *Service) checkRefreshTokenReuse(token string, client *model.Client) error {
+	rotation := new(RefreshTokenRotation)
+
+	err := s.db.NewSelect().
+		Model(rotation).
+		Where("client_id = ?", client.ID).
+		Where("token = ?", token).
+		Limit(1).
+		Scan(context.Background())
+
+	// Not found, the token has never been rotated
+	if err != nil {
+		return nil
+	}
+
+	if rotation.RotatedAt.IsZero() {
+		return nil
+	}
+
+	if err := s.revokeRefreshTokenFamily(rotation); err != nil {
+		return err
+	}
+
+	return ErrRefreshTokenReused
+}
+
+// rotateRefreshToken replaces a refresh token with a new token of the same
+// family, linked to the device session which is saved along with it
+func (s *Service) rotateRefreshToken(refreshToken *model.RefreshToken, deviceSession *DeviceSession) (*model.RefreshToken, error) {
+	ctx := context.Background()
+	now := time.Now().UTC()
+	lifetime, err := s.RefreshTokenLifetime(refreshToken.Client)
+	if err != nil {
+		return nil, err
+	}
+
+	// Begin a transaction
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+
+	// Lock the rotation so concurrent requests cannot both use the token
+	rotation := new(RefreshTokenRotation)
+	err = tx.NewSelect().
+		Model(rotation).
+		Where("token = ?", refreshToken.Token).
+		For("UPDATE").
+		Limit(1).
+		Scan(ctx)
+
+	switch {
+	case err == sql.ErrNoRows:
+		// First rotation, start a new family
+		rotation = &RefreshTokenRotation{
+			Token:     refreshToken.Token,
+			FamilyID:  uuid.New(),
+			ClientID:  refreshToken.ClientID,
+			UserID:    refreshToken.UserID,
+			CreatedAt: refreshToken.CreatedAt,
+			RotatedAt: now,
+		}
+		var res sql.Result
+		res, err = tx.NewInsert().
+			Model(rotation).
+			On("CONFLICT (token) DO NOTHING").
+			Exec(ctx)
+		if err == nil {
+			if rows, _ := res.RowsAffected(); rows == 0 {
+				// A concurrent request rotated the token first, this
+				// is a reuse of the token it rotated
+				tx.Rollback() // rollback the transaction
+				if err := s.checkRefreshTokenReuse(refreshToken.Token, refreshToken.Client); err != nil {
+					return nil, err
+				}
+				return nil, ErrRefreshTokenReused
+			}
+		}
+	case err != nil:
+		// The lookup failed, rolled back below
+	case !rotation.RotatedAt.IsZero():
+		tx.Rollback() // rollback the transaction
+		if err := s.revokeRefreshTokenFamily(rotation); err != nil {
+			return nil, err
+		}
+		return nil, ErrRefreshTokenReused
+	default:
+		_, err = tx.NewUpdate().
+			Model(rotation).
+			Set("rotated_at = ?", now).
+			WherePK().
+			Exec(ctx)
+	}
+
+	if err != nil {
+		tx.Rollback() // rollback the transaction
+		return nil, err
+	}
+
+	// Delete the rotated refresh token
+	_, err = tx.NewDelete().
+		Model(refreshToken).
+		WherePK().
+		ForceDelete().
+		Exec(ctx)
+	if err != nil {
+		tx.Rollback() // rollback the transaction
+		return nil, err
+	}
+
+	// Create a new refresh token with the originally granted scope
+	newRefreshToken := model.NewOauthRefreshToken(
+		refreshToken.Client,
+		refreshToken.User,
+		lifetime, // expires in
+		refreshToken.Scope,
+	)
+	_, err = tx.NewInsert().
+		Model(newRefreshToken).
+		Exec(ctx)
+	if err != nil {
+		tx.Rollback() // rollback the transaction
+		return nil, err
+	}
+
+	// The new token continues the device session of the rotated token,
+	// tokens granted before device sessions existed start a new one
+	if err := saveDeviceSession(tx, deviceSession); err != nil {
+		tx.Rollback() // rollback the transaction
+		return nil, err
+	}
+
+	_, err = tx.NewDelete().
+		Model((*DeviceSessionToken)(nil)).
+		Where("token = ?", refreshToken.Token).
+		Exec(ctx)
+	if err != nil {
+		tx.Rollback() // rollback the transaction
+		return nil, err
+	}
+
+	if err := addDeviceSessionToken(tx, deviceSession, newRefreshToken.Token); err != nil {
+		tx.Rollback() // rollback the transaction
+		return nil, err
+	}
+
+	_, err = tx.NewInsert().
+		Model(&RefreshTokenRotation{
+			Token:     newRefreshToken.Token,
+			FamilyID:  rotation.FamilyID,
+			ClientID:  rotation.ClientID,
+			UserID:    rotation.UserID,
+			CreatedAt: now,
+		}).
+		Exec(ctx)
+	if err != nil {
+		tx.Rollback() // rollback the transaction
+		return nil, err
+	}
+
+	// Rotated tokens older than the refresh token lifetime would be expired
+	// anyway, there is no need to keep them for reuse detection
+	_, err = tx.NewDelete().
+		Model((*RefreshTokenRotation)(nil)).
+		Where("family_id = ?", rotation.FamilyID).
+		Where("rotated_at IS NOT NULL").
+		Where("created_at < ?", now.Add(-time.Duration(lifetime)*time.Second)).
+		Exec(ctx)
+	if err != nil {
+		tx.Rollback() // rollback the transaction
+		return nil, err
+	}
+
+	// Commit the transaction
+	if err = tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	newRefreshToken.Client = refreshToken.Client
+	newRefreshToken.User = refreshToken.User
+
+	return newRefreshToken, nil
+}
+
+// revokeRefreshTokenFamily deletes every refresh token of the family along
+// with the device session it belongs to
+func (s *Service) revokeRefreshTokenFamily(rotation *RefreshTokenRotation) error {
+	ctx := context.Background()
+
+	// Begin a transaction
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+
+	family := tx.NewSelect().
+		Model((*RefreshTokenRotation)(nil)).
+		Column("token").
+		Where("family_id = ?", rotation.FamilyID)
+
+	_, err = tx.NewDelete().
+		Model((*model.RefreshToken)(nil)).
+		Where("token IN (?)", family).
+		ForceDelete().
+		Exec(ctx)
+	if err != nil {
+		tx.Rollback() // rollback the transaction
+		return err
+	}
+
+	// Sign out the device session the family belongs to
+	var deviceSessionIDs []uuid.UUID
+	err = tx.NewSelect().
+		Model((*DeviceSessionToken)(nil)).
+		Column("device_session_id").
+		Where("token IN (?)", family).
+		Scan(ctx, &deviceSessionIDs)
+	if err != nil {
+		tx.Rollback() // rollback the transaction
+		return err
+	}
+
+	for _, deviceSessionID := range deviceSessionIDs {
+		if err := revokeDeviceSession(tx, deviceSessionID); err != nil {
+			tx.Rollback() // rollback the transaction
+			return err
+		}
+	}
+
+	if err := deleteUnlinkedAccessTokens(tx, rotation.ClientID, rotation.UserID); err != nil {
+		tx.Rollback() // rollback the transaction
+		return err
+	}
+
+	_, err = tx.NewDelete().
+		Model((*RefreshTokenRotation)(nil)).
+		Where("family_id = ?", rotation.FamilyID).
+		Exec(ctx)
+	if err != nil {
+		tx.Rollback() // rollback the transaction
+		return err
+	}
+
+	// Commit the transaction
+	return tx.Commit()
+}
@@ -0,0 +1,132 @@
+package oauth_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"time"
+
+	"github.com/resonatecoop/id/oauth"
+	testutil "github.com/resonatecoop/id/test-util"
+	"github.com/resonatecoop/user-api/model"
+	"github.com/stretchr/testify/assert"
+)
+
+func (suite *OauthTestSuite) TestRefreshTokenGrantRotation() {
+	suite.cnf.Oauth.RefreshTokenRotation = true
+	defer func() { suite.cnf.Oauth.RefreshTokenRotation = false }()
+
+	suite.insertRotationTestRefreshToken()
+
+	w := suite.serveRefreshTokenRequest("test_token")
+	assert.Equal(suite.T(), 200, w.Code)
+
+	resp := new(oauth.AccessTokenResponse)
+	assert.NoError(suite.T(), json.Unmarshal(w.Body.Bytes(), resp))
+
+	// A new refresh token is issued with the originally granted scope
+	assert.NotEqual(suite.T(), "test_token", resp.RefreshToken)
+	refreshToken, err := suite.service.GetValidRefreshToken(resp.RefreshToken, suite.clients[0])
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), "read_write tenantadmin", refreshToken.Scope)
+
+	// The rotated refresh token is gone
+	_, err = suite.service.GetValidRefreshToken("test_token", suite.clients[0])
+	assert.Equal(suite.T(), oauth.ErrRefreshTokenNotFound, err)
+
+	// Both tokens belong to the same family
+	count, err := suite.db.NewSelect().
+		Model((*oauth.RefreshTokenRotation)(nil)).
+		Where("token IN (?, ?)", "test_token", resp.RefreshToken).
+		Count(context.Background())
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), 2, count)
+
+	// The new token is the only refresh token left, and starts a device
+	// session as the rotated token predates them
+	count, err = suite.db.NewSelect().
+		Model((*model.RefreshToken)(nil)).
+		Where("client_id = ?", suite.clients[0].ID).
+		Where("user_id = ?", suite.users[0].ID).
+		Count(context.Background())
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), 1, count)
+
+	deviceSession, err := suite.service.FindDeviceSessionByToken(resp.RefreshToken)
+	assert.NoError(suite.T(), err)
+
+	// Rotating again keeps the device session
+	w = suite.serveRefreshTokenRequest(resp.RefreshToken)
+	assert.Equal(suite.T(), 200, w.Code)
+
+	rotated := new(oauth.AccessTokenResponse)
+	assert.NoError(suite.T(), json.Unmarshal(w.Body.Bytes(), rotated))
+	assert.NotEqual(suite.T(), resp.RefreshToken, rotated.RefreshToken)
+
+	rotatedDeviceSession, err := suite.service.FindDeviceSessionByToken(rotated.RefreshToken)
+	if assert.NoError(suite.T(), err) {
+		assert.Equal(suite.T(), deviceSession.ID, rotatedDeviceSession.ID)
+	}
+}
+
+func (suite *OauthTestSuite) TestRefreshTokenGrantRotationReuse() {
+	suite.cnf.Oauth.RefreshTokenRotation = true
+	defer func() { suite.cnf.Oauth.RefreshTokenRotation = false }()
+
+	suite.insertRotationTestRefreshToken()
+
+	w := suite.serveRefreshTokenRequest("test_token")
+	assert.Equal(suite.T(), 200, w.Code)
+
+	resp := new(oauth.AccessTokenResponse)
+	assert.NoError(suite.T(), json.Unmarshal(w.Body.Bytes(), resp))
+
+	// Presenting the rotated token again is detected as reuse
+	w = suite.serveRefreshTokenRequest("test_token")
+	testutil.TestResponseForOauthError(
+		suite.T(),
+		w,
+		oauth.ErrorCodeInvalidGrant,
+		oauth.ErrRefreshTokenReused.Error(),
+		400,
+	)
+
+	// The whole family is revoked along with the access tokens
+	_, err := suite.service.GetValidRefreshToken(resp.RefreshToken, suite.clients[0])
+	assert.Equal(suite.T(), oauth.ErrRefreshTokenNotFound, err)
+
+	_, err = suite.service.Authenticate(resp.AccessToken)
+	assert.Equal(suite.T(), oauth.ErrAccessTokenNotFound, err)
+}
+
+func (suite *OauthTestSuite) insertRotationTestRefreshToken() {
+	refreshToken := &model.RefreshToken{
+		IDRecord:  model.IDRecord{CreatedAt: time.Now().UTC()},
+		Token:     "test_token",
+		ExpiresAt: time.Now().UTC().Add(+10 * time.Second),
+		ClientID:  suite.clients[0].ID,
+		UserID:    suite.users[0].ID,
+		Scope:     "read_write tenantadmin",
+	}
+	_, err := suite.db.NewInsert().Model(refreshToken).Exec(context.Background())
+	assert.NoError(suite.T(), err, "Inserting test data failed")
+}
+
+func (suite *OauthTestSuite) serveRefreshTokenRequest(token string) *httptest.ResponseRecorder {
+	// Prepare a request
+	r, err := http.NewRequest("POST", "http://1.2.3.4/v1/oauth/tokens", nil)
+	assert.NoError(suite.T(), err, "Request setup should not get an error")
+	r.SetBasicAuth("test_client_1", "test_secret")
+	r.PostForm = url.Values{
+		"grant_type":    {"refresh_token"},
+		"refresh_token": {token},
+	}
+
+	// Serve the request
+	w := httptest.NewRecorder()
+	suite.router.ServeHTTP(w, r)
+
+	return w
+}
@@ -26,6 +26,7 @@ func (suite *OauthTestSuite) TestGetOrCreateRefreshTokenCreatesNew() {
 		suite.users[0],   // user
 		3600,             // expires in
 		"read_write",     // scope
+		nil,              // device session
 	)
 
 	ctx = context.Background()
@@ -61,6 +62,7 @@ func (suite *OauthTestSuite) TestGetOrCreateRefreshTokenCreatesNew() {
 		suite.users[0],   // user
 		3600,             // expires in
 		"read_write",     // scope
+		nil,              // device session
 	)
 
 	// Error should be nil
@@ -95,6 +97,7 @@ func (suite *OauthTestSuite) TestGetOrCreateRefreshTokenCreatesNew() {
 		nil,              // user
 		3600,             // expires in
 		"read_write",     // scope
+		nil,              // device session
 	)
 
 	// Error should be nil
@@ -127,6 +130,7 @@ func (suite *OauthTestSuite) TestGetOrCreateRefreshTokenCreatesNew() {
 		nil,              // user
 		3600,             // expires in
 		"read_write",     // scope
+		nil,              // device session
 	)
 
 	// Error should be nil
@@ -184,6 +188,7 @@ func (suite *OauthTestSuite) TestGetOrCreateRefreshTokenReturnsExisting() {
 		nil,              // user
 		3600,             // expires in
 		"read_write",     // scope
+		nil,              // device session
 	)
 
 	// Error should be Nil
@@ -239,6 +244,7 @@ func (suite *OauthTestSuite) TestGetOrCreateRefreshTokenReturnsExisting() {
 		suite.users[0],   // user
 		3600,             // expires in
 		"read_write",     // scope
+		nil,              // device session
 	)
 
 	// Error should be Nil
@@ -304,6 +310,7 @@ func (suite *OauthTestSuite) TestGetOrCreateRefreshTokenDeletesExpired() {
 		nil,              // user
 		3600,             // expires in
 		"read_write",     // scope
+		nil,              // device session
 	)
 
 	// Error should be nil
@@ -354,6 +361,7 @@ func (suite *OauthTestSuite) TestGetOrCreateRefreshTokenDeletesExpired() {
 		suite.users[0],   // user
 		3600,             // expires in
 		"read_write",     // scope
+		nil,              // device session
 	)
 
 	// Error should be nil
@@ -1,7 +1,11 @@
 package oauth
 
 import (
+	"time"
+
+	"github.com/RichardKnop/jsonhal"
 	"github.com/google/uuid"
+	"github.com/resonatecoop/id/oauth/jwk"
 	"github.com/resonatecoop/id/util"
 	"github.com/resonatecoop/user-api/model"
 )
@@ -13,18 +17,88 @@ type AccessTokenResponse struct {
 	ExpiresIn    int    `json:"expires_in"`
 	TokenType    string `json:"token_type"`
 	Scope        string `json:"scope"`
+	Role         string `json:"role,omitempty"`
 	RefreshToken string `json:"refresh_token,omitempty"`
+	IDToken      string `json:"id_token,omitempty"`
+	// IssuedTokenType is only set by the token exchange grant
+	IssuedTokenType string `json:"issued_token_type,omitempty"`
 }
 
 // IntrospectResponse ...
 type IntrospectResponse struct {
-	UserID    string `json:"user_id,omitempty"`
-	Active    bool   `json:"active"`
-	Scope     string `json:"scope,omitempty"`
-	ClientID  string `json:"client_id,omitempty"`
-	Username  string `json:"username,omitempty"`
-	TokenType string `json:"token_type,omitempty"`
-	ExpiresAt int    `json:"exp,omitempty"`
+	UserID    string        `json:"user_id,omitempty"`
+	Active    bool          `json:"active"`
+	Scope     string        `json:"scope,omitempty"`
+	ClientID  string        `json:"client_id,omitempty"`
+	Username  string        `json:"username,omitempty"`
+	TokenType string        `json:"token_type,omitempty"`
+	ExpiresAt int           `json:"exp,omitempty"`
+	IssuedAt  int           `json:"iat,omitempty"`
+	NotBefore int           `json:"nbf,omitempty"`
+	Subject   string        `json:"sub,omitempty"`
+	Audience  string        `json:"aud,omitempty"`
+	Issuer    string        `json:"iss,omitempty"`
+	Act       *Actor        `json:"act,omitempty"`
+	Cnf       *Confirmation `json:"cnf,omitempty"`
+	UserClaims
+}
+
+// DeviceAuthorizationResponse ...
+type DeviceAuthorizationResponse struct {
+	DeviceCode              string `json:"device_code"`
+	UserCode                string `json:"user_code"`
+	VerificationURI         string `json:"verification_uri"`
+	VerificationURIComplete string `json:"verification_uri_complete"`
+	ExpiresIn               int    `json:"expires_in"`
+	Interval                int    `json:"interval"`
+}
+
+// PushedAuthorizationResponse is the response of the par endpoint (RFC 9126)
+type PushedAuthorizationResponse struct {
+	RequestURI string `json:"request_uri"`
+	ExpiresIn  int    `json:"expires_in"`
+}
+
+// ClientRegistrationResponse is the client information response of RFC 7591
+type ClientRegistrationResponse struct {
+	ClientID                string   `json:"client_id"`
+	ClientSecret            string   `json:"client_secret,omitempty"`
+	ClientIDIssuedAt        int64    `json:"client_id_issued_at"`
+	ClientSecretExpiresAt   int64    `json:"client_secret_expires_at"`
+	RegistrationAccessToken string   `json:"registration_access_token,omitempty"`
+	RegistrationClientURI   string   `json:"registration_client_uri"`
+	ClientName              string   `json:"client_name,omitempty"`
+	ClientURI               string   `json:"client_uri,omitempty"`
+	RedirectURIs            []string `json:"redirect_uris"`
+	GrantTypes              []string `json:"grant_types"`
+	ResponseTypes           []string `json:"response_types"`
+	TokenEndpointAuthMethod string   `json:"token_endpoint_auth_method"`
+	Scope                   string   `json:"scope,omitempty"`
+	Contacts                []string `json:"contacts,omitempty"`
+	JWKS                    *jwk.Set `json:"jwks,omitempty"`
+	JWKSURI                 string   `json:"jwks_uri,omitempty"`
+	FirstParty              bool     `json:"first_party,omitempty"`
+	// RequirePushedAuthorizationRequests ...
+	RequirePushedAuthorizationRequests bool     `json:"require_pushed_authorization_requests,omitempty"`
+	AccessTokenLifetime                int      `json:"access_token_lifetime,omitempty"`
+	RefreshTokenLifetime               int      `json:"refresh_token_lifetime,omitempty"`
+	AuthCodeLifetime                   int      `json:"authorization_code_lifetime,omitempty"`
+	Claims                             []string `json:"claims,omitempty"`
+}
+
+// ClientResponse is a client as returned by the admin API
+type ClientResponse struct {
+	jsonhal.Hal
+	ClientID                string `json:"client_id"`
+	ClientSecret            string `json:"client_secret,omitempty"`
+	RedirectURI             string `json:"redirect_uri"`
+	ApplicationName         string `json:"application_name"`
+	ApplicationHostname     string `json:"application_hostname"`
+	ApplicationURL          string `json:"application_url"`
+	Disabled                bool   `json:"disabled"`
+	PreviousSecretExpiresAt string `json:"previous_secret_expires_at,omitempty"`
+	CreatedAt               string `json:"created_at"`
+	UpdatedAt               string `json:"updated_at"`
 }
 
 // NewAccessTokenResponse ...
@@ -43,3 +117,85 @@ func NewAccessTokenResponse(accessToken *model.AccessToken, refreshToken *model.
 	}
 	return response, nil
 }
+
+// NewDeviceAuthorizationResponse ...
+func NewDeviceAuthorizationResponse(deviceCode *DeviceCode, verificationURI string) *DeviceAuthorizationResponse {
+	userCode := FormatUserCode(deviceCode.UserCode)
+	return &DeviceAuthorizationResponse{
+		DeviceCode:              deviceCode.Code,
+		UserCode:                userCode,
+		VerificationURI:         verificationURI,
+		VerificationURIComplete: verificationURI + "?user_code=" + userCode,
+		ExpiresIn:               int(time.Until(deviceCode.ExpiresAt).Seconds()),
+		Interval:                deviceCode.PollInterval,
+	}
+}
+
+// NewPushedAuthorizationResponse ...
+func NewPushedAuthorizationResponse(pushedAuthorizationRequest *PushedAuthorizationRequest) *PushedAuthorizationResponse {
+	return &PushedAuthorizationResponse{
+		RequestURI: pushedAuthorizationRequest.RequestURI,
+		ExpiresIn:  int(time.Until(pushedAuthorizationRequest.ExpiresAt).Seconds()),
+	}
+}
+
+// NewClientRegistrationResponse ...
+func (s *Service) NewClientRegistrationResponse(client *model.Client, metadata *ClientMetadata) *ClientRegistrationResponse {
+	return &ClientRegistrationResponse{
+		ClientID:                client.Key,
+		ClientIDIssuedAt:        client.CreatedAt.Unix(),
+		RegistrationClientURI:   s.GetIssuer() + "/v1/oauth/register/" + client.Key,
+		ClientName:              client.ApplicationName.String,
+		ClientURI:               client.ApplicationURL.String,
+		RedirectURIs:            emptyIfNil(metadata.RedirectURIs),
+		GrantTypes:              emptyIfNil(metadata.GrantTypes),
+		ResponseTypes:           emptyIfNil(metadata.ResponseTypes),
+		TokenEndpointAuthMethod: metadata.TokenEndpointAuthMethod,
+		Scope:                   metadata.Scope,
+		Contacts:                metadata.Contacts,
+		JWKS:                    metadata.JWKS,
+		JWKSURI:                 metadata.JWKSURI,
+		FirstParty:              metadata.FirstParty,
+
+		RequirePushedAuthorizationRequests: metadata.RequirePushedAuthorizationRequests,
+		AccessTokenLifetime:                metadata.AccessTokenLifetime,
+		RefreshTokenLifetime:               metadata.RefreshTokenLifetime,
+		AuthCodeLifetime:                   metadata.AuthCodeLifetime,
+		Claims:                             metadata.Claims,
+	}
+}
+
+// emptyIfNil makes nil slices encode as empty JSON arrays
+func emptyIfNil(values []string) []string {
+	if values == nil {
+		return []string{}
+	}
+	return values
+}
+
+// NewClientResponse ...
+func (s *Service) NewClientResponse(client *model.Client) (*ClientResponse, error) {
+	state, err := s.FindClientState(client)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &ClientResponse{
+		ClientID:            client.Key,
+		RedirectURI:         client.RedirectURI.String,
+		ApplicationName:     client.ApplicationName.String,
+		ApplicationHostname: client.ApplicationHostname.String,
+		ApplicationURL:      client.ApplicationURL.String,
+		Disabled:            state.Disabled,
+		CreatedAt:           util.FormatTime(&client.CreatedAt),
+		UpdatedAt:           util.FormatTime(&client.UpdatedAt),
+	}
+
+	if state.PreviousSecret != "" && time.Now().Before(state.PreviousSecretExpiresAt) {
+		resp.PreviousSecretExpiresAt = util.FormatTime(&state.PreviousSecretExpiresAt)
+	}
+
+	resp.SetLink("self", "/v1/admin/clients/"+client.Key, "")
+
+	return resp, nil
+}
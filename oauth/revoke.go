@@ -0,0 +1,137 @@
+package oauth
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/resonatecoop/user-api/model"
+)
+
+var (
+	// ErrTokenNotIssuedToClient ...
+	ErrTokenNotIssuedToClient = errors.New("Token was not issued to this client")
+)
+
+// revokeToken revokes the access or refresh token sent by the client,
+// unknown tokens are ignored as required by RFC 7009
+func (s *Service) revokeToken(r *http.Request, client *model.Client) error {
+	// Parse the form so r.Form becomes available
+	if err := r.ParseForm(); err != nil {
+		return ErrInvalidRequest
+	}
+
+	// Get token from the form
+	token := r.Form.Get("token")
+	if token == "" {
+		return ErrTokenMissing
+	}
+
+	// The hint only decides which kind of token is looked up first
+	var revokers []func(token string, client *model.Client) (bool, error)
+
+	switch r.Form.Get("token_type_hint") {
+	case "", AccessTokenHint:
+		revokers = append(revokers, s.revokeAccessToken, s.revokeRefreshToken)
+	case RefreshTokenHint:
+		revokers = append(revokers, s.revokeRefreshToken, s.revokeAccessToken)
+	default:
+		return ErrTokenHintInvalid
+	}
+
+	for _, revoke := range revokers {
+		found, err := revoke(token, client)
+		if found || err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// revokeAccessToken deletes an access token issued to the client
+func (s *Service) revokeAccessToken(token string, client *model.Client) (bool, error) {
+	ctx := context.Background()
+
+	// JWT access tokens are stored under their jti
+	lookupKey, err := s.getAccessTokenLookupKey(token)
+	if err != nil {
+		return false, nil
+	}
+
+	accessToken := new(model.AccessToken)
+	err = s.db.NewSelect().
+		Model(accessToken).
+		Where("token = ?", lookupKey).
+		Limit(1).
+		Scan(ctx)
+
+	// Not found
+	if err != nil {
+		return false, nil
+	}
+
+	if accessToken.ClientID != client.ID {
+		return true, ErrTokenNotIssuedToClient
+	}
+
+	_, err = s.db.NewDelete().
+		Model(accessToken).
+		WherePK().
+		ForceDelete().
+		Exec(ctx)
+
+	return true, err
+}
+
+// revokeRefreshToken deletes a refresh token issued to the client along
+// with the access tokens of its device session
+func (s *Service) revokeRefreshToken(token string, client *model.Client) (bool, error) {
+	ctx := context.Background()
+
+	refreshToken := new(model.RefreshToken)
+	err := s.db.NewSelect().
+		Model(refreshToken).
+		Where("token = ?", token).
+		Limit(1).
+		Scan(ctx)
+
+	// Not found
+	if err != nil {
+		return false, nil
+	}
+
+	if refreshToken.ClientID != client.ID {
+		return true, ErrTokenNotIssuedToClient
+	}
+
+	// Sign out the device session the refresh token was granted for
+	deviceSession, err := s.FindDeviceSessionByToken(refreshToken.Token)
+	if err == nil {
+		return true, s.RevokeDeviceSession(deviceSession)
+	}
+
+	// Begin a transaction
+	tx, err := s.db.Begin()
+	if err != nil {
+		return true, err
+	}
+
+	_, err = tx.NewDelete().
+		Model(refreshToken).
+		WherePK().
+		ForceDelete().
+		Exec(ctx)
+	if err != nil {
+		tx.Rollback() // rollback the transaction
+		return true, err
+	}
+
+	if err := deleteUnlinkedAccessTokens(tx, refreshToken.ClientID, refreshToken.UserID); err != nil {
+		tx.Rollback() // rollback the transaction
+		return true, err
+	}
+
+	// Commit the transaction
+	return true, tx.Commit()
+}
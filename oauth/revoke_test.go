@@ -0,0 +1,130 @@
+package oauth_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"time"
+
+	"github.com/resonatecoop/id/oauth"
+	testutil "github.com/resonatecoop/id/test-util"
+	"github.com/resonatecoop/user-api/model"
+	"github.com/stretchr/testify/assert"
+)
+
+func (suite *OauthTestSuite) TestRevokeAccessToken() {
+	accessToken := &model.AccessToken{
+		IDRecord:  model.IDRecord{CreatedAt: time.Now().UTC()},
+		Token:     "test_revoke_access_token",
+		ExpiresAt: time.Now().UTC().Add(+10 * time.Second),
+		ClientID:  suite.clients[0].ID,
+		UserID:    suite.users[0].ID,
+		Scope:     "read_write",
+	}
+	_, err := suite.db.NewInsert().Model(accessToken).Exec(context.Background())
+	assert.NoError(suite.T(), err, "Inserting test data failed")
+
+	w := suite.serveRevokeRequest(url.Values{
+		"token":           {"test_revoke_access_token"},
+		"token_type_hint": {"access_token"},
+	})
+	assert.Equal(suite.T(), 200, w.Code)
+
+	_, err = suite.service.Authenticate("test_revoke_access_token")
+	assert.Equal(suite.T(), oauth.ErrAccessTokenNotFound, err)
+}
+
+func (suite *OauthTestSuite) TestRevokeRefreshTokenCascades() {
+	refreshToken := &model.RefreshToken{
+		IDRecord:  model.IDRecord{CreatedAt: time.Now().UTC()},
+		Token:     "test_revoke_refresh_token",
+		ExpiresAt: time.Now().UTC().Add(+10 * time.Second),
+		ClientID:  suite.clients[0].ID,
+		UserID:    suite.users[0].ID,
+		Scope:     "read_write",
+	}
+	_, err := suite.db.NewInsert().Model(refreshToken).Exec(context.Background())
+	assert.NoError(suite.T(), err, "Inserting test data failed")
+
+	accessToken := &model.AccessToken{
+		IDRecord:  model.IDRecord{CreatedAt: time.Now().UTC()},
+		Token:     "test_revoke_access_token",
+		ExpiresAt: time.Now().UTC().Add(+10 * time.Second),
+		ClientID:  suite.clients[0].ID,
+		UserID:    suite.users[0].ID,
+		Scope:     "read_write",
+	}
+	_, err = suite.db.NewInsert().Model(accessToken).Exec(context.Background())
+	assert.NoError(suite.T(), err, "Inserting test data failed")
+
+	// Without a hint the access tokens are searched first
+	w := suite.serveRevokeRequest(url.Values{
+		"token": {"test_revoke_refresh_token"},
+	})
+	assert.Equal(suite.T(), 200, w.Code)
+
+	_, err = suite.service.GetValidRefreshToken("test_revoke_refresh_token", suite.clients[0])
+	assert.Equal(suite.T(), oauth.ErrRefreshTokenNotFound, err)
+
+	_, err = suite.service.Authenticate("test_revoke_access_token")
+	assert.Equal(suite.T(), oauth.ErrAccessTokenNotFound, err)
+}
+
+func (suite *OauthTestSuite) TestRevokeTokenNotIssuedToClient() {
+	accessToken := &model.AccessToken{
+		IDRecord:  model.IDRecord{CreatedAt: time.Now().UTC()},
+		Token:     "test_revoke_access_token",
+		ExpiresAt: time.Now().UTC().Add(+10 * time.Second),
+		ClientID:  suite.clients[1].ID,
+		UserID:    suite.users[0].ID,
+		Scope:     "read_write",
+	}
+	_, err := suite.db.NewInsert().Model(accessToken).Exec(context.Background())
+	assert.NoError(suite.T(), err, "Inserting test data failed")
+
+	w := suite.serveRevokeRequest(url.Values{
+		"token": {"test_revoke_access_token"},
+	})
+
+	testutil.TestResponseForOauthError(
+		suite.T(),
+		w,
+		oauth.ErrorCodeInvalidGrant,
+		oauth.ErrTokenNotIssuedToClient.Error(),
+		400,
+	)
+}
+
+func (suite *OauthTestSuite) TestRevokeUnknownToken() {
+	w := suite.serveRevokeRequest(url.Values{
+		"token": {"bogus"},
+	})
+	assert.Equal(suite.T(), 200, w.Code)
+}
+
+func (suite *OauthTestSuite) TestRevokeTokenMissing() {
+	w := suite.serveRevokeRequest(url.Values{})
+
+	testutil.TestResponseForOauthError(
+		suite.T(),
+		w,
+		oauth.ErrorCodeInvalidRequest,
+		oauth.ErrTokenMissing.Error(),
+		400,
+	)
+}
+
+func (suite *OauthTestSuite) serveRevokeRequest(form url.Values) *httptest.ResponseRecorder {
+	// Prepare a request
+	r, err := http.NewRequest("POST", "http://1.2.3.4/v1/oauth/revoke", nil)
+	assert.NoError(suite.T(), err, "Request setup should not get an error")
+	r.SetBasicAuth("test_client_1", "test_secret")
+	r.PostForm = form
+
+	// Serve the request
+	w := httptest.NewRecorder()
+	suite.router.ServeHTTP(w, r)
+
+	return w
+}
@@ -23,3 +23,33 @@ func (s *Service) FindRoleByID(id int32) (*model.AccessRole, error) {
 	}
 	return (*model.AccessRole)(&role.ID), nil
 }
+
+// GetRoleName returns the name of a role, e.g. user, artist or admin
+func (s *Service) GetRoleName(id int32) (string, error) {
+	role := new(model.Role)
+	err := s.db.NewSelect().
+		Model(role).
+		Column("name").
+		Where("id = ?", id).
+		Limit(1).
+		Scan(context.Background())
+	if err != nil {
+		return "", ErrRoleNotFound
+	}
+	return role.Name, nil
+}
+
+// getUserRoleName returns the role name of a user looked up by ID
+func (s *Service) getUserRoleName(userID string) (string, error) {
+	user := new(model.User)
+	err := s.db.NewSelect().
+		Model(user).
+		Column("role_id").
+		Where("id = ?", userID).
+		Limit(1).
+		Scan(context.Background())
+	if err != nil {
+		return "", ErrUserNotFound
+	}
+	return s.GetRoleName(user.RoleID)
+}
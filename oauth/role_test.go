@@ -34,3 +34,12 @@ func (suite *OauthTestSuite) TestFindRoleByID() {
 		assert.Equal(suite.T(), model.UserRole, *role)
 	}
 }
+
+func (suite *OauthTestSuite) TestGetRoleName() {
+	_, err := suite.service.GetRoleName(99)
+	assert.Equal(suite.T(), oauth.ErrRoleNotFound, err)
+
+	name, err := suite.service.GetRoleName(int32(model.UserRole))
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), "user", name)
+}
@@ -10,6 +10,28 @@ const (
 	tokensPath         = "/" + tokensResource
 	introspectResource = "introspect"
 	introspectPath     = "/" + introspectResource
+	revokeResource     = "revoke"
+	revokePath         = "/" + revokeResource
+	userInfoResource   = "userinfo"
+	userInfoPath       = "/" + userInfoResource
+
+	deviceAuthorizationResource = "device_authorization"
+	deviceAuthorizationPath     = "/" + deviceAuthorizationResource
+
+	parResource = "par"
+	parPath     = "/" + parResource
+
+	registerResource        = "register"
+	registerPath            = "/" + registerResource
+	clientConfigurationPath = registerPath + "/{client_id}"
+
+	clientsResource  = "clients"
+	clientsPath      = "/" + clientsResource
+	clientPath       = clientsPath + "/{client_id}"
+	clientSecretPath = clientPath + "/secret"
+
+	openIDConfigurationPath = "/openid-configuration"
+	jwksPath                = "/jwks.json"
 )
 
 // RegisterRoutes registers route handlers for the oauth service
@@ -27,11 +49,137 @@ func (s *Service) GetRoutes() []routes.Route {
 			Pattern:     tokensPath,
 			HandlerFunc: s.tokensHandler,
 		},
+		{
+			Name:        "oauth_device_authorization",
+			Method:      "POST",
+			Pattern:     deviceAuthorizationPath,
+			HandlerFunc: s.deviceAuthorizationHandler,
+		},
+		{
+			Name:        "oauth_par",
+			Method:      "POST",
+			Pattern:     parPath,
+			HandlerFunc: s.parHandler,
+		},
+		{
+			Name:        "oauth_register",
+			Method:      "POST",
+			Pattern:     registerPath,
+			HandlerFunc: s.registerHandler,
+		},
+		{
+			Name:        "oauth_client_configuration",
+			Method:      "GET",
+			Pattern:     clientConfigurationPath,
+			HandlerFunc: s.clientConfigurationHandler,
+		},
+		{
+			Name:        "oauth_client_configuration_update",
+			Method:      "PUT",
+			Pattern:     clientConfigurationPath,
+			HandlerFunc: s.clientConfigurationHandler,
+		},
+		{
+			Name:        "oauth_client_configuration_delete",
+			Method:      "DELETE",
+			Pattern:     clientConfigurationPath,
+			HandlerFunc: s.clientConfigurationHandler,
+		},
 		{
 			Name:        "oauth_introspect",
 			Method:      "POST",
 			Pattern:     introspectPath,
 			HandlerFunc: s.introspectHandler,
 		},
+		{
+			Name:        "oauth_revoke",
+			Method:      "POST",
+			Pattern:     revokePath,
+			HandlerFunc: s.revokeHandler,
+		},
+		{
+			Name:        "oauth_userinfo",
+			Method:      "GET",
+			Pattern:     userInfoPath,
+			HandlerFunc: s.userInfoHandler,
+		},
+		{
+			Name:        "oauth_userinfo_post",
+			Method:      "POST",
+			Pattern:     userInfoPath,
+			HandlerFunc: s.userInfoHandler,
+		},
+	}
+}
+
+// RegisterWellKnownRoutes registers the discovery route handlers for the oauth service
+func (s *Service) RegisterWellKnownRoutes(router *mux.Router, prefix string) {
+	subRouter := router.PathPrefix(prefix).Subrouter()
+	routes.AddRoutes(s.GetWellKnownRoutes(), subRouter)
+}
+
+// GetWellKnownRoutes returns []routes.Route slice for the discovery documents
+func (s *Service) GetWellKnownRoutes() []routes.Route {
+	return []routes.Route{
+		{
+			Name:        "openid_configuration",
+			Method:      "GET",
+			Pattern:     openIDConfigurationPath,
+			HandlerFunc: s.openIDConfigurationHandler,
+		},
+		{
+			Name:        "jwks",
+			Method:      "GET",
+			Pattern:     jwksPath,
+			HandlerFunc: s.jwksHandler,
+		},
+	}
+}
+
+// RegisterAdminRoutes registers the admin route handlers for the oauth service
+func (s *Service) RegisterAdminRoutes(router *mux.Router, prefix string) {
+	subRouter := router.PathPrefix(prefix).Subrouter()
+	routes.AddRoutes(s.GetAdminRoutes(), subRouter)
+}
+
+// GetAdminRoutes returns []routes.Route slice for the admin API
+func (s *Service) GetAdminRoutes() []routes.Route {
+	return []routes.Route{
+		{
+			Name:        "admin_clients",
+			Method:      "GET",
+			Pattern:     clientsPath,
+			HandlerFunc: s.adminClientsHandler,
+		},
+		{
+			Name:        "admin_clients_create",
+			Method:      "POST",
+			Pattern:     clientsPath,
+			HandlerFunc: s.adminClientsHandler,
+		},
+		{
+			Name:        "admin_client",
+			Method:      "GET",
+			Pattern:     clientPath,
+			HandlerFunc: s.adminClientHandler,
+		},
+		{
+			Name:        "admin_client_update",
+			Method:      "PUT",
+			Pattern:     clientPath,
+			HandlerFunc: s.adminClientHandler,
+		},
+		{
+			Name:        "admin_client_delete",
+			Method:      "DELETE",
+			Pattern:     clientPath,
+			HandlerFunc: s.adminClientHandler,
+		},
+		{
+			Name:        "admin_client_secret",
+			Method:      "POST",
+			Pattern:     clientSecretPath,
+			HandlerFunc: s.adminClientSecretHandler,
+		},
 	}
 }
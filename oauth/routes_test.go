@@ -38,3 +38,67 @@ func (suite *OauthTestSuite) TestIntrospectRouteIsValid() {
 		assert.Equal(suite.T(), "oauth_introspect", match.Route.GetName(), "Expected route to be matched")
 	}
 }
+
+func (suite *OauthTestSuite) TestRevokeRouteIsValid() {
+	r, err := http.NewRequest(
+		"POST",
+		"http://1.2.3.4/v1/oauth/revoke",
+		nil,
+	)
+	assert.NoError(suite.T(), err, "New request should not cause an error")
+
+	// Check the routing
+	match := new(mux.RouteMatch)
+	suite.router.Match(r, match)
+	if assert.NotNil(suite.T(), match.Route, "Expected to find a route match") {
+		assert.Equal(suite.T(), "oauth_revoke", match.Route.GetName(), "Expected route to be matched")
+	}
+}
+
+func (suite *OauthTestSuite) TestUserInfoRouteIsValid() {
+	r, err := http.NewRequest(
+		"GET",
+		"http://1.2.3.4/v1/oauth/userinfo",
+		nil,
+	)
+	assert.NoError(suite.T(), err, "New request should not cause an error")
+
+	// Check the routing
+	match := new(mux.RouteMatch)
+	suite.router.Match(r, match)
+	if assert.NotNil(suite.T(), match.Route, "Expected to find a route match") {
+		assert.Equal(suite.T(), "oauth_userinfo", match.Route.GetName(), "Expected route to be matched")
+	}
+}
+
+func (suite *OauthTestSuite) TestOpenIDConfigurationRouteIsValid() {
+	r, err := http.NewRequest(
+		"GET",
+		"http://1.2.3.4/.well-known/openid-configuration",
+		nil,
+	)
+	assert.NoError(suite.T(), err, "New request should not cause an error")
+
+	// Check the routing
+	match := new(mux.RouteMatch)
+	suite.router.Match(r, match)
+	if assert.NotNil(suite.T(), match.Route, "Expected to find a route match") {
+		assert.Equal(suite.T(), "openid_configuration", match.Route.GetName(), "Expected route to be matched")
+	}
+}
+
+func (suite *OauthTestSuite) TestJWKSRouteIsValid() {
+	r, err := http.NewRequest(
+		"GET",
+		"http://1.2.3.4/.well-known/jwks.json",
+		nil,
+	)
+	assert.NoError(suite.T(), err, "New request should not cause an error")
+
+	// Check the routing
+	match := new(mux.RouteMatch)
+	suite.router.Match(r, match)
+	if assert.NotNil(suite.T(), match.Route, "Expected to find a route match") {
+		assert.Equal(suite.T(), "jwks", match.Route.GetName(), "Expected route to be matched")
+	}
+}
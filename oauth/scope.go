@@ -16,20 +16,32 @@ var (
 )
 
 // GetScope takes a requested scope and, if it's empty, returns the default
-// scope, if not empty, it validates the requested scope
-func (s *Service) GetScope(requestedScope string) (string, error) {
+// scope, if not empty, it validates the requested scope. A client, if any,
+// can only be granted the scope its policy allows.
+func (s *Service) GetScope(client *model.Client, requestedScope string) (string, error) {
 	// Return the default scope if the requested scope is empty
 	if requestedScope == "" {
-		return s.GetDefaultScope(), nil
+		scope, err := s.allowedDefaultScope(client)
+		if err != nil {
+			return "", err
+		}
+		if scope == "" {
+			return "", ErrInvalidScope
+		}
+		return scope, nil
+	}
+
+	// Otherwise return error
+	if !s.ScopeExists(requestedScope) {
+		return "", ErrInvalidScope
 	}
 
-	// If the requested scope exists in the database, return it
-	if s.ScopeExists(requestedScope) {
-		return requestedScope, nil
+	// The scope must be within the client's policy
+	if err := s.authorizeScope(client, requestedScope); err != nil {
+		return "", err
 	}
 
-	// Otherwise return error
-	return "", ErrInvalidScope
+	return requestedScope, nil
 }
 
 // GetDefaultScope returns the default scope
@@ -72,8 +84,18 @@ func (s *Service) GetDefaultScope() string {
 // ScopeExists checks if a scope exists
 func (s *Service) ScopeExists(requestedScope string) bool {
 	ctx := context.Background()
-	// Split the requested scope string
-	scopes := strings.Split(requestedScope, " ")
+	// Split the requested scope string, the openid scope is handled
+	// by this server and is not stored in the database
+	var scopes []string
+	for _, scope := range strings.Split(requestedScope, " ") {
+		if scope != OpenIDScope {
+			scopes = append(scopes, scope)
+		}
+	}
+
+	if len(scopes) == 0 {
+		return false
+	}
 
 	var available_scopes []model.Scope
 
@@ -86,3 +108,39 @@ func (s *Service) ScopeExists(requestedScope string) bool {
 	// Return true only if all requested scopes found
 	return count == len(scopes)
 }
+
+// FindScopes returns the scopes of a space delimited scope string in the
+// same order, with their descriptions for the consent screen
+func (s *Service) FindScopes(scope string) ([]*model.Scope, error) {
+	names := strings.Fields(scope)
+	if len(names) == 0 {
+		return nil, nil
+	}
+
+	var found []*model.Scope
+	err := s.db.NewSelect().
+		Model(&found).
+		Where("name IN (?)", bun.In(names)).
+		Scan(context.Background())
+	if err != nil {
+		return nil, err
+	}
+
+	scopes := make([]*model.Scope, 0, len(names))
+	for _, name := range names {
+		// The openid scope is handled by this server and is not stored
+		// in the database
+		if name == OpenIDScope {
+			scopes = append(scopes, &model.Scope{Name: name, Description: "Sign you in with your Resonate account"})
+			continue
+		}
+		for _, f := range found {
+			if f.Name == name {
+				scopes = append(scopes, f)
+				break
+			}
+		}
+	}
+
+	return scopes, nil
+}
@@ -13,17 +13,17 @@ func (suite *OauthTestSuite) TestGetScope() {
 
 	// When the requested scope is an empty string,
 	// the default scope should be returned
-	scope, err = suite.service.GetScope("")
+	scope, err = suite.service.GetScope(nil, "")
 	assert.Nil(suite.T(), err)
 	assert.Equal(suite.T(), "read user", scope)
 
 	// When the requested scope is valid, it should be returned
-	scope, err = suite.service.GetScope("read read_write")
+	scope, err = suite.service.GetScope(nil, "read read_write")
 	assert.Nil(suite.T(), err)
 	assert.Equal(suite.T(), "read read_write", scope)
 
 	// When the requested scope is invalid, an error should be returned
-	_, err = suite.service.GetScope("read_write bogus")
+	_, err = suite.service.GetScope(nil, "read_write bogus")
 	if assert.NotNil(suite.T(), err) {
 		assert.Equal(suite.T(), oauth.ErrInvalidScope, err)
 	}
@@ -1,6 +1,7 @@
 package oauth
 
 import (
+	"github.com/google/uuid"
 	"github.com/resonatecoop/id/config"
 	"github.com/uptrace/bun"
 
@@ -9,17 +10,23 @@ import (
 
 // Service struct keeps objects to avoid passing them around
 type Service struct {
-	cnf          *config.Config
-	db           *bun.DB
-	allowedRoles []int32
+	cnf                   *config.Config
+	db                    *bun.DB
+	allowedRoles          []int32
+	totpRequiredRoles     []int32
+	jwksCache             *clientJWKSCache
+	verificationKeysCache *verificationKeysCache
 }
 
 // NewService returns a new Service instance
 func NewService(cnf *config.Config, db *bun.DB) *Service {
 	return &Service{
-		cnf:          cnf,
-		db:           db,
-		allowedRoles: []int32{int32(model.SuperAdminRole), int32(model.AdminRole), int32(model.TenantAdminRole), int32(model.LabelRole), int32(model.ArtistRole), int32(model.UserRole)},
+		cnf:                   cnf,
+		db:                    db,
+		allowedRoles:          []int32{int32(model.SuperAdminRole), int32(model.AdminRole), int32(model.TenantAdminRole), int32(model.LabelRole), int32(model.ArtistRole), int32(model.UserRole)},
+		totpRequiredRoles:     cnf.TOTP.RequiredRoles,
+		jwksCache:             &clientJWKSCache{entries: make(map[uuid.UUID]*clientJWKSEntry)},
+		verificationKeysCache: new(verificationKeysCache),
 	}
 }
 
@@ -46,5 +53,21 @@ func (s *Service) IsRoleAllowed(role int32) bool {
 	return false
 }
 
+// RequireTOTPForRoles requires users with the specified roles to log in with
+// two-factor authentication
+func (s *Service) RequireTOTPForRoles(requiredRoles ...int32) {
+	s.totpRequiredRoles = requiredRoles
+}
+
+// IsTOTPRequired returns true if the user's role must use two-factor authentication
+func (s *Service) IsTOTPRequired(user *model.User) bool {
+	for _, requiredRole := range s.totpRequiredRoles {
+		if user.RoleID == requiredRole {
+			return true
+		}
+	}
+	return false
+}
+
 // Close stops any running services
 func (s *Service) Close() {}
@@ -1,8 +1,14 @@
 package oauth
 
 import (
+	"net/http"
+	"net/url"
+
 	"github.com/gorilla/mux"
+	"github.com/pquerna/otp"
 	"github.com/resonatecoop/id/config"
+	"github.com/resonatecoop/id/oauth/jwk"
+	"github.com/resonatecoop/id/oauth/webauthn"
 	"github.com/resonatecoop/id/session"
 	"github.com/resonatecoop/id/util/routes"
 	"github.com/resonatecoop/user-api/model"
@@ -15,9 +21,62 @@ type ServiceInterface interface {
 	GetConfig() *config.Config
 	RestrictToRoles(allowedRoles ...int32)
 	IsRoleAllowed(role int32) bool
+	RequireTOTPForRoles(requiredRoles ...int32)
+	IsTOTPRequired(user *model.User) bool
+	IsTOTPEnabled(user *model.User) bool
+	EnrollTOTP(user *model.User) (*otp.Key, error)
+	EnableTOTP(user *model.User, passcode string) ([]string, error)
+	DisableTOTP(user *model.User, password string) error
+	RegenerateRecoveryCodes(user *model.User) ([]string, error)
+	VerifyTOTP(user *model.User, code string) error
+	CountRecoveryCodes(user *model.User) (int, error)
+	BeginWebAuthnRegistration(user *model.User) (*webauthn.CreationOptions, error)
+	FinishWebAuthnRegistration(user *model.User, name, challenge string, response *webauthn.AttestationResponse) (*WebAuthnCredential, error)
+	BeginWebAuthnLogin(user *model.User) (*webauthn.RequestOptions, error)
+	FinishWebAuthnLogin(user *model.User, challenge string, response *webauthn.AssertionResponse) (*model.User, error)
+	FindWebAuthnCredentials(user *model.User) ([]*WebAuthnCredential, error)
+	HasWebAuthnCredentials(user *model.User) bool
+	DeleteWebAuthnCredential(user *model.User, id string) error
+	GrantDeviceCode(client *model.Client, scope string) (*DeviceCode, error)
+	FindDeviceCodeByUserCode(userCode string) (*DeviceCode, error)
+	ApproveDeviceCode(deviceCode *DeviceCode, user *model.User) error
+	DenyDeviceCode(deviceCode *DeviceCode) error
+	RegisterClient(req *ClientRegistrationRequest, admin bool) (*ClientRegistrationResponse, error)
+	GetClientRegistration(client *model.Client) (*ClientRegistrationResponse, error)
+	UpdateClientRegistration(client *model.Client, req *ClientRegistrationRequest, admin bool) (*ClientRegistrationResponse, error)
+	DeleteClient(client *model.Client) error
+	FindClientMetadata(client *model.Client) (*ClientMetadata, error)
+	AuthRegistrationAccessToken(clientID, token string) (*model.Client, error)
+	IsPublicClient(client *model.Client) (bool, error)
+	AuthorizeGrantType(client *model.Client, grantType string) error
+	AuthorizeResponseType(client *model.Client, responseType string) error
+	RegisteredRedirectURIs(client *model.Client) ([]string, error)
+	ResolveRedirectURI(client *model.Client, redirectURI string) (*url.URL, error)
+	FindScopes(scope string) ([]*model.Scope, error)
+	FindConsent(user *model.User, client *model.Client) (*Consent, error)
+	GrantConsent(user *model.User, client *model.Client, scope string) error
+	ScopesNeedingConsent(user *model.User, client *model.Client, scope string) ([]string, error)
+	IsFirstPartyClient(client *model.Client) (bool, error)
+	FindConnectedApps(user *model.User) ([]*ConnectedApp, error)
+	RevokeConnectedApp(user *model.User, client *model.Client) error
+	ListClients(page, limit int) ([]*model.Client, int, error)
+	UpdateClient(client *model.Client, redirectURI, applicationName, applicationHostname, applicationURL string) error
+	FindClientState(client *model.Client) (*ClientState, error)
+	CheckClientEnabled(client *model.Client) error
+	SetClientDisabled(client *model.Client, disabled bool) error
+	RotateClientSecret(client *model.Client) (string, error)
+	PushAuthorizationRequest(client *model.Client, form url.Values) (*PushedAuthorizationRequest, error)
+	ResolveAuthorizationRequest(client *model.Client, form url.Values) (url.Values, error)
+	DeletePushedAuthorizationRequest(requestURI string) error
+	RequiresPushedAuthorizationRequests(client *model.Client) (bool, error)
 	FindRoleByID(id int32) (*model.AccessRole, error)
+	GetRoleName(id int32) (string, error)
 	GetRoutes() []routes.Route
 	RegisterRoutes(router *mux.Router, prefix string)
+	GetWellKnownRoutes() []routes.Route
+	RegisterWellKnownRoutes(router *mux.Router, prefix string)
+	RegisterAdminRoutes(router *mux.Router, prefix string)
+	GetAdminRoutes() []routes.Route
 	ClientExists(clientID string) bool
 	FindClientByClientID(clientID string) (*model.Client, error)
 	FindClientByApplicationURL(applicationURL string) (*model.Client, error)
@@ -32,6 +91,7 @@ type ServiceInterface interface {
 	UserExists(username string) bool
 	FindUserByUsername(username string) (*model.User, error)
 	FindUserByEmail(email string) (*model.User, error)
+	FindUserByID(id string) (*model.User, error)
 	DeleteUser(user *model.User, password string) error
 	DeleteUserTx(tx *bun.DB, user *model.User, password string) error
 	ConfirmUserEmail(email string) error
@@ -43,15 +103,31 @@ type ServiceInterface interface {
 	SetUserCountry(user *model.User, country string) error
 	SetUserCountryTx(db *bun.DB, user *model.User, country string) error
 	AuthUser(username, thePassword string) (*model.User, error)
-	GetScope(requestedScope string) (string, error)
+	GetScope(client *model.Client, requestedScope string) (string, error)
 	GetDefaultScope() string
 	ScopeExists(requestedScope string) bool
-	Login(client *model.Client, user *model.User, scope string) (*model.AccessToken, *model.RefreshToken, error)
-	GrantAuthorizationCode(client *model.Client, user *model.User, expiresIn int, redirectURI, scope string) (*model.AuthorizationCode, error)
-	GrantAccessToken(client *model.Client, user *model.User, expiresIn int, scope string) (*model.AccessToken, error)
-	GetOrCreateRefreshToken(client *model.Client, user *model.User, expiresIn int, scope string) (*model.RefreshToken, error)
+	Login(client *model.Client, user *model.User, scope string, deviceSession *DeviceSession) (*model.AccessToken, *model.RefreshToken, error)
+	GrantAuthorizationCode(client *model.Client, user *model.User, expiresIn int, redirectURI, scope, codeChallenge, codeChallengeMethod, nonce string) (*model.AuthorizationCode, error)
+	GrantIDToken(client *model.Client, user *model.User, nonce string) (string, error)
+	GetIssuer() string
+	GetSigningKey() (*SigningKey, error)
+	RotateSigningKey() (*SigningKey, error)
+	GetJWKS() (*jwk.Set, error)
+	GrantAccessToken(client *model.Client, user *model.User, expiresIn int, scope string, deviceSession *DeviceSession) (*model.AccessToken, error)
+	GetOrCreateRefreshToken(client *model.Client, user *model.User, expiresIn int, scope string, deviceSession *DeviceSession) (*model.RefreshToken, error)
+	AccessTokenLifetime(client *model.Client) (int, error)
+	RefreshTokenLifetime(client *model.Client) (int, error)
+	AuthCodeLifetime(client *model.Client) (int, error)
+	NewDeviceSession(client *model.Client, user *model.User, r *http.Request) *DeviceSession
+	FindDeviceSessionByToken(token string) (*DeviceSession, error)
+	GetRefreshTokenDeviceSession(refreshToken *model.RefreshToken, r *http.Request) *DeviceSession
+	FindActiveDeviceSessions(user *model.User) ([]*DeviceSession, error)
+	FindUserDeviceSession(user *model.User, id string) (*DeviceSession, error)
+	RevokeDeviceSession(deviceSession *DeviceSession) error
+	RevokeOtherDeviceSessions(user *model.User, current *DeviceSession) error
 	GetValidRefreshToken(token string, client *model.Client) (*model.RefreshToken, error)
 	Authenticate(token string) (*model.AccessToken, error)
+	AuthenticateRequest(r *http.Request) (*model.AccessToken, error)
 	NewIntrospectResponseFromAccessToken(accessToken *model.AccessToken) (*IntrospectResponse, error)
 	NewIntrospectResponseFromRefreshToken(refreshToken *model.RefreshToken) (*IntrospectResponse, error)
 	ClearUserTokens(userSession *session.UserSession)
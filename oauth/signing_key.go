@@ -0,0 +1,385 @@
+package oauth
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	jwt "github.com/form3tech-oss/jwt-go"
+	"github.com/google/uuid"
+	"github.com/resonatecoop/id/oauth/jwk"
+	"github.com/uptrace/bun"
+)
+
+const (
+	// SigningAlgorithmRS256 signs JWTs with RSA 2048 keys
+	SigningAlgorithmRS256 = "RS256"
+	// SigningAlgorithmES256 signs JWTs with ECDSA P-256 keys
+	SigningAlgorithmES256 = "ES256"
+
+	// signingKeysLockID is the postgres advisory lock taken while rotating keys
+	signingKeysLockID = 728463001
+	// verificationKeysLifetime is how long the published keys are cached for
+	// verifying JWTs, keys rotated by another instance are picked up sooner
+	// when a token signed with an unknown key comes along
+	verificationKeysLifetime = 5 * time.Minute
+	// verificationKeysReloadInterval is how often an unknown kid may reload
+	// the published keys, so forged tokens cannot make this server hammer
+	// the database
+	verificationKeysReloadInterval = 10 * time.Second
+)
+
+var (
+	// ErrInvalidSigningAlgorithm ...
+	ErrInvalidSigningAlgorithm = errors.New("Invalid signing algorithm")
+	// ErrInvalidJWTType ...
+	ErrInvalidJWTType = errors.New("Invalid JWT type")
+)
+
+// SigningKey is a private key used to sign JWTs, the ID is published as the kid
+type SigningKey struct {
+	bun.BaseModel `bun:"table:signing_keys"`
+
+	ID         string    `bun:"type:varchar(36),pk"`
+	Algorithm  string    `bun:"type:varchar(10),notnull"`
+	PrivateKey string    `bun:"type:text,notnull"`
+	CreatedAt  time.Time `bun:",nullzero,notnull,default:current_timestamp"`
+	RotatedAt  time.Time `bun:",nullzero"`
+	ExpiresAt  time.Time `bun:",nullzero"`
+}
+
+// verificationKeysCache keeps the published keys parsed, so verifying a JWT
+// neither queries the database nor parses PEM encoded keys
+type verificationKeysCache struct {
+	mu        sync.Mutex
+	set       *jwk.Set
+	loadedAt  time.Time
+	expiresAt time.Time
+}
+
+// NewSigningKey generates a new PEM encoded signing key
+func NewSigningKey(algorithm string) (*SigningKey, error) {
+	var (
+		der     []byte
+		pemType string
+	)
+
+	switch algorithm {
+	case SigningAlgorithmRS256:
+		privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+		if err != nil {
+			return nil, err
+		}
+		der = x509.MarshalPKCS1PrivateKey(privateKey)
+		pemType = "RSA PRIVATE KEY"
+	case SigningAlgorithmES256:
+		privateKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		if err != nil {
+			return nil, err
+		}
+		der, err = x509.MarshalECPrivateKey(privateKey)
+		if err != nil {
+			return nil, err
+		}
+		pemType = "EC PRIVATE KEY"
+	default:
+		return nil, ErrInvalidSigningAlgorithm
+	}
+
+	return &SigningKey{
+		ID:         uuid.New().String(),
+		Algorithm:  algorithm,
+		PrivateKey: string(pem.EncodeToMemory(&pem.Block{Type: pemType, Bytes: der})),
+		CreatedAt:  time.Now().UTC(),
+	}, nil
+}
+
+// Signer returns the parsed private key
+func (k *SigningKey) Signer() (crypto.Signer, error) {
+	switch k.Algorithm {
+	case SigningAlgorithmRS256:
+		return jwt.ParseRSAPrivateKeyFromPEM([]byte(k.PrivateKey))
+	case SigningAlgorithmES256:
+		return jwt.ParseECPrivateKeyFromPEM([]byte(k.PrivateKey))
+	default:
+		return nil, ErrInvalidSigningAlgorithm
+	}
+}
+
+// JWK returns the public JSON Web Key of the signing key
+func (k *SigningKey) JWK() (*jwk.Key, error) {
+	signer, err := k.Signer()
+	if err != nil {
+		return nil, err
+	}
+
+	return jwk.NewKey(k.ID, k.Algorithm, signer.Public())
+}
+
+// GetSigningKey returns the key currently signing tokens, a new key is
+// generated once the active key is older than the rotation period
+func (s *Service) GetSigningKey() (*SigningKey, error) {
+	signingKey, err := s.findActiveSigningKey(s.db)
+	if err == nil && !s.signingKeyNeedsRotation(signingKey) {
+		return signingKey, nil
+	}
+
+	return s.rotateSigningKey(false)
+}
+
+// RotateSigningKey replaces the active signing key, the previous key stays
+// published for verification until the overlap period has passed
+func (s *Service) RotateSigningKey() (*SigningKey, error) {
+	return s.rotateSigningKey(true)
+}
+
+// GetPublishedSigningKeys returns the keys tokens can still be verified with
+func (s *Service) GetPublishedSigningKeys() ([]*SigningKey, error) {
+	var signingKeys []*SigningKey
+
+	err := s.db.NewSelect().
+		Model(&signingKeys).
+		Where("expires_at IS NULL OR expires_at > ?", time.Now().UTC()).
+		Order("created_at DESC").
+		Scan(context.Background())
+
+	if err != nil {
+		return nil, err
+	}
+
+	return signingKeys, nil
+}
+
+// GetJWKS returns the JSON Web Key Set of the published signing keys
+func (s *Service) GetJWKS() (*jwk.Set, error) {
+	// Make sure there is a key to publish before the first token is signed
+	if _, err := s.GetSigningKey(); err != nil {
+		return nil, err
+	}
+
+	signingKeys, err := s.GetPublishedSigningKeys()
+	if err != nil {
+		return nil, err
+	}
+
+	return newJWKS(signingKeys)
+}
+
+// signJWT signs the claims with the active signing key, typ overrides the
+// default JWT type header
+func (s *Service) signJWT(claims jwt.Claims, typ string) (string, error) {
+	signingKey, err := s.GetSigningKey()
+	if err != nil {
+		return "", err
+	}
+
+	signer, err := signingKey.Signer()
+	if err != nil {
+		return "", err
+	}
+
+	token := jwt.NewWithClaims(jwt.GetSigningMethod(signingKey.Algorithm), claims)
+	token.Header["kid"] = signingKey.ID
+	if typ != "" {
+		token.Header["typ"] = typ
+	}
+
+	return token.SignedString(signer)
+}
+
+// parseJWT verifies a JWT signed with one of the published keys and parses
+// its claims, typ is the expected type header
+func (s *Service) parseJWT(tokenString string, claims jwt.Claims, typ string) (*jwt.Token, error) {
+	token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+
+		set, err := s.verificationKeys(kid)
+		if err != nil {
+			return nil, err
+		}
+
+		return set.Keyfunc(token)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Stop tokens of one kind being used as another, e.g. an ID token as an access token
+	if header, _ := token.Header["typ"].(string); typ != "" && !strings.EqualFold(header, typ) {
+		return nil, ErrInvalidJWTType
+	}
+
+	return token, nil
+}
+
+// rotateSigningKey generates a new signing key, unless another instance
+// rotated the active key in the meantime
+func (s *Service) rotateSigningKey(force bool) (*SigningKey, error) {
+	ctx := context.Background()
+
+	// Begin a transaction
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+
+	// Serialise rotations across server instances
+	_, err = tx.ExecContext(ctx, "SELECT pg_advisory_xact_lock(?)", signingKeysLockID)
+	if err != nil {
+		tx.Rollback() // rollback the transaction
+		return nil, err
+	}
+
+	activeKey, err := s.findActiveSigningKey(tx)
+	if err == nil && !force && !s.signingKeyNeedsRotation(activeKey) {
+		tx.Rollback() // rollback the transaction
+		return activeKey, nil
+	}
+
+	signingKey, err := NewSigningKey(s.cnf.SigningKeys.Algorithm)
+	if err != nil {
+		tx.Rollback() // rollback the transaction
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+
+	// Stop signing with the previous key but keep publishing it
+	_, err = tx.NewUpdate().
+		Model((*SigningKey)(nil)).
+		Set("rotated_at = ?", now).
+		Set("expires_at = ?", now.Add(time.Duration(s.cnf.SigningKeys.OverlapPeriod)*time.Second)).
+		Where("rotated_at IS NULL").
+		Exec(ctx)
+	if err != nil {
+		tx.Rollback() // rollback the transaction
+		return nil, err
+	}
+
+	// Delete keys which are no longer published
+	_, err = tx.NewDelete().
+		Model((*SigningKey)(nil)).
+		Where("expires_at <= ?", now).
+		Exec(ctx)
+	if err != nil {
+		tx.Rollback() // rollback the transaction
+		return nil, err
+	}
+
+	_, err = tx.NewInsert().
+		Model(signingKey).
+		Exec(ctx)
+	if err != nil {
+		tx.Rollback() // rollback the transaction
+		return nil, err
+	}
+
+	// Commit the transaction
+	if err = tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	// Verify tokens signed with the new key straight away
+	s.verificationKeysCache.mu.Lock()
+	s.verificationKeysCache.set = nil
+	s.verificationKeysCache.mu.Unlock()
+
+	return signingKey, nil
+}
+
+// verificationKeys returns the published keys from the cache, they are
+// loaded again once the cache has expired or when kid is not among them
+func (s *Service) verificationKeys(kid string) (*jwk.Set, error) {
+	c := s.verificationKeysCache
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+
+	if c.set != nil && now.Before(c.expiresAt) {
+		if _, err := c.set.Key(kid); err == nil || now.Before(c.loadedAt.Add(verificationKeysReloadInterval)) {
+			return c.set, nil
+		}
+	}
+
+	signingKeys, err := s.GetPublishedSigningKeys()
+	if err != nil {
+		return nil, err
+	}
+
+	set, err := newJWKS(signingKeys)
+	if err != nil {
+		return nil, err
+	}
+
+	// Stop trusting a key once its overlap period has passed
+	expiresAt := now.Add(verificationKeysLifetime)
+	for _, signingKey := range signingKeys {
+		if !signingKey.ExpiresAt.IsZero() && signingKey.ExpiresAt.Before(expiresAt) {
+			expiresAt = signingKey.ExpiresAt
+		}
+	}
+
+	c.set, c.loadedAt, c.expiresAt = set, now, expiresAt
+
+	return set, nil
+}
+
+// newJWKS returns the JSON Web Key Set of the signing keys
+func newJWKS(signingKeys []*SigningKey) (*jwk.Set, error) {
+	set := &jwk.Set{Keys: make([]*jwk.Key, 0, len(signingKeys))}
+
+	for _, signingKey := range signingKeys {
+		key, err := signingKey.JWK()
+		if err != nil {
+			return nil, err
+		}
+		set.Keys = append(set.Keys, key)
+	}
+
+	return set, nil
+}
+
+// findActiveSigningKey returns the key currently signing tokens
+func (s *Service) findActiveSigningKey(db bun.IDB) (*SigningKey, error) {
+	signingKey := new(SigningKey)
+
+	err := db.NewSelect().
+		Model(signingKey).
+		Where("rotated_at IS NULL").
+		Order("created_at DESC").
+		Limit(1).
+		Scan(context.Background())
+
+	if err != nil {
+		return nil, err
+	}
+
+	return signingKey, nil
+}
+
+// signingKeyNeedsRotation returns true once the key is older than the rotation
+// period or the configured algorithm has changed
+func (s *Service) signingKeyNeedsRotation(signingKey *SigningKey) bool {
+	if signingKey.Algorithm != s.cnf.SigningKeys.Algorithm {
+		return true
+	}
+
+	if s.cnf.SigningKeys.RotationPeriod <= 0 {
+		return false
+	}
+
+	rotationPeriod := time.Duration(s.cnf.SigningKeys.RotationPeriod) * time.Second
+
+	return time.Now().UTC().After(signingKey.CreatedAt.Add(rotationPeriod))
+}
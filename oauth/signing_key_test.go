@@ -0,0 +1,146 @@
+package oauth_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	jwt "github.com/form3tech-oss/jwt-go"
+	"github.com/resonatecoop/id/oauth"
+	"github.com/resonatecoop/id/oauth/jwk"
+	testutil "github.com/resonatecoop/id/test-util"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewSigningKey(t *testing.T) {
+	for _, algorithm := range []string{oauth.SigningAlgorithmRS256, oauth.SigningAlgorithmES256} {
+		signingKey, err := oauth.NewSigningKey(algorithm)
+		assert.NoError(t, err)
+
+		key, err := signingKey.JWK()
+		assert.NoError(t, err)
+		assert.Equal(t, signingKey.ID, key.KeyID)
+		assert.Equal(t, algorithm, key.Algorithm)
+	}
+
+	_, err := oauth.NewSigningKey("HS256")
+	assert.Equal(t, oauth.ErrInvalidSigningAlgorithm, err)
+}
+
+func (suite *OauthTestSuite) TestGetSigningKey() {
+	signingKey, err := suite.service.GetSigningKey()
+	assert.NoError(suite.T(), err)
+
+	// The active key is reused until it is rotated
+	activeKey, err := suite.service.GetSigningKey()
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), signingKey.ID, activeKey.ID)
+}
+
+func (suite *OauthTestSuite) TestGetSigningKeyRotationPeriod() {
+	signingKey, err := suite.service.GetSigningKey()
+	assert.NoError(suite.T(), err)
+
+	// Age the key past the rotation period
+	_, err = suite.db.NewUpdate().
+		Model((*oauth.SigningKey)(nil)).
+		Set("created_at = ?", time.Now().UTC().Add(-time.Duration(suite.cnf.SigningKeys.RotationPeriod+1)*time.Second)).
+		Where("id = ?", signingKey.ID).
+		Exec(context.Background())
+	assert.NoError(suite.T(), err)
+
+	activeKey, err := suite.service.GetSigningKey()
+	assert.NoError(suite.T(), err)
+	assert.NotEqual(suite.T(), signingKey.ID, activeKey.ID)
+}
+
+func (suite *OauthTestSuite) TestRotateSigningKey() {
+	idToken, err := suite.service.GrantIDToken(suite.clients[0], suite.users[0], "")
+	assert.NoError(suite.T(), err)
+
+	oldKey, err := suite.service.GetSigningKey()
+	assert.NoError(suite.T(), err)
+
+	newKey, err := suite.service.RotateSigningKey()
+	assert.NoError(suite.T(), err)
+	assert.NotEqual(suite.T(), oldKey.ID, newKey.ID)
+
+	// Both keys are published during the overlap period
+	set, err := suite.service.GetJWKS()
+	assert.NoError(suite.T(), err)
+	assert.Len(suite.T(), set.Keys, 2)
+
+	// Tokens signed with the old key can still be verified
+	_, err = jwt.ParseWithClaims(idToken, new(oauth.IDTokenClaims), set.Keyfunc)
+	assert.NoError(suite.T(), err)
+
+	// Once the overlap period has passed the old key is deleted
+	_, err = suite.db.NewUpdate().
+		Model((*oauth.SigningKey)(nil)).
+		Set("expires_at = ?", time.Now().UTC().Add(-time.Second)).
+		Where("id = ?", oldKey.ID).
+		Exec(context.Background())
+	assert.NoError(suite.T(), err)
+
+	_, err = suite.service.RotateSigningKey()
+	assert.NoError(suite.T(), err)
+
+	set, err = suite.service.GetJWKS()
+	assert.NoError(suite.T(), err)
+	assert.Len(suite.T(), set.Keys, 2)
+
+	_, err = set.Key(oldKey.ID)
+	assert.Equal(suite.T(), jwk.ErrKeyNotFound, err)
+}
+
+func (suite *OauthTestSuite) TestJWKSHandler() {
+	// Prepare a request
+	r, err := http.NewRequest("GET", "http://1.2.3.4/.well-known/jwks.json", nil)
+	assert.NoError(suite.T(), err, "Request setup should not get an error")
+
+	// Serve the request
+	w := httptest.NewRecorder()
+	suite.router.ServeHTTP(w, r)
+
+	// Check the response
+	set, err := suite.service.GetJWKS()
+	assert.NoError(suite.T(), err)
+	testutil.TestResponseObject(suite.T(), w, set, 200)
+	assert.Equal(suite.T(), "public, max-age=3600", w.Header().Get("Cache-Control"))
+}
+
+func (suite *OauthTestSuite) TestParseJWTCachesVerificationKeys() {
+	suite.cnf.Oauth.AccessTokenFormat = oauth.AccessTokenFormatJWT
+	defer func() { suite.cnf.Oauth.AccessTokenFormat = oauth.AccessTokenFormatOpaque }()
+
+	accessToken, err := suite.service.GrantAccessToken(
+		suite.clients[0], // client
+		suite.users[0],   // user
+		3600,             // expires in
+		"read_write",     // scope
+		nil,              // device session
+	)
+	assert.NoError(suite.T(), err)
+
+	_, err = suite.service.ParseJWTAccessToken(accessToken.Token)
+	assert.NoError(suite.T(), err)
+
+	// The published keys are cached, verifying does not query the database
+	_, err = suite.db.NewDelete().
+		Model((*oauth.SigningKey)(nil)).
+		Where("1 = 1").
+		Exec(context.Background())
+	assert.NoError(suite.T(), err)
+
+	_, err = suite.service.ParseJWTAccessToken(accessToken.Token)
+	assert.NoError(suite.T(), err)
+
+	// Rotating the signing key reloads the cache
+	_, err = suite.service.RotateSigningKey()
+	assert.NoError(suite.T(), err)
+
+	_, err = suite.service.ParseJWTAccessToken(accessToken.Token)
+	assert.Equal(suite.T(), oauth.ErrAccessTokenNotFound, err)
+}
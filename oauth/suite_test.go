@@ -26,9 +26,9 @@ var (
 	// 	"./oauth/fixtures/test_users.yml",
 	// }
 
-// 	testMigrations = []func(*bun.DB) error{
-// 		model.MigrateAll,
-// 	}
+//	testMigrations = []func(*bun.DB) error{
+//		model.MigrateAll,
+//	}
 )
 
 func init() {
@@ -62,6 +62,11 @@ func (suite *OauthTestSuite) SetupSuite() {
 		panic(err)
 	}
 
+	// Create the tables owned by this server
+	if err := oauth.MigrateAll(suite.db); err != nil {
+		panic(err)
+	}
+
 	// ASSUME THAT TEST DATABASE HAS ALREADY BEEN CREATED
 	// Create the test database
 	// db, err := testutil.CreateTestDatabasePostgres(
@@ -113,6 +118,8 @@ func (suite *OauthTestSuite) SetupSuite() {
 	// Register routes
 	suite.router = mux.NewRouter()
 	suite.service.RegisterRoutes(suite.router, "/v1/oauth")
+	suite.service.RegisterAdminRoutes(suite.router, "/v1/admin")
+	suite.service.RegisterWellKnownRoutes(suite.router, "/.well-known")
 }
 
 // The TearDownSuite method will be run by testify once, at the very
@@ -136,6 +143,78 @@ func (suite *OauthTestSuite) TearDownTest() {
 		Model(new(model.AuthorizationCode)).
 		Exec(ctx)
 
+	suite.db.NewTruncateTable().
+		Model(new(oauth.AuthorizationCodeChallenge)).
+		Exec(ctx)
+
+	suite.db.NewTruncateTable().
+		Model(new(oauth.AuthorizationCodeNonce)).
+		Exec(ctx)
+
+	suite.db.NewTruncateTable().
+		Model(new(oauth.SigningKey)).
+		Exec(ctx)
+
+	suite.db.NewTruncateTable().
+		Model(new(oauth.RefreshTokenRotation)).
+		Exec(ctx)
+
+	suite.db.NewTruncateTable().
+		Model(new(oauth.DeviceSession)).
+		Exec(ctx)
+
+	suite.db.NewTruncateTable().
+		Model(new(oauth.DeviceSessionToken)).
+		Exec(ctx)
+
+	suite.db.NewTruncateTable().
+		Model(new(oauth.TOTPSecret)).
+		Exec(ctx)
+
+	suite.db.NewTruncateTable().
+		Model(new(oauth.RecoveryCode)).
+		Exec(ctx)
+
+	suite.db.NewTruncateTable().
+		Model(new(oauth.WebAuthnCredential)).
+		Exec(ctx)
+
+	suite.db.NewTruncateTable().
+		Model(new(oauth.DeviceCode)).
+		Exec(ctx)
+
+	suite.db.NewTruncateTable().
+		Model(new(oauth.TokenExchange)).
+		Exec(ctx)
+
+	suite.db.NewTruncateTable().
+		Model(new(oauth.ClientMetadata)).
+		Exec(ctx)
+
+	suite.db.NewTruncateTable().
+		Model(new(oauth.Consent)).
+		Exec(ctx)
+
+	suite.db.NewTruncateTable().
+		Model(new(oauth.ClientState)).
+		Exec(ctx)
+
+	suite.db.NewTruncateTable().
+		Model(new(oauth.ClientAssertion)).
+		Exec(ctx)
+
+	suite.db.NewTruncateTable().
+		Model(new(oauth.TokenBinding)).
+		Exec(ctx)
+
+	suite.db.NewTruncateTable().
+		Model(new(oauth.DPoPProof)).
+		Exec(ctx)
+
+	suite.db.NewTruncateTable().
+		Model(new(oauth.PushedAuthorizationRequest)).
+		Exec(ctx)
+
 	suite.db.NewTruncateTable().
 		Model(new(model.RefreshToken)).
 		Exec(ctx)
@@ -0,0 +1,62 @@
+package oauth
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/uptrace/bun"
+)
+
+const (
+	// TokenExchangeGrantType is the grant type of RFC 8693
+	TokenExchangeGrantType = "urn:ietf:params:oauth:grant-type:token-exchange"
+	// AccessTokenType identifies access tokens as subject and issued tokens
+	AccessTokenType = "urn:ietf:params:oauth:token-type:access_token"
+)
+
+var (
+	// ErrSubjectTokenMissing ...
+	ErrSubjectTokenMissing = errors.New("Subject token missing")
+	// ErrInvalidSubjectTokenType ...
+	ErrInvalidSubjectTokenType = errors.New("Unsupported subject token type")
+	// ErrInvalidRequestedTokenType ...
+	ErrInvalidRequestedTokenType = errors.New("Unsupported requested token type")
+	// ErrAudienceMissing ...
+	ErrAudienceMissing = errors.New("Audience missing")
+	// ErrInvalidAudience ...
+	ErrInvalidAudience = errors.New("Invalid audience")
+)
+
+// Actor is the act claim of RFC 8693, the party acting on behalf of the
+// subject of a token. Tokens exchanged more than once nest the previous actors.
+type Actor struct {
+	Subject string `json:"sub"`
+	Actor   *Actor `json:"act,omitempty"`
+}
+
+// TokenExchange records the audience and actor of an access token issued
+// by the token exchange grant
+type TokenExchange struct {
+	bun.BaseModel `bun:"table:token_exchanges"`
+
+	Token     string    `bun:"type:varchar(40),pk"`
+	Audience  string    `bun:"type:varchar(254),notnull"`
+	Act       *Actor    `bun:"type:jsonb,notnull"`
+	CreatedAt time.Time `bun:",nullzero,notnull,default:current_timestamp"`
+}
+
+// findTokenExchange returns how an access token was exchanged, by its
+// stored token
+func (s *Service) findTokenExchange(token string) (*TokenExchange, error) {
+	tokenExchange := new(TokenExchange)
+	err := s.db.NewSelect().
+		Model(tokenExchange).
+		Where("token = ?", token).
+		Limit(1).
+		Scan(context.Background())
+	if err != nil {
+		return nil, err
+	}
+	return tokenExchange, nil
+}
@@ -0,0 +1,98 @@
+package oauth
+
+import (
+	"github.com/resonatecoop/user-api/model"
+)
+
+// AccessTokenLifetime returns how long (in seconds) access tokens issued to
+// the client are valid
+func (s *Service) AccessTokenLifetime(client *model.Client) (int, error) {
+	policy, err := s.clientPolicy(client)
+	if err != nil {
+		return 0, err
+	}
+	registered := 0
+	if policy != nil {
+		registered = policy.AccessTokenLifetime
+	}
+	return tokenLifetime(registered, s.cnf.Oauth.AccessTokenLifetime, s.cnf.Oauth.MaxAccessTokenLifetime), nil
+}
+
+// RefreshTokenLifetime returns how long (in seconds) refresh tokens issued
+// to the client are valid
+func (s *Service) RefreshTokenLifetime(client *model.Client) (int, error) {
+	policy, err := s.clientPolicy(client)
+	if err != nil {
+		return 0, err
+	}
+	registered := 0
+	if policy != nil {
+		registered = policy.RefreshTokenLifetime
+	}
+	return tokenLifetime(registered, s.cnf.Oauth.RefreshTokenLifetime, s.cnf.Oauth.MaxRefreshTokenLifetime), nil
+}
+
+// AuthCodeLifetime returns how long (in seconds) authorization codes issued
+// to the client are valid
+func (s *Service) AuthCodeLifetime(client *model.Client) (int, error) {
+	policy, err := s.clientPolicy(client)
+	if err != nil {
+		return 0, err
+	}
+	registered := 0
+	if policy != nil {
+		registered = policy.AuthCodeLifetime
+	}
+	return tokenLifetime(registered, s.cnf.Oauth.AuthCodeLifetime, s.cnf.Oauth.MaxAuthCodeLifetime), nil
+}
+
+// tokenLifetime returns the lifetime registered by a client, or the server
+// default when none is, never longer than the server maximum. Without a
+// configured maximum the default is the maximum.
+func tokenLifetime(registered, defaultLifetime, maxLifetime int) int {
+	if maxLifetime <= 0 {
+		maxLifetime = defaultLifetime
+	}
+
+	lifetime := defaultLifetime
+	if registered > 0 {
+		lifetime = registered
+	}
+
+	if lifetime > maxLifetime {
+		return maxLifetime
+	}
+	return lifetime
+}
+
+// limitLifetime caps the lifetime a token is granted with at the client's
+// lifetime, a lifetime of zero or less is the client's lifetime
+func limitLifetime(expiresIn, lifetime int) int {
+	if expiresIn <= 0 || expiresIn > lifetime {
+		return lifetime
+	}
+	return expiresIn
+}
+
+// validateTokenLifetimes checks the lifetimes a client registers are within
+// the server maximums, zero is the server default
+func (s *Service) validateTokenLifetimes(req *ClientRegistrationRequest) error {
+	lifetimes := []struct {
+		registered, defaultLifetime, maxLifetime int
+	}{
+		{req.AccessTokenLifetime, s.cnf.Oauth.AccessTokenLifetime, s.cnf.Oauth.MaxAccessTokenLifetime},
+		{req.RefreshTokenLifetime, s.cnf.Oauth.RefreshTokenLifetime, s.cnf.Oauth.MaxRefreshTokenLifetime},
+		{req.AuthCodeLifetime, s.cnf.Oauth.AuthCodeLifetime, s.cnf.Oauth.MaxAuthCodeLifetime},
+	}
+
+	for _, l := range lifetimes {
+		if l.registered < 0 {
+			return ErrInvalidClientMetadata
+		}
+		if l.registered > 0 && tokenLifetime(l.registered, l.defaultLifetime, l.maxLifetime) != l.registered {
+			return ErrInvalidClientMetadata
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,74 @@
+package oauth_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+
+	"github.com/resonatecoop/id/oauth"
+	"github.com/stretchr/testify/assert"
+)
+
+func (suite *OauthTestSuite) TestTokenLifetimes() {
+	// Clients without a policy get the server defaults
+	lifetime, err := suite.service.AccessTokenLifetime(suite.clients[0])
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), suite.cnf.Oauth.AccessTokenLifetime, lifetime)
+	lifetime, err = suite.service.RefreshTokenLifetime(suite.clients[0])
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), suite.cnf.Oauth.RefreshTokenLifetime, lifetime)
+	lifetime, err = suite.service.AuthCodeLifetime(suite.clients[0])
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), suite.cnf.Oauth.AuthCodeLifetime, lifetime)
+
+	// Lifetimes cannot exceed the server maximums
+	_, err = suite.service.RegisterClient(&oauth.ClientRegistrationRequest{
+		ClientName:          "Partner Service",
+		GrantTypes:          []string{"client_credentials"},
+		AccessTokenLifetime: 10 * 365 * 86400,
+	}, true)
+	assert.Equal(suite.T(), oauth.ErrInvalidClientMetadata, err)
+
+	_, err = suite.service.RegisterClient(&oauth.ClientRegistrationRequest{
+		ClientName:           "Partner Service",
+		GrantTypes:           []string{"client_credentials"},
+		RefreshTokenLifetime: -1,
+	}, true)
+	assert.Equal(suite.T(), oauth.ErrInvalidClientMetadata, err)
+
+	resp, err := suite.service.RegisterClient(&oauth.ClientRegistrationRequest{
+		ClientName:          "Partner Service",
+		GrantTypes:          []string{"client_credentials"},
+		AccessTokenLifetime: 300,
+	}, true)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), 300, resp.AccessTokenLifetime)
+
+	client, err := suite.service.FindClientByClientID(resp.ClientID)
+	assert.NoError(suite.T(), err)
+	lifetime, err = suite.service.AccessTokenLifetime(client)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), 300, lifetime)
+	lifetime, err = suite.service.RefreshTokenLifetime(client)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), suite.cnf.Oauth.RefreshTokenLifetime, lifetime)
+
+	// Tokens are issued with the client's lifetime
+	r, err := http.NewRequest("POST", "http://1.2.3.4/v1/oauth/tokens", nil)
+	assert.NoError(suite.T(), err, "Request setup should not get an error")
+	r.SetBasicAuth(resp.ClientID, resp.ClientSecret)
+	r.PostForm = url.Values{"grant_type": {"client_credentials"}}
+	w := httptest.NewRecorder()
+	suite.router.ServeHTTP(w, r)
+	assert.Equal(suite.T(), http.StatusOK, w.Code)
+
+	accessTokenResponse := new(oauth.AccessTokenResponse)
+	assert.NoError(suite.T(), json.Unmarshal(w.Body.Bytes(), accessTokenResponse))
+	assert.Equal(suite.T(), 300, accessTokenResponse.ExpiresIn)
+
+	// Longer lifetimes asked by the caller are capped
+	accessToken, err := suite.service.GrantAccessToken(client, nil, 3600, "read", nil)
+	assert.NoError(suite.T(), err)
+	assert.InDelta(suite.T(), 300, accessToken.ExpiresAt.Sub(accessToken.CreatedAt).Seconds(), 2)
+}
@@ -2,3 +2,6 @@ package tokentypes
 
 // Bearer is the default type of generated tokens.
 const Bearer = "Bearer"
+
+// DPoP is the type of tokens bound to a DPoP key (RFC 9449).
+const DPoP = "DPoP"
@@ -0,0 +1,410 @@
+package oauth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/pquerna/otp"
+	"github.com/pquerna/otp/totp"
+	pass "github.com/resonatecoop/id/util/password"
+	"github.com/resonatecoop/user-api/model"
+	"github.com/uptrace/bun"
+)
+
+const (
+	// totpPeriod is how long (in seconds) a TOTP code is valid for
+	totpPeriod = 30
+	// totpSkew is how many periods before and after the current one are accepted
+	totpSkew = 1
+	// recoveryCodeCount is how many recovery codes a user gets
+	recoveryCodeCount = 10
+)
+
+var (
+	// ErrTOTPNotEnabled ...
+	ErrTOTPNotEnabled = errors.New("Two-factor authentication is not enabled")
+	// ErrTOTPAlreadyEnabled ...
+	ErrTOTPAlreadyEnabled = errors.New("Two-factor authentication is already enabled")
+	// ErrTOTPNotEnrolled ...
+	ErrTOTPNotEnrolled = errors.New("Two-factor authentication has not been set up")
+	// ErrTOTPRequired ...
+	ErrTOTPRequired = errors.New("Two-factor authentication is required for your account")
+	// ErrInvalidTOTPCode ...
+	ErrInvalidTOTPCode = errors.New("Invalid authentication code")
+)
+
+// TOTPSecret is the shared secret of a user's authenticator app (RFC 6238),
+// it is enabled once the user has confirmed a code generated with it
+type TOTPSecret struct {
+	bun.BaseModel `bun:"table:totp_secrets"`
+
+	UserID uuid.UUID `bun:"type:uuid,pk"`
+	Secret string    `bun:"type:varchar(64),notnull"`
+	// LastCounter is the time step of the last accepted code, codes can only be used once
+	LastCounter int64     `bun:",notnull,default:0"`
+	CreatedAt   time.Time `bun:",nullzero,notnull,default:current_timestamp"`
+	EnabledAt   time.Time `bun:",nullzero"`
+}
+
+// RecoveryCode is a one-time code logging in a user who lost their authenticator
+type RecoveryCode struct {
+	bun.BaseModel `bun:"table:recovery_codes"`
+
+	ID        uuid.UUID `bun:"type:uuid,pk"`
+	UserID    uuid.UUID `bun:"type:uuid,notnull"`
+	CodeHash  string    `bun:"type:varchar(64),notnull"`
+	CreatedAt time.Time `bun:",nullzero,notnull,default:current_timestamp"`
+	UsedAt    time.Time `bun:",nullzero"`
+}
+
+// IsTOTPEnabled returns true if the user logs in with two-factor authentication
+func (s *Service) IsTOTPEnabled(user *model.User) bool {
+	totpSecret, err := s.findTOTPSecret(user)
+	return err == nil && !totpSecret.EnabledAt.IsZero()
+}
+
+// EnrollTOTP returns the key to provision the user's authenticator app with,
+// the same key is returned until two-factor authentication is enabled
+func (s *Service) EnrollTOTP(user *model.User) (*otp.Key, error) {
+	totpSecret, err := s.findTOTPSecret(user)
+	if err == nil {
+		if !totpSecret.EnabledAt.IsZero() {
+			return nil, ErrTOTPAlreadyEnabled
+		}
+
+		secret, err := base32.StdEncoding.WithPadding(base32.NoPadding).DecodeString(totpSecret.Secret)
+		if err != nil {
+			return nil, err
+		}
+
+		return s.newTOTPKey(user, secret)
+	}
+
+	key, err := s.newTOTPKey(user, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = s.db.NewInsert().
+		Model(&TOTPSecret{
+			UserID:    user.ID,
+			Secret:    key.Secret(),
+			CreatedAt: time.Now().UTC(),
+		}).
+		Exec(context.Background())
+	if err != nil {
+		return nil, err
+	}
+
+	return key, nil
+}
+
+// EnableTOTP enables two-factor authentication once the user has entered a
+// code from their authenticator app, it returns new recovery codes
+func (s *Service) EnableTOTP(user *model.User, passcode string) ([]string, error) {
+	totpSecret, err := s.findTOTPSecret(user)
+	if err != nil {
+		return nil, ErrTOTPNotEnrolled
+	}
+
+	if !totpSecret.EnabledAt.IsZero() {
+		return nil, ErrTOTPAlreadyEnabled
+	}
+
+	if err := s.validateTOTPCode(totpSecret, passcode); err != nil {
+		return nil, err
+	}
+
+	// Begin a transaction
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = tx.NewUpdate().
+		Model(totpSecret).
+		Set("enabled_at = ?", time.Now().UTC()).
+		WherePK().
+		Exec(context.Background())
+	if err != nil {
+		tx.Rollback() // rollback the transaction
+		return nil, err
+	}
+
+	recoveryCodes, err := replaceRecoveryCodes(tx, user)
+	if err != nil {
+		tx.Rollback() // rollback the transaction
+		return nil, err
+	}
+
+	// Commit the transaction
+	if err = tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return recoveryCodes, nil
+}
+
+// DisableTOTP turns off two-factor authentication, the user confirms with their password
+func (s *Service) DisableTOTP(user *model.User, password string) error {
+	if s.IsTOTPRequired(user) {
+		return ErrTOTPRequired
+	}
+
+	// Check that the password is set
+	if !user.Password.Valid {
+		return ErrUserPasswordNotSet
+	}
+
+	// Verify the password
+	if pass.VerifyPassword(user.Password.String, password) != nil {
+		return ErrInvalidUserPassword
+	}
+
+	ctx := context.Background()
+
+	// Begin a transaction
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.NewDelete().
+		Model((*TOTPSecret)(nil)).
+		Where("user_id = ?", user.ID).
+		Exec(ctx)
+	if err != nil {
+		tx.Rollback() // rollback the transaction
+		return err
+	}
+
+	_, err = tx.NewDelete().
+		Model((*RecoveryCode)(nil)).
+		Where("user_id = ?", user.ID).
+		Exec(ctx)
+	if err != nil {
+		tx.Rollback() // rollback the transaction
+		return err
+	}
+
+	// Commit the transaction
+	return tx.Commit()
+}
+
+// RegenerateRecoveryCodes replaces the user's recovery codes
+func (s *Service) RegenerateRecoveryCodes(user *model.User) ([]string, error) {
+	if !s.IsTOTPEnabled(user) {
+		return nil, ErrTOTPNotEnabled
+	}
+
+	// Begin a transaction
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+
+	recoveryCodes, err := replaceRecoveryCodes(tx, user)
+	if err != nil {
+		tx.Rollback() // rollback the transaction
+		return nil, err
+	}
+
+	// Commit the transaction
+	if err = tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return recoveryCodes, nil
+}
+
+// VerifyTOTP checks the second login factor, either a code from the user's
+// authenticator app or one of their unused recovery codes
+func (s *Service) VerifyTOTP(user *model.User, code string) error {
+	totpSecret, err := s.findTOTPSecret(user)
+	if err != nil || totpSecret.EnabledAt.IsZero() {
+		return ErrTOTPNotEnabled
+	}
+
+	code = strings.Join(strings.Fields(code), "")
+
+	if len(code) == int(otp.DigitsSix) {
+		return s.validateTOTPCode(totpSecret, code)
+	}
+
+	// Recovery codes can be used once
+	res, err := s.db.NewUpdate().
+		Model((*RecoveryCode)(nil)).
+		Set("used_at = ?", time.Now().UTC()).
+		Where("user_id = ?", user.ID).
+		Where("code_hash = ?", hashRecoveryCode(code)).
+		Where("used_at IS NULL").
+		Exec(context.Background())
+	if err != nil {
+		return err
+	}
+
+	if n, _ := res.RowsAffected(); n != 1 {
+		return ErrInvalidTOTPCode
+	}
+
+	return nil
+}
+
+// CountRecoveryCodes returns how many unused recovery codes the user has left
+func (s *Service) CountRecoveryCodes(user *model.User) (int, error) {
+	return s.db.NewSelect().
+		Model((*RecoveryCode)(nil)).
+		Where("user_id = ?", user.ID).
+		Where("used_at IS NULL").
+		Count(context.Background())
+}
+
+// validateTOTPCode accepts a code of the current time step or an adjacent one,
+// each time step is only accepted once to stop codes being replayed
+func (s *Service) validateTOTPCode(totpSecret *TOTPSecret, passcode string) error {
+	opts := totp.ValidateOpts{
+		Period:    totpPeriod,
+		Digits:    otp.DigitsSix,
+		Algorithm: otp.AlgorithmSHA1,
+	}
+
+	current := time.Now().UTC().Unix() / totpPeriod
+
+	for counter := current - totpSkew; counter <= current+totpSkew; counter++ {
+		if counter <= totpSecret.LastCounter {
+			continue
+		}
+
+		code, err := totp.GenerateCodeCustom(totpSecret.Secret, time.Unix(counter*totpPeriod, 0), opts)
+		if err != nil {
+			return err
+		}
+
+		if subtle.ConstantTimeCompare([]byte(code), []byte(passcode)) != 1 {
+			continue
+		}
+
+		// Another request may have used the code in the meantime
+		res, err := s.db.NewUpdate().
+			Model((*TOTPSecret)(nil)).
+			Set("last_counter = ?", counter).
+			Where("user_id = ?", totpSecret.UserID).
+			Where("last_counter < ?", counter).
+			Exec(context.Background())
+		if err != nil {
+			return err
+		}
+
+		if n, _ := res.RowsAffected(); n != 1 {
+			return ErrInvalidTOTPCode
+		}
+
+		totpSecret.LastCounter = counter
+
+		return nil
+	}
+
+	return ErrInvalidTOTPCode
+}
+
+// newTOTPKey returns the provisioning key of a secret, a nil secret generates a new one
+func (s *Service) newTOTPKey(user *model.User, secret []byte) (*otp.Key, error) {
+	issuer := s.cnf.TOTP.Issuer
+	if issuer == "" {
+		issuer = s.cnf.Hostname
+	}
+
+	return totp.Generate(totp.GenerateOpts{
+		Issuer:      issuer,
+		AccountName: user.Username,
+		Period:      totpPeriod,
+		Secret:      secret,
+		Digits:      otp.DigitsSix,
+		Algorithm:   otp.AlgorithmSHA1,
+	})
+}
+
+// findTOTPSecret returns the TOTP secret of the user
+func (s *Service) findTOTPSecret(user *model.User) (*TOTPSecret, error) {
+	totpSecret := new(TOTPSecret)
+
+	err := s.db.NewSelect().
+		Model(totpSecret).
+		Where("user_id = ?", user.ID).
+		Limit(1).
+		Scan(context.Background())
+
+	if err != nil {
+		return nil, err
+	}
+
+	return totpSecret, nil
+}
+
+// replaceRecoveryCodes deletes the user's recovery codes and returns new ones,
+// only their hashes are stored
+func replaceRecoveryCodes(db bun.IDB, user *model.User) ([]string, error) {
+	ctx := context.Background()
+
+	_, err := db.NewDelete().
+		Model((*RecoveryCode)(nil)).
+		Where("user_id = ?", user.ID).
+		Exec(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	codes := make([]string, recoveryCodeCount)
+	recoveryCodes := make([]*RecoveryCode, recoveryCodeCount)
+	now := time.Now().UTC()
+
+	for i := range codes {
+		codes[i], err = newRecoveryCode()
+		if err != nil {
+			return nil, err
+		}
+
+		recoveryCodes[i] = &RecoveryCode{
+			ID:        uuid.New(),
+			UserID:    user.ID,
+			CodeHash:  hashRecoveryCode(codes[i]),
+			CreatedAt: now,
+		}
+	}
+
+	_, err = db.NewInsert().
+		Model(&recoveryCodes).
+		Exec(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return codes, nil
+}
+
+// newRecoveryCode returns a random code formatted as xxxxx-xxxxx
+func newRecoveryCode() (string, error) {
+	b := make([]byte, 7)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	code := strings.ToLower(base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(b))[:10]
+
+	return code[:5] + "-" + code[5:], nil
+}
+
+// hashRecoveryCode hashes a recovery code ignoring case and dashes
+func hashRecoveryCode(code string) string {
+	code = strings.ToLower(strings.ReplaceAll(code, "-", ""))
+	sum := sha256.Sum256([]byte(code))
+	return hex.EncodeToString(sum[:])
+}
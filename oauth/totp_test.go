@@ -0,0 +1,85 @@
+package oauth_test
+
+import (
+	"time"
+
+	"github.com/pquerna/otp/totp"
+	"github.com/resonatecoop/id/oauth"
+	"github.com/resonatecoop/user-api/model"
+	"github.com/stretchr/testify/assert"
+)
+
+func (suite *OauthTestSuite) TestEnableTOTP() {
+	assert.False(suite.T(), suite.service.IsTOTPEnabled(suite.users[0]))
+
+	key, err := suite.service.EnrollTOTP(suite.users[0])
+	assert.NoError(suite.T(), err)
+
+	// The same key is returned until two-factor authentication is enabled
+	sameKey, err := suite.service.EnrollTOTP(suite.users[0])
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), key.Secret(), sameKey.Secret())
+
+	_, err = suite.service.EnableTOTP(suite.users[0], "000000")
+	assert.Equal(suite.T(), oauth.ErrInvalidTOTPCode, err)
+
+	code, err := totp.GenerateCode(key.Secret(), time.Now())
+	assert.NoError(suite.T(), err)
+
+	recoveryCodes, err := suite.service.EnableTOTP(suite.users[0], code)
+	assert.NoError(suite.T(), err)
+	assert.Len(suite.T(), recoveryCodes, 10)
+	assert.True(suite.T(), suite.service.IsTOTPEnabled(suite.users[0]))
+
+	_, err = suite.service.EnrollTOTP(suite.users[0])
+	assert.Equal(suite.T(), oauth.ErrTOTPAlreadyEnabled, err)
+
+	// The code used to enable two-factor authentication cannot be replayed
+	err = suite.service.VerifyTOTP(suite.users[0], code)
+	assert.Equal(suite.T(), oauth.ErrInvalidTOTPCode, err)
+
+	// Recovery codes work once
+	err = suite.service.VerifyTOTP(suite.users[0], recoveryCodes[0])
+	assert.NoError(suite.T(), err)
+	err = suite.service.VerifyTOTP(suite.users[0], recoveryCodes[0])
+	assert.Equal(suite.T(), oauth.ErrInvalidTOTPCode, err)
+
+	left, err := suite.service.CountRecoveryCodes(suite.users[0])
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), 9, left)
+
+	// Regenerating replaces the unused codes
+	newRecoveryCodes, err := suite.service.RegenerateRecoveryCodes(suite.users[0])
+	assert.NoError(suite.T(), err)
+	err = suite.service.VerifyTOTP(suite.users[0], recoveryCodes[1])
+	assert.Equal(suite.T(), oauth.ErrInvalidTOTPCode, err)
+	err = suite.service.VerifyTOTP(suite.users[0], newRecoveryCodes[1])
+	assert.NoError(suite.T(), err)
+}
+
+func (suite *OauthTestSuite) TestDisableTOTP() {
+	defer suite.service.RequireTOTPForRoles(suite.cnf.TOTP.RequiredRoles...)
+
+	key, err := suite.service.EnrollTOTP(suite.users[0])
+	assert.NoError(suite.T(), err)
+	code, err := totp.GenerateCode(key.Secret(), time.Now())
+	assert.NoError(suite.T(), err)
+	_, err = suite.service.EnableTOTP(suite.users[0], code)
+	assert.NoError(suite.T(), err)
+
+	// Roles which require two-factor authentication cannot turn it off
+	suite.service.RequireTOTPForRoles(suite.users[0].RoleID)
+	assert.True(suite.T(), suite.service.IsTOTPRequired(suite.users[0]))
+	err = suite.service.DisableTOTP(suite.users[0], "test_password")
+	assert.Equal(suite.T(), oauth.ErrTOTPRequired, err)
+
+	suite.service.RequireTOTPForRoles(int32(model.SuperAdminRole))
+	assert.False(suite.T(), suite.service.IsTOTPRequired(suite.users[0]))
+
+	err = suite.service.DisableTOTP(suite.users[0], "bogus")
+	assert.Equal(suite.T(), oauth.ErrInvalidUserPassword, err)
+
+	err = suite.service.DisableTOTP(suite.users[0], "test_password")
+	assert.NoError(suite.T(), err)
+	assert.False(suite.T(), suite.service.IsTOTPEnabled(suite.users[0]))
+}
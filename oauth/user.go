@@ -86,6 +86,24 @@ func (s *Service) FindUserByUsername(username string) (*model.User, error) {
 	return user, nil
 }
 
+// FindUserByID looks up a user by ID
+func (s *Service) FindUserByID(id string) (*model.User, error) {
+	ctx := context.Background()
+	user := new(model.User)
+	err := s.db.NewSelect().
+		Model(user).
+		Where("id = ?", id).
+		Limit(1).
+		Scan(ctx)
+
+	// Not found
+	if err != nil {
+		return nil, ErrUserNotFound
+	}
+
+	return user, nil
+}
+
 func (s *Service) FindUserByEmail(email string) (*model.User, error) {
 	ctx := context.Background()
 	user := new(model.User)
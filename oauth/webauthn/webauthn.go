@@ -0,0 +1,567 @@
+// Package webauthn verifies WebAuthn (passkey) registration and authentication
+// responses (https://www.w3.org/TR/webauthn-2/). Attestation statements are not
+// verified, registration asks authenticators for "none" attestation.
+package webauthn
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"math/big"
+
+	"github.com/fxamacker/cbor/v2"
+)
+
+const (
+	// AlgES256 is the COSE identifier of ECDSA P-256 with SHA-256
+	AlgES256 = -7
+	// AlgEdDSA is the COSE identifier of Ed25519
+	AlgEdDSA = -8
+	// AlgRS256 is the COSE identifier of RSASSA-PKCS1-v1_5 with SHA-256
+	AlgRS256 = -257
+
+	// UserVerificationRequired makes authenticators verify the user, e.g. with a PIN or biometrics
+	UserVerificationRequired = "required"
+	// UserVerificationPreferred verifies the user when the authenticator supports it
+	UserVerificationPreferred = "preferred"
+	// UserVerificationDiscouraged only checks the user is present
+	UserVerificationDiscouraged = "discouraged"
+
+	// Timeout is how long (in milliseconds) the browser waits for the authenticator
+	Timeout = 300000
+
+	flagUserPresent            = 0x01
+	flagUserVerified           = 0x04
+	flagAttestedCredentialData = 0x40
+
+	coseKeyTypeOKP   = 1
+	coseKeyTypeEC2   = 2
+	coseKeyTypeRSA   = 3
+	coseCurveP256    = 1
+	coseCurveEd25519 = 6
+)
+
+var (
+	// ErrInvalidResponse ...
+	ErrInvalidResponse = errors.New("Invalid WebAuthn response")
+	// ErrChallengeMismatch ...
+	ErrChallengeMismatch = errors.New("WebAuthn challenge mismatch")
+	// ErrOriginNotAllowed ...
+	ErrOriginNotAllowed = errors.New("WebAuthn origin not allowed")
+	// ErrRelyingPartyMismatch ...
+	ErrRelyingPartyMismatch = errors.New("WebAuthn relying party mismatch")
+	// ErrUserNotPresent ...
+	ErrUserNotPresent = errors.New("User presence is required")
+	// ErrUserNotVerified ...
+	ErrUserNotVerified = errors.New("User verification is required")
+	// ErrUnsupportedAlgorithm ...
+	ErrUnsupportedAlgorithm = errors.New("Unsupported credential algorithm")
+	// ErrInvalidSignature ...
+	ErrInvalidSignature = errors.New("Invalid WebAuthn signature")
+	// ErrSignCountRegressed ...
+	ErrSignCountRegressed = errors.New("Credential sign count went backwards, it may have been cloned")
+)
+
+// RelyingParty is the website credentials are scoped to
+type RelyingParty struct {
+	// ID is the domain credentials are bound to, e.g. id.resonate.coop
+	ID   string
+	Name string
+	// Origins lists the origins ceremonies may run on, e.g. https://id.resonate.coop
+	Origins []string
+}
+
+// RelyingPartyEntity describes the relying party to the authenticator
+type RelyingPartyEntity struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+// UserEntity describes the user account a credential is created for
+type UserEntity struct {
+	// ID is the base64url encoded user handle
+	ID          string `json:"id"`
+	Name        string `json:"name"`
+	DisplayName string `json:"displayName"`
+}
+
+// CredentialParameter is a credential type and algorithm the relying party accepts
+type CredentialParameter struct {
+	Type string `json:"type"`
+	Alg  int    `json:"alg"`
+}
+
+// CredentialDescriptor identifies an existing credential
+type CredentialDescriptor struct {
+	Type string `json:"type"`
+	// ID is the base64url encoded credential ID
+	ID         string   `json:"id"`
+	Transports []string `json:"transports,omitempty"`
+}
+
+// AuthenticatorSelection states the authenticator features the relying party needs
+type AuthenticatorSelection struct {
+	ResidentKey        string `json:"residentKey,omitempty"`
+	RequireResidentKey bool   `json:"requireResidentKey"`
+	UserVerification   string `json:"userVerification,omitempty"`
+}
+
+// CreationOptions are passed to navigator.credentials.create as publicKey,
+// binary values are base64url encoded
+type CreationOptions struct {
+	Challenge              string                 `json:"challenge"`
+	RP                     RelyingPartyEntity     `json:"rp"`
+	User                   UserEntity             `json:"user"`
+	PubKeyCredParams       []CredentialParameter  `json:"pubKeyCredParams"`
+	Timeout                int                    `json:"timeout"`
+	ExcludeCredentials     []CredentialDescriptor `json:"excludeCredentials"`
+	AuthenticatorSelection AuthenticatorSelection `json:"authenticatorSelection"`
+	Attestation            string                 `json:"attestation"`
+}
+
+// RequestOptions are passed to navigator.credentials.get as publicKey,
+// binary values are base64url encoded
+type RequestOptions struct {
+	Challenge        string                 `json:"challenge"`
+	Timeout          int                    `json:"timeout"`
+	RPID             string                 `json:"rpId"`
+	AllowCredentials []CredentialDescriptor `json:"allowCredentials"`
+	UserVerification string                 `json:"userVerification"`
+}
+
+// AttestationResponse is the credential returned by navigator.credentials.create,
+// binary values are base64url encoded
+type AttestationResponse struct {
+	ID       string `json:"id"`
+	RawID    string `json:"rawId"`
+	Type     string `json:"type"`
+	Response struct {
+		ClientDataJSON    string   `json:"clientDataJSON"`
+		AttestationObject string   `json:"attestationObject"`
+		Transports        []string `json:"transports"`
+	} `json:"response"`
+}
+
+// AssertionResponse is the credential returned by navigator.credentials.get,
+// binary values are base64url encoded
+type AssertionResponse struct {
+	ID       string `json:"id"`
+	RawID    string `json:"rawId"`
+	Type     string `json:"type"`
+	Response struct {
+		ClientDataJSON    string `json:"clientDataJSON"`
+		AuthenticatorData string `json:"authenticatorData"`
+		Signature         string `json:"signature"`
+		UserHandle        string `json:"userHandle"`
+	} `json:"response"`
+}
+
+// Credential is a verified new public key credential
+type Credential struct {
+	ID []byte
+	// PublicKey is the COSE encoded public key
+	PublicKey    []byte
+	SignCount    uint32
+	UserVerified bool
+	Transports   []string
+}
+
+// clientData is the JSON the browser signs over
+type clientData struct {
+	Type      string `json:"type"`
+	Challenge string `json:"challenge"`
+	Origin    string `json:"origin"`
+}
+
+// attestationObject is the CBOR encoded result of a registration
+type attestationObject struct {
+	Format   string          `cbor:"fmt"`
+	AttStmt  cbor.RawMessage `cbor:"attStmt"`
+	AuthData []byte          `cbor:"authData"`
+}
+
+// authenticatorData is the binary data signed by the authenticator
+type authenticatorData struct {
+	RPIDHash            []byte
+	Flags               byte
+	SignCount           uint32
+	CredentialID        []byte
+	CredentialPublicKey []byte
+}
+
+// NewChallenge returns a random base64url encoded challenge
+func NewChallenge() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return encode(b), nil
+}
+
+// NewCreationOptions returns the options to register a credential for a user,
+// credentials the user already has are excluded
+func (rp *RelyingParty) NewCreationOptions(challenge string, userHandle []byte, name, displayName string, exclude []CredentialDescriptor) *CreationOptions {
+	if exclude == nil {
+		exclude = []CredentialDescriptor{}
+	}
+
+	return &CreationOptions{
+		Challenge: challenge,
+		RP: RelyingPartyEntity{
+			ID:   rp.ID,
+			Name: rp.Name,
+		},
+		User: UserEntity{
+			ID:          encode(userHandle),
+			Name:        name,
+			DisplayName: displayName,
+		},
+		PubKeyCredParams: []CredentialParameter{
+			{Type: "public-key", Alg: AlgES256},
+			{Type: "public-key", Alg: AlgEdDSA},
+			{Type: "public-key", Alg: AlgRS256},
+		},
+		Timeout:            Timeout,
+		ExcludeCredentials: exclude,
+		AuthenticatorSelection: AuthenticatorSelection{
+			ResidentKey:      "preferred",
+			UserVerification: UserVerificationPreferred,
+		},
+		Attestation: "none",
+	}
+}
+
+// NewRequestOptions returns the options to authenticate with one of the
+// allowed credentials, any discoverable credential if allow is empty
+func (rp *RelyingParty) NewRequestOptions(challenge string, allow []CredentialDescriptor, userVerification string) *RequestOptions {
+	if allow == nil {
+		allow = []CredentialDescriptor{}
+	}
+
+	return &RequestOptions{
+		Challenge:        challenge,
+		Timeout:          Timeout,
+		RPID:             rp.ID,
+		AllowCredentials: allow,
+		UserVerification: userVerification,
+	}
+}
+
+// VerifyRegistration checks the response of navigator.credentials.create to
+// the challenge and returns the new credential
+func (rp *RelyingParty) VerifyRegistration(response *AttestationResponse, challenge string, requireUserVerification bool) (*Credential, error) {
+	if _, err := rp.verifyClientData(response.Response.ClientDataJSON, "webauthn.create", challenge); err != nil {
+		return nil, err
+	}
+
+	rawAttestationObject, err := decode(response.Response.AttestationObject)
+	if err != nil {
+		return nil, ErrInvalidResponse
+	}
+
+	attestation := new(attestationObject)
+	if err := cbor.Unmarshal(rawAttestationObject, attestation); err != nil {
+		return nil, ErrInvalidResponse
+	}
+
+	authData, err := parseAuthenticatorData(attestation.AuthData)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := rp.verifyAuthenticatorData(authData, requireUserVerification); err != nil {
+		return nil, err
+	}
+
+	if authData.CredentialID == nil {
+		return nil, ErrInvalidResponse
+	}
+
+	// Make sure the key can be used to verify assertions
+	if _, _, err := parsePublicKey(authData.CredentialPublicKey); err != nil {
+		return nil, err
+	}
+
+	return &Credential{
+		ID:           authData.CredentialID,
+		PublicKey:    authData.CredentialPublicKey,
+		SignCount:    authData.SignCount,
+		UserVerified: authData.Flags&flagUserVerified != 0,
+		Transports:   response.Response.Transports,
+	}, nil
+}
+
+// VerifyAssertion checks the response of navigator.credentials.get to the
+// challenge was signed with the credential's public key, it returns the new
+// sign count to store
+func (rp *RelyingParty) VerifyAssertion(response *AssertionResponse, challenge string, publicKey []byte, signCount uint32, requireUserVerification bool) (uint32, error) {
+	rawClientData, err := rp.verifyClientData(response.Response.ClientDataJSON, "webauthn.get", challenge)
+	if err != nil {
+		return 0, err
+	}
+
+	rawAuthData, err := decode(response.Response.AuthenticatorData)
+	if err != nil {
+		return 0, ErrInvalidResponse
+	}
+
+	authData, err := parseAuthenticatorData(rawAuthData)
+	if err != nil {
+		return 0, err
+	}
+
+	if err := rp.verifyAuthenticatorData(authData, requireUserVerification); err != nil {
+		return 0, err
+	}
+
+	signature, err := decode(response.Response.Signature)
+	if err != nil {
+		return 0, ErrInvalidResponse
+	}
+
+	clientDataHash := sha256.Sum256(rawClientData)
+	signed := append(append([]byte{}, rawAuthData...), clientDataHash[:]...)
+
+	if err := verifySignature(publicKey, signed, signature); err != nil {
+		return 0, err
+	}
+
+	// Authenticators which count signatures must always count up
+	if (authData.SignCount != 0 || signCount != 0) && authData.SignCount <= signCount {
+		return 0, ErrSignCountRegressed
+	}
+
+	return authData.SignCount, nil
+}
+
+// CredentialID returns the decoded ID of the credential used
+func (a *AssertionResponse) CredentialID() ([]byte, error) {
+	id := a.RawID
+	if id == "" {
+		id = a.ID
+	}
+	return decode(id)
+}
+
+// UserHandle returns the decoded user handle, it is only sent for discoverable credentials
+func (a *AssertionResponse) UserHandle() ([]byte, error) {
+	return decode(a.Response.UserHandle)
+}
+
+// EncodeID base64url encodes a credential ID or user handle
+func EncodeID(id []byte) string {
+	return encode(id)
+}
+
+// DecodeID decodes a base64url encoded credential ID or user handle
+func DecodeID(id string) ([]byte, error) {
+	return decode(id)
+}
+
+// verifyClientData checks the type, challenge and origin of the client data
+// and returns the raw JSON
+func (rp *RelyingParty) verifyClientData(encoded, typ, challenge string) ([]byte, error) {
+	raw, err := decode(encoded)
+	if err != nil {
+		return nil, ErrInvalidResponse
+	}
+
+	data := new(clientData)
+	if err := json.Unmarshal(raw, data); err != nil {
+		return nil, ErrInvalidResponse
+	}
+
+	if data.Type != typ {
+		return nil, ErrInvalidResponse
+	}
+
+	if challenge == "" || subtle.ConstantTimeCompare([]byte(data.Challenge), []byte(challenge)) != 1 {
+		return nil, ErrChallengeMismatch
+	}
+
+	for _, origin := range rp.Origins {
+		if data.Origin == origin {
+			return raw, nil
+		}
+	}
+
+	return nil, ErrOriginNotAllowed
+}
+
+// verifyAuthenticatorData checks the data was created for this relying party
+// with the user present
+func (rp *RelyingParty) verifyAuthenticatorData(authData *authenticatorData, requireUserVerification bool) error {
+	rpIDHash := sha256.Sum256([]byte(rp.ID))
+	if !bytes.Equal(authData.RPIDHash, rpIDHash[:]) {
+		return ErrRelyingPartyMismatch
+	}
+
+	if authData.Flags&flagUserPresent == 0 {
+		return ErrUserNotPresent
+	}
+
+	if requireUserVerification && authData.Flags&flagUserVerified == 0 {
+		return ErrUserNotVerified
+	}
+
+	return nil
+}
+
+// parseAuthenticatorData parses the authenticator data, the attested credential
+// data is only present on registration
+func parseAuthenticatorData(data []byte) (*authenticatorData, error) {
+	if len(data) < 37 {
+		return nil, ErrInvalidResponse
+	}
+
+	authData := &authenticatorData{
+		RPIDHash:  data[:32],
+		Flags:     data[32],
+		SignCount: binary.BigEndian.Uint32(data[33:37]),
+	}
+
+	if authData.Flags&flagAttestedCredentialData == 0 {
+		return authData, nil
+	}
+
+	// AAGUID (16 bytes) followed by the credential ID length (2 bytes)
+	rest := data[37:]
+	if len(rest) < 18 {
+		return nil, ErrInvalidResponse
+	}
+
+	n := int(binary.BigEndian.Uint16(rest[16:18]))
+	rest = rest[18:]
+	if n == 0 || len(rest) < n {
+		return nil, ErrInvalidResponse
+	}
+
+	authData.CredentialID = rest[:n]
+	rest = rest[n:]
+
+	// The public key is followed by extensions, if any
+	var publicKey cbor.RawMessage
+	decoder := cbor.NewDecoder(bytes.NewReader(rest))
+	if err := decoder.Decode(&publicKey); err != nil {
+		return nil, ErrInvalidResponse
+	}
+
+	authData.CredentialPublicKey = rest[:decoder.NumBytesRead()]
+
+	return authData, nil
+}
+
+// parsePublicKey decodes a COSE encoded public key
+func parsePublicKey(coseKey []byte) (int64, crypto.PublicKey, error) {
+	var key map[int64]interface{}
+	if err := cbor.Unmarshal(coseKey, &key); err != nil {
+		return 0, nil, ErrInvalidResponse
+	}
+
+	kty, _ := coseInt(key[1])
+	alg, _ := coseInt(key[3])
+
+	switch {
+	case kty == coseKeyTypeEC2 && alg == AlgES256:
+		crv, _ := coseInt(key[-1])
+		x, _ := key[-2].([]byte)
+		y, _ := key[-3].([]byte)
+		if crv != coseCurveP256 || len(x) != 32 || len(y) != 32 {
+			return 0, nil, ErrInvalidResponse
+		}
+		pub := &ecdsa.PublicKey{
+			Curve: elliptic.P256(),
+			X:     new(big.Int).SetBytes(x),
+			Y:     new(big.Int).SetBytes(y),
+		}
+		if !pub.Curve.IsOnCurve(pub.X, pub.Y) {
+			return 0, nil, ErrInvalidResponse
+		}
+		return alg, pub, nil
+	case kty == coseKeyTypeOKP && alg == AlgEdDSA:
+		crv, _ := coseInt(key[-1])
+		x, _ := key[-2].([]byte)
+		if crv != coseCurveEd25519 || len(x) != ed25519.PublicKeySize {
+			return 0, nil, ErrInvalidResponse
+		}
+		return alg, ed25519.PublicKey(x), nil
+	case kty == coseKeyTypeRSA && alg == AlgRS256:
+		n, _ := key[-1].([]byte)
+		e, _ := key[-2].([]byte)
+		if len(n) == 0 || len(e) == 0 || len(e) > 4 {
+			return 0, nil, ErrInvalidResponse
+		}
+		return alg, &rsa.PublicKey{
+			N: new(big.Int).SetBytes(n),
+			E: int(new(big.Int).SetBytes(e).Int64()),
+		}, nil
+	default:
+		return 0, nil, ErrUnsupportedAlgorithm
+	}
+}
+
+// verifySignature verifies the signature of data with a COSE encoded public key
+func verifySignature(coseKey, data, signature []byte) error {
+	alg, publicKey, err := parsePublicKey(coseKey)
+	if err != nil {
+		return err
+	}
+
+	valid := false
+	hash := sha256.Sum256(data)
+
+	switch alg {
+	case AlgES256:
+		valid = ecdsa.VerifyASN1(publicKey.(*ecdsa.PublicKey), hash[:], signature)
+	case AlgEdDSA:
+		valid = ed25519.Verify(publicKey.(ed25519.PublicKey), data, signature)
+	case AlgRS256:
+		valid = rsa.VerifyPKCS1v15(publicKey.(*rsa.PublicKey), crypto.SHA256, hash[:], signature) == nil
+	}
+
+	if !valid {
+		return ErrInvalidSignature
+	}
+
+	return nil
+}
+
+// coseInt converts a decoded CBOR integer
+func coseInt(v interface{}) (int64, bool) {
+	switch i := v.(type) {
+	case int64:
+		return i, true
+	case uint64:
+		return int64(i), true
+	default:
+		return 0, false
+	}
+}
+
+// encode base64url encodes without padding, as browsers do
+func encode(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// decode base64url decodes with or without padding
+func decode(s string) ([]byte, error) {
+	return base64.RawURLEncoding.DecodeString(trimPadding(s))
+}
+
+// trimPadding removes base64 padding
+func trimPadding(s string) string {
+	for len(s) > 0 && s[len(s)-1] == '=' {
+		s = s[:len(s)-1]
+	}
+	return s
+}
@@ -0,0 +1,216 @@
+package webauthn_test
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"testing"
+
+	"github.com/fxamacker/cbor/v2"
+	"github.com/resonatecoop/id/oauth/webauthn"
+	"github.com/stretchr/testify/assert"
+)
+
+var rp = &webauthn.RelyingParty{
+	ID:      "id.resonate.coop",
+	Name:    "Resonate",
+	Origins: []string{"https://id.resonate.coop"},
+}
+
+// authenticator is a software authenticator signing with an ECDSA P-256 key
+type authenticator struct {
+	credentialID []byte
+	privateKey   *ecdsa.PrivateKey
+	signCount    uint32
+	flags        byte
+}
+
+func newAuthenticator(t *testing.T) *authenticator {
+	privateKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+
+	return &authenticator{
+		credentialID: []byte("test_credential"),
+		privateKey:   privateKey,
+		flags:        0x01 | 0x04, // user present and verified
+	}
+}
+
+func (a *authenticator) coseKey(t *testing.T) []byte {
+	key, err := cbor.Marshal(map[int]interface{}{
+		1:  2,  // EC2
+		3:  -7, // ES256
+		-1: 1,  // P-256
+		-2: a.privateKey.X.FillBytes(make([]byte, 32)),
+		-3: a.privateKey.Y.FillBytes(make([]byte, 32)),
+	})
+	assert.NoError(t, err)
+	return key
+}
+
+func (a *authenticator) authData(rpID string, attested []byte) []byte {
+	rpIDHash := sha256.Sum256([]byte(rpID))
+	data := append([]byte{}, rpIDHash[:]...)
+	flags := a.flags
+	if attested != nil {
+		flags |= 0x40
+	}
+	data = append(data, flags)
+	data = append(data, make([]byte, 4)...)
+	binary.BigEndian.PutUint32(data[33:], a.signCount)
+	return append(data, attested...)
+}
+
+func clientDataJSON(t *testing.T, typ, challenge, origin string) []byte {
+	data, err := json.Marshal(map[string]string{
+		"type":      typ,
+		"challenge": challenge,
+		"origin":    origin,
+	})
+	assert.NoError(t, err)
+	return data
+}
+
+func (a *authenticator) create(t *testing.T, challenge, origin string) *webauthn.AttestationResponse {
+	attested := make([]byte, 16) // AAGUID
+	attested = append(attested, byte(len(a.credentialID)>>8), byte(len(a.credentialID)))
+	attested = append(attested, a.credentialID...)
+	attested = append(attested, a.coseKey(t)...)
+
+	attestationObject, err := cbor.Marshal(map[string]interface{}{
+		"fmt":      "none",
+		"attStmt":  map[string]interface{}{},
+		"authData": a.authData(rp.ID, attested),
+	})
+	assert.NoError(t, err)
+
+	response := new(webauthn.AttestationResponse)
+	response.ID = base64.RawURLEncoding.EncodeToString(a.credentialID)
+	response.RawID = response.ID
+	response.Type = "public-key"
+	response.Response.ClientDataJSON = base64.RawURLEncoding.EncodeToString(clientDataJSON(t, "webauthn.create", challenge, origin))
+	response.Response.AttestationObject = base64.RawURLEncoding.EncodeToString(attestationObject)
+	return response
+}
+
+func (a *authenticator) get(t *testing.T, challenge, origin string) *webauthn.AssertionResponse {
+	a.signCount++
+
+	authData := a.authData(rp.ID, nil)
+	clientData := clientDataJSON(t, "webauthn.get", challenge, origin)
+	clientDataHash := sha256.Sum256(clientData)
+	hash := sha256.Sum256(append(append([]byte{}, authData...), clientDataHash[:]...))
+
+	signature, err := ecdsa.SignASN1(rand.Reader, a.privateKey, hash[:])
+	assert.NoError(t, err)
+
+	response := new(webauthn.AssertionResponse)
+	response.ID = base64.RawURLEncoding.EncodeToString(a.credentialID)
+	response.RawID = response.ID
+	response.Type = "public-key"
+	response.Response.ClientDataJSON = base64.RawURLEncoding.EncodeToString(clientData)
+	response.Response.AuthenticatorData = base64.RawURLEncoding.EncodeToString(authData)
+	response.Response.Signature = base64.RawURLEncoding.EncodeToString(signature)
+	response.Response.UserHandle = base64.RawURLEncoding.EncodeToString([]byte("test_user"))
+	return response
+}
+
+func TestRegistrationAndAssertion(t *testing.T) {
+	a := newAuthenticator(t)
+
+	challenge, err := webauthn.NewChallenge()
+	assert.NoError(t, err)
+
+	credential, err := rp.VerifyRegistration(a.create(t, challenge, "https://id.resonate.coop"), challenge, true)
+	assert.NoError(t, err)
+	assert.Equal(t, a.credentialID, credential.ID)
+	assert.True(t, credential.UserVerified)
+
+	challenge, err = webauthn.NewChallenge()
+	assert.NoError(t, err)
+
+	response := a.get(t, challenge, "https://id.resonate.coop")
+
+	credentialID, err := response.CredentialID()
+	assert.NoError(t, err)
+	assert.Equal(t, a.credentialID, credentialID)
+
+	userHandle, err := response.UserHandle()
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("test_user"), userHandle)
+
+	signCount, err := rp.VerifyAssertion(response, challenge, credential.PublicKey, credential.SignCount, true)
+	assert.NoError(t, err)
+	assert.Equal(t, uint32(1), signCount)
+
+	// A replayed assertion does not count up
+	_, err = rp.VerifyAssertion(response, challenge, credential.PublicKey, signCount, true)
+	assert.Equal(t, webauthn.ErrSignCountRegressed, err)
+}
+
+func TestRegistrationErrors(t *testing.T) {
+	a := newAuthenticator(t)
+
+	_, err := rp.VerifyRegistration(a.create(t, "challenge", "https://id.resonate.coop"), "other_challenge", false)
+	assert.Equal(t, webauthn.ErrChallengeMismatch, err)
+
+	_, err = rp.VerifyRegistration(a.create(t, "challenge", "https://evil.example"), "challenge", false)
+	assert.Equal(t, webauthn.ErrOriginNotAllowed, err)
+
+	otherRP := &webauthn.RelyingParty{ID: "evil.example", Origins: rp.Origins}
+	_, err = otherRP.VerifyRegistration(a.create(t, "challenge", "https://id.resonate.coop"), "challenge", false)
+	assert.Equal(t, webauthn.ErrRelyingPartyMismatch, err)
+
+	a.flags = 0x01 // user present but not verified
+	_, err = rp.VerifyRegistration(a.create(t, "challenge", "https://id.resonate.coop"), "challenge", true)
+	assert.Equal(t, webauthn.ErrUserNotVerified, err)
+
+	_, err = rp.VerifyRegistration(a.create(t, "challenge", "https://id.resonate.coop"), "challenge", false)
+	assert.NoError(t, err)
+}
+
+func TestAssertionWithWrongKey(t *testing.T) {
+	a := newAuthenticator(t)
+	other := newAuthenticator(t)
+
+	_, err := rp.VerifyAssertion(a.get(t, "challenge", "https://id.resonate.coop"), "challenge", other.coseKey(t), 0, false)
+	assert.Equal(t, webauthn.ErrInvalidSignature, err)
+}
+
+func TestEd25519Assertion(t *testing.T) {
+	publicKey, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	assert.NoError(t, err)
+
+	coseKey, err := cbor.Marshal(map[int]interface{}{
+		1:  1,  // OKP
+		3:  -8, // EdDSA
+		-1: 6,  // Ed25519
+		-2: []byte(publicKey),
+	})
+	assert.NoError(t, err)
+
+	rpIDHash := sha256.Sum256([]byte(rp.ID))
+	authData := append(append([]byte{}, rpIDHash[:]...), 0x01, 0, 0, 0, 0)
+	clientData := clientDataJSON(t, "webauthn.get", "challenge", "https://id.resonate.coop")
+	clientDataHash := sha256.Sum256(clientData)
+
+	response := new(webauthn.AssertionResponse)
+	response.Response.ClientDataJSON = base64.RawURLEncoding.EncodeToString(clientData)
+	response.Response.AuthenticatorData = base64.RawURLEncoding.EncodeToString(authData)
+	response.Response.Signature = base64.RawURLEncoding.EncodeToString(
+		ed25519.Sign(privateKey, append(append([]byte{}, authData...), clientDataHash[:]...)),
+	)
+
+	// Authenticators without a signature counter always report 0
+	signCount, err := rp.VerifyAssertion(response, "challenge", coseKey, 0, false)
+	assert.NoError(t, err)
+	assert.Equal(t, uint32(0), signCount)
+
+	_, err = rp.VerifyAssertion(response, "challenge", coseKey, 0, true)
+	assert.Equal(t, webauthn.ErrUserNotVerified, err)
+}
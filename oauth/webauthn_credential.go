@@ -0,0 +1,293 @@
+package oauth
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/resonatecoop/id/oauth/webauthn"
+	"github.com/resonatecoop/user-api/model"
+	"github.com/uptrace/bun"
+)
+
+var (
+	// ErrWebAuthnCredentialNotFound ...
+	ErrWebAuthnCredentialNotFound = errors.New("Passkey not found")
+	// ErrWebAuthnCredentialExists ...
+	ErrWebAuthnCredentialExists = errors.New("Passkey is already registered")
+)
+
+// WebAuthnCredential is a passkey or security key registered by a user
+type WebAuthnCredential struct {
+	bun.BaseModel `bun:"table:webauthn_credentials"`
+
+	// ID is the base64url encoded credential ID
+	ID     string    `bun:"type:varchar(1400),pk"`
+	UserID uuid.UUID `bun:"type:uuid,notnull"`
+	Name   string    `bun:"type:varchar(100),notnull"`
+	// PublicKey is COSE encoded
+	PublicKey    []byte    `bun:"type:bytea,notnull"`
+	SignCount    int64     `bun:",notnull,default:0"`
+	Transports   string    `bun:"type:varchar(255)"`
+	UserVerified bool      `bun:",notnull,default:false"`
+	CreatedAt    time.Time `bun:",nullzero,notnull,default:current_timestamp"`
+	LastUsedAt   time.Time `bun:",nullzero"`
+}
+
+// BeginWebAuthnRegistration returns the options to register a new passkey
+// for the user, the challenge must be passed to FinishWebAuthnRegistration
+func (s *Service) BeginWebAuthnRegistration(user *model.User) (*webauthn.CreationOptions, error) {
+	challenge, err := webauthn.NewChallenge()
+	if err != nil {
+		return nil, err
+	}
+
+	credentials, err := s.FindWebAuthnCredentials(user)
+	if err != nil {
+		return nil, err
+	}
+
+	userHandle, err := user.ID.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+
+	displayName := user.FullName
+	if displayName == "" {
+		displayName = user.Username
+	}
+
+	return s.webAuthnRelyingParty().NewCreationOptions(
+		challenge,
+		userHandle,
+		user.Username,
+		displayName,
+		credentialDescriptors(credentials),
+	), nil
+}
+
+// FinishWebAuthnRegistration verifies the response of the browser and stores the new passkey
+func (s *Service) FinishWebAuthnRegistration(user *model.User, name, challenge string, response *webauthn.AttestationResponse) (*WebAuthnCredential, error) {
+	credential, err := s.webAuthnRelyingParty().VerifyRegistration(response, challenge, false)
+	if err != nil {
+		return nil, err
+	}
+
+	name = strings.TrimSpace(name)
+	if name == "" {
+		name = "Passkey"
+	}
+
+	webAuthnCredential := &WebAuthnCredential{
+		ID:           webauthn.EncodeID(credential.ID),
+		UserID:       user.ID,
+		Name:         truncate(name, 100),
+		PublicKey:    credential.PublicKey,
+		SignCount:    int64(credential.SignCount),
+		Transports:   truncate(strings.Join(credential.Transports, ","), 255),
+		UserVerified: credential.UserVerified,
+		CreatedAt:    time.Now().UTC(),
+	}
+
+	ctx := context.Background()
+
+	exists, err := s.db.NewSelect().
+		Model((*WebAuthnCredential)(nil)).
+		Where("id = ?", webAuthnCredential.ID).
+		Exists(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if exists {
+		return nil, ErrWebAuthnCredentialExists
+	}
+
+	_, err = s.db.NewInsert().
+		Model(webAuthnCredential).
+		Exec(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return webAuthnCredential, nil
+}
+
+// BeginWebAuthnLogin returns the options to log in with a passkey. With a
+// user the passkey is a second factor after the password, without one any
+// passkey stored on the device can be used and the user must be verified.
+func (s *Service) BeginWebAuthnLogin(user *model.User) (*webauthn.RequestOptions, error) {
+	challenge, err := webauthn.NewChallenge()
+	if err != nil {
+		return nil, err
+	}
+
+	if user == nil {
+		return s.webAuthnRelyingParty().NewRequestOptions(
+			challenge,
+			nil,
+			webauthn.UserVerificationRequired,
+		), nil
+	}
+
+	credentials, err := s.FindWebAuthnCredentials(user)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(credentials) == 0 {
+		return nil, ErrWebAuthnCredentialNotFound
+	}
+
+	return s.webAuthnRelyingParty().NewRequestOptions(
+		challenge,
+		credentialDescriptors(credentials),
+		webauthn.UserVerificationPreferred,
+	), nil
+}
+
+// FinishWebAuthnLogin verifies the response of the browser and returns the
+// user the passkey belongs to, user is the one passed to BeginWebAuthnLogin
+func (s *Service) FinishWebAuthnLogin(user *model.User, challenge string, response *webauthn.AssertionResponse) (*model.User, error) {
+	credentialID, err := response.CredentialID()
+	if err != nil {
+		return nil, ErrWebAuthnCredentialNotFound
+	}
+
+	ctx := context.Background()
+
+	webAuthnCredential := new(WebAuthnCredential)
+	err = s.db.NewSelect().
+		Model(webAuthnCredential).
+		Where("id = ?", webauthn.EncodeID(credentialID)).
+		Limit(1).
+		Scan(ctx)
+	if err != nil {
+		return nil, ErrWebAuthnCredentialNotFound
+	}
+
+	if user != nil && webAuthnCredential.UserID != user.ID {
+		return nil, ErrWebAuthnCredentialNotFound
+	}
+
+	// Discoverable credentials also return the user handle they were created for
+	if userHandle, err := response.UserHandle(); err == nil && len(userHandle) > 0 {
+		if userID, err := uuid.FromBytes(userHandle); err != nil || userID != webAuthnCredential.UserID {
+			return nil, ErrWebAuthnCredentialNotFound
+		}
+	}
+
+	signCount, err := s.webAuthnRelyingParty().VerifyAssertion(
+		response,
+		challenge,
+		webAuthnCredential.PublicKey,
+		uint32(webAuthnCredential.SignCount),
+		user == nil,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = s.db.NewUpdate().
+		Model(webAuthnCredential).
+		Set("sign_count = ?", int64(signCount)).
+		Set("last_used_at = ?", time.Now().UTC()).
+		WherePK().
+		Exec(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if user != nil {
+		return user, nil
+	}
+
+	return s.FindUserByID(webAuthnCredential.UserID.String())
+}
+
+// FindWebAuthnCredentials returns the passkeys of the user, oldest first
+func (s *Service) FindWebAuthnCredentials(user *model.User) ([]*WebAuthnCredential, error) {
+	var credentials []*WebAuthnCredential
+
+	err := s.db.NewSelect().
+		Model(&credentials).
+		Where("user_id = ?", user.ID).
+		Order("created_at ASC").
+		Scan(context.Background())
+
+	if err != nil {
+		return nil, err
+	}
+
+	return credentials, nil
+}
+
+// HasWebAuthnCredentials returns true if the user registered a passkey
+func (s *Service) HasWebAuthnCredentials(user *model.User) bool {
+	exists, err := s.db.NewSelect().
+		Model((*WebAuthnCredential)(nil)).
+		Where("user_id = ?", user.ID).
+		Exists(context.Background())
+
+	return err == nil && exists
+}
+
+// DeleteWebAuthnCredential removes a passkey of the user
+func (s *Service) DeleteWebAuthnCredential(user *model.User, id string) error {
+	res, err := s.db.NewDelete().
+		Model((*WebAuthnCredential)(nil)).
+		Where("id = ?", id).
+		Where("user_id = ?", user.ID).
+		Exec(context.Background())
+	if err != nil {
+		return err
+	}
+
+	if n, _ := res.RowsAffected(); n != 1 {
+		return ErrWebAuthnCredentialNotFound
+	}
+
+	return nil
+}
+
+// webAuthnRelyingParty returns the relying party passkeys are registered with
+func (s *Service) webAuthnRelyingParty() *webauthn.RelyingParty {
+	rp := &webauthn.RelyingParty{
+		ID:      s.cnf.WebAuthn.RPID,
+		Name:    s.cnf.WebAuthn.RPName,
+		Origins: s.cnf.WebAuthn.Origins,
+	}
+
+	if rp.ID == "" {
+		rp.ID = s.cnf.Hostname
+	}
+
+	if rp.Name == "" {
+		rp.Name = rp.ID
+	}
+
+	if len(rp.Origins) == 0 {
+		rp.Origins = []string{"https://" + rp.ID}
+	}
+
+	return rp
+}
+
+// credentialDescriptors lists passkeys for the browser
+func credentialDescriptors(credentials []*WebAuthnCredential) []webauthn.CredentialDescriptor {
+	descriptors := make([]webauthn.CredentialDescriptor, len(credentials))
+
+	for i, credential := range credentials {
+		descriptors[i] = webauthn.CredentialDescriptor{
+			Type: "public-key",
+			ID:   credential.ID,
+		}
+		if credential.Transports != "" {
+			descriptors[i].Transports = strings.Split(credential.Transports, ",")
+		}
+	}
+
+	return descriptors
+}
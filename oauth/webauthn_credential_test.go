@@ -0,0 +1,47 @@
+package oauth_test
+
+import (
+	"context"
+	"time"
+
+	"github.com/resonatecoop/id/oauth"
+	"github.com/stretchr/testify/assert"
+)
+
+func (suite *OauthTestSuite) TestWebAuthnCredentials() {
+	assert.False(suite.T(), suite.service.HasWebAuthnCredentials(suite.users[0]))
+
+	_, err := suite.service.BeginWebAuthnLogin(suite.users[0])
+	assert.Equal(suite.T(), oauth.ErrWebAuthnCredentialNotFound, err)
+
+	_, err = suite.db.NewInsert().
+		Model(&oauth.WebAuthnCredential{
+			ID:        "test_credential",
+			UserID:    suite.users[0].ID,
+			Name:      "Laptop",
+			PublicKey: []byte{0xa0},
+			CreatedAt: time.Now().UTC(),
+		}).
+		Exec(context.Background())
+	assert.NoError(suite.T(), err)
+
+	assert.True(suite.T(), suite.service.HasWebAuthnCredentials(suite.users[0]))
+	assert.False(suite.T(), suite.service.HasWebAuthnCredentials(suite.users[1]))
+
+	options, err := suite.service.BeginWebAuthnLogin(suite.users[0])
+	assert.NoError(suite.T(), err)
+	assert.Len(suite.T(), options.AllowCredentials, 1)
+
+	credentials, err := suite.service.FindWebAuthnCredentials(suite.users[0])
+	assert.NoError(suite.T(), err)
+	assert.Len(suite.T(), credentials, 1)
+	assert.Equal(suite.T(), "Laptop", credentials[0].Name)
+
+	// Users cannot remove passkeys of other users
+	err = suite.service.DeleteWebAuthnCredential(suite.users[1], "test_credential")
+	assert.Equal(suite.T(), oauth.ErrWebAuthnCredentialNotFound, err)
+
+	err = suite.service.DeleteWebAuthnCredential(suite.users[0], "test_credential")
+	assert.NoError(suite.T(), err)
+	assert.False(suite.T(), suite.service.HasWebAuthnCredentials(suite.users[0]))
+}
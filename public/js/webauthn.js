@@ -0,0 +1,119 @@
+/* Passkey login and registration for the server rendered pages */
+(function () {
+  'use strict'
+
+  if (!window.PublicKeyCredential) return
+
+  function decode (value) {
+    var base64 = value.replace(/-/g, '+').replace(/_/g, '/')
+    var binary = window.atob(base64 + '==='.slice((base64.length + 3) % 4))
+    var bytes = new Uint8Array(binary.length)
+    for (var i = 0; i < binary.length; i++) bytes[i] = binary.charCodeAt(i)
+    return bytes.buffer
+  }
+
+  function encode (buffer) {
+    if (!buffer) return ''
+    var bytes = new Uint8Array(buffer)
+    var binary = ''
+    for (var i = 0; i < bytes.length; i++) binary += String.fromCharCode(bytes[i])
+    return window.btoa(binary).replace(/\+/g, '-').replace(/\//g, '_').replace(/=+$/, '')
+  }
+
+  function csrfToken () {
+    var input = document.querySelector('input[name="gorilla.csrf.Token"]')
+    return input ? input.value : ''
+  }
+
+  function post (url, body) {
+    return window.fetch(url, {
+      method: 'POST',
+      credentials: 'same-origin',
+      headers: {
+        Accept: 'application/json',
+        'Content-Type': 'application/json',
+        'X-CSRF-Token': csrfToken()
+      },
+      body: JSON.stringify(body || {})
+    }).then(function (res) {
+      return res.json().then(function (json) {
+        if (!res.ok) throw new Error(json.error || json.message || res.statusText)
+        return json
+      })
+    })
+  }
+
+  function showError (el, err) {
+    var container = el.closest('form') || el.parentNode.parentNode
+    var message = container.querySelector('[data-webauthn-error]')
+    if (message) message.textContent = err.message
+  }
+
+  function login (el) {
+    return post(el.dataset.optionsAction).then(function (json) {
+      var options = json.data.publicKey
+      options.challenge = decode(options.challenge)
+      options.allowCredentials = (options.allowCredentials || []).map(function (c) {
+        return Object.assign({}, c, { id: decode(c.id) })
+      })
+      return navigator.credentials.get({ publicKey: options })
+    }).then(function (credential) {
+      return post(el.dataset.action, {
+        id: credential.id,
+        rawId: encode(credential.rawId),
+        type: credential.type,
+        response: {
+          clientDataJSON: encode(credential.response.clientDataJSON),
+          authenticatorData: encode(credential.response.authenticatorData),
+          signature: encode(credential.response.signature),
+          userHandle: encode(credential.response.userHandle)
+        }
+      })
+    })
+  }
+
+  function register (form) {
+    return post(form.dataset.optionsAction).then(function (json) {
+      var options = json.data.publicKey
+      options.challenge = decode(options.challenge)
+      options.user.id = decode(options.user.id)
+      options.excludeCredentials = (options.excludeCredentials || []).map(function (c) {
+        return Object.assign({}, c, { id: decode(c.id) })
+      })
+      return navigator.credentials.create({ publicKey: options })
+    }).then(function (credential) {
+      var response = credential.response
+      return post(form.action, {
+        name: form.elements.name.value,
+        credential: {
+          id: credential.id,
+          rawId: encode(credential.rawId),
+          type: credential.type,
+          response: {
+            clientDataJSON: encode(response.clientDataJSON),
+            attestationObject: encode(response.attestationObject),
+            transports: response.getTransports ? response.getTransports() : []
+          }
+        }
+      })
+    })
+  }
+
+  function done (json) {
+    window.location.assign(json.data.success_redirect_url)
+  }
+
+  document.addEventListener('click', function (e) {
+    var el = e.target.closest('[data-webauthn="login"]')
+    if (!el) return
+    e.preventDefault()
+    login(el).then(done).catch(function (err) { showError(el, err) })
+  })
+
+  document.addEventListener('submit', function (e) {
+    var form = e.target.closest('[data-webauthn="register"]')
+    if (!form) return
+    e.preventDefault()
+    register(form).then(done).catch(function (err) { showError(form, err) })
+  })
+})()
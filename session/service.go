@@ -4,6 +4,7 @@ import (
 	"encoding/gob"
 	"errors"
 	"net/http"
+	"time"
 
 	//"github.com/resonatecoop/id/config"
 	"github.com/gorilla/sessions"
@@ -43,19 +44,43 @@ type UserSession struct {
 	RefreshToken string
 }
 
+// PendingLogin has user data stored in a session between checking the
+// password and the second login factor
+type PendingLogin struct {
+	ClientID  string
+	Username  string
+	ExpiresAt time.Time
+}
+
+// WebAuthnChallenge is the challenge of a passkey ceremony in progress
+type WebAuthnChallenge struct {
+	Challenge string
+	ExpiresAt time.Time
+}
+
 var (
 	// StorageSessionName ...
 	StorageSessionName = "go_oauth2_server_session"
 	// UserSessionKey ...
 	UserSessionKey = "go_oauth2_server_user"
+	// PendingLoginKey ...
+	PendingLoginKey = "go_oauth2_server_pending_login"
+	// WebAuthnChallengeKey ...
+	WebAuthnChallengeKey = "go_oauth2_server_webauthn_challenge"
 	// ErrSessonNotStarted ...
 	ErrSessonNotStarted = errors.New("Session not started")
+	// ErrPendingLoginExpired ...
+	ErrPendingLoginExpired = errors.New("Login expired, please log in again")
+	// ErrWebAuthnChallengeExpired ...
+	ErrWebAuthnChallengeExpired = errors.New("Passkey request expired, please try again")
 )
 
 func init() {
 	gob.Register(new(Flash))
 	// Register a new datatype for storage in sessions
 	gob.Register(new(UserSession))
+	gob.Register(new(PendingLogin))
+	gob.Register(new(WebAuthnChallenge))
 }
 
 // NewService returns a new Service instance
@@ -130,6 +155,80 @@ func (s *Service) ClearUserSession() error {
 	return s.session.Save(s.r, s.w)
 }
 
+// GetPendingLogin returns the pending login
+func (s *Service) GetPendingLogin() (*PendingLogin, error) {
+	// Make sure StartSession has been called
+	if s.session == nil {
+		return nil, ErrSessonNotStarted
+	}
+
+	pendingLogin, ok := s.session.Values[PendingLoginKey].(*PendingLogin)
+	if !ok || time.Now().After(pendingLogin.ExpiresAt) {
+		return nil, ErrPendingLoginExpired
+	}
+
+	return pendingLogin, nil
+}
+
+// SetPendingLogin saves the pending login
+func (s *Service) SetPendingLogin(pendingLogin *PendingLogin) error {
+	// Make sure StartSession has been called
+	if s.session == nil {
+		return ErrSessonNotStarted
+	}
+
+	s.session.Values[PendingLoginKey] = pendingLogin
+	return s.session.Save(s.r, s.w)
+}
+
+// ClearPendingLogin deletes the pending login
+func (s *Service) ClearPendingLogin() error {
+	// Make sure StartSession has been called
+	if s.session == nil {
+		return ErrSessonNotStarted
+	}
+
+	delete(s.session.Values, PendingLoginKey)
+	return s.session.Save(s.r, s.w)
+}
+
+// GetWebAuthnChallenge returns the challenge of the passkey ceremony in progress
+func (s *Service) GetWebAuthnChallenge() (*WebAuthnChallenge, error) {
+	// Make sure StartSession has been called
+	if s.session == nil {
+		return nil, ErrSessonNotStarted
+	}
+
+	webAuthnChallenge, ok := s.session.Values[WebAuthnChallengeKey].(*WebAuthnChallenge)
+	if !ok || time.Now().After(webAuthnChallenge.ExpiresAt) {
+		return nil, ErrWebAuthnChallengeExpired
+	}
+
+	return webAuthnChallenge, nil
+}
+
+// SetWebAuthnChallenge saves the challenge of a passkey ceremony
+func (s *Service) SetWebAuthnChallenge(webAuthnChallenge *WebAuthnChallenge) error {
+	// Make sure StartSession has been called
+	if s.session == nil {
+		return ErrSessonNotStarted
+	}
+
+	s.session.Values[WebAuthnChallengeKey] = webAuthnChallenge
+	return s.session.Save(s.r, s.w)
+}
+
+// ClearWebAuthnChallenge deletes the challenge so it cannot be used again
+func (s *Service) ClearWebAuthnChallenge() error {
+	// Make sure StartSession has been called
+	if s.session == nil {
+		return ErrSessonNotStarted
+	}
+
+	delete(s.session.Values, WebAuthnChallengeKey)
+	return s.session.Save(s.r, s.w)
+}
+
 // SetFlashMessage sets a flash message,
 // useful for displaying an error after 302 redirection
 func (s *Service) SetFlashMessage(flash *Flash) error {
@@ -9,6 +9,12 @@ type ServiceInterface interface {
 	GetUserSession() (*UserSession, error)
 	SetUserSession(userSession *UserSession) error
 	ClearUserSession() error
+	GetPendingLogin() (*PendingLogin, error)
+	SetPendingLogin(pendingLogin *PendingLogin) error
+	ClearPendingLogin() error
+	GetWebAuthnChallenge() (*WebAuthnChallenge, error)
+	SetWebAuthnChallenge(webAuthnChallenge *WebAuthnChallenge) error
+	ClearWebAuthnChallenge() error
 	SetFlashMessage(flash *Flash) error
 	GetFlashMessage() (interface{}, error)
 	Close()
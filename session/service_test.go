@@ -1,6 +1,8 @@
 package session_test
 
 import (
+	"time"
+
 	"github.com/resonatecoop/id/session"
 	"github.com/stretchr/testify/assert"
 )
@@ -62,3 +64,40 @@ func (suite *SessionTestSuite) TestService() {
 		assert.Equal(suite.T(), "User session type assertion error", err.Error())
 	}
 }
+
+func (suite *SessionTestSuite) TestPendingLogin() {
+	err := suite.service.StartSession()
+	assert.Nil(suite.T(), err)
+
+	// No pending login yet
+	pendingLogin, err := suite.service.GetPendingLogin()
+	assert.Nil(suite.T(), pendingLogin)
+	assert.Equal(suite.T(), session.ErrPendingLoginExpired, err)
+
+	err = suite.service.SetPendingLogin(&session.PendingLogin{
+		ClientID:  "test_client",
+		Username:  "test@username",
+		ExpiresAt: time.Now().Add(time.Minute),
+	})
+	assert.Nil(suite.T(), err)
+
+	pendingLogin, err = suite.service.GetPendingLogin()
+	assert.Nil(suite.T(), err)
+	if assert.NotNil(suite.T(), pendingLogin) {
+		assert.Equal(suite.T(), "test@username", pendingLogin.Username)
+	}
+
+	// Expired pending logins are not returned
+	err = suite.service.SetPendingLogin(&session.PendingLogin{
+		ClientID:  "test_client",
+		Username:  "test@username",
+		ExpiresAt: time.Now().Add(-time.Minute),
+	})
+	assert.Nil(suite.T(), err)
+
+	_, err = suite.service.GetPendingLogin()
+	assert.Equal(suite.T(), session.ErrPendingLoginExpired, err)
+
+	err = suite.service.ClearPendingLogin()
+	assert.Nil(suite.T(), err)
+}
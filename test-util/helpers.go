@@ -71,6 +71,15 @@ func TestResponseForError(t *testing.T, w *httptest.ResponseRecorder, msg string
 	TestResponseBody(t, w, getErrorJSON(msg))
 }
 
+// TestResponseForOauthError tests a response w to see if it returned an
+// RFC 6749 error response with the error code, description and http code
+func TestResponseForOauthError(t *testing.T, w *httptest.ResponseRecorder, errorCode, description string, code int) {
+	TestResponseObject(t, w, &response.OauthErrorResponse{
+		Error:            errorCode,
+		ErrorDescription: description,
+	}, code)
+}
+
 // TestEmptyResponse tests an empty 204 response
 func TestEmptyResponse(t *testing.T, w *httptest.ResponseRecorder) {
 	assert.Equal(t, 204, w.Code)
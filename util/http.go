@@ -3,6 +3,7 @@ package util
 import (
 	"errors"
 	"fmt"
+	"net"
 	"net/http"
 	"strings"
 )
@@ -19,6 +20,18 @@ func ParseBearerToken(r *http.Request) ([]byte, error) {
 	return []byte(bearerToken), nil
 }
 
+// ParseDPoPToken parses DPoP token from Authorization header (RFC 9449)
+func ParseDPoPToken(r *http.Request) ([]byte, error) {
+	auth := r.Header.Get("Authorization")
+
+	if !strings.HasPrefix(auth, "DPoP ") {
+		return nil, errors.New("DPoP token not found")
+	}
+
+	dpopToken := strings.TrimPrefix(auth, "DPoP ")
+	return []byte(dpopToken), nil
+}
+
 // GetCurrentURL returns the current request URL
 func GetCurrentURL(r *http.Request) string {
 	url := r.URL.Path
@@ -28,3 +41,54 @@ func GetCurrentURL(r *http.Request) string {
 	}
 	return url
 }
+
+// GetClientIP returns the IP address the request came from. The
+// X-Forwarded-For header is only honoured for requests from one of the
+// trusted proxies (IP addresses or CIDR ranges), the client is the last
+// address not added by a trusted proxy.
+func GetClientIP(r *http.Request, trustedProxies []string) string {
+	ip, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		ip = r.RemoteAddr
+	}
+
+	if !isTrustedProxy(ip, trustedProxies) {
+		return ip
+	}
+
+	addrs := strings.Split(r.Header.Get("X-Forwarded-For"), ",")
+	for i := len(addrs) - 1; i >= 0; i-- {
+		addr := strings.TrimSpace(addrs[i])
+		if addr == "" {
+			continue
+		}
+		ip = addr
+		if !isTrustedProxy(ip, trustedProxies) {
+			break
+		}
+	}
+
+	return ip
+}
+
+// isTrustedProxy returns true if the IP address is one of the trusted proxies
+func isTrustedProxy(ip string, trustedProxies []string) bool {
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return false
+	}
+
+	for _, proxy := range trustedProxies {
+		if _, network, err := net.ParseCIDR(proxy); err == nil {
+			if network.Contains(parsed) {
+				return true
+			}
+			continue
+		}
+		if proxyIP := net.ParseIP(proxy); proxyIP != nil && proxyIP.Equal(parsed) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -39,3 +39,41 @@ func TestParseBearerToken(t *testing.T) {
 		assert.Equal(t, []byte("test_token"), token)
 	}
 }
+
+func TestParseDPoPToken(t *testing.T) {
+	r, err := http.NewRequest("GET", "http://1.2.3.4/something", nil)
+	assert.NoError(t, err, "Request setup should not get an error")
+	r.Header.Add("Authorization", "Bearer test_token")
+
+	_, err = util.ParseDPoPToken(r)
+	if assert.NotNil(t, err) {
+		assert.Equal(t, "DPoP token not found", err.Error())
+	}
+
+	r.Header.Set("Authorization", "DPoP test_token")
+
+	token, err := util.ParseDPoPToken(r)
+	assert.Nil(t, err)
+	assert.Equal(t, []byte("test_token"), token)
+}
+
+func TestGetClientIP(t *testing.T) {
+	r, err := http.NewRequest("GET", "http://1.2.3.4/something", nil)
+	assert.NoError(t, err, "Request setup should not get an error")
+	r.RemoteAddr = "10.0.0.1:51234"
+
+	assert.Equal(t, "10.0.0.1", util.GetClientIP(r, nil))
+
+	// The header is ignored unless the request comes from a trusted proxy
+	r.Header.Set("X-Forwarded-For", "198.51.100.1, 203.0.113.7")
+	assert.Equal(t, "10.0.0.1", util.GetClientIP(r, nil))
+	assert.Equal(t, "10.0.0.1", util.GetClientIP(r, []string{"10.0.0.2"}))
+
+	// Addresses spoofed by the client are skipped
+	assert.Equal(t, "203.0.113.7", util.GetClientIP(r, []string{"10.0.0.1"}))
+	assert.Equal(t, "203.0.113.7", util.GetClientIP(r, []string{"10.0.0.0/8"}))
+
+	// As are other trusted proxies on the way
+	r.Header.Set("X-Forwarded-For", "198.51.100.1, 203.0.113.7, 10.0.0.3")
+	assert.Equal(t, "203.0.113.7", util.GetClientIP(r, []string{"10.0.0.0/8"}))
+}
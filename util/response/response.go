@@ -36,6 +36,27 @@ func Error(w http.ResponseWriter, err string, code int) {
 	}
 }
 
+// OauthErrorResponse is an error response of RFC 6749 section 5.2
+type OauthErrorResponse struct {
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description,omitempty"`
+	ErrorURI         string `json:"error_uri,omitempty"`
+}
+
+// OauthError produces a JSON error response with the following structure:
+// {"error":"invalid_grant","error_description":"some error message"}
+// Unauthorized clients are asked to authenticate with basic auth
+func OauthError(w http.ResponseWriter, code, description, uri string, status int) {
+	if status == http.StatusUnauthorized {
+		w.Header().Set("WWW-Authenticate", fmt.Sprintf("Basic realm=%s", realm))
+	}
+	WriteJSON(w, &OauthErrorResponse{
+		Error:            code,
+		ErrorDescription: description,
+		ErrorURI:         uri,
+	}, status)
+}
+
 // UnauthorizedError has to contain WWW-Authenticate header
 // See http://self-issued.info/docs/draft-ietf-oauth-v2-bearer.html#rfc.section.3
 func UnauthorizedError(w http.ResponseWriter, err string) {
@@ -43,3 +64,11 @@ func UnauthorizedError(w http.ResponseWriter, err string) {
 	w.Header().Set("WWW-Authenticate", fmt.Sprintf("Bearer realm=%s", realm))
 	Error(w, err, http.StatusUnauthorized)
 }
+
+// InvalidTokenError is an UnauthorizedError for requests with an expired,
+// revoked or otherwise invalid access token
+// See https://tools.ietf.org/html/rfc6750#section-3.1
+func InvalidTokenError(w http.ResponseWriter, err string) {
+	w.Header().Set("WWW-Authenticate", fmt.Sprintf("Bearer realm=%s, error=\"invalid_token\"", realm))
+	Error(w, err, http.StatusUnauthorized)
+}
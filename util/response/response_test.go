@@ -41,3 +41,32 @@ func TestError(t *testing.T) {
 	expected := "{\"error\":\"something went wrong\"}"
 	assert.Equal(t, expected, strings.TrimSpace(w.Body.String()))
 }
+
+func TestOauthError(t *testing.T) {
+	w := httptest.NewRecorder()
+	response.OauthError(w, "invalid_grant", "Refresh token expired", "", 400)
+
+	assert.Equal(t, 400, w.Code)
+	assert.Equal(t, "", w.Header().Get("WWW-Authenticate"))
+	expected := "{\"error\":\"invalid_grant\",\"error_description\":\"Refresh token expired\"}"
+	assert.Equal(t, expected, strings.TrimSpace(w.Body.String()))
+
+	// Unauthorized clients are asked to authenticate
+	w = httptest.NewRecorder()
+	response.OauthError(w, "invalid_client", "", "https://example.com/errors#invalid_client", 401)
+
+	assert.Equal(t, 401, w.Code)
+	assert.Equal(t, "Basic realm=go_oauth2_server", w.Header().Get("WWW-Authenticate"))
+	expected = "{\"error\":\"invalid_client\",\"error_uri\":\"https://example.com/errors#invalid_client\"}"
+	assert.Equal(t, expected, strings.TrimSpace(w.Body.String()))
+}
+
+func TestInvalidTokenError(t *testing.T) {
+	w := httptest.NewRecorder()
+	response.InvalidTokenError(w, "Access token expired")
+
+	assert.Equal(t, 401, w.Code)
+	assert.Equal(t, "Bearer realm=go_oauth2_server, error=\"invalid_token\"", w.Header().Get("WWW-Authenticate"))
+	expected := "{\"error\":\"Access token expired\"}"
+	assert.Equal(t, expected, strings.TrimSpace(w.Body.String()))
+}
@@ -52,18 +52,50 @@ func (s *Service) accountSettingsForm(w http.ResponseWriter, r *http.Request) {
 
 	profile := NewProfile(user, usergroups.Usergroup, isUserAccountComplete, userSession.Role)
 
-	err = renderTemplate(w, "account_settings.html", map[string]interface{}{
+	totpEnabled := s.oauthService.IsTOTPEnabled(user)
+
+	passkeys, err := s.oauthService.FindWebAuthnCredentials(user)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	data := map[string]interface{}{
 		"appURL":                s.cnf.AppURL,
 		"applicationName":       client.ApplicationName.String,
 		"clientID":              client.Key,
 		"flash":                 flash,
 		"initialState":          template.HTML(fragment),
 		"isUserAccountComplete": isUserAccountComplete,
+		"passkeys":              webAuthnCredentialsData(passkeys),
 		"profile":               profile,
 		"queryString":           getQueryString(query),
 		"staticURL":             s.cnf.StaticURL,
+		"totpEnabled":           totpEnabled,
+		"totpRequired":          s.oauthService.IsTOTPRequired(user),
+		"webauthn":              true,
 		csrf.TemplateTag:        csrf.TemplateField(r),
-	})
+	}
+
+	// Show how many recovery codes are left, or the key to set up an authenticator app
+	if totpEnabled {
+		data["recoveryCodesLeft"], _ = s.oauthService.CountRecoveryCodes(user)
+	} else {
+		key, err := s.oauthService.EnrollTOTP(user)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		data["totpSecret"] = key.Secret()
+		data["totpQRCode"], err = totpQRCode(key)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+
+	err = renderTemplate(w, "account_settings.html", data)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
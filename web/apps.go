@@ -0,0 +1,159 @@
+package web
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gorilla/csrf"
+	"github.com/resonatecoop/id/oauth"
+	"github.com/resonatecoop/id/session"
+	"github.com/resonatecoop/id/util/response"
+)
+
+var (
+	// ErrAppIDMissing ...
+	ErrAppIDMissing = errors.New("Application ID missing")
+)
+
+// ConnectedApp is an application authorized to access the user's account
+type ConnectedApp struct {
+	ClientID          string    `json:"client_id"`
+	ApplicationName   string    `json:"application_name"`
+	ApplicationURL    string    `json:"application_url"`
+	Scopes            []string  `json:"scopes"`
+	FirstAuthorizedAt time.Time `json:"first_authorized_at"`
+	LastAuthorizedAt  time.Time `json:"last_authorized_at"`
+}
+
+// NewConnectedApp ...
+func NewConnectedApp(connectedApp *oauth.ConnectedApp) *ConnectedApp {
+	return &ConnectedApp{
+		ClientID:          connectedApp.Client.Key,
+		ApplicationName:   connectedApp.Client.ApplicationName.String,
+		ApplicationURL:    connectedApp.Client.ApplicationURL.String,
+		Scopes:            strings.Fields(connectedApp.Scope),
+		FirstAuthorizedAt: connectedApp.FirstAuthorizedAt,
+		LastAuthorizedAt:  connectedApp.LastAuthorizedAt,
+	}
+}
+
+func (s *Service) appsForm(w http.ResponseWriter, r *http.Request) {
+	sessionService, client, user, isUserAccountComplete, userSession, err := s.profileCommon(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	connectedApps, err := s.oauthService.FindConnectedApps(user)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	apps := make([]*ConnectedApp, len(connectedApps))
+	for i, connectedApp := range connectedApps {
+		apps[i] = NewConnectedApp(connectedApp)
+	}
+
+	w.Header().Set("X-CSRF-Token", csrf.Token(r))
+
+	if r.Header.Get("Accept") == "application/json" {
+		response.WriteJSON(w, map[string]interface{}{
+			"data":   apps,
+			"status": http.StatusOK,
+		}, http.StatusOK)
+		return
+	}
+
+	// Render the template
+	flash, _ := sessionService.GetFlashMessage()
+	query := r.URL.Query()
+	query.Set("login_redirect_uri", r.URL.Path)
+
+	profile := NewProfile(user, nil, isUserAccountComplete, userSession.Role)
+
+	err = renderTemplate(w, "apps.html", map[string]interface{}{
+		"appURL":                s.cnf.AppURL,
+		"applicationName":       client.ApplicationName.String,
+		"apps":                  apps,
+		"clientID":              client.Key,
+		"flash":                 flash,
+		"isUserAccountComplete": isUserAccountComplete,
+		"profile":               profile,
+		"queryString":           getQueryString(query),
+		"staticURL":             s.cnf.StaticURL,
+		csrf.TemplateTag:        csrf.TemplateField(r),
+	})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+// apps revokes the tokens and consent of a connected app (app_id)
+func (s *Service) apps(w http.ResponseWriter, r *http.Request) {
+	sessionService, _, user, _, _, err := s.profileCommon(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	method := strings.ToLower(r.Form.Get("_method"))
+	if method != "delete" && r.Method != http.MethodDelete {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	err = ErrAppIDMissing
+	if appID := r.Form.Get("app_id"); appID != "" {
+		connectedClient, findErr := s.oauthService.FindClientByClientID(appID)
+		err = findErr
+		if err == nil {
+			err = s.oauthService.RevokeConnectedApp(user, connectedClient)
+		}
+	}
+
+	if err != nil {
+		switch r.Header.Get("Accept") {
+		case "application/json":
+			response.Error(w, err.Error(), http.StatusBadRequest)
+		default:
+			err = sessionService.SetFlashMessage(&session.Flash{
+				Type:    "Error",
+				Message: err.Error(),
+			})
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			http.Redirect(w, r, r.RequestURI, http.StatusFound)
+		}
+		return
+	}
+
+	message := "Access revoked"
+
+	if r.Header.Get("Accept") == "application/json" {
+		response.WriteJSON(w, map[string]interface{}{
+			"message": message,
+			"data": map[string]interface{}{
+				"success_redirect_url": "/web/apps",
+			},
+			"status": http.StatusOK,
+		}, http.StatusOK)
+		return
+	}
+
+	err = sessionService.SetFlashMessage(&session.Flash{
+		Type:    "Info",
+		Message: message,
+	})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	redirectWithQueryString("/web/apps", r.URL.Query(), w, r)
+}
@@ -7,10 +7,11 @@ import (
 	"html/template"
 	"net/http"
 	"net/url"
-	"strconv"
 
 	"github.com/gorilla/csrf"
+	"github.com/resonatecoop/id/oauth"
 	"github.com/resonatecoop/id/session"
+	"github.com/resonatecoop/id/util"
 	"github.com/resonatecoop/user-api/model"
 )
 
@@ -18,12 +19,42 @@ import (
 var ErrIncorrectResponseType = errors.New("Response type not one of token or code")
 
 func (s *Service) authorizeForm(w http.ResponseWriter, r *http.Request) {
-	sessionService, client, user, userSession, responseType, _, err := s.authorizeCommon(r)
+	sessionService, client, user, userSession, responseType, redirectURI, err := s.authorizeCommon(r)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
+	// Check the requested scope
+	scope, err := s.oauthService.GetScope(client, r.Form.Get("scope"))
+	if err == oauth.ErrUnauthorizedClient {
+		errorRedirect(w, r, redirectURI, "unauthorized_client", r.Form.Get("state"), responseType)
+		return
+	}
+	if err != nil {
+		errorRedirect(w, r, redirectURI, "invalid_scope", r.Form.Get("state"), responseType)
+		return
+	}
+
+	// Skip the consent screen if the user already allowed the requested
+	// scope, only newly requested scopes are prompted for
+	scopesNeedingConsent, err := s.oauthService.ScopesNeedingConsent(user, client, scope)
+	if err != nil {
+		errorRedirect(w, r, redirectURI, "server_error", r.Form.Get("state"), responseType)
+		return
+	}
+	if len(scopesNeedingConsent) == 0 {
+		r.Form.Set("allow", "true")
+		s.authorize(w, r)
+		return
+	}
+
+	scopes, err := s.scopesData(scope, scopesNeedingConsent)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
 	w.Header().Set("X-CSRF-Token", csrf.Token(r))
 
 	isUserAccountComplete := s.isUserAccountComplete(userSession)
@@ -78,8 +109,8 @@ func (s *Service) authorizeForm(w http.ResponseWriter, r *http.Request) {
 		"isUserAccountComplete": isUserAccountComplete,
 		"profile":               profile,
 		"queryString":           getQueryString(query),
+		"scopes":                scopes,
 		"staticURL":             s.cnf.StaticURL,
-		"token":                 responseType == "token",
 		csrf.TemplateTag:        csrf.TemplateField(r),
 	})
 	if err != nil {
@@ -95,6 +126,14 @@ func (s *Service) authorize(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// A pushed request is used once, when the user answers it
+	if requestURI := r.Form.Get("request_uri"); requestURI != "" {
+		if err := s.oauthService.DeletePushedAuthorizationRequest(requestURI); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+
 	// Get the state parameter
 	state := r.Form.Get("state")
 
@@ -106,15 +145,19 @@ func (s *Service) authorize(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Check the requested scope
-	scope, err := s.oauthService.GetScope(r.Form.Get("scope"))
+	scope, err := s.oauthService.GetScope(client, r.Form.Get("scope"))
+	if err == oauth.ErrUnauthorizedClient {
+		errorRedirect(w, r, redirectURI, "unauthorized_client", state, responseType)
+		return
+	}
 	if err != nil {
 		errorRedirect(w, r, redirectURI, "invalid_scope", state, responseType)
 		return
 	}
 
-	// Check redirect uri
-	if redirectURI.String() != client.RedirectURI.String {
-		errorRedirect(w, r, redirectURI, "invalid_redirect_uri", state, responseType)
+	// Remember the user allowed the scope
+	if err := s.oauthService.GrantConsent(user, client, scope); err != nil {
+		errorRedirect(w, r, redirectURI, "server_error", state, responseType)
 		return
 	}
 
@@ -122,13 +165,32 @@ func (s *Service) authorize(w http.ResponseWriter, r *http.Request) {
 
 	// When response_type == "code", we will grant an authorization code
 	if responseType == "code" {
+		// Check the PKCE code challenge, if any
+		codeChallenge := r.Form.Get("code_challenge")
+		codeChallengeMethod := r.Form.Get("code_challenge_method")
+		if codeChallenge != "" || codeChallengeMethod != "" {
+			if _, err := oauth.ValidateCodeChallenge(codeChallenge, codeChallengeMethod); err != nil {
+				errorRedirect(w, r, redirectURI, "invalid_request", state, responseType)
+				return
+			}
+		}
+
+		lifetime, err := s.oauthService.AuthCodeLifetime(client)
+		if err != nil {
+			errorRedirect(w, r, redirectURI, "server_error", state, responseType)
+			return
+		}
+
 		// Create a new authorization code
 		authorizationCode, err := s.oauthService.GrantAuthorizationCode(
-			client,                       // client
-			user,                         // user
-			s.cnf.Oauth.AuthCodeLifetime, // expires in
-			redirectURI.String(),         // redirect URI
-			scope,                        // scope
+			client,               // client
+			user,                 // user
+			lifetime,             // expires in
+			redirectURI.String(), // redirect URI
+			scope,                // scope
+			codeChallenge,        // code challenge
+			codeChallengeMethod,  // code challenge method
+			r.Form.Get("nonce"),  // nonce
 		)
 		if err != nil {
 			errorRedirect(w, r, redirectURI, "server_error", state, responseType)
@@ -148,8 +210,8 @@ func (s *Service) authorize(w http.ResponseWriter, r *http.Request) {
 
 	// When response_type == "token", we will directly grant an access token
 	if responseType == "token" {
-		// Get access token lifetime from user input
-		lifetime, err := strconv.Atoi(r.Form.Get("lifetime"))
+		// The lifetime is the client's, not chosen by the browser
+		lifetime, err := s.oauthService.AccessTokenLifetime(client)
 		if err != nil {
 			errorRedirect(w, r, redirectURI, "server_error", state, responseType)
 			return
@@ -161,6 +223,7 @@ func (s *Service) authorize(w http.ResponseWriter, r *http.Request) {
 			user,     // user
 			lifetime, // expires in
 			scope,    // scope
+			nil,      // implicit grants have no refresh token to keep a device session
 		)
 		if err != nil {
 			errorRedirect(w, r, redirectURI, "server_error", state, responseType)
@@ -216,6 +279,14 @@ func (s *Service) authorizeCommon(r *http.Request) (
 		return nil, nil, nil, nil, "", nil, err
 	}
 
+	// Pushed authorization requests replace the parameters of the query
+	// string with the ones the client pushed
+	form, err := s.oauthService.ResolveAuthorizationRequest(client, r.Form)
+	if err != nil {
+		return nil, nil, nil, nil, "", nil, err
+	}
+	r.Form = form
+
 	// Set default response type
 	responseType := "code"
 
@@ -228,17 +299,42 @@ func (s *Service) authorizeCommon(r *http.Request) (
 		return nil, nil, nil, nil, "", nil, ErrIncorrectResponseType
 	}
 
-	// Fallback to the client redirect URI if not in query string
-	redirectURI := r.Form.Get("redirect_uri")
-	if redirectURI == "" {
-		redirectURI = client.RedirectURI.String
+	// The client must be allowed to use the response type
+	if err := s.oauthService.AuthorizeResponseType(client, responseType); err != nil {
+		return nil, nil, nil, nil, "", nil, err
 	}
 
-	// // Parse the redirect URL
-	parsedRedirectURI, err := url.ParseRequestURI(redirectURI)
+	// Match the redirect URI against the registered ones, falling back to
+	// the only registered one if not in query string
+	redirectURI, err := s.oauthService.ResolveRedirectURI(client, r.Form.Get("redirect_uri"))
 	if err != nil {
 		return nil, nil, nil, nil, "", nil, err
 	}
 
-	return sessionService, client, user, userSession, responseType, parsedRedirectURI, nil
+	return sessionService, client, user, userSession, responseType, redirectURI, nil
+}
+
+// scopesData lists the requested scopes for the consent screen, with the
+// scopes already allowed marked as granted
+func (s *Service) scopesData(scope string, scopesNeedingConsent []string) ([]map[string]interface{}, error) {
+	scopes, err := s.oauthService.FindScopes(scope)
+	if err != nil {
+		return nil, err
+	}
+
+	data := make([]map[string]interface{}, len(scopes))
+
+	for i, scope := range scopes {
+		description := scope.Description
+		if description == "" {
+			description = scope.Name
+		}
+		data[i] = map[string]interface{}{
+			"name":        scope.Name,
+			"description": description,
+			"granted":     !util.StringInSlice(scope.Name, scopesNeedingConsent),
+		}
+	}
+
+	return data, nil
 }
@@ -0,0 +1,119 @@
+package web
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/csrf"
+	"github.com/resonatecoop/id/oauth"
+	"github.com/resonatecoop/id/session"
+	"github.com/resonatecoop/id/util/response"
+)
+
+// deviceForm asks for the user code shown on a device, then which
+// application the device asks access for
+func (s *Service) deviceForm(w http.ResponseWriter, r *http.Request) {
+	sessionService, client, user, isUserAccountComplete, userSession, err := s.profileCommon(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("X-CSRF-Token", csrf.Token(r))
+
+	flash, _ := sessionService.GetFlashMessage()
+	query := r.URL.Query()
+	query.Set("login_redirect_uri", r.URL.Path)
+
+	data := map[string]interface{}{
+		"appURL":                s.cnf.AppURL,
+		"applicationName":       client.ApplicationName.String,
+		"clientID":              client.Key,
+		"flash":                 flash,
+		"isUserAccountComplete": isUserAccountComplete,
+		"profile":               NewProfile(user, nil, isUserAccountComplete, userSession.Role),
+		"queryString":           getQueryString(query),
+		"staticURL":             s.cnf.StaticURL,
+		csrf.TemplateTag:        csrf.TemplateField(r),
+	}
+
+	if userCode := r.Form.Get("user_code"); userCode != "" {
+		deviceCode, err := s.oauthService.FindDeviceCodeByUserCode(userCode)
+		if err != nil {
+			data["flash"] = &session.Flash{Type: "Error", Message: err.Error()}
+		} else {
+			data["device"] = map[string]interface{}{
+				"userCode":        oauth.FormatUserCode(deviceCode.UserCode),
+				"applicationName": deviceCode.Client.ApplicationName.String,
+				"scopes":          strings.Split(deviceCode.Scope, " "),
+			}
+		}
+	}
+
+	err = renderTemplate(w, "device.html", data)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+// device approves (approve=true) or denies the device the user code was shown on
+func (s *Service) device(w http.ResponseWriter, r *http.Request) {
+	sessionService, _, user, _, _, err := s.profileCommon(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	query := r.URL.Query()
+	query.Del("user_code")
+
+	message := "Device connected, you can continue on your device"
+
+	deviceCode, err := s.oauthService.FindDeviceCodeByUserCode(r.Form.Get("user_code"))
+	if err == nil {
+		if r.Form.Get("approve") == "true" {
+			err = s.oauthService.ApproveDeviceCode(deviceCode, user)
+		} else {
+			message = "Device not connected"
+			err = s.oauthService.DenyDeviceCode(deviceCode)
+		}
+	}
+
+	if err != nil {
+		switch r.Header.Get("Accept") {
+		case "application/json":
+			response.Error(w, err.Error(), http.StatusBadRequest)
+		default:
+			err = sessionService.SetFlashMessage(&session.Flash{
+				Type:    "Error",
+				Message: err.Error(),
+			})
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			redirectWithQueryString("/web/device", query, w, r)
+		}
+		return
+	}
+
+	if r.Header.Get("Accept") == "application/json" {
+		response.WriteJSON(w, map[string]interface{}{
+			"message": message,
+			"status":  http.StatusOK,
+		}, http.StatusOK)
+		return
+	}
+
+	err = sessionService.SetFlashMessage(&session.Flash{
+		Type:    "Info",
+		Message: message,
+	})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	redirectWithQueryString("/web/device", query, w, r)
+}
@@ -4,7 +4,6 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
-	"strings"
 
 	"github.com/gorilla/csrf"
 	"github.com/resonatecoop/id/session"
@@ -49,8 +48,16 @@ func (s *Service) getEmailConfirmationToken(w http.ResponseWriter, r *http.Reque
 		return
 	}
 
+	// Users with two-factor authentication enter a code before logging in
+	if s.requiresSecondFactor(user) {
+		query.Set("login_redirect_uri", "/web/account")
+		r.URL.RawQuery = query.Encode()
+		s.startTwoFactorLogin(w, r, sessionService, client, user)
+		return
+	}
+
 	// Get the scope string
-	scope, err := s.oauthService.GetScope("read_write")
+	scope, err := s.oauthService.GetScope(nil, "read_write")
 	if err != nil {
 		err = sessionService.SetFlashMessage(&session.Flash{
 			Type:    "Error",
@@ -69,6 +76,7 @@ func (s *Service) getEmailConfirmationToken(w http.ResponseWriter, r *http.Reque
 		client,
 		user,
 		scope,
+		s.oauthService.NewDeviceSession(client, user, r),
 	)
 	if err != nil {
 		err = sessionService.SetFlashMessage(&session.Flash{
@@ -83,11 +91,25 @@ func (s *Service) getEmailConfirmationToken(w http.ResponseWriter, r *http.Reque
 		return
 	}
 
+	role, err := s.oauthService.GetRoleName(user.RoleID)
+	if err != nil {
+		err = sessionService.SetFlashMessage(&session.Flash{
+			Type:    "Error",
+			Message: err.Error(),
+		})
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		http.Redirect(w, r, r.RequestURI, http.StatusFound)
+		return
+	}
+
 	// Log in the user and store the user session in a cookie
 	userSession := &session.UserSession{
 		ClientID:     client.Key,
 		Username:     user.Username,
-		Role:         strings.Split(accessToken.Scope, " ")[1],
+		Role:         role,
 		AccessToken:  accessToken.Token,
 		RefreshToken: refreshToken.Token,
 	}
@@ -5,7 +5,6 @@ import (
 	"fmt"
 	"html/template"
 	"net/http"
-	"strings"
 
 	"github.com/gorilla/csrf"
 	"github.com/resonatecoop/id/session"
@@ -40,6 +39,7 @@ func (s *Service) loginForm(w http.ResponseWriter, r *http.Request) {
 		"flash":          flash,
 		"initialState":   template.HTML(fragment),
 		"queryString":    getQueryString(r.URL.Query()),
+		"webauthn":       true,
 		csrf.TemplateTag: csrf.TemplateField(r),
 	})
 	if err != nil {
@@ -122,9 +122,14 @@ func (s *Service) login(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Get the scope string
-	scope, err := s.oauthService.GetScope("read_write")
-	if err != nil {
+	// Users with two-factor authentication enter a code before logging in
+	if s.requiresSecondFactor(user) {
+		s.startTwoFactorLogin(w, r, sessionService, client, user)
+		return
+	}
+
+	// Log in the user
+	if err := s.logIn(r, sessionService, client, user); err != nil {
 		err = sessionService.SetFlashMessage(&session.Flash{
 			Type:    "Error",
 			Message: err.Error(),
@@ -137,50 +142,50 @@ func (s *Service) login(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	redirectAfterLogin(w, r)
+}
+
+// logIn grants tokens to the user on a new device session and stores the
+// user session in a cookie
+func (s *Service) logIn(r *http.Request, sessionService session.ServiceInterface, client *model.Client, user *model.User) error {
+	// Get the scope string
+	scope, err := s.oauthService.GetScope(nil, "read_write")
+	if err != nil {
+		return err
+	}
+
 	// Log in the user
 	accessToken, refreshToken, err := s.oauthService.Login(
 		client,
 		user,
 		scope,
+		s.oauthService.NewDeviceSession(client, user, r),
 	)
 	if err != nil {
-		err = sessionService.SetFlashMessage(&session.Flash{
-			Type:    "Error",
-			Message: err.Error(),
-		})
-		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
-			return
-		}
-		http.Redirect(w, r, r.RequestURI, http.StatusFound)
-		return
+		return err
 	}
 
-	scopes := strings.Split(accessToken.Scope, " ")
+	role, err := s.oauthService.GetRoleName(user.RoleID)
+	if err != nil {
+		return err
+	}
 
 	// Log in the user and store the user session in a cookie
 	userSession := &session.UserSession{
 		ClientID:     client.Key,
 		Username:     user.Username,
-		Role:         scopes[1],
+		Role:         role,
 		AccessToken:  accessToken.Token,
 		RefreshToken: refreshToken.Token,
 	}
-	if err := sessionService.SetUserSession(userSession); err != nil {
-		err = sessionService.SetFlashMessage(&session.Flash{
-			Type:    "Error",
-			Message: err.Error(),
-		})
-		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
-			return
-		}
-		http.Redirect(w, r, r.RequestURI, http.StatusFound)
-		return
-	}
 
-	// Redirect to the authorize page by default but allow redirection to other
-	// pages by specifying a path with login_redirect_uri query string param
+	return sessionService.SetUserSession(userSession)
+}
+
+// redirectAfterLogin redirects to the authorize page by default but allows
+// redirection to other pages by specifying a path with login_redirect_uri
+// query string param
+func redirectAfterLogin(w http.ResponseWriter, r *http.Request) {
 	loginRedirectURI := r.URL.Query().Get("login_redirect_uri")
 	if loginRedirectURI == "" {
 		loginRedirectURI = "/web/authorize"
@@ -2,7 +2,6 @@ package web
 
 import (
 	"net/http"
-	"strings"
 
 	"github.com/gorilla/context"
 	"github.com/gorilla/csrf"
@@ -113,7 +112,7 @@ func (m *loggedInMiddleware) ServeHTTP(w http.ResponseWriter, r *http.Request, n
 	}
 
 	// Authenticate
-	if err := m.authenticate(userSession); err != nil {
+	if err := m.authenticate(userSession, r); err != nil {
 		// Delete the user session
 		err = sessionService.ClearUserSession()
 		if err != nil {
@@ -137,7 +136,7 @@ func (m *loggedInMiddleware) ServeHTTP(w http.ResponseWriter, r *http.Request, n
 	next(w, r)
 }
 
-func (m *loggedInMiddleware) authenticate(userSession *session.UserSession) error {
+func (m *loggedInMiddleware) authenticate(userSession *session.UserSession, r *http.Request) error {
 	// Try to authenticate with the stored access token
 	_, err := m.service.GetOauthService().Authenticate(userSession.AccessToken)
 	if err == nil {
@@ -154,6 +153,11 @@ func (m *loggedInMiddleware) authenticate(userSession *session.UserSession) erro
 		return err
 	}
 
+	// Disabled clients cannot refresh the session
+	if err := m.service.GetOauthService().CheckClientEnabled(client); err != nil {
+		return err
+	}
+
 	// Validate the refresh token
 	theRefreshToken, err := m.service.GetOauthService().GetValidRefreshToken(
 		userSession.RefreshToken, // refresh token
@@ -168,14 +172,18 @@ func (m *loggedInMiddleware) authenticate(userSession *session.UserSession) erro
 		theRefreshToken.Client,
 		theRefreshToken.User,
 		theRefreshToken.Scope,
+		m.service.GetOauthService().GetRefreshTokenDeviceSession(theRefreshToken, r),
 	)
 	if err != nil {
 		return err
 	}
 
-	scopes := strings.Split(accessToken.Scope, " ")
+	role, err := m.service.GetOauthService().GetRoleName(theRefreshToken.User.RoleID)
+	if err != nil {
+		return err
+	}
 
-	userSession.Role = scopes[1] // user, artist, label, admin, tenantadmin, ...
+	userSession.Role = role
 	userSession.AccessToken = accessToken.Token
 	userSession.RefreshToken = refreshToken.Token
 
@@ -212,6 +220,12 @@ func (m *clientMiddleware) ServeHTTP(w http.ResponseWriter, r *http.Request, nex
 			return
 		}
 
+		// Disabled clients cannot start a flow
+		if err := m.service.GetOauthService().CheckClientEnabled(client); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
 		context.Set(r, clientKey, client)
 	} else {
 		// fallback to default application uri
@@ -224,6 +238,11 @@ func (m *clientMiddleware) ServeHTTP(w http.ResponseWriter, r *http.Request, nex
 			return
 		}
 
+		if err := m.service.GetOauthService().CheckClientEnabled(client); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
 		context.Set(r, clientKey, client)
 	}
 
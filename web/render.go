@@ -115,11 +115,16 @@ func loadTemplates() {
 			"./web/includes/password_reset.html",
 			"./web/includes/password_reset_update_password.html",
 			"./web/includes/home.html",
+			"./web/includes/two_factor_login.html",
+			"./web/includes/recovery_codes.html",
 		},
 		"web/layouts/inside.html": {
 			"./web/includes/authorize.html",
 			"./web/includes/account.html",
 			"./web/includes/account_settings.html",
+			"./web/includes/sessions.html",
+			"./web/includes/apps.html",
+			"./web/includes/device.html",
 		},
 	}
 
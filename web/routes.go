@@ -77,6 +77,56 @@ func (s *Service) GetRoutes() []routes.Route {
 				newClientMiddleware(s),
 			},
 		},
+		{
+			Name:        "login_two_factor_form",
+			Method:      "GET",
+			Pattern:     "/login/two-factor",
+			HandlerFunc: s.twoFactorLoginForm,
+			Middlewares: []negroni.Handler{
+				new(parseFormMiddleware),
+				newGuestMiddleware(s),
+				newClientMiddleware(s),
+			},
+		},
+		{
+			Name:        "login_two_factor",
+			Method:      "POST",
+			Pattern:     "/login/two-factor",
+			HandlerFunc: s.twoFactorLogin,
+			Middlewares: []negroni.Handler{
+				tollbooth_negroni.LimitHandler(
+					tollbooth.NewLimiter(1, nil),
+				),
+				new(parseFormMiddleware),
+				newGuestMiddleware(s),
+				newClientMiddleware(s),
+			},
+		},
+		{
+			Name:        "login_webauthn_options",
+			Method:      "POST",
+			Pattern:     "/login/webauthn/options",
+			HandlerFunc: s.webAuthnLoginOptions,
+			Middlewares: []negroni.Handler{
+				new(parseFormMiddleware),
+				newGuestMiddleware(s),
+				newClientMiddleware(s),
+			},
+		},
+		{
+			Name:        "login_webauthn",
+			Method:      "POST",
+			Pattern:     "/login/webauthn",
+			HandlerFunc: s.webAuthnLogin,
+			Middlewares: []negroni.Handler{
+				tollbooth_negroni.LimitHandler(
+					tollbooth.NewLimiter(1, nil),
+				),
+				new(parseFormMiddleware),
+				newGuestMiddleware(s),
+				newClientMiddleware(s),
+			},
+		},
 		{
 			Name:        "logout",
 			Method:      "GET",
@@ -254,6 +304,190 @@ func (s *Service) GetRoutes() []routes.Route {
 				newClientMiddleware(s),
 			},
 		},
+		{
+			Name:        "account_settings_two_factor",
+			Method:      "POST",
+			Pattern:     "/account-settings/two-factor",
+			HandlerFunc: s.twoFactor,
+			Middlewares: []negroni.Handler{
+				tollbooth_negroni.LimitHandler(
+					tollbooth.NewLimiter(1, nil),
+				),
+				new(parseFormMiddleware),
+				newLoggedInMiddleware(s),
+				newClientMiddleware(s),
+			},
+		},
+		{
+			Name:        "account_settings_two_factor_enable",
+			Method:      "PUT",
+			Pattern:     "/account-settings/two-factor",
+			HandlerFunc: s.twoFactor,
+			Middlewares: []negroni.Handler{
+				tollbooth_negroni.LimitHandler(
+					tollbooth.NewLimiter(1, nil),
+				),
+				new(parseFormMiddleware),
+				newLoggedInMiddleware(s),
+				newClientMiddleware(s),
+			},
+		},
+		{
+			Name:        "account_settings_two_factor_disable",
+			Method:      "DELETE",
+			Pattern:     "/account-settings/two-factor",
+			HandlerFunc: s.twoFactor,
+			Middlewares: []negroni.Handler{
+				tollbooth_negroni.LimitHandler(
+					tollbooth.NewLimiter(1, nil),
+				),
+				new(parseFormMiddleware),
+				newLoggedInMiddleware(s),
+				newClientMiddleware(s),
+			},
+		},
+		{
+			Name:        "account_settings_passkeys_options",
+			Method:      "POST",
+			Pattern:     "/account-settings/passkeys/options",
+			HandlerFunc: s.passkeyOptions,
+			Middlewares: []negroni.Handler{
+				new(parseFormMiddleware),
+				newLoggedInMiddleware(s),
+				newClientMiddleware(s),
+			},
+		},
+		{
+			Name:        "account_settings_passkeys",
+			Method:      "POST",
+			Pattern:     "/account-settings/passkeys",
+			HandlerFunc: s.passkeys,
+			Middlewares: []negroni.Handler{
+				tollbooth_negroni.LimitHandler(
+					tollbooth.NewLimiter(1, nil),
+				),
+				new(parseFormMiddleware),
+				newLoggedInMiddleware(s),
+				newClientMiddleware(s),
+			},
+		},
+		{
+			Name:        "account_settings_passkeys_delete",
+			Method:      "DELETE",
+			Pattern:     "/account-settings/passkeys",
+			HandlerFunc: s.passkeys,
+			Middlewares: []negroni.Handler{
+				tollbooth_negroni.LimitHandler(
+					tollbooth.NewLimiter(1, nil),
+				),
+				new(parseFormMiddleware),
+				newLoggedInMiddleware(s),
+				newClientMiddleware(s),
+			},
+		},
+		{
+			Name:        "device_form",
+			Method:      "GET",
+			Pattern:     "/device",
+			HandlerFunc: s.deviceForm,
+			Middlewares: []negroni.Handler{
+				new(parseFormMiddleware),
+				newLoggedInMiddleware(s),
+				newClientMiddleware(s),
+			},
+		},
+		{
+			Name:        "device",
+			Method:      "POST",
+			Pattern:     "/device",
+			HandlerFunc: s.device,
+			Middlewares: []negroni.Handler{
+				tollbooth_negroni.LimitHandler(
+					tollbooth.NewLimiter(1, nil),
+				),
+				new(parseFormMiddleware),
+				newLoggedInMiddleware(s),
+				newClientMiddleware(s),
+			},
+		},
+		{
+			Name:        "sessions_form",
+			Method:      "GET",
+			Pattern:     "/sessions",
+			HandlerFunc: s.sessionsForm,
+			Middlewares: []negroni.Handler{
+				new(parseFormMiddleware),
+				newLoggedInMiddleware(s),
+				newClientMiddleware(s),
+			},
+		},
+		{
+			Name:        "sessions",
+			Method:      "POST",
+			Pattern:     "/sessions",
+			HandlerFunc: s.sessions,
+			Middlewares: []negroni.Handler{
+				tollbooth_negroni.LimitHandler(
+					tollbooth.NewLimiter(1, nil),
+				),
+				new(parseFormMiddleware),
+				newLoggedInMiddleware(s),
+				newClientMiddleware(s),
+			},
+		},
+		{
+			Name:        "sessions_delete",
+			Method:      "DELETE",
+			Pattern:     "/sessions",
+			HandlerFunc: s.sessions,
+			Middlewares: []negroni.Handler{
+				tollbooth_negroni.LimitHandler(
+					tollbooth.NewLimiter(1, nil),
+				),
+				new(parseFormMiddleware),
+				newLoggedInMiddleware(s),
+				newClientMiddleware(s),
+			},
+		},
+		{
+			Name:        "apps_form",
+			Method:      "GET",
+			Pattern:     "/apps",
+			HandlerFunc: s.appsForm,
+			Middlewares: []negroni.Handler{
+				new(parseFormMiddleware),
+				newLoggedInMiddleware(s),
+				newClientMiddleware(s),
+			},
+		},
+		{
+			Name:        "apps",
+			Method:      "POST",
+			Pattern:     "/apps",
+			HandlerFunc: s.apps,
+			Middlewares: []negroni.Handler{
+				tollbooth_negroni.LimitHandler(
+					tollbooth.NewLimiter(1, nil),
+				),
+				new(parseFormMiddleware),
+				newLoggedInMiddleware(s),
+				newClientMiddleware(s),
+			},
+		},
+		{
+			Name:        "apps_delete",
+			Method:      "DELETE",
+			Pattern:     "/apps",
+			HandlerFunc: s.apps,
+			Middlewares: []negroni.Handler{
+				tollbooth_negroni.LimitHandler(
+					tollbooth.NewLimiter(1, nil),
+				),
+				new(parseFormMiddleware),
+				newLoggedInMiddleware(s),
+				newClientMiddleware(s),
+			},
+		},
 		{
 			Name:        "account_update",
 			Method:      "PUT",
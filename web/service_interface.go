@@ -33,8 +33,21 @@ type ServiceInterface interface {
 	account(w http.ResponseWriter, r *http.Request)
 	accountSettingsForm(w http.ResponseWriter, r *http.Request)
 	accountSettings(w http.ResponseWriter, r *http.Request)
+	twoFactor(w http.ResponseWriter, r *http.Request)
+	passkeyOptions(w http.ResponseWriter, r *http.Request)
+	passkeys(w http.ResponseWriter, r *http.Request)
+	deviceForm(w http.ResponseWriter, r *http.Request)
+	device(w http.ResponseWriter, r *http.Request)
+	sessionsForm(w http.ResponseWriter, r *http.Request)
+	sessions(w http.ResponseWriter, r *http.Request)
+	appsForm(w http.ResponseWriter, r *http.Request)
+	apps(w http.ResponseWriter, r *http.Request)
 	loginForm(w http.ResponseWriter, r *http.Request)
 	login(w http.ResponseWriter, r *http.Request)
+	twoFactorLoginForm(w http.ResponseWriter, r *http.Request)
+	twoFactorLogin(w http.ResponseWriter, r *http.Request)
+	webAuthnLoginOptions(w http.ResponseWriter, r *http.Request)
+	webAuthnLogin(w http.ResponseWriter, r *http.Request)
 	logout(w http.ResponseWriter, r *http.Request)
 	joinForm(w http.ResponseWriter, r *http.Request)
 	join(w http.ResponseWriter, r *http.Request)
@@ -0,0 +1,202 @@
+package web
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gorilla/csrf"
+	"github.com/resonatecoop/id/oauth"
+	"github.com/resonatecoop/id/session"
+	"github.com/resonatecoop/id/util/response"
+)
+
+var (
+	// ErrSessionIDMissing ...
+	ErrSessionIDMissing = errors.New("Session ID missing")
+)
+
+// DeviceSession is a device the user is signed in on
+type DeviceSession struct {
+	ID              string    `json:"id"`
+	ApplicationName string    `json:"application_name"`
+	UserAgent       string    `json:"user_agent"`
+	IPAddress       string    `json:"ip_address"`
+	CreatedAt       time.Time `json:"created_at"`
+	LastUsedAt      time.Time `json:"last_used_at"`
+	Current         bool      `json:"current"`
+}
+
+// NewDeviceSession ...
+func NewDeviceSession(deviceSession *oauth.DeviceSession, current bool) *DeviceSession {
+	applicationName := ""
+	if deviceSession.Client != nil {
+		applicationName = deviceSession.Client.ApplicationName.String
+	}
+
+	return &DeviceSession{
+		ID:              deviceSession.ID.String(),
+		ApplicationName: applicationName,
+		UserAgent:       deviceSession.UserAgent,
+		IPAddress:       deviceSession.IPAddress,
+		CreatedAt:       deviceSession.CreatedAt,
+		LastUsedAt:      deviceSession.LastUsedAt,
+		Current:         current,
+	}
+}
+
+func (s *Service) sessionsForm(w http.ResponseWriter, r *http.Request) {
+	sessionService, client, user, isUserAccountComplete, userSession, err := s.profileCommon(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	deviceSessions, err := s.oauthService.FindActiveDeviceSessions(user)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// Mark the device session this page is viewed from
+	currentID := ""
+	if current, err := s.oauthService.FindDeviceSessionByToken(userSession.RefreshToken); err == nil {
+		currentID = current.ID.String()
+	}
+
+	sessions := make([]*DeviceSession, len(deviceSessions))
+	for i, deviceSession := range deviceSessions {
+		sessions[i] = NewDeviceSession(deviceSession, deviceSession.ID.String() == currentID)
+	}
+
+	w.Header().Set("X-CSRF-Token", csrf.Token(r))
+
+	if r.Header.Get("Accept") == "application/json" {
+		response.WriteJSON(w, map[string]interface{}{
+			"data":   sessions,
+			"status": http.StatusOK,
+		}, http.StatusOK)
+		return
+	}
+
+	// Render the template
+	flash, _ := sessionService.GetFlashMessage()
+	query := r.URL.Query()
+	query.Set("login_redirect_uri", r.URL.Path)
+
+	profile := NewProfile(user, nil, isUserAccountComplete, userSession.Role)
+
+	err = renderTemplate(w, "sessions.html", map[string]interface{}{
+		"appURL":                s.cnf.AppURL,
+		"applicationName":       client.ApplicationName.String,
+		"clientID":              client.Key,
+		"flash":                 flash,
+		"isUserAccountComplete": isUserAccountComplete,
+		"profile":               profile,
+		"queryString":           getQueryString(query),
+		"sessions":              sessions,
+		"staticURL":             s.cnf.StaticURL,
+		csrf.TemplateTag:        csrf.TemplateField(r),
+	})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+// sessions signs out a single device session (session_id) or every other
+// device session (others=true)
+func (s *Service) sessions(w http.ResponseWriter, r *http.Request) {
+	sessionService, _, user, _, userSession, err := s.profileCommon(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	method := strings.ToLower(r.Form.Get("_method"))
+	if method != "delete" && r.Method != http.MethodDelete {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	current, err := s.oauthService.FindDeviceSessionByToken(userSession.RefreshToken)
+	if err != nil {
+		current = nil
+	}
+
+	message := "Signed out of all other sessions"
+	signedOut := false
+
+	if r.Form.Get("others") == "true" {
+		err = s.oauthService.RevokeOtherDeviceSessions(user, current)
+		// Sessions granted before device sessions existed cannot be told apart
+		signedOut = current == nil
+	} else {
+		message = "Session signed out"
+
+		var deviceSession *oauth.DeviceSession
+		if r.Form.Get("session_id") == "" {
+			err = ErrSessionIDMissing
+		} else {
+			deviceSession, err = s.oauthService.FindUserDeviceSession(user, r.Form.Get("session_id"))
+		}
+		if err == nil {
+			err = s.oauthService.RevokeDeviceSession(deviceSession)
+			signedOut = current != nil && current.ID == deviceSession.ID
+		}
+	}
+
+	if err != nil {
+		switch r.Header.Get("Accept") {
+		case "application/json":
+			response.Error(w, err.Error(), http.StatusBadRequest)
+		default:
+			err = sessionService.SetFlashMessage(&session.Flash{
+				Type:    "Error",
+				Message: err.Error(),
+			})
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			http.Redirect(w, r, r.RequestURI, http.StatusFound)
+		}
+		return
+	}
+
+	redirectURI := "/web/sessions"
+
+	// The user signed out of the session they are using
+	if signedOut {
+		if err := sessionService.ClearUserSession(); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		redirectURI = "/web/login"
+	}
+
+	if r.Header.Get("Accept") == "application/json" {
+		response.WriteJSON(w, map[string]interface{}{
+			"message": message,
+			"data": map[string]interface{}{
+				"success_redirect_url": redirectURI,
+			},
+			"status": http.StatusOK,
+		}, http.StatusOK)
+		return
+	}
+
+	if !signedOut {
+		err = sessionService.SetFlashMessage(&session.Flash{
+			Type:    "Info",
+			Message: message,
+		})
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+
+	redirectWithQueryString(redirectURI, r.URL.Query(), w, r)
+}
@@ -0,0 +1,321 @@
+package web
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"html/template"
+	"image/png"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gorilla/csrf"
+	"github.com/pquerna/otp"
+	"github.com/resonatecoop/id/oauth"
+	"github.com/resonatecoop/id/session"
+	"github.com/resonatecoop/id/util/response"
+	"github.com/resonatecoop/user-api/model"
+)
+
+// pendingLoginLifetime is how long (in seconds) the user has to enter the second factor
+const pendingLoginLifetime = 300
+
+var (
+	// ErrPendingLoginClientMismatch ...
+	ErrPendingLoginClientMismatch = errors.New("Login was started by another client")
+)
+
+// requiresSecondFactor returns true if the user logs in with a code or a
+// passkey after entering their password
+func (s *Service) requiresSecondFactor(user *model.User) bool {
+	return s.oauthService.IsTOTPEnabled(user) ||
+		s.oauthService.HasWebAuthnCredentials(user) ||
+		s.oauthService.IsTOTPRequired(user)
+}
+
+// startTwoFactorLogin remembers the user who entered a valid password and
+// redirects to the second login step
+func (s *Service) startTwoFactorLogin(w http.ResponseWriter, r *http.Request, sessionService session.ServiceInterface, client *model.Client, user *model.User) {
+	err := sessionService.SetPendingLogin(&session.PendingLogin{
+		ClientID:  client.Key,
+		Username:  user.Username,
+		ExpiresAt: time.Now().Add(pendingLoginLifetime * time.Second),
+	})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	redirectWithQueryString("/web/login/two-factor", r.URL.Query(), w, r)
+}
+
+// twoFactorLoginCommon returns the user of the pending login
+func (s *Service) twoFactorLoginCommon(r *http.Request) (
+	session.ServiceInterface,
+	*model.Client,
+	*model.User,
+	error,
+) {
+	// Get the session service from the request context
+	sessionService, err := getSessionService(r)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	// Get the client from the request context
+	client, err := getClient(r)
+	if err != nil {
+		return sessionService, nil, nil, err
+	}
+
+	pendingLogin, err := sessionService.GetPendingLogin()
+	if err != nil {
+		return sessionService, nil, nil, err
+	}
+
+	if pendingLogin.ClientID != client.Key {
+		return sessionService, nil, nil, ErrPendingLoginClientMismatch
+	}
+
+	user, err := s.oauthService.FindUserByUsername(pendingLogin.Username)
+	if err != nil {
+		return sessionService, nil, nil, err
+	}
+
+	return sessionService, client, user, nil
+}
+
+func (s *Service) twoFactorLoginForm(w http.ResponseWriter, r *http.Request) {
+	sessionService, _, user, err := s.twoFactorLoginCommon(r)
+	if err != nil {
+		s.restartLogin(w, r, sessionService, err)
+		return
+	}
+
+	w.Header().Set("X-CSRF-Token", csrf.Token(r))
+
+	totpEnabled := s.oauthService.IsTOTPEnabled(user)
+	hasPasskeys := s.oauthService.HasWebAuthnCredentials(user)
+
+	data := map[string]interface{}{
+		"appURL":         s.cnf.AppURL,
+		"enroll":         false,
+		"passkeys":       hasPasskeys,
+		"queryString":    getQueryString(r.URL.Query()),
+		"totp":           totpEnabled,
+		"webauthn":       hasPasskeys,
+		csrf.TemplateTag: csrf.TemplateField(r),
+	}
+
+	// Users whose role requires two-factor authentication set it up now
+	if !totpEnabled && !hasPasskeys {
+		key, err := s.oauthService.EnrollTOTP(user)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		qrCode, err := totpQRCode(key)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		data["enroll"] = true
+		data["totp"] = true
+		data["totpSecret"] = key.Secret()
+		data["totpQRCode"] = qrCode
+	}
+
+	data["flash"], _ = sessionService.GetFlashMessage()
+
+	err = renderTemplate(w, "two_factor_login.html", data)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+func (s *Service) twoFactorLogin(w http.ResponseWriter, r *http.Request) {
+	sessionService, client, user, err := s.twoFactorLoginCommon(r)
+	if err != nil {
+		s.restartLogin(w, r, sessionService, err)
+		return
+	}
+
+	var recoveryCodes []string
+
+	switch {
+	case s.oauthService.IsTOTPEnabled(user):
+		err = s.oauthService.VerifyTOTP(user, r.Form.Get("code"))
+	case s.oauthService.HasWebAuthnCredentials(user):
+		err = oauth.ErrTOTPNotEnabled
+	default:
+		recoveryCodes, err = s.oauthService.EnableTOTP(user, r.Form.Get("code"))
+	}
+
+	if err == nil {
+		err = sessionService.ClearPendingLogin()
+	}
+
+	if err == nil {
+		err = s.logIn(r, sessionService, client, user)
+	}
+
+	if err != nil {
+		switch r.Header.Get("Accept") {
+		case "application/json":
+			response.Error(w, err.Error(), http.StatusBadRequest)
+		default:
+			err = sessionService.SetFlashMessage(&session.Flash{
+				Type:    "Error",
+				Message: err.Error(),
+			})
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			http.Redirect(w, r, r.RequestURI, http.StatusFound)
+		}
+		return
+	}
+
+	// Recovery codes are only shown once, right after setting up
+	if recoveryCodes != nil {
+		loginRedirectURI := r.URL.Query().Get("login_redirect_uri")
+		if loginRedirectURI == "" {
+			loginRedirectURI = "/web/authorize"
+		}
+		s.renderRecoveryCodes(w, r, recoveryCodes, loginRedirectURI+getQueryString(r.URL.Query()))
+		return
+	}
+
+	redirectAfterLogin(w, r)
+}
+
+// twoFactor enables (PUT) or disables (DELETE) two-factor authentication on
+// the account settings page, POST replaces the recovery codes
+func (s *Service) twoFactor(w http.ResponseWriter, r *http.Request) {
+	sessionService, _, user, _, _, err := s.profileCommon(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("X-CSRF-Token", csrf.Token(r))
+
+	method := strings.ToLower(r.Form.Get("_method"))
+
+	var (
+		recoveryCodes []string
+		message       string
+	)
+
+	switch {
+	case method == "put" || r.Method == http.MethodPut:
+		recoveryCodes, err = s.oauthService.EnableTOTP(user, r.Form.Get("code"))
+		message = "Two-factor authentication enabled"
+	case method == "delete" || r.Method == http.MethodDelete:
+		err = s.oauthService.DisableTOTP(user, r.Form.Get("password"))
+		message = "Two-factor authentication disabled"
+	default:
+		recoveryCodes, err = s.oauthService.RegenerateRecoveryCodes(user)
+		message = "New recovery codes generated"
+	}
+
+	if err != nil {
+		switch r.Header.Get("Accept") {
+		case "application/json":
+			response.Error(w, err.Error(), http.StatusBadRequest)
+		default:
+			err = sessionService.SetFlashMessage(&session.Flash{
+				Type:    "Error",
+				Message: err.Error(),
+			})
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			redirectWithQueryString("/web/account-settings", r.URL.Query(), w, r)
+		}
+		return
+	}
+
+	redirectURI := "/web/account-settings"
+
+	if r.Header.Get("Accept") == "application/json" {
+		response.WriteJSON(w, map[string]interface{}{
+			"message": message,
+			"data": map[string]interface{}{
+				"success_redirect_url": redirectURI,
+				"recovery_codes":       recoveryCodes,
+			},
+			"status": http.StatusOK,
+		}, http.StatusOK)
+		return
+	}
+
+	if recoveryCodes != nil {
+		s.renderRecoveryCodes(w, r, recoveryCodes, redirectURI+getQueryString(r.URL.Query()))
+		return
+	}
+
+	err = sessionService.SetFlashMessage(&session.Flash{
+		Type:    "Info",
+		Message: message,
+	})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	redirectWithQueryString(redirectURI, r.URL.Query(), w, r)
+}
+
+// restartLogin sends the user back to the first login step
+func (s *Service) restartLogin(w http.ResponseWriter, r *http.Request, sessionService session.ServiceInterface, err error) {
+	if sessionService == nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	err = sessionService.SetFlashMessage(&session.Flash{
+		Type:    "Error",
+		Message: err.Error(),
+	})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	redirectWithQueryString("/web/login", r.URL.Query(), w, r)
+}
+
+// renderRecoveryCodes shows new recovery codes, they cannot be shown again
+func (s *Service) renderRecoveryCodes(w http.ResponseWriter, r *http.Request, recoveryCodes []string, continueURI string) {
+	err := renderTemplate(w, "recovery_codes.html", map[string]interface{}{
+		"appURL":        s.cnf.AppURL,
+		"continueURI":   continueURI,
+		"recoveryCodes": recoveryCodes,
+	})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+// totpQRCode returns the provisioning QR code of a TOTP key as a PNG data URI
+func totpQRCode(key *otp.Key) (template.URL, error) {
+	img, err := key.Image(200, 200)
+	if err != nil {
+		return "", err
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return "", err
+	}
+
+	return template.URL("data:image/png;base64," + base64.StdEncoding.EncodeToString(buf.Bytes())), nil
+}
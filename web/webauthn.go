@@ -0,0 +1,292 @@
+package web
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gorilla/csrf"
+	"github.com/resonatecoop/id/oauth"
+	"github.com/resonatecoop/id/oauth/webauthn"
+	"github.com/resonatecoop/id/session"
+	"github.com/resonatecoop/id/util/response"
+	"github.com/resonatecoop/user-api/model"
+)
+
+var (
+	// ErrCredentialIDMissing ...
+	ErrCredentialIDMissing = errors.New("Passkey ID missing")
+)
+
+// passkeyRequest is the body posted by the browser to register a passkey
+type passkeyRequest struct {
+	Name       string                        `json:"name"`
+	Credential *webauthn.AttestationResponse `json:"credential"`
+}
+
+// webAuthnLoginOptions starts a passkey login. During a pending login only
+// the passkeys of that user are allowed, otherwise any passkey stored on the
+// device can be used.
+func (s *Service) webAuthnLoginOptions(w http.ResponseWriter, r *http.Request) {
+	// Get the session service from the request context
+	sessionService, err := getSessionService(r)
+	if err != nil {
+		response.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("X-CSRF-Token", csrf.Token(r))
+
+	options, err := s.oauthService.BeginWebAuthnLogin(s.pendingLoginUser(r))
+	if err != nil {
+		response.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := setWebAuthnChallenge(sessionService, options.Challenge); err != nil {
+		response.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	response.WriteJSON(w, map[string]interface{}{
+		"data": map[string]interface{}{
+			"publicKey": options,
+		},
+		"status": http.StatusOK,
+	}, http.StatusOK)
+}
+
+// webAuthnLogin finishes a passkey login
+func (s *Service) webAuthnLogin(w http.ResponseWriter, r *http.Request) {
+	// Get the session service from the request context
+	sessionService, err := getSessionService(r)
+	if err != nil {
+		response.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// Get the client from the request context
+	client, err := getClient(r)
+	if err != nil {
+		response.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	assertion := new(webauthn.AssertionResponse)
+	if err := json.NewDecoder(r.Body).Decode(assertion); err != nil {
+		response.Error(w, webauthn.ErrInvalidResponse.Error(), http.StatusBadRequest)
+		return
+	}
+
+	challenge, err := getWebAuthnChallenge(sessionService)
+	if err != nil {
+		response.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	pendingUser := s.pendingLoginUser(r)
+
+	user, err := s.oauthService.FinishWebAuthnLogin(pendingUser, challenge, assertion)
+	if err != nil {
+		response.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Email should be confirmed, this was already checked with the password
+	if !user.EmailConfirmed {
+		response.Error(w, "Please confirm your email", http.StatusBadRequest)
+		return
+	}
+
+	if pendingUser != nil {
+		if err := sessionService.ClearPendingLogin(); err != nil {
+			response.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+
+	if err := s.logIn(r, sessionService, client, user); err != nil {
+		response.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	loginRedirectURI := r.URL.Query().Get("login_redirect_uri")
+	if loginRedirectURI == "" {
+		loginRedirectURI = "/web/authorize"
+	}
+
+	response.WriteJSON(w, map[string]interface{}{
+		"message": "Logged in with passkey",
+		"data": map[string]interface{}{
+			"success_redirect_url": loginRedirectURI + getQueryString(r.URL.Query()),
+		},
+		"status": http.StatusOK,
+	}, http.StatusOK)
+}
+
+// passkeyOptions starts registering a passkey on the account settings page
+func (s *Service) passkeyOptions(w http.ResponseWriter, r *http.Request) {
+	sessionService, _, user, _, _, err := s.profileCommon(r)
+	if err != nil {
+		response.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("X-CSRF-Token", csrf.Token(r))
+
+	options, err := s.oauthService.BeginWebAuthnRegistration(user)
+	if err != nil {
+		response.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if err := setWebAuthnChallenge(sessionService, options.Challenge); err != nil {
+		response.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	response.WriteJSON(w, map[string]interface{}{
+		"data": map[string]interface{}{
+			"publicKey": options,
+		},
+		"status": http.StatusOK,
+	}, http.StatusOK)
+}
+
+// passkeys registers (POST) or removes (DELETE) a passkey on the account
+// settings page
+func (s *Service) passkeys(w http.ResponseWriter, r *http.Request) {
+	sessionService, _, user, _, _, err := s.profileCommon(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("X-CSRF-Token", csrf.Token(r))
+
+	method := strings.ToLower(r.Form.Get("_method"))
+
+	var message string
+
+	switch {
+	case method == "delete" || r.Method == http.MethodDelete:
+		err = ErrCredentialIDMissing
+		if id := r.Form.Get("credential_id"); id != "" {
+			err = s.oauthService.DeleteWebAuthnCredential(user, id)
+		}
+		message = "Passkey removed"
+	default:
+		var challenge string
+		challenge, err = getWebAuthnChallenge(sessionService)
+		if err == nil {
+			err = s.registerPasskey(r, user, challenge)
+		}
+		message = "Passkey added"
+	}
+
+	if err != nil {
+		switch r.Header.Get("Accept") {
+		case "application/json":
+			response.Error(w, err.Error(), http.StatusBadRequest)
+		default:
+			err = sessionService.SetFlashMessage(&session.Flash{
+				Type:    "Error",
+				Message: err.Error(),
+			})
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			redirectWithQueryString("/web/account-settings", r.URL.Query(), w, r)
+		}
+		return
+	}
+
+	err = sessionService.SetFlashMessage(&session.Flash{
+		Type:    "Info",
+		Message: message,
+	})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if r.Header.Get("Accept") == "application/json" {
+		response.WriteJSON(w, map[string]interface{}{
+			"message": message,
+			"data": map[string]interface{}{
+				"success_redirect_url": "/web/account-settings" + getQueryString(r.URL.Query()),
+			},
+			"status": http.StatusOK,
+		}, http.StatusOK)
+		return
+	}
+
+	redirectWithQueryString("/web/account-settings", r.URL.Query(), w, r)
+}
+
+// registerPasskey stores the passkey posted by the browser
+func (s *Service) registerPasskey(r *http.Request, user *model.User, challenge string) error {
+	req := new(passkeyRequest)
+	if err := json.NewDecoder(r.Body).Decode(req); err != nil || req.Credential == nil {
+		return webauthn.ErrInvalidResponse
+	}
+
+	_, err := s.oauthService.FinishWebAuthnRegistration(user, req.Name, challenge, req.Credential)
+	return err
+}
+
+// pendingLoginUser returns the user who already entered their password, if
+// any, so a passkey can be used as a second factor
+func (s *Service) pendingLoginUser(r *http.Request) *model.User {
+	_, _, user, err := s.twoFactorLoginCommon(r)
+	if err != nil {
+		return nil
+	}
+	return user
+}
+
+// setWebAuthnChallenge remembers the challenge of a passkey ceremony
+func setWebAuthnChallenge(sessionService session.ServiceInterface, challenge string) error {
+	return sessionService.SetWebAuthnChallenge(&session.WebAuthnChallenge{
+		Challenge: challenge,
+		ExpiresAt: time.Now().Add(webauthn.Timeout * time.Millisecond),
+	})
+}
+
+// getWebAuthnChallenge returns the challenge of the passkey ceremony in
+// progress, a challenge can only be used once
+func getWebAuthnChallenge(sessionService session.ServiceInterface) (string, error) {
+	webAuthnChallenge, err := sessionService.GetWebAuthnChallenge()
+	if err != nil {
+		return "", err
+	}
+
+	if err := sessionService.ClearWebAuthnChallenge(); err != nil {
+		return "", err
+	}
+
+	return webAuthnChallenge.Challenge, nil
+}
+
+// webAuthnCredentialsData lists passkeys for the account settings template
+func webAuthnCredentialsData(credentials []*oauth.WebAuthnCredential) []map[string]interface{} {
+	data := make([]map[string]interface{}, len(credentials))
+
+	for i, credential := range credentials {
+		lastUsed := "Never"
+		if !credential.LastUsedAt.IsZero() {
+			lastUsed = credential.LastUsedAt.Format("2 Jan 2006 15:04")
+		}
+		data[i] = map[string]interface{}{
+			"id":       credential.ID,
+			"name":     credential.Name,
+			"created":  credential.CreatedAt.Format("2 Jan 2006"),
+			"lastUsed": lastUsed,
+		}
+	}
+
+	return data
+}